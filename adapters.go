@@ -1,48 +1,381 @@
 package mpesa
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
-	"math"
+	"time"
 )
 
+// gatewayDateLayout is the yyyymmdd layout used by the gateway for dates.
+const gatewayDateLayout = "20060102"
+
 type (
 	requestAdapter struct {
 		platform            Platform
 		market              Market
 		serviceProviderCode string
+		rawMSISDN           bool
+		location            *time.Location
 	}
 )
 
-func (a *requestAdapter) adapt(requestType requestType, request Request) (interface{}, error) {
-	amount := math.Floor(request.Amount * 100 / 100)
-	if requestType == pushPay {
-		response := pushPayRequest{
-			Amount:                   fmt.Sprintf("%0.2f", amount),
-			Country:                  a.market.Country(),
-			Currency:                 a.market.Currency(),
-			CustomerMSISDN:           request.MSISDN,
-			ServiceProviderCode:      a.serviceProviderCode,
-			ThirdPartyConversationID: request.ThirdPartyID,
-			TransactionReference:     request.Reference,
-			PurchasedItemsDesc:       request.Description,
-		}
-		return response, nil
-	}
-
-	if requestType == disburse {
-		response := disburseRequest{
-			Amount:                   fmt.Sprintf("%0.2f", amount),
-			Country:                  a.market.Country(),
-			Currency:                 a.market.Currency(),
-			CustomerMSISDN:           request.MSISDN,
-			ServiceProviderCode:      a.serviceProviderCode,
-			ThirdPartyConversationID: request.ThirdPartyID,
-			TransactionReference:     request.Reference,
-			PaymentItemsDesc:         request.Description,
+func (a *requestAdapter) adaptPush(request PushRequest) (pushPayRequest, error) {
+	request.MSISDN = a.msisdn(request.MSISDN)
+	if err := request.validate(a.market, !a.rawMSISDN); err != nil {
+		return pushPayRequest{}, err
+	}
+
+	response := pushPayRequest{
+		Amount:                   request.Amount.String(),
+		Country:                  a.market.Country(),
+		Currency:                 a.currency(request.Currency),
+		CustomerMSISDN:           request.MSISDN,
+		ServiceProviderCode:      a.providerCode(request.ServiceProviderCode),
+		ThirdPartyConversationID: request.ThirdPartyID,
+		TransactionReference:     request.Reference,
+		PurchasedItemsDesc:       request.Description,
+	}
+
+	return response, nil
+}
+
+func (a *requestAdapter) adaptDisburse(request DisburseRequest) (disburseRequest, error) {
+	request.MSISDN = a.msisdn(request.MSISDN)
+	if err := request.validate(a.market, !a.rawMSISDN); err != nil {
+		return disburseRequest{}, err
+	}
+
+	response := disburseRequest{
+		Amount:                   request.Amount.String(),
+		Country:                  a.market.Country(),
+		Currency:                 a.currency(request.Currency),
+		CustomerMSISDN:           request.MSISDN,
+		ServiceProviderCode:      a.providerCode(request.ServiceProviderCode),
+		ThirdPartyConversationID: request.ThirdPartyID,
+		TransactionReference:     request.Reference,
+		PaymentItemsDesc:         request.Description,
+	}
+
+	return response, nil
+}
+
+func (a *requestAdapter) adaptB2B(request Request) (B2BRequest, error) {
+	if err := request.Validate(B2BOperation, a.market); err != nil {
+		return B2BRequest{}, err
+	}
+
+	response := B2BRequest{
+		Amount:                   request.Amount.String(),
+		Country:                  a.market.Country(),
+		Currency:                 a.currency(request.Currency),
+		PrimaryPartyCode:         a.providerCode(request.ServiceProviderCode),
+		ReceiverPartyCode:        request.ReceiverPartyCode,
+		ThirdPartyConversationID: request.ThirdPartyID,
+		TransactionReference:     request.Reference,
+		PurchasedItemsDesc:       request.Description,
+	}
+
+	return response, nil
+}
+
+// msisdn returns msisdn normalized to the international format of the market,
+// unchanged when the normalization is turned off.
+func (a *requestAdapter) msisdn(msisdn string) string {
+	if a.rawMSISDN {
+		return msisdn
+	}
+
+	return normalizeMSISDN(msisdn, a.market)
+}
+
+// providerCode returns code, or the configured ServiceProvideCode when it is
+// empty.
+func (a *requestAdapter) providerCode(code string) string {
+	if code == "" {
+		return a.serviceProviderCode
+	}
+
+	return code
+}
+
+// currency returns currency, or the currency of the market when it is empty.
+func (a *requestAdapter) currency(currency string) string {
+	if currency == "" {
+		return a.market.Currency()
+	}
+
+	return currency
+}
+
+func (a *requestAdapter) adaptQueryTx(params QueryTxParams) (queryTxRequest, error) {
+	if err := params.Validate(); err != nil {
+		return queryTxRequest{}, err
+	}
+
+	id, err := queryConversationID(params.ConversationID)
+	if err != nil {
+		return queryTxRequest{}, err
+	}
+
+	country := params.CountryCode
+	if country == "" {
+		country = a.market.Country()
+	}
+
+	reference, qualifier := params.queryReference()
+
+	return queryTxRequest{
+		QueryReference:           reference,
+		QueryReferenceType:       qualifier,
+		ServiceProviderCode:      a.providerCode(params.ServiceProviderCode),
+		ThirdPartyConversationID: id,
+		Country:                  country,
+	}, nil
+}
+
+func (a *requestAdapter) adaptDirectDebitCreate(request DirectDebitCreateRequest) (directDebitCreateRequest, error) {
+	var v validation
+	if request.ThirdPartyID == "" {
+		v.add("ThirdPartyID", RuleRequired, "is required")
+	}
+	v.checkThirdPartyID(request.ThirdPartyID)
+	request.MSISDN = a.msisdn(request.MSISDN)
+	v.checkMSISDN(request.MSISDN, a.market)
+	if !a.rawMSISDN {
+		v.checkMobile(request.MSISDN, a.market)
+	}
+	if request.Reference == "" {
+		v.add("Reference", RuleRequired, "is required")
+	}
+	v.checkReference(request.Reference)
+	v.checkRangeOfDays("StartRangeOfDays", request.StartRangeOfDays)
+	v.checkRangeOfDays("EndRangeOfDays", request.EndRangeOfDays)
+	v.checkProviderCode(request.ServiceProviderCode)
+	if err := v.errFor(directDebitCreate.Name()); err != nil {
+		return directDebitCreateRequest{}, err
+	}
+
+	agreedTC := "0"
+	if request.AgreedTC {
+		agreedTC = "1"
+	}
+
+	response := directDebitCreateRequest{
+		AgreedTC:                 agreedTC,
+		Country:                  a.market.Country(),
+		CustomerMSISDN:           request.MSISDN,
+		EndRangeOfDays:           rangeOfDays(request.EndRangeOfDays),
+		ExpiryDate:               gatewayDate(request.ExpiryDate, a.location),
+		FirstPaymentDate:         gatewayDate(request.FirstPaymentDate, a.location),
+		Frequency:                request.Frequency.String(),
+		ServiceProviderCode:      a.providerCode(request.ServiceProviderCode),
+		StartRangeOfDays:         rangeOfDays(request.StartRangeOfDays),
+		ThirdPartyConversationID: request.ThirdPartyID,
+		ThirdPartyReference:      request.Reference,
+	}
+
+	return response, nil
+}
+
+func (a *requestAdapter) adaptDirectDebitPayment(request DirectDebitPaymentRequest) (directDebitPaymentRequest, error) {
+	var v validation
+	hasCustomer := request.MSISDN != "" || request.MsisdnToken != ""
+	if request.MandateID == "" && (!hasCustomer || request.Reference == "") {
+		v.add("MandateID", RuleRequired, "is required: either the mandate id or the customer msisdn (or msisdn token) and mandate reference must be supplied")
+	}
+	if request.ThirdPartyID == "" {
+		v.add("ThirdPartyID", RuleRequired, "is required")
+	}
+	v.checkThirdPartyID(request.ThirdPartyID)
+	if request.MSISDN != "" {
+		request.MSISDN = a.msisdn(request.MSISDN)
+		v.checkMSISDN(request.MSISDN, a.market)
+		if !a.rawMSISDN {
+			v.checkMobile(request.MSISDN, a.market)
 		}
+	}
+	v.checkAmount(request.Amount)
+	v.checkCurrency(request.Currency, a.market)
+	v.checkProviderCode(request.ServiceProviderCode)
+	if err := v.errFor(directDebitPay.Name()); err != nil {
+		return directDebitPaymentRequest{}, err
+	}
 
-		return response, nil
+	response := directDebitPaymentRequest{
+		MandateID:                request.MandateID,
+		MsisdnToken:              request.MsisdnToken,
+		Amount:                   request.Amount.String(),
+		Country:                  a.market.Country(),
+		Currency:                 a.currency(request.Currency),
+		CustomerMSISDN:           request.MSISDN,
+		ServiceProviderCode:      a.providerCode(request.ServiceProviderCode),
+		ThirdPartyConversationID: request.ThirdPartyID,
+		ThirdPartyReference:      request.Reference,
+	}
+
+	return response, nil
+}
+
+func (a *requestAdapter) adaptDirectDebitCancel(request DirectDebitCancelRequest) (directDebitCancelRequest, error) {
+	var v validation
+	hasCustomer := request.MSISDN != "" || request.MsisdnToken != ""
+	if request.AgreementID == "" && (!hasCustomer || request.Reference == "") {
+		v.add("AgreementID", RuleRequired, "is required: either the agreement id or the customer msisdn (or msisdn token) and mandate reference must be supplied")
+	}
+	v.checkProviderCode(request.ServiceProviderCode)
+	if err := v.errFor(directDebitCancel.Name()); err != nil {
+		return directDebitCancelRequest{}, err
+	}
+	id, err := queryConversationID(request.ThirdPartyID)
+	if err != nil {
+		return directDebitCancelRequest{}, err
+	}
+
+	response := directDebitCancelRequest{
+		AgreementID:              request.AgreementID,
+		MsisdnToken:              request.MsisdnToken,
+		CustomerMSISDN:           request.MSISDN,
+		Country:                  a.market.Country(),
+		ServiceProviderCode:      a.providerCode(request.ServiceProviderCode),
+		ThirdPartyReference:      request.Reference,
+		ThirdPartyConversationID: id,
+	}
+
+	return response, nil
+}
+
+func (a *requestAdapter) adaptDirectDebitQuery(params QueryDirectDebitParams) (queryDirectDebitRequest, error) {
+	var v validation
+	if params.AgreementID == "" && (params.MSISDN == "" || params.Reference == "") {
+		v.add("AgreementID", RuleRequired, "is required: either the agreement id or the customer msisdn and mandate reference must be supplied")
+	}
+	v.checkProviderCode(params.ServiceProviderCode)
+	if err := v.errFor(directDebitQuery.Name()); err != nil {
+		return queryDirectDebitRequest{}, err
+	}
+	id, err := queryConversationID(params.ThirdPartyID)
+	if err != nil {
+		return queryDirectDebitRequest{}, err
+	}
+
+	response := queryDirectDebitRequest{
+		AgreementID:              params.AgreementID,
+		CustomerMSISDN:           params.MSISDN,
+		Country:                  a.market.Country(),
+		ServiceProviderCode:      a.providerCode(params.ServiceProviderCode),
+		ThirdPartyReference:      params.Reference,
+		ThirdPartyConversationID: id,
+	}
+
+	return response, nil
+}
+
+func (a *requestAdapter) adaptBeneficiaryName(params QueryBeneficiaryParams) (beneficiaryNameRequest, error) {
+	var v validation
+	params.MSISDN = a.msisdn(params.MSISDN)
+	v.checkMSISDN(params.MSISDN, a.market)
+	if !a.rawMSISDN {
+		v.checkMobile(params.MSISDN, a.market)
+	}
+	v.checkProviderCode(params.ServiceProviderCode)
+	if err := v.errFor(beneficiaryName.Name()); err != nil {
+		return beneficiaryNameRequest{}, err
+	}
+
+	id, err := newConversationID()
+	if err != nil {
+		return beneficiaryNameRequest{}, err
+	}
+
+	response := beneficiaryNameRequest{
+		CustomerMSISDN:           params.MSISDN,
+		Country:                  a.market.Country(),
+		ServiceProviderCode:      a.providerCode(params.ServiceProviderCode),
+		KycQueryType:             "Name",
+		ThirdPartyConversationID: id,
+	}
+
+	return response, nil
+}
 
+// newConversationID returns a random UUID without the dashes, 32 hex
+// characters usable as a ThirdPartyConversationID.
+func newConversationID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("could not generate conversation id: %w", err)
 	}
-	return nil, fmt.Errorf("unknown request type: accespted types are pushpay and disburse")
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+
+	return hex.EncodeToString(b), nil
+}
+
+// queryConversationID returns id, or a generated one when it is empty. The
+// gateway requires a ThirdPartyConversationID on the queries and the
+// cancellations too, so one is generated for them whatever
+// WithAutoConversationID says, like for QueryBeneficiaryName.
+func queryConversationID(id string) (string, error) {
+	if id != "" {
+		return id, nil
+	}
+
+	return newConversationID()
+}
+
+// conversationID returns id, or a generated ThirdPartyID when id is empty
+// unless the generation was turned off with WithAutoConversationID.
+func (c *Client) conversationID(id string) (string, error) {
+	if id != "" || c.noAutoConversationID {
+		return id, nil
+	}
+
+	return newConversationID()
+}
+
+// gatewayDate formats the day of t in loc in the yyyymmdd layout, a zero t is
+// formatted as an empty string so that optional dates are left out of the
+// request. A nil loc keeps the location of t.
+func gatewayDate(t time.Time, loc *time.Location) string {
+	if t.IsZero() {
+		return ""
+	}
+	if loc != nil {
+		t = t.In(loc)
+	}
+
+	return t.Format(gatewayDateLayout)
+}
+
+// checkRangeOfDays checks that days, when set, is a day of the month.
+func (v *validation) checkRangeOfDays(field string, days int) {
+	if days < 0 || days > 31 {
+		v.add(field, RuleRange, "must be between 1 and 31, or 0 when not set, got %d", days)
+	}
+}
+
+// rangeOfDays formats a day of the month as two digits, zero is formatted as an
+// empty string so that optional ranges are left out of the request.
+func rangeOfDays(days int) string {
+	if days == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%02d", days)
+}
+
+// isNumeric reports whether s is a non-empty string of ASCII digits.
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
 }
@@ -0,0 +1,146 @@
+package mpesa
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// amountDecimals is the number of decimals of the amounts exchanged with the
+// gateway, every market uses two.
+const amountDecimals = 2
+
+// Amount is an exact amount of money in minor units, cents for a currency with
+// two decimals. The zero value is zero. It is encoded in JSON as the decimal
+// string used by the gateway, e.g. "1234.56", and decoded from either a string
+// or a number.
+//
+// Make an Amount with ParseAmount, MustParseAmount, FromMinorUnits or
+// AmountFromFloat.
+type Amount struct {
+	minor int64
+}
+
+// FromMinorUnits returns the Amount of minor minor units, FromMinorUnits(123456)
+// is 1234.56.
+func FromMinorUnits(minor int64) Amount {
+	return Amount{minor: minor}
+}
+
+// AmountFromFloat returns f rounded half away from zero to the nearest minor
+// unit, to migrate code computing amounts as float64.
+func AmountFromFloat(f float64) Amount {
+	return Amount{minor: int64(math.Round(f * math.Pow10(amountDecimals)))}
+}
+
+// ParseAmount parses a decimal amount such as "1234.56", "10" or "-0.5". It
+// rejects the amounts with more than two decimals, unless the extra decimals are
+// zeros, and returns a *FieldError for the Amount field.
+func ParseAmount(s string) (Amount, error) {
+	invalid := func(reason string) (Amount, error) {
+		return Amount{}, &FieldError{Field: "Amount", Rule: RuleFormat, Reason: fmt.Sprintf("%s, got %q", reason, s)}
+	}
+
+	digits := strings.TrimSpace(s)
+	negative := strings.HasPrefix(digits, "-")
+	digits = strings.TrimPrefix(strings.TrimPrefix(digits, "-"), "+")
+
+	whole, fraction := digits, ""
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		whole, fraction = digits[:i], digits[i+1:]
+	}
+	if (whole == "" && fraction == "") || (whole != "" && !isNumeric(whole)) || (fraction != "" && !isNumeric(fraction)) {
+		return invalid("must be a decimal number")
+	}
+	if len(fraction) > amountDecimals {
+		if strings.Trim(fraction[amountDecimals:], "0") != "" {
+			return invalid(fmt.Sprintf("must have at most %d decimals", amountDecimals))
+		}
+		fraction = fraction[:amountDecimals]
+	}
+	fraction += strings.Repeat("0", amountDecimals-len(fraction))
+
+	minor, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return invalid("is out of range")
+	}
+	if negative {
+		minor = -minor
+	}
+
+	return Amount{minor: minor}, nil
+}
+
+// MustParseAmount is like ParseAmount but panics when s is invalid. It
+// simplifies the initialization of amounts known to be valid.
+func MustParseAmount(s string) Amount {
+	amount, err := ParseAmount(s)
+	if err != nil {
+		panic(fmt.Sprintf("mpesa: %v", err))
+	}
+
+	return amount
+}
+
+// MinorUnits returns the amount in minor units.
+func (a Amount) MinorUnits() int64 {
+	return a.minor
+}
+
+// Float64 returns the amount as a float64, to display it or to compute with it
+// where exactness does not matter.
+func (a Amount) Float64() float64 {
+	return float64(a.minor) / math.Pow10(amountDecimals)
+}
+
+// IsZero reports whether the amount is zero.
+func (a Amount) IsZero() bool {
+	return a.minor == 0
+}
+
+// String formats the amount with two decimals, as sent to the gateway.
+func (a Amount) String() string {
+	sign, minor := "", a.minor
+	if minor < 0 {
+		sign, minor = "-", -minor
+	}
+	unit := int64(math.Pow10(amountDecimals))
+
+	return fmt.Sprintf("%s%d.%0*d", sign, minor/unit, amountDecimals, minor%unit)
+}
+
+// MarshalJSON encodes the amount as a decimal string.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+// UnmarshalJSON decodes a decimal string or number, an empty string or null is
+// decoded as zero.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	s := string(data)
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		if strings.TrimSpace(s) == "" {
+			*a = Amount{}
+			return nil
+		}
+	}
+
+	amount, err := ParseAmount(s)
+	if err != nil {
+		return err
+	}
+	*a = amount
+
+	return nil
+}
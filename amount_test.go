@@ -0,0 +1,95 @@
+package mpesa
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "1234.56", want: 123456},
+		{in: "10", want: 1000},
+		{in: "0.5", want: 50},
+		{in: ".5", want: 50},
+		{in: "7.", want: 700},
+		{in: " 10.00 ", want: 1000},
+		{in: "10.500", want: 1050},
+		{in: "-0.05", want: -5},
+		{in: "10.005", wantErr: true},
+		{in: "1,500", wantErr: true},
+		{in: "1e3", wantErr: true},
+		{in: ".", wantErr: true},
+		{in: "", wantErr: true},
+		{in: "99999999999999999999", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseAmount(tt.in)
+		if tt.wantErr {
+			var fieldErr *FieldError
+			if !errors.As(err, &fieldErr) || fieldErr.Field != "Amount" {
+				t.Errorf("ParseAmount(%q) error = %v, want an Amount *FieldError", tt.in, err)
+			}
+			continue
+		}
+		if err != nil || got.MinorUnits() != tt.want {
+			t.Errorf("ParseAmount(%q) = %d, %v, want %d", tt.in, got.MinorUnits(), err, tt.want)
+		}
+	}
+}
+
+func TestAmountString(t *testing.T) {
+	tests := []struct {
+		amount Amount
+		want   string
+	}{
+		{FromMinorUnits(123456), "1234.56"},
+		{FromMinorUnits(5), "0.05"},
+		{FromMinorUnits(-150), "-1.50"},
+		{Amount{}, "0.00"},
+		{AmountFromFloat(1234.56), "1234.56"},
+		{AmountFromFloat(0.1 + 0.2), "0.30"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.amount.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestAmountJSON(t *testing.T) {
+	buf, err := json.Marshal(DisburseCallbackRequest{Amount: MustParseAmount("1234.56")})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(buf, &fields); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if fields["input_Amount"] != "1234.56" {
+		t.Errorf("input_Amount = %v, want \"1234.56\"", fields["input_Amount"])
+	}
+
+	for in, want := range map[string]Amount{
+		`{"input_Amount": "10.50"}`: FromMinorUnits(1050),
+		`{"input_Amount": 10.5}`:    FromMinorUnits(1050),
+		`{"input_Amount": ""}`:      {},
+		`{"input_Amount": null}`:    {},
+	} {
+		var request DisburseCallbackRequest
+		if err := json.Unmarshal([]byte(in), &request); err != nil || request.Amount != want {
+			t.Errorf("Unmarshal(%s) = %s, %v, want %s", in, request.Amount, err, want)
+		}
+	}
+
+	var request DisburseCallbackRequest
+	if err := json.Unmarshal([]byte(`{"input_Amount": "ten"}`), &request); err == nil {
+		t.Errorf("Unmarshal() error = nil, want the invalid amount rejected")
+	}
+}
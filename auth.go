@@ -6,32 +6,212 @@ import (
 	"time"
 )
 
-func (c *Client) getEncryptionKey() (string, error) {
+// MaxSessionLifetimeMinutes is the longest session lifetime the portal lets an
+// application configure. A longer Config.SessionLifetime would make the client
+// reuse a session the gateway has already expired, so NewClient clamps the
+// value to this bound.
+const MaxSessionLifetimeMinutes int64 = 24 * 60
+
+// DefaultSessionLifetimeMinutes is the session lifetime the portal gives a new
+// application. NewClient and the config loaders use it when no lifetime is
+// set.
+const DefaultSessionLifetimeMinutes int64 = 60
+
+// DefaultSessionRefreshMargin is how long before the gateway expires a session
+// the client stops using it, unless WithSessionRefreshMargin sets another
+// margin. It keeps a request issued just before the expiration from racing the
+// gateway's clock.
+const DefaultSessionRefreshMargin = 30 * time.Second
+
+// WithSessionRefreshMargin sets how long before the end of its lifetime a
+// session is replaced, DefaultSessionRefreshMargin by default. margin must not
+// be negative and must be shorter than the session lifetime, otherwise
+// NewClient returns a *ConfigError.
+func WithSessionRefreshMargin(margin time.Duration) ClientOption {
+	return func(client *Client) {
+		client.sessionMargin = margin
+	}
+}
+
+// getEncryptionKey returns the encrypted API key and the version of the
+// credentials it was derived from.
+func (c *Client) getEncryptionKey(ctx context.Context) (string, uint64, error) {
+	c.sessionMu.RLock()
 	isAvailable := c.encryptedAPIKey != nil && *c.encryptedAPIKey != ""
 
 	// notExpired := client.sessionExpiration.Sub(time.Now()).Minutes() > 1
 	if isAvailable {
-		return *c.encryptedAPIKey, nil
+		key, version := *c.encryptedAPIKey, c.credentialsVersion
+		c.sessionMu.RUnlock()
+		return key, version, nil
+	}
+	c.sessionMu.RUnlock()
+
+	apiKey, publicKey, version, err := c.credentials(ctx)
+	if err != nil {
+		return "", version, err
+	}
+	key, err := encryptKey(apiKey, publicKey)
+
+	return key, version, err
+}
+
+// EnsureSession fetches a session unless the client holds one that is not about
+// to expire, e.g. to warm up the client or to check the credentials when a
+// service starts. The requests authenticate the same way before they are sent.
+func (c *Client) EnsureSession(ctx context.Context) error {
+	_, err := c.checkSessionID(ctx)
+
+	return err
+}
+
+// Ping checks the credentials end to end, e.g. from a startup probe: it
+// encrypts the API key, asks the gateway for a session and checks that a
+// session id comes back. A rejection is returned as an *APIError and a failed
+// check leaves the client as it was, the session is only kept when the check
+// succeeds. Unlike EnsureSession it always sends a request, once, without
+// retries, and it gives up when ctx is done. It behaves the same on every
+// platform; in dry-run mode it returns the *DryRunError of the session
+// request.
+func (c *Client) Ping(ctx context.Context) error {
+	ctx, cancel := c.withTimeout(ctx, sessionID)
+	defer cancel()
+
+	response, version, err := c.requestSession(ctx)
+	if err != nil {
+		return err
+	}
+	c.cacheSession(ctx, response.ID, version)
+
+	return nil
+}
+
+// HasValidSession reports whether the client holds a session that is not about
+// to expire, in which case the next request does not fetch one.
+func (c *Client) HasValidSession() bool {
+	_, ok := c.validSession()
+
+	return ok
+}
+
+// SessionExpiresAt returns when the client stops using the session it holds,
+// the session refresh margin before the gateway expires it. It returns the zero
+// time when the client holds no session.
+func (c *Client) SessionExpiresAt() time.Time {
+	c.sessionMu.RLock()
+	defer c.sessionMu.RUnlock()
+
+	if c.sessionID == nil || *c.sessionID == "" {
+		return time.Time{}
 	}
 
-	return encryptKey(c.Conf.APIKey, c.Conf.PublicKey)
+	return c.sessionExpiration
+}
+
+// SessionLifetime returns the lifetime the client gives the sessions it
+// fetches: the configured one, DefaultSessionLifetimeMinutes when none is set,
+// at most MaxSessionLifetimeMinutes. The client stops using a session the
+// session refresh margin before the end of its lifetime, see SessionExpiresAt.
+func (c *Client) SessionLifetime() time.Duration {
+	return c.sessionLifetime
 }
 
 // checkSessionID examine if there is a session id saved as Client.sessionID
-// if it is available it checks if it has already expired or have more than
-// 1 minute till expiration date and returns it
+// if it is available it checks if it has already expired, the session refresh
+// margin before the end of its lifetime, and returns it
 // if the above conditions are not fulfilled it calls Client.SessionID
 // then save it and increment the expiration date
-func (c *Client) checkSessionID() (string, error) {
-	sessAvailable := c.sessionID != nil && *c.sessionID != ""
-	sessExpiresAt := c.sessionExpiration
-	sessExpired := !sessExpiresAt.IsZero() && time.Until(sessExpiresAt) < (60*time.Second)
+//
+// Only one goroutine fetches a new session at a time, see sharedSession.
+func (c *Client) checkSessionID(ctx context.Context) (string, error) {
+	if id, ok := c.validSession(); ok {
+		return id, nil
+	}
 
-	if sessAvailable && !sessExpired {
-		return *c.sessionID, nil
+	return c.sharedSession(ctx, false)
+}
+
+// renewSession fetches a new session id even if the current one is still valid.
+func (c *Client) renewSession(ctx context.Context) (string, error) {
+	return c.sharedSession(ctx, true)
+}
+
+// sessionFlight is a session fetch shared by the goroutines needing a new
+// session. done is closed once id and err are set.
+type sessionFlight struct {
+	done chan struct{}
+	id   string
+	err  error
+
+	// abandoned is set when the fetch failed because the context of the
+	// goroutine leading it was done, the waiters then lead a new fetch.
+	abandoned bool
+}
+
+// sharedSession returns a new session, or the valid one when renew is false.
+// The first goroutine to need a session leads the fetch with its own ctx, the
+// others wait for its result, or for their own ctx to be done, and share its
+// error: bad credentials fail every waiter at once rather than sending one
+// request each to the gateway.
+func (c *Client) sharedSession(ctx context.Context, renew bool) (string, error) {
+	if c.dryRun {
+		return dryRunSessionID, nil
 	}
 
-	resp, err := c.SessionID(context.Background())
+	for {
+		c.refreshMu.Lock()
+		flight := c.sessionFlight
+		if flight == nil {
+			// the session may have been renewed while waiting for the lock
+			if id, ok := c.validSession(); ok && !renew {
+				c.refreshMu.Unlock()
+				return id, nil
+			}
+
+			flight = &sessionFlight{done: make(chan struct{})}
+			c.sessionFlight = flight
+			c.refreshMu.Unlock()
+
+			return c.leadSessionFetch(ctx, flight, renew)
+		}
+		c.refreshMu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-flight.done:
+		}
+		if !flight.abandoned {
+			return flight.id, flight.err
+		}
+	}
+}
+
+// leadSessionFetch runs flight and hands its result to the waiters. Unless
+// renew is true, the session of the SessionStore is used when there is one.
+func (c *Client) leadSessionFetch(ctx context.Context, flight *sessionFlight, renew bool) (string, error) {
+	defer func() {
+		c.refreshMu.Lock()
+		c.sessionFlight = nil
+		c.refreshMu.Unlock()
+		close(flight.done)
+	}()
+
+	if !renew {
+		if id, ok := c.loadSession(ctx); ok {
+			flight.id = id
+			return id, nil
+		}
+	}
+
+	flight.id, flight.err = c.fetchSession(ctx)
+	flight.abandoned = flight.err != nil && ctx.Err() != nil
+
+	return flight.id, flight.err
+}
+
+func (c *Client) fetchSession(ctx context.Context) (string, error) {
+	resp, err := c.SessionID(ctx)
 	if err != nil {
 		return "", fmt.Errorf("could not fetch session id: %w", err)
 	}
@@ -41,5 +221,46 @@ func (c *Client) checkSessionID() (string, error) {
 	}
 
 	return resp.ID, err
+}
+
+// validSession returns the cached session id if there is one that is not about to expire.
+func (c *Client) validSession() (string, bool) {
+	c.sessionMu.RLock()
+	defer c.sessionMu.RUnlock()
+
+	sessAvailable := c.sessionID != nil && *c.sessionID != ""
+	sessExpiresAt := c.sessionExpiration
+	sessExpired := !sessExpiresAt.IsZero() && !c.clock.Now().Before(sessExpiresAt)
+
+	if sessAvailable && !sessExpired {
+		return *c.sessionID, true
+	}
+
+	return "", false
+}
+
+// resetSession drops the cached session id so that the next call to
+// checkSessionID fetches a new one from the gateway. Nothing is dropped if
+// the cached session is no longer rejected, another goroutine may already
+// have replaced it.
+func (c *Client) resetSession(rejected string) {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+
+	if c.sessionID != nil && *c.sessionID != rejected {
+		return
+	}
+
+	c.sessionID = new(string)
+	c.sessionExpiration = time.Time{}
+}
+
+// clampSessionLifetime returns the lifetime to apply to fetched session ids,
+// the values above MaxSessionLifetimeMinutes are clamped.
+func clampSessionLifetime(lifetime time.Duration) time.Duration {
+	if max := time.Duration(MaxSessionLifetimeMinutes) * time.Minute; lifetime > max {
+		return max
+	}
 
+	return lifetime
 }
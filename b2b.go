@@ -2,6 +2,15 @@ package mpesa
 
 import "context"
 
+// B2BRequest
+// Amount	The transaction amount. This amount will be moved from the organization's account to the receiving party's account.	True	^\d*\.?\d+$	10.00
+// Country	The country of the mobile money platform where the transaction needs happen on.	True	N/A	GHA
+// Currency	The currency in which the transaction should take place.	True	^[a-zA-Z]{1,3}$	GHS
+// PrimaryPartyCode	The shortcode of the business where funds will be debited from.	True	^([0-9A-Za-z]{4,12})$	000000
+// ReceiverPartyCode	The shortcode of the business where funds will be credited to.	True	^([0-9A-Za-z]{4,12})$	000001
+// ThirdPartyConversationID	The third party's transaction reference on their system.	True	^[0-9a-zA-Z \w+]{1,40}$	1e9b774d1da34af78412a498cbc28f5e
+// TransactionReference	The transaction reference	True	^[0-9a-zA-Z \w+]{1,20}$	T12344C
+// PurchasedItemsDesc	Description of purchased items	True	^[0-9a-zA-Z \w+]{1,256}$	Shoes
 type B2BRequest struct {
 	Amount                   string `json:"input_Amount"`
 	Country                  string `json:"input_Country"`
@@ -13,14 +22,29 @@ type B2BRequest struct {
 	PurchasedItemsDesc       string `json:"input_PurchasedItemsDesc"`
 }
 
+// B2BResponse ...
+// ResponseCode	The result code for the transaction.	INS-0
+// ResponseDesc	The result description for the transaction.	Request processed successfully
+// TransactionID	The transaction identifier that gets generated on the Mobile Money platform.	hv9ahxcg4ccv
+// ConversationID	The OpenAPI platform generates this as a reference to the transaction.	fd1e9143d22544459f7c66e1860ef276
+// ThirdPartyConversationID	The incoming reference from the third party system.	1e9b774d1da34af78412a498cbc28f5e
 type B2BResponse struct {
-	ConversationID           string `json:"output_ConversationID"`
-	ResponseCode             string `json:"output_ResponseCode"`
-	ResponseDesc             string `json:"output_ResponseDesc"`
-	TransactionID            string `json:"output_TransactionID"`
-	ThirdPartyConversationID string `json:"output_ThirdPartyConversationID"`
+	ConversationID           string    `json:"output_ConversationID"`
+	ResponseCode             string    `json:"output_ResponseCode"`
+	ResponseDesc             string    `json:"output_ResponseDesc"`
+	TransactionID            string    `json:"output_TransactionID"`
+	ThirdPartyConversationID string    `json:"output_ThirdPartyConversationID"`
+	OutputErr                string    `json:"output_error,omitempty"`
+	HTTP                     *HTTPInfo `json:"-"`
 }
 
+// Code returns ResponseCode as a ResponseCode.
+func (r B2BResponse) Code() ResponseCode { return ResponseCode(r.ResponseCode) }
+
+func (r *B2BResponse) responseCode() ResponseCode { return r.Code() }
+
+func (r *B2BResponse) setHTTP(info *HTTPInfo) { r.HTTP = info }
+
 // b2b The B2B API Call is used for business-to-business transactions. Funds from
 // the business’ mobile money wallet will be deducted and transferred to the mobile
 // money wallet of the other business. Use cases for the B2C includes:
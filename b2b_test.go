@@ -0,0 +1,111 @@
+package mpesa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestB2BPayment(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["b2bPayment/"] = func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("decode payload: %v", err)
+		}
+
+		want := map[string]string{
+			"input_Amount":                   "1000.00",
+			"input_Country":                  "TZN",
+			"input_Currency":                 "TZS",
+			"input_PrimaryPartyCode":         "000000",
+			"input_ReceiverPartyCode":        "000001",
+			"input_ThirdPartyConversationID": "1e9b774d1da34af78412a498cbc28f5e",
+			"input_TransactionReference":     "T12344C",
+			"input_PurchasedItemsDesc":       "Stock",
+		}
+		if !reflect.DeepEqual(payload, want) {
+			t.Errorf("payload = %v, want %v", payload, want)
+		}
+
+		writeJSON(w, http.StatusCreated, B2BResponse{
+			ResponseCode:             "INS-0",
+			ResponseDesc:             "Request processed successfully",
+			TransactionID:            "hv9ahxcg4ccv",
+			ConversationID:           "fd1e9143d22544459f7c66e1860ef276",
+			ThirdPartyConversationID: "1e9b774d1da34af78412a498cbc28f5e",
+		})
+	}
+
+	c := g.client()
+	response, err := c.B2BPayment(context.Background(), Request{
+		ThirdPartyID:      "1e9b774d1da34af78412a498cbc28f5e",
+		Reference:         "T12344C",
+		Amount:            MustParseAmount("1000"),
+		Description:       "Stock",
+		ReceiverPartyCode: "000001",
+	})
+	if err != nil {
+		t.Fatalf("B2BPayment() error = %v", err)
+	}
+	want := B2BResponse{
+		ResponseCode:             "INS-0",
+		ResponseDesc:             "Request processed successfully",
+		TransactionID:            "hv9ahxcg4ccv",
+		ConversationID:           "fd1e9143d22544459f7c66e1860ef276",
+		ThirdPartyConversationID: "1e9b774d1da34af78412a498cbc28f5e",
+	}
+	if response.HTTP == nil || response.HTTP.StatusCode != http.StatusCreated {
+		t.Errorf("B2BPayment() HTTP = %+v, want a 201", response.HTTP)
+	}
+	response.HTTP = nil
+	if response != want {
+		t.Errorf("B2BPayment() = %+v, want %+v", response, want)
+	}
+}
+
+func TestB2BPaymentErrors(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["b2bPayment/"] = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, B2BResponse{ResponseCode: "INS-2006", OutputErr: "Insufficient balance"})
+	}
+
+	response, err := g.client().B2BPayment(context.Background(), Request{
+		ThirdPartyID:      "tp-1",
+		Reference:         "T12344C",
+		Amount:            MustParseAmount("1000"),
+		ReceiverPartyCode: "000001",
+	})
+	if err == nil || !strings.Contains(err.Error(), "Insufficient balance") {
+		t.Errorf("B2BPayment() error = %v, want the output error", err)
+	}
+	if response.ResponseCode != "INS-2006" {
+		t.Errorf("B2BPayment() response code = %s, want INS-2006", response.ResponseCode)
+	}
+}
+
+func TestB2BPaymentInvalidRequest(t *testing.T) {
+	g := newTestGateway(t)
+	var sent int32
+	g.handlers["b2bPayment/"] = func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&sent, 1)
+	}
+
+	_, err := g.client().B2BPayment(context.Background(), Request{
+		ThirdPartyID: "tp-1",
+		Reference:    "T12344C",
+		Amount:       MustParseAmount("1000"),
+	})
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Field != "ReceiverPartyCode" {
+		t.Errorf("B2BPayment() error = %v, want the receiver party code rejected", err)
+	}
+	if n := atomic.LoadInt32(&sent); n != 0 {
+		t.Errorf("b2b payments sent = %d, want 0", n)
+	}
+}
@@ -0,0 +1,325 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBatchConcurrency is the number of requests DisburseBatch and
+// QueryTxBatch send at a time when BatchOptions.Concurrency is not set.
+const DefaultBatchConcurrency = 4
+
+// ErrBatchTruncated is returned by QueryTxBatch when its context is done before
+// every item was queried. The error returned also wraps the error of the
+// context.
+var ErrBatchTruncated = errors.New("mpesa: batch truncated")
+
+type (
+	// BatchOptions configures DisburseBatch and QueryTxBatch. Concurrency is
+	// the number of requests in flight at a time, DefaultBatchConcurrency when
+	// zero or negative.
+	//
+	// Progress, when set, is called each time an item completes with the
+	// number of items done so far, the number of items of the batch and the
+	// item that completed. The calls are made one at a time, from the workers,
+	// so Progress must return quickly. QueryTxBatch only sets the Index and
+	// the Err of last.
+	//
+	// Deduplicate makes QueryTxBatch query the identical params of a batch
+	// once, every copy gets the result of that query.
+	BatchOptions struct {
+		Concurrency int
+		Progress    func(done, total int, last BatchItem)
+		Deduplicate bool
+	}
+
+	// BatchItem is the outcome of one disbursement of a batch. Index is the
+	// position of Request in the slice given to DisburseBatch and Request
+	// carries the ThirdPartyID it was sent with. Err is not nil when the
+	// disbursement failed, Response then holds what the gateway answered, if
+	// anything.
+	BatchItem struct {
+		Index    int
+		Request  DisburseRequest
+		Response DisburseResponse
+		Err      error
+	}
+
+	// QueryTxResult is the outcome of one query of a batch. Index is the
+	// position of Params in the slice given to QueryTxBatch.
+	QueryTxResult struct {
+		Index    int
+		Params   QueryTxParams
+		Response QueryTxResponse
+		Err      error
+	}
+
+	// BatchResult holds the items of a batch that were sent, in the order of
+	// their Index. Items is shorter than Total, the number of requests of the
+	// batch, when the context was done before every item was sent.
+	//
+	// Succeeded, Failed and Skipped count the items: Skipped are the ones
+	// rejected by the validation before being sent and Failed the other
+	// failures. FailedByCode counts the failures by the response code of
+	// their *APIError, the failures without a response code, e.g. a network
+	// error, are counted under the empty code. Duration is how long the batch
+	// took.
+	BatchResult struct {
+		Items        []BatchItem
+		Total        int
+		Succeeded    int
+		Failed       int
+		Skipped      int
+		FailedByCode map[ResponseCode]int
+		Duration     time.Duration
+	}
+)
+
+// DisburseBatch sends the disbursements of requests through a pool of
+// options.Concurrency workers. The workers share the session of the client and
+// go through its rate limiter and circuit breaker like any Disburse call, and
+// the failure of an item does not stop the others.
+//
+// Each request is given its own ThirdPartyID when it has none, the requests
+// that have one must not share it, otherwise nothing is sent and an error is
+// returned.
+//
+// Once ctx is done no new item is sent: DisburseBatch waits for the items in
+// flight and returns the items gathered so far along with the error of ctx.
+func (c *Client) DisburseBatch(ctx context.Context, requests []DisburseRequest, options BatchOptions) (BatchResult, error) {
+	requests, err := c.batchRequests(requests)
+	if err != nil {
+		return BatchResult{}, err
+	}
+
+	start := c.clock.Now()
+	items := make([]BatchItem, len(requests))
+	var (
+		progressMu sync.Mutex
+		done       int
+	)
+	sent := runBatch(ctx, len(requests), options.workers(len(requests)), func(i int) {
+		response, err := c.Disburse(ctx, requests[i])
+		items[i] = BatchItem{Index: i, Request: requests[i], Response: response, Err: err}
+
+		if options.Progress != nil {
+			progressMu.Lock()
+			done++
+			options.Progress(done, len(requests), items[i])
+			progressMu.Unlock()
+		}
+	})
+
+	result := BatchResult{Total: len(requests), FailedByCode: map[ResponseCode]int{}}
+	for i, item := range items {
+		if sent[i] {
+			result.add(item)
+		}
+	}
+	result.Duration = c.clock.Now().Sub(start)
+
+	return result, ctx.Err()
+}
+
+// QueryTxBatch queries the transactions of params through a pool of
+// options.Concurrency workers sharing the session, the rate limiter and the
+// circuit breaker of the client. The results are in the order of params, the
+// failure of a query is reported in the Err of its result and does not stop
+// the others.
+//
+// Once ctx is done no new query is sent: QueryTxBatch waits for the queries in
+// flight and returns their results, in order, with an error matching both
+// ErrBatchTruncated and the error of ctx.
+func (c *Client) QueryTxBatch(ctx context.Context, params []QueryTxParams, options BatchOptions) ([]QueryTxResult, error) {
+	// queries holds the indexes of params to send, copies holds the indexes
+	// of params answered by each query
+	queries := make([]int, 0, len(params))
+	copies := make(map[int][]int, len(params))
+	seen := make(map[QueryTxParams]int, len(params))
+	for i, p := range params {
+		if first, ok := seen[p]; ok && options.Deduplicate {
+			copies[first] = append(copies[first], i)
+			continue
+		}
+		seen[p] = i
+		queries = append(queries, i)
+		copies[i] = []int{i}
+	}
+
+	results := make([]QueryTxResult, len(params))
+	completed := make([]bool, len(params))
+	var (
+		progressMu sync.Mutex
+		done       int
+	)
+	runBatch(ctx, len(queries), options.workers(len(queries)), func(q int) {
+		response, err := c.QueryTx(ctx, params[queries[q]])
+
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		for _, i := range copies[queries[q]] {
+			results[i] = QueryTxResult{Index: i, Params: params[i], Response: response, Err: err}
+			completed[i] = true
+			if options.Progress != nil {
+				done++
+				options.Progress(done, len(params), BatchItem{Index: i, Err: err})
+			}
+		}
+	})
+
+	partial := make([]QueryTxResult, 0, len(params))
+	for i, result := range results {
+		if completed[i] {
+			partial = append(partial, result)
+		}
+	}
+	if len(partial) < len(params) {
+		return partial, &truncatedError{err: ctx.Err()}
+	}
+
+	return results, nil
+}
+
+// truncatedError is the error of a batch stopped by its context.
+type truncatedError struct {
+	err error
+}
+
+func (e *truncatedError) Error() string { return ErrBatchTruncated.Error() + ": " + e.err.Error() }
+
+func (e *truncatedError) Is(target error) bool { return target == ErrBatchTruncated }
+
+func (e *truncatedError) Unwrap() error { return e.err }
+
+// runBatch calls work with the indexes 0 to n-1 from a pool of workers
+// goroutines, until every index was handed out or ctx is done. It waits for the
+// calls in flight and reports which indexes were handed out.
+func runBatch(ctx context.Context, n, workers int, work func(i int)) []bool {
+	sent := make([]bool, n)
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				work(i)
+			}
+		}()
+	}
+
+dispatch:
+	for i := 0; i < n; i++ {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case jobs <- i:
+			sent[i] = true
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return sent
+}
+
+// workers returns the number of workers of a batch of n items.
+func (o BatchOptions) workers(n int) int {
+	workers := o.Concurrency
+	if workers <= 0 {
+		workers = DefaultBatchConcurrency
+	}
+	if workers > n {
+		workers = n
+	}
+
+	return workers
+}
+
+// add appends item to the items of r and counts it.
+func (r *BatchResult) add(item BatchItem) {
+	r.Items = append(r.Items, item)
+
+	var (
+		apiErr        *APIError
+		validationErr *ValidationError
+	)
+	switch {
+	case item.Err == nil:
+		r.Succeeded++
+	case errors.As(item.Err, &validationErr):
+		r.Skipped++
+	case errors.As(item.Err, &apiErr):
+		r.Failed++
+		r.FailedByCode[ResponseCode(apiErr.Code)]++
+	default:
+		r.Failed++
+		r.FailedByCode[""]++
+	}
+}
+
+// String summarizes r for the logs, e.g. "20 of 20 items in 3.2s: 15
+// succeeded, 4 failed (3 INS-10 Duplicate Transaction, 1 INS-13 Invalid
+// Shortcode Used), 1 skipped".
+func (r BatchResult) String() string {
+	summary := fmt.Sprintf("%d of %d items in %s: %d succeeded, %d failed", len(r.Items), r.Total,
+		r.Duration.Round(time.Millisecond), r.Succeeded, r.Failed)
+
+	if len(r.FailedByCode) > 0 {
+		codes := make([]ResponseCode, 0, len(r.FailedByCode))
+		for code := range r.FailedByCode {
+			codes = append(codes, code)
+		}
+		// the most frequent failures first
+		sort.Slice(codes, func(i, j int) bool {
+			ci, cj := r.FailedByCode[codes[i]], r.FailedByCode[codes[j]]
+			if ci != cj {
+				return ci > cj
+			}
+			return codes[i] < codes[j]
+		})
+
+		failures := make([]string, len(codes))
+		for i, code := range codes {
+			if code == "" {
+				failures[i] = fmt.Sprintf("%d without a response code", r.FailedByCode[code])
+				continue
+			}
+			failures[i] = fmt.Sprintf("%d %s %s", r.FailedByCode[code], code, code.Description())
+		}
+		summary += " (" + strings.Join(failures, ", ") + ")"
+	}
+
+	return fmt.Sprintf("%s, %d skipped", summary, r.Skipped)
+}
+
+// batchRequests returns a copy of requests where every request has a
+// ThirdPartyID, rejecting the ThirdPartyIDs set more than once.
+func (c *Client) batchRequests(requests []DisburseRequest) ([]DisburseRequest, error) {
+	batch := make([]DisburseRequest, len(requests))
+	seen := make(map[string]int, len(requests))
+	for i, request := range requests {
+		if first, ok := seen[request.ThirdPartyID]; ok && request.ThirdPartyID != "" {
+			return nil, fmt.Errorf("mpesa: batch items %d and %d share the ThirdPartyID %q", first, i, request.ThirdPartyID)
+		}
+		seen[request.ThirdPartyID] = i
+
+		id, err := c.conversationID(request.ThirdPartyID)
+		if err != nil {
+			return nil, err
+		}
+		request.ThirdPartyID = id
+		batch[i] = request
+	}
+
+	return batch, nil
+}
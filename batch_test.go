@@ -0,0 +1,263 @@
+package mpesa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDisburseBatch(t *testing.T) {
+	g := newTestGateway(t)
+	var inFlight, maxInFlight int32
+	g.handlers["b2cPayment/"] = func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["input_TransactionReference"] == "FAIL" {
+			writeJSON(w, http.StatusBadRequest, DisburseResponse{ResponseCode: "INS-13", ResponseDesc: "Invalid Shortcode Used"})
+			return
+		}
+		writeJSON(w, http.StatusCreated, DisburseResponse{
+			ResponseCode:             "INS-0",
+			ConversationID:           "conversation-" + body["input_ThirdPartyConversationID"],
+			ThirdPartyConversationID: body["input_ThirdPartyConversationID"],
+		})
+	}
+	c := g.client()
+
+	requests := make([]DisburseRequest, 20)
+	for i := range requests {
+		requests[i] = testRequest("").DisburseRequest()
+	}
+	requests[3].Amount = Amount{}
+	requests[7].Reference = "FAIL"
+	requests[11].ThirdPartyID = "payroll-11"
+
+	var progress []int
+	options := BatchOptions{
+		Concurrency: 3,
+		Progress: func(done, total int, last BatchItem) {
+			if total != len(requests) {
+				t.Errorf("Progress() total = %d, want %d", total, len(requests))
+			}
+			progress = append(progress, done)
+		},
+	}
+	result, err := c.DisburseBatch(context.Background(), requests, options)
+	if err != nil {
+		t.Fatalf("DisburseBatch() error = %v", err)
+	}
+	for i, done := range progress {
+		if done != i+1 {
+			t.Fatalf("Progress() done = %v, want 1 to %d", progress, len(requests))
+		}
+	}
+	if len(progress) != len(requests) {
+		t.Errorf("Progress() called %d times, want %d", len(progress), len(requests))
+	}
+
+	if result.Total != 20 || result.Succeeded != 18 || result.Failed != 1 || result.Skipped != 1 ||
+		result.FailedByCode["INS-13"] != 1 || result.Duration <= 0 {
+		t.Errorf("result = %+v, want 18 succeeded, 1 INS-13 failure and 1 skipped", result)
+	}
+	if got, want := result.String(), "1 failed (1 INS-13 Invalid Shortcode Used), 1 skipped"; !strings.Contains(got, want) {
+		t.Errorf("String() = %q, want it to contain %q", got, want)
+	}
+	if len(result.Items) != len(requests) {
+		t.Fatalf("items = %d, want %d", len(result.Items), len(requests))
+	}
+	if max := atomic.LoadInt32(&maxInFlight); max > 3 {
+		t.Errorf("disbursements in flight = %d, want at most 3", max)
+	}
+	if n := atomic.LoadInt32(&g.sessions); n != 1 {
+		t.Errorf("sessions = %d, want the session shared by the workers", n)
+	}
+
+	ids := map[string]bool{}
+	for i, item := range result.Items {
+		if item.Index != i {
+			t.Errorf("items[%d].Index = %d", i, item.Index)
+		}
+		if ids[item.Request.ThirdPartyID] || item.Request.ThirdPartyID == "" {
+			t.Errorf("items[%d].Request.ThirdPartyID = %q, want a unique id", i, item.Request.ThirdPartyID)
+		}
+		ids[item.Request.ThirdPartyID] = true
+
+		switch {
+		case i == 3:
+			var validationErr *ValidationError
+			if !errors.As(item.Err, &validationErr) {
+				t.Errorf("items[3].Err = %v, want a *ValidationError", item.Err)
+			}
+		case i == 7:
+			var apiErr *APIError
+			if !errors.As(item.Err, &apiErr) {
+				t.Errorf("items[7].Err = %v, want an *APIError", item.Err)
+			}
+		case item.Err != nil:
+			t.Errorf("items[%d].Err = %v", i, item.Err)
+		case item.Response.ThirdPartyConversationID != item.Request.ThirdPartyID:
+			t.Errorf("items[%d] sent %q, response echoes %q", i, item.Request.ThirdPartyID, item.Response.ThirdPartyConversationID)
+		}
+	}
+	if got := result.Items[11].Request.ThirdPartyID; got != "payroll-11" {
+		t.Errorf("items[11].Request.ThirdPartyID = %q, want the one given kept", got)
+	}
+	if requests[0].ThirdPartyID != "" {
+		t.Errorf("requests[0].ThirdPartyID = %q, want the slice of the caller left alone", requests[0].ThirdPartyID)
+	}
+}
+
+func TestDisburseBatchCanceled(t *testing.T) {
+	g := newTestGateway(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var sent int32
+	g.handlers["b2cPayment/"] = func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&sent, 1) == 4 {
+			cancel()
+		}
+		writeJSON(w, http.StatusCreated, DisburseResponse{ResponseCode: "INS-0"})
+	}
+	c := g.client()
+
+	requests := make([]DisburseRequest, 50)
+	for i := range requests {
+		requests[i] = testRequest(fmt.Sprintf("tp-%d", i)).DisburseRequest()
+	}
+
+	result, err := c.DisburseBatch(ctx, requests, BatchOptions{Concurrency: 2})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("DisburseBatch() error = %v, want context.Canceled", err)
+	}
+	if len(result.Items) == 0 || len(result.Items) >= len(requests) {
+		t.Fatalf("items = %d, want the partial results", len(result.Items))
+	}
+	for i, item := range result.Items {
+		if item.Index != i {
+			t.Errorf("items[%d].Index = %d, want the items sent before the cancellation", i, item.Index)
+		}
+	}
+}
+
+func TestDisburseBatchDuplicateIDs(t *testing.T) {
+	g := newTestGateway(t)
+	c := g.client()
+
+	requests := []DisburseRequest{
+		testRequest("tp-1").DisburseRequest(),
+		testRequest("tp-2").DisburseRequest(),
+		testRequest("tp-1").DisburseRequest(),
+	}
+	if _, err := c.DisburseBatch(context.Background(), requests, BatchOptions{}); err == nil {
+		t.Errorf("DisburseBatch() error = nil, want the shared ThirdPartyID rejected")
+	}
+	if n := atomic.LoadInt32(&g.sessions); n != 0 {
+		t.Errorf("sessions = %d, want nothing sent", n)
+	}
+}
+
+func TestQueryTxBatch(t *testing.T) {
+	g := newTestGateway(t)
+	var queries int32
+	g.handlers["queryTransactionStatus/"] = func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&queries, 1)
+		ref := r.URL.Query().Get("input_QueryReference")
+		if ref == "missing" {
+			writeJSON(w, http.StatusBadRequest, QueryTxResponse{ResponseCode: "INS-2051", ResponseDesc: "MSISDN invalid"})
+			return
+		}
+		writeJSON(w, http.StatusOK, QueryTxResponse{ResponseCode: "INS-0", ConversationID: "conversation-" + ref})
+	}
+	c := g.client()
+
+	params := []QueryTxParams{
+		{Reference: "a"}, {Reference: "b"}, {Reference: "a"}, {Reference: "missing"}, {Reference: ""}, {Reference: "b"},
+	}
+	for _, dedup := range []bool{false, true} {
+		atomic.StoreInt32(&queries, 0)
+		var progress int
+		results, err := c.QueryTxBatch(context.Background(), params, BatchOptions{
+			Concurrency: 2,
+			Deduplicate: dedup,
+			Progress:    func(done, total int, last BatchItem) { progress = done },
+		})
+		if err != nil {
+			t.Fatalf("QueryTxBatch(dedup %v) error = %v", dedup, err)
+		}
+		if len(results) != len(params) || progress != len(params) {
+			t.Fatalf("QueryTxBatch(dedup %v) = %d results, %d progress calls, want %d", dedup, len(results), progress, len(params))
+		}
+
+		for i, result := range results {
+			if result.Index != i || result.Params != params[i] {
+				t.Errorf("results[%d] = %+v, want the result of params[%d]", i, result, i)
+			}
+			switch ref := params[i].Reference; ref {
+			case "missing", "":
+				if result.Err == nil {
+					t.Errorf("results[%d].Err = nil, want the failure of %q", i, ref)
+				}
+			default:
+				if result.Err != nil || result.Response.ConversationID != "conversation-"+ref {
+					t.Errorf("results[%d] = %+v, want the transaction %q", i, result, ref)
+				}
+			}
+		}
+
+		// the empty reference is rejected before being sent
+		want := int32(5)
+		if dedup {
+			want = 3
+		}
+		if n := atomic.LoadInt32(&queries); n != want {
+			t.Errorf("QueryTxBatch(dedup %v) queries = %d, want %d", dedup, n, want)
+		}
+	}
+}
+
+func TestQueryTxBatchCanceled(t *testing.T) {
+	g := newTestGateway(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var queries int32
+	g.handlers["queryTransactionStatus/"] = func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&queries, 1) == 3 {
+			cancel()
+		}
+		writeJSON(w, http.StatusOK, QueryTxResponse{ResponseCode: "INS-0"})
+	}
+
+	params := make([]QueryTxParams, 50)
+	for i := range params {
+		params[i] = QueryTxParams{Reference: fmt.Sprintf("ref-%d", i)}
+	}
+
+	results, err := g.client().QueryTxBatch(ctx, params, BatchOptions{Concurrency: 1})
+	if !errors.Is(err, ErrBatchTruncated) || !errors.Is(err, context.Canceled) {
+		t.Errorf("QueryTxBatch() error = %v, want ErrBatchTruncated and context.Canceled", err)
+	}
+	if len(results) == 0 || len(results) >= len(params) {
+		t.Fatalf("results = %d, want the partial results", len(results))
+	}
+	for i, result := range results {
+		if result.Index != i {
+			t.Errorf("results[%d].Index = %d, want the results in order", i, result.Index)
+		}
+	}
+}
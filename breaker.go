@@ -0,0 +1,255 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/techcraftlabs/base"
+)
+
+const (
+	defaultBreakerFailures = 5
+	defaultBreakerWindow   = 20
+	defaultBreakerCoolDown = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned without calling the gateway while the circuit
+// breaker set up with WithCircuitBreaker is open.
+var ErrCircuitOpen = errors.New("mpesa: circuit breaker is open")
+
+// CircuitState is the state of the circuit breaker.
+type CircuitState int
+
+const (
+	// CircuitClosed lets every request through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails every request with ErrCircuitOpen.
+	CircuitOpen
+	// CircuitHalfOpen lets a single probe through, its outcome closes or
+	// opens the circuit again.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerSettings configures WithCircuitBreaker.
+//
+// The circuit opens after ConsecutiveFailures failed requests in a row, or when
+// at least FailureRatio of the last Window requests failed. A zero value turns
+// the matching rule off, when both are zero the circuit opens after 5 failures
+// in a row. Window defaults to 20 requests.
+//
+// Once open, requests fail with ErrCircuitOpen for CoolDown, 30 seconds by
+// default. The next request is then sent as a probe while the others keep
+// failing fast, the circuit closes if the probe succeeds and opens again if it
+// fails.
+//
+// OnStateChange, when set, is called on every transition. It must not block.
+type CircuitBreakerSettings struct {
+	ConsecutiveFailures int
+	FailureRatio        float64
+	Window              int
+	CoolDown            time.Duration
+	OnStateChange       func(from, to CircuitState)
+}
+
+// WithCircuitBreaker fails the requests fast with ErrCircuitOpen while the
+// gateway is failing, instead of letting every request wait for its timeout.
+//
+// Transport errors, timeouts, 5xx responses and the transient response codes
+// of ResponseCode.IsRetryable count as failures. Any other response, including
+// the rejection of an invalid request, counts as a success: the gateway is up.
+// Requests cancelled by the caller and throttled requests are not counted.
+func WithCircuitBreaker(settings CircuitBreakerSettings) ClientOption {
+	return func(client *Client) {
+		client.breaker = newCircuitBreaker(settings, func() time.Time { return client.clock.Now() })
+	}
+}
+
+type breakerOutcome int
+
+const (
+	breakerSuccess breakerOutcome = iota
+	breakerFailure
+	breakerIgnored
+)
+
+type circuitBreaker struct {
+	settings CircuitBreakerSettings
+	now      func() time.Time
+
+	mu          sync.Mutex
+	state       CircuitState
+	openedAt    time.Time
+	probing     bool
+	consecutive int
+	outcomes    []bool
+	next        int
+	recorded    int
+	failures    int
+}
+
+func newCircuitBreaker(settings CircuitBreakerSettings, now func() time.Time) *circuitBreaker {
+	if settings.ConsecutiveFailures <= 0 && settings.FailureRatio <= 0 {
+		settings.ConsecutiveFailures = defaultBreakerFailures
+	}
+	if settings.Window <= 0 {
+		settings.Window = defaultBreakerWindow
+	}
+	if settings.CoolDown <= 0 {
+		settings.CoolDown = defaultBreakerCoolDown
+	}
+
+	return &circuitBreaker{
+		settings: settings,
+		now:      now,
+		outcomes: make([]bool, settings.Window),
+	}
+}
+
+// allow reports whether a request may be sent. probe is true when the request
+// is the single probe of a half-open circuit.
+func (b *circuitBreaker) allow() (probe bool, err error) {
+	if b == nil {
+		return false, nil
+	}
+
+	b.mu.Lock()
+	var changed []CircuitState
+	defer func() {
+		b.mu.Unlock()
+		b.notify(changed)
+	}()
+
+	if b.state == CircuitOpen {
+		if b.now().Sub(b.openedAt) < b.settings.CoolDown {
+			return false, ErrCircuitOpen
+		}
+		changed = b.setState(CircuitHalfOpen)
+	}
+
+	if b.state == CircuitHalfOpen {
+		if b.probing {
+			return false, ErrCircuitOpen
+		}
+		b.probing = true
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// done records the outcome of a request let through by allow.
+func (b *circuitBreaker) done(probe bool, outcome breakerOutcome) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	var changed []CircuitState
+	defer func() {
+		b.mu.Unlock()
+		b.notify(changed)
+	}()
+
+	if probe {
+		b.probing = false
+		switch outcome {
+		case breakerSuccess:
+			changed = b.setState(CircuitClosed)
+		case breakerFailure:
+			changed = b.setState(CircuitOpen)
+		}
+
+		return
+	}
+
+	// requests sent before the circuit opened are not counted
+	if b.state != CircuitClosed || outcome == breakerIgnored {
+		return
+	}
+
+	failed := outcome == breakerFailure
+	if failed {
+		b.consecutive++
+	} else {
+		b.consecutive = 0
+	}
+
+	if b.recorded == len(b.outcomes) && b.outcomes[b.next] {
+		b.failures--
+	}
+	b.outcomes[b.next] = failed
+	b.next = (b.next + 1) % len(b.outcomes)
+	if b.recorded < len(b.outcomes) {
+		b.recorded++
+	}
+	if failed {
+		b.failures++
+	}
+
+	s := b.settings
+	tooMany := s.ConsecutiveFailures > 0 && b.consecutive >= s.ConsecutiveFailures
+	tooOften := s.FailureRatio > 0 && b.recorded == len(b.outcomes) &&
+		float64(b.failures)/float64(b.recorded) >= s.FailureRatio
+	if tooMany || tooOften {
+		changed = b.setState(CircuitOpen)
+	}
+}
+
+// setState moves the circuit to state and returns the transition to report.
+// Counters start over on every transition. It must be called with mu held.
+func (b *circuitBreaker) setState(state CircuitState) []CircuitState {
+	from := b.state
+	b.state = state
+	b.consecutive, b.next, b.recorded, b.failures = 0, 0, 0, 0
+	if state == CircuitOpen {
+		b.openedAt = b.now()
+	}
+
+	return []CircuitState{from, state}
+}
+
+func (b *circuitBreaker) notify(changed []CircuitState) {
+	if changed != nil && b.settings.OnStateChange != nil {
+		b.settings.OnStateChange(changed[0], changed[1])
+	}
+}
+
+// classify tells the circuit breaker whether the gateway failed to handle a
+// request.
+func classify(res *base.Response, err error, v interface{}) breakerOutcome {
+	if err != nil {
+		var apiErr *APIError
+		if errors.Is(err, context.Canceled) ||
+			(errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests) {
+			return breakerIgnored
+		}
+
+		return breakerFailure
+	}
+
+	if res.StatusCode >= http.StatusInternalServerError {
+		return breakerFailure
+	}
+	if coded, ok := v.(codedResponse); ok && coded.responseCode().IsRetryable() {
+		return breakerFailure
+	}
+
+	return breakerSuccess
+}
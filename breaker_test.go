@@ -0,0 +1,127 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Date(2021, 12, 31, 14, 30, 0, 0, time.UTC)
+	var changes []string
+	b := newCircuitBreaker(CircuitBreakerSettings{
+		ConsecutiveFailures: 2,
+		CoolDown:            time.Minute,
+		OnStateChange: func(from, to CircuitState) {
+			changes = append(changes, from.String()+"->"+to.String())
+		},
+	}, func() time.Time { return now })
+
+	record := func(outcome breakerOutcome) {
+		t.Helper()
+		probe, err := b.allow()
+		if err != nil {
+			t.Fatalf("allow() error = %v", err)
+		}
+		b.done(probe, outcome)
+	}
+
+	record(breakerFailure)
+	record(breakerSuccess)
+	record(breakerFailure)
+	record(breakerIgnored)
+	record(breakerFailure)
+	if _, err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow() error = %v after 2 failures in a row, want ErrCircuitOpen", err)
+	}
+
+	now = now.Add(time.Minute)
+	probe, err := b.allow()
+	if err != nil || !probe {
+		t.Fatalf("allow() = %v, %v after the cool-down, want a probe", probe, err)
+	}
+	if _, err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow() error = %v during the probe, want ErrCircuitOpen", err)
+	}
+	b.done(probe, breakerFailure)
+	if _, err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow() error = %v after a failed probe, want ErrCircuitOpen", err)
+	}
+
+	now = now.Add(time.Minute)
+	record(breakerSuccess)
+	record(breakerFailure)
+	if _, err := b.allow(); err != nil {
+		t.Fatalf("allow() error = %v after a successful probe", err)
+	}
+
+	want := []string{
+		"closed->open", "open->half-open", "half-open->open",
+		"open->half-open", "half-open->closed",
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("state changes = %v, want %v", changes, want)
+	}
+}
+
+func TestCircuitBreakerFailureRatio(t *testing.T) {
+	b := newCircuitBreaker(CircuitBreakerSettings{FailureRatio: 0.5, Window: 4}, time.Now)
+
+	for _, outcome := range []breakerOutcome{breakerFailure, breakerSuccess, breakerSuccess, breakerSuccess, breakerFailure} {
+		probe, err := b.allow()
+		if err != nil {
+			t.Fatalf("allow() error = %v before the ratio was reached", err)
+		}
+		b.done(probe, outcome)
+	}
+
+	probe, _ := b.allow()
+	b.done(probe, breakerFailure)
+	if _, err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow() error = %v with 2 failures in the last 4 requests, want ErrCircuitOpen", err)
+	}
+}
+
+func TestWithCircuitBreaker(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		code   ResponseCode
+		want   error
+		pushes int32
+	}{
+		{name: "gateway down", status: http.StatusServiceUnavailable, want: ErrCircuitOpen, pushes: 2},
+		{name: "transient code", status: http.StatusBadRequest, code: "INS-9", want: ErrCircuitOpen, pushes: 2},
+		{name: "invalid request", status: http.StatusBadRequest, code: "INS-15", pushes: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGateway(t)
+			var pushes int32
+			g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&pushes, 1)
+				writeJSON(w, tt.status, PushAsyncResponse{ResponseCode: string(tt.code)})
+			}
+			c := g.client(WithCircuitBreaker(CircuitBreakerSettings{ConsecutiveFailures: 2}))
+
+			var err error
+			for i := 0; i < 3; i++ {
+				_, err = c.PushAsync(context.Background(), testRequest("tp-1").PushRequest())
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("PushAsync() error = %v, want %v", err, tt.want)
+			}
+			if tt.want == nil && errors.Is(err, ErrCircuitOpen) {
+				t.Errorf("PushAsync() error = %v, want the gateway error", err)
+			}
+			if got := atomic.LoadInt32(&pushes); got != tt.pushes {
+				t.Errorf("pushes = %d, want %d", got, tt.pushes)
+			}
+		})
+	}
+}
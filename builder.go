@@ -0,0 +1,229 @@
+package mpesa
+
+// PushRequestBuilder builds a PushRequest, checking every field as it is set.
+// The errors are reported together by Build.
+//
+//	request, err := mpesa.NewPushRequest(mpesa.TanzaniaMarket).
+//		Amount("1500.00").
+//		MSISDN("0712 345 678").
+//		Reference("INV123").
+//		Description("order 123").
+//		Build()
+type PushRequestBuilder struct {
+	transfer transferBuilder
+}
+
+// NewPushRequest returns a builder of a PushRequest for a customer of market.
+func NewPushRequest(market Market) *PushRequestBuilder {
+	return &PushRequestBuilder{transfer: transferBuilder{market: market}}
+}
+
+// ThirdPartyID sets the ThirdPartyID, left empty it is generated by the
+// Client.
+func (b *PushRequestBuilder) ThirdPartyID(id string) *PushRequestBuilder {
+	b.transfer.setThirdPartyID(id)
+	return b
+}
+
+// Amount sets the amount from its decimal representation, e.g. "1500.00".
+func (b *PushRequestBuilder) Amount(amount string) *PushRequestBuilder {
+	b.transfer.setAmount(amount)
+	return b
+}
+
+// MSISDN sets the MSISDN of the customer, normalized as by NormalizeMSISDN.
+func (b *PushRequestBuilder) MSISDN(msisdn string) *PushRequestBuilder {
+	b.transfer.setMSISDN(msisdn)
+	return b
+}
+
+// Reference sets the transaction reference.
+func (b *PushRequestBuilder) Reference(reference string) *PushRequestBuilder {
+	b.transfer.setReference(reference)
+	return b
+}
+
+// Description sets the description of the purchased items.
+func (b *PushRequestBuilder) Description(description string) *PushRequestBuilder {
+	b.transfer.setDescription(description)
+	return b
+}
+
+// Currency sets the currency, one of the Market.Currencies of the market.
+func (b *PushRequestBuilder) Currency(currency string) *PushRequestBuilder {
+	b.transfer.setCurrency(currency)
+	return b
+}
+
+// ServiceProviderCode sets the short code overriding Config.ServiceProvideCode.
+func (b *PushRequestBuilder) ServiceProviderCode(code string) *PushRequestBuilder {
+	b.transfer.setProviderCode(code)
+	return b
+}
+
+// Build returns the PushRequest, or a *ValidationError listing the invalid
+// and the missing fields.
+func (b *PushRequestBuilder) Build() (PushRequest, error) {
+	t := b.transfer
+	request := PushRequest{
+		ThirdPartyID:        t.thirdPartyID,
+		Reference:           t.reference,
+		Amount:              t.amount,
+		MSISDN:              t.msisdn,
+		Description:         t.description,
+		Currency:            t.currency,
+		ServiceProviderCode: t.providerCode,
+	}
+
+	return request, t.err(PushOperation)
+}
+
+// DisburseRequestBuilder builds a DisburseRequest like PushRequestBuilder
+// builds a PushRequest.
+type DisburseRequestBuilder struct {
+	transfer transferBuilder
+}
+
+// NewDisburseRequest returns a builder of a DisburseRequest for a customer of
+// market.
+func NewDisburseRequest(market Market) *DisburseRequestBuilder {
+	return &DisburseRequestBuilder{transfer: transferBuilder{market: market}}
+}
+
+// ThirdPartyID sets the ThirdPartyID, left empty it is generated by the
+// Client.
+func (b *DisburseRequestBuilder) ThirdPartyID(id string) *DisburseRequestBuilder {
+	b.transfer.setThirdPartyID(id)
+	return b
+}
+
+// Amount sets the amount from its decimal representation, e.g. "1500.00".
+func (b *DisburseRequestBuilder) Amount(amount string) *DisburseRequestBuilder {
+	b.transfer.setAmount(amount)
+	return b
+}
+
+// MSISDN sets the MSISDN of the customer, normalized as by NormalizeMSISDN.
+func (b *DisburseRequestBuilder) MSISDN(msisdn string) *DisburseRequestBuilder {
+	b.transfer.setMSISDN(msisdn)
+	return b
+}
+
+// Reference sets the transaction reference.
+func (b *DisburseRequestBuilder) Reference(reference string) *DisburseRequestBuilder {
+	b.transfer.setReference(reference)
+	return b
+}
+
+// Description sets the description of the paid items.
+func (b *DisburseRequestBuilder) Description(description string) *DisburseRequestBuilder {
+	b.transfer.setDescription(description)
+	return b
+}
+
+// Currency sets the currency, one of the Market.Currencies of the market.
+func (b *DisburseRequestBuilder) Currency(currency string) *DisburseRequestBuilder {
+	b.transfer.setCurrency(currency)
+	return b
+}
+
+// ServiceProviderCode sets the short code overriding Config.ServiceProvideCode.
+func (b *DisburseRequestBuilder) ServiceProviderCode(code string) *DisburseRequestBuilder {
+	b.transfer.setProviderCode(code)
+	return b
+}
+
+// Build returns the DisburseRequest, or a *ValidationError listing the invalid
+// and the missing fields.
+func (b *DisburseRequestBuilder) Build() (DisburseRequest, error) {
+	t := b.transfer
+	request := DisburseRequest{
+		ThirdPartyID:        t.thirdPartyID,
+		Reference:           t.reference,
+		Amount:              t.amount,
+		MSISDN:              t.msisdn,
+		Description:         t.description,
+		Currency:            t.currency,
+		ServiceProviderCode: t.providerCode,
+	}
+
+	return request, t.err(DisburseOperation)
+}
+
+// transferBuilder holds the fields shared by the push and the disbursement
+// builders and the problems found while setting them.
+type transferBuilder struct {
+	market       Market
+	thirdPartyID string
+	reference    string
+	amount       Amount
+	msisdn       string
+	description  string
+	currency     string
+	providerCode string
+	v            validation
+}
+
+func (t *transferBuilder) setThirdPartyID(id string) {
+	t.thirdPartyID = id
+	if n := len([]rune(id)); n > MaxThirdPartyIDLength {
+		t.v.add("ThirdPartyID", RuleLength, "must be at most %d characters, got %d", MaxThirdPartyIDLength, n)
+	}
+}
+
+func (t *transferBuilder) setAmount(amount string) {
+	value, err := ParseAmount(amount)
+	if err != nil {
+		t.v.fields = append(t.v.fields, err.(*FieldError))
+		return
+	}
+
+	t.amount = value
+	t.v.checkAmount(value)
+}
+
+func (t *transferBuilder) setMSISDN(msisdn string) {
+	t.msisdn = normalizeMSISDN(msisdn, t.market)
+	t.v.checkMSISDN(t.msisdn, t.market)
+	if !t.v.has("MSISDN") {
+		t.v.checkMobile(t.msisdn, t.market)
+	}
+}
+
+func (t *transferBuilder) setReference(reference string) {
+	t.reference = reference
+	if err := ValidateReference(reference); err != nil {
+		t.v.fields = append(t.v.fields, err.(*FieldError))
+	}
+}
+
+func (t *transferBuilder) setDescription(description string) {
+	t.description = description
+	if n := len([]rune(description)); n > MaxDescriptionLength {
+		t.v.add("Description", RuleLength, "must be at most %d characters, got %d", MaxDescriptionLength, n)
+	}
+}
+
+func (t *transferBuilder) setCurrency(currency string) {
+	t.currency = currency
+	t.v.checkCurrency(currency, t.market)
+}
+
+func (t *transferBuilder) setProviderCode(code string) {
+	t.providerCode = code
+	t.v.checkProviderCode(code)
+}
+
+// err adds the missing required fields to the problems found by the setters
+// and returns them as a *ValidationError.
+func (t *transferBuilder) err(op Operation) error {
+	v := validation{fields: append([]*FieldError(nil), t.v.fields...)}
+	if t.amount.IsZero() && !v.has("Amount") {
+		v.add("Amount", RuleRequired, "is required")
+	}
+	if t.msisdn == "" && !v.has("MSISDN") {
+		v.add("MSISDN", RuleRequired, "is required")
+	}
+
+	return v.err(op)
+}
@@ -0,0 +1,93 @@
+package mpesa
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestPushRequestBuilder(t *testing.T) {
+	request, err := NewPushRequest(TanzaniaMarket).
+		Amount("1500.00").
+		MSISDN("0712 345-678").
+		Reference("INV123").
+		Description("order 123").
+		Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	want := PushRequest{Reference: "INV123", Amount: MustParseAmount("1500"), MSISDN: "255712345678", Description: "order 123"}
+	if !reflect.DeepEqual(request, want) {
+		t.Errorf("Build() = %+v, want %+v", request, want)
+	}
+}
+
+func TestDisburseRequestBuilder(t *testing.T) {
+	request, err := NewDisburseRequest(GhanaMarket).
+		ThirdPartyID("tp-1").
+		Amount("10.5").
+		Currency("GHS").
+		ServiceProviderCode("1001").
+		MSISDN("+233 24 123 4567").
+		Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	want := DisburseRequest{ThirdPartyID: "tp-1", Amount: MustParseAmount("10.5"), MSISDN: "233241234567", Currency: "GHS", ServiceProviderCode: "1001"}
+	if !reflect.DeepEqual(request, want) {
+		t.Errorf("Build() = %+v, want %+v", request, want)
+	}
+}
+
+func TestRequestBuilderErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func() error
+		want  []string
+	}{
+		{"missing fields", func() error {
+			_, err := NewPushRequest(TanzaniaMarket).Build()
+			return err
+		}, []string{"Amount", "MSISDN"}},
+		{"invalid fields", func() error {
+			_, err := NewPushRequest(TanzaniaMarket).Amount("1,500").MSISDN("0712").Reference("INV-123").Build()
+			return err
+		}, []string{"Amount", "MSISDN", "Reference"}},
+		{"too many decimals", func() error {
+			_, err := NewDisburseRequest(TanzaniaMarket).Amount("10.005").MSISDN("255712345678").Build()
+			return err
+		}, []string{"Amount"}},
+		{"negative amount", func() error {
+			_, err := NewDisburseRequest(TanzaniaMarket).Amount("-10").MSISDN("255712345678").Build()
+			return err
+		}, []string{"Amount"}},
+		{"currency of another market", func() error {
+			_, err := NewPushRequest(GhanaMarket).Amount("10").MSISDN("0241234567").Currency("TZS").Build()
+			return err
+		}, []string{"Currency"}},
+		{"msisdn of another market", func() error {
+			_, err := NewDisburseRequest(TanzaniaMarket).Amount("10").MSISDN("+254712345678").Build()
+			return err
+		}, []string{"MSISDN"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.build()
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Build() error = %v, want a *ValidationError", err)
+			}
+			var fields []string
+			for _, field := range validationErr.Fields {
+				fields = append(fields, field.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.want) {
+				t.Errorf("invalid fields = %v, want %v", fields, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,353 @@
+package mpesa
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/techcraftlabs/base"
+)
+
+// DefaultCallbackBodyLimit is the largest callback body CallbackServeHTTP reads
+// unless another limit is set with WithCallbackBodyLimit.
+const DefaultCallbackBodyLimit int64 = 1 << 20
+
+const (
+	// callbackTimeout bounds the time spent on a callback.
+	callbackTimeout = time.Minute
+
+	// response codes of the acknowledgements sent when a callback fails
+	callbackDecodeFailed  ResponseCode = "INS-21"
+	callbackHandlerFailed ResponseCode = "INS-1"
+)
+
+// CallbackVerifier authenticates a callback request before its body is read.
+// A non nil error rejects the callback with 401. The error is not sent back or
+// logged, so it may safely mention what was wrong.
+type CallbackVerifier func(r *http.Request) error
+
+var errCallbackUnauthorized = errors.New("callback credentials do not match")
+
+// CallbackBearerToken returns a CallbackVerifier that requires the
+// "Authorization: Bearer <token>" header configured on the result URL.
+func CallbackBearerToken(token string) CallbackVerifier {
+	return func(r *http.Request) error {
+		const prefix = "bearer "
+		header := r.Header.Get("Authorization")
+		if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+			return errCallbackUnauthorized
+		}
+
+		if !secretEqual(header[len(prefix):], token) {
+			return errCallbackUnauthorized
+		}
+
+		return nil
+	}
+}
+
+// CallbackBasicAuth returns a CallbackVerifier that requires HTTP basic
+// authentication with username and password.
+func CallbackBasicAuth(username, password string) CallbackVerifier {
+	return func(r *http.Request) error {
+		user, pass, ok := r.BasicAuth()
+		if !ok {
+			return errCallbackUnauthorized
+		}
+
+		// evaluate both so the time taken does not tell which one was wrong
+		userOK, passOK := secretEqual(user, username), secretEqual(pass, password)
+		if !userOK || !passOK {
+			return errCallbackUnauthorized
+		}
+
+		return nil
+	}
+}
+
+// secretEqual compares got and want in constant time.
+func secretEqual(got, want string) bool {
+	return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+// serveCallback runs the shared part of the callback endpoints. The callback is
+// decoded into body and handle is called to process it under the request
+// context limited to callbackTimeout. When that context is done, because the
+// gateway went away or the server is shutting down, nothing is written back.
+// That context also carries the CallbackMeta of the callback, its conversation
+// ids and its correlation id, see CallbackMetaFromContext,
+// ConversationIDsFromContext and WithCorrelationIDFunc.
+//
+// When Config.TrustedSources is set, callbacks from any other address are
+// rejected with 403 before the body is read. Likewise a callback that fails the
+// WithCallbackAuth verification is rejected with 401. Only POST is accepted
+// (405 otherwise, OPTIONS is answered with the allowed methods), the content
+// type must be JSON (415) and the body must fit in the limit set with
+// WithCallbackBodyLimit (413). A gzip encoded body is decompressed, the limit
+// applies to both the compressed and the decompressed body, and a malformed
+// one is rejected with 400.
+//
+// A body that can not be decoded is acknowledged with 400 and a handler error
+// with 500. Both acknowledgements carry a failure output_ResponseCode and echo
+// the conversation ids of the callback so the gateway can retry it. A panic,
+// in the handler or while processing the callback, is recovered and answered
+// like a handler error, see CallbackPanicError.
+func (c *Client) serveCallback(w http.ResponseWriter, r *http.Request, name string, body interface{},
+	handle func(ctx context.Context) (interface{}, error)) {
+	start := c.clock.Now()
+	sw := &statusWriter{ResponseWriter: w}
+	w = sw
+
+	var handleErr error
+	var span *tracedSpan
+	if c.tracer != nil {
+		ctx := c.tracer.Extract(r.Context(), r.Header)
+		ctx, span = c.startSpan(ctx, name, name)
+		r = r.WithContext(ctx)
+	}
+	defer func() {
+		if handleErr == nil {
+			handleErr = sw.err()
+		}
+		conversationID, thirdPartyID, code := callbackIDs(body)
+		span.finish(conversationID, thirdPartyID, code, handleErr)
+		duration := c.clock.Now().Sub(start)
+		c.metrics.ObserveCallback(callbackKind(body), duration, handleErr)
+
+		if c.records != nil {
+			level, attrs := levelInfo, []interface{}{
+				"op", callbackKind(body) + " callback", "conversation_id", conversationID,
+				"third_party_conversation_id", thirdPartyID, "response_code", string(code),
+				"duration_ms", duration.Milliseconds(),
+			}
+			if handleErr != nil {
+				level, attrs = levelWarn, append(attrs, "error", handleErr)
+			}
+			c.record(r.Context(), level, "callback received", attrs...)
+		}
+	}()
+
+	var raw []byte
+	defer func() {
+		var panicErr *CallbackPanicError
+		if errors.As(handleErr, &panicErr) && sw.status == 0 {
+			c.ack(w, http.StatusInternalServerError, failureAck(callbackHandlerFailed, raw))
+		}
+	}()
+	defer c.recoverCallback(callbackKind(body), &handleErr)
+
+	if !c.trustedSource(r) {
+		callbackError(w, http.StatusForbidden, "callback source is not trusted")
+		return
+	}
+
+	raw, ok := c.checkCallbackRequest(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), callbackTimeout)
+	defer cancel()
+	if c.base.DebugMode {
+		// dumps the callback to the logger
+		_, _ = c.rv.Receive(ctx, name, r, nil)
+	}
+	err := decodeCallback(bytes.NewReader(raw), body)
+	if ctx.Err() != nil {
+		return
+	}
+
+	if err != nil {
+		handleErr = err
+		c.ack(w, http.StatusBadRequest, failureAck(callbackDecodeFailed, raw))
+		return
+	}
+
+	resp, err := handle(c.callbackContext(ctx, r, raw, start, body))
+	handleErr = err
+	if ctx.Err() != nil {
+		return
+	}
+
+	var ackErr *callbackAckError
+	if errors.As(err, &ackErr) {
+		c.ack(w, ackErr.status, failureAck(ackErr.code, raw))
+		return
+	}
+
+	if err != nil {
+		c.ack(w, http.StatusInternalServerError, failureAck(callbackHandlerFailed, raw))
+		return
+	}
+
+	c.ack(w, http.StatusOK, resp)
+}
+
+// callbackIDs returns the conversation ids and the result code of a decoded
+// callback body.
+func callbackIDs(body interface{}) (conversationID, thirdPartyID string, code ResponseCode) {
+	switch body := body.(type) {
+	case *PushCallbackRequest:
+		return body.OriginalConversationID, body.ThirdPartyConversationID, ResponseCode(body.ResultCode)
+	case *DisburseCallbackRequest:
+		return body.OriginalConversationID, body.ThirdPartyConversationID, ResponseCode(body.ResultCode)
+	default:
+		return "", "", ""
+	}
+}
+
+// callbackAckError is returned by the handle func of serveCallback to answer
+// with status and a failure acknowledgement carrying code.
+type callbackAckError struct {
+	status int
+	code   ResponseCode
+}
+
+func (e *callbackAckError) Error() string {
+	return fmt.Sprintf("callback not accepted: %s", e.code.Description())
+}
+
+// parseTrustedSources turns the Config.TrustedSources entries, single IPv4 or
+// IPv6 addresses and CIDR ranges, into networks.
+func parseTrustedSources(sources []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(sources))
+	for _, source := range sources {
+		source = strings.TrimSpace(source)
+		if strings.Contains(source, "/") {
+			_, ipNet, err := net.ParseCIDR(source)
+			if err != nil {
+				return nil, fmt.Errorf("trusted source %q is not an IP address or CIDR range", source)
+			}
+			nets = append(nets, ipNet)
+
+			continue
+		}
+
+		ip := net.ParseIP(source)
+		if ip == nil {
+			return nil, fmt.Errorf("trusted source %q is not an IP address or CIDR range", source)
+		}
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+
+	return nets, nil
+}
+
+// trustedSource reports whether the remote address of r is in one of the
+// trusted networks. An empty list trusts every source. Only the connection
+// address is used, headers such as X-Forwarded-For are ignored.
+func (c *Client) trustedSource(r *http.Request) bool {
+	if len(c.trustedNets) == 0 {
+		return true
+	}
+
+	ip := remoteIP(r)
+	if ip == nil {
+		return false
+	}
+
+	for _, ipNet := range c.trustedNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// callbackError writes a JSON error body with status to w.
+func callbackError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+// checkCallbackRequest rejects callbacks that are not a JSON POST within the
+// body limit and writes the matching response. It returns false when the
+// request was answered. The body is read up front so that an oversized one
+// never reaches the decoder, and returned for use in error acknowledgements.
+func (c *Client) checkCallbackRequest(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	switch r.Method {
+	case http.MethodPost:
+	case http.MethodOptions:
+		w.Header().Set("Allow", "POST, OPTIONS")
+		w.WriteHeader(http.StatusNoContent)
+		return nil, false
+	default:
+		w.Header().Set("Allow", "POST, OPTIONS")
+		callbackError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s is not allowed", r.Method))
+		return nil, false
+	}
+
+	if c.callbackAuth != nil && c.callbackAuth(r) != nil {
+		callbackError(w, http.StatusUnauthorized, "callback authentication failed")
+		return nil, false
+	}
+
+	body, rerr := readCallbackBody(w, r, c.callbackBodyLimit)
+	if rerr != nil {
+		callbackError(w, rerr.StatusCode, rerr.Message)
+		return nil, false
+	}
+
+	return body, true
+}
+
+// ack replies to the gateway with the acknowledgement resp.
+func (c *Client) ack(w http.ResponseWriter, status int, resp interface{}) {
+	hs := base.WithMoreResponseHeaders(map[string]string{
+		"Content-Type": "application/json",
+	})
+	c.rp.Reply(w, base.NewResponse(status, resp, hs))
+}
+
+// failureAck builds the acknowledgement sent when a callback could not be
+// processed. The conversation ids are echoed from body when they can be read.
+// Push and disburse callbacks are acknowledged with the same fields.
+func failureAck(code ResponseCode, body []byte) PushCallbackResponse {
+	var request PushCallbackRequest
+	_ = json.Unmarshal(body, &request)
+
+	return callbackAck(request, code, "")
+}
+
+// CallbackRoute is a callback endpoint mounted by RegisterRoutes.
+type CallbackRoute struct {
+	Name string
+	Path string
+}
+
+// RegisterRoutes mounts every callback endpoint on mux under prefix and returns
+// the mounted routes, e.g. /callbacks/mpesa/push and /callbacks/mpesa/disburse
+// for the prefix /callbacks/mpesa. The endpoints whose handler is not set
+// answer 404. All of them share the checks described in serveCallback.
+func (c *Client) RegisterRoutes(mux *http.ServeMux, prefix string) []CallbackRoute {
+	endpoints := []struct {
+		name  string
+		serve http.HandlerFunc
+	}{
+		{"push", c.CallbackServeHTTP},
+		{"disburse", c.DisburseCallbackServeHTTP},
+	}
+
+	routes := make([]CallbackRoute, 0, len(endpoints))
+	for _, e := range endpoints {
+		p := path.Join("/", prefix, e.name)
+		mux.HandleFunc(p, e.serve)
+		routes = append(routes, CallbackRoute{Name: e.name, Path: p})
+	}
+
+	return routes
+}
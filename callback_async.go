@@ -0,0 +1,125 @@
+package mpesa
+
+import (
+	"context"
+	"sync"
+)
+
+// callbackQueueFull is the response code of the acknowledgement sent when the
+// asynchronous callback queue is full, the gateway retries the callback later.
+const callbackQueueFull ResponseCode = "INS-16"
+
+// CallbackErrorHook receives the errors returned by the PushCallbackHandler in
+// asynchronous mode, where no HTTP response is left to report them.
+type CallbackErrorHook func(request PushCallbackRequest, err error)
+
+// queuedCallback is a callback waiting for a worker with the context it was
+// received with, detached from the request.
+type queuedCallback struct {
+	ctx     context.Context
+	request PushCallbackRequest
+}
+
+// callbackPool runs the PushCallbackHandler in the background for callbacks
+// that were already acknowledged.
+type callbackPool struct {
+	mu      sync.RWMutex
+	closed  bool
+	queue   chan queuedCallback
+	done    chan struct{}
+	workers int
+	onError CallbackErrorHook
+}
+
+func newCallbackPool(workers, queueLen int, onError CallbackErrorHook) *callbackPool {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueLen < 0 {
+		queueLen = 0
+	}
+
+	return &callbackPool{
+		queue:   make(chan queuedCallback, queueLen),
+		done:    make(chan struct{}),
+		workers: workers,
+		onError: onError,
+	}
+}
+
+// start launches the workers, each one calling handle for the queued callbacks
+// until the pool is closed and the queue drained.
+func (p *callbackPool) start(handle func(ctx context.Context, request PushCallbackRequest) error) {
+	var wg sync.WaitGroup
+	wg.Add(p.workers)
+	for i := 0; i < p.workers; i++ {
+		go func() {
+			defer wg.Done()
+			for queued := range p.queue {
+				if err := handle(queued.ctx, queued.request); err != nil {
+					p.onError(queued.request, err)
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(p.done)
+	}()
+}
+
+// enqueue queues request, received with ctx, and reports whether there was
+// room for it.
+func (p *callbackPool) enqueue(ctx context.Context, request PushCallbackRequest) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return false
+	}
+
+	select {
+	case p.queue <- queuedCallback{ctx: detachedContext{ctx}, request: request}:
+		return true
+	default:
+		return false
+	}
+}
+
+// close stops accepting callbacks, the workers exit once the queue is drained.
+func (p *callbackPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+}
+
+// handleAsync runs the PushCallbackHandler for a callback taken off the queue.
+// The request that delivered it is gone, so the handler gets a context keeping
+// only the values of the request context, limited to callbackTimeout. A panic
+// of the handler is returned as a *CallbackPanicError to the
+// CallbackErrorHook, the worker keeps running.
+func (c *Client) handleAsync(ctx context.Context, request PushCallbackRequest) error {
+	ctx, cancel := context.WithTimeout(ctx, callbackTimeout)
+	defer cancel()
+
+	_, _, err := c.dedup(ctx, request, func() (ack PushCallbackResponse, err error) {
+		defer c.recoverCallback("push", &err)
+		if h, ok := c.pushCallbackFunc.(PushCallbackContextHandler); ok {
+			return h.HandleCallbackContext(ctx, request)
+		}
+
+		return c.pushCallbackFunc.HandleCallback(request)
+	})
+
+	return err
+}
+
+// successAck acknowledges request as received.
+func successAck(request PushCallbackRequest) PushCallbackResponse {
+	return callbackAck(request, SUCCESS_CODE, "")
+}
@@ -0,0 +1,103 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAsyncCallbacks(t *testing.T) {
+	g := newTestGateway(t)
+
+	block := make(chan struct{})
+	var handled int32
+	handler := PushCallbackFunc(func(request PushCallbackRequest) (PushCallbackResponse, error) {
+		<-block
+		atomic.AddInt32(&handled, 1)
+		if request.TransactionID == "fail" {
+			return PushCallbackResponse{}, errors.New("database is down")
+		}
+		return PushCallbackResponse{ResponseCode: SUCCESS_CODE}, nil
+	})
+
+	var mu sync.Mutex
+	var failed []string
+	onError := func(request PushCallbackRequest, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		failed = append(failed, request.TransactionID)
+	}
+	c := g.client(WithCallbackHandler(handler), WithAsyncCallbacks(1, 2, onError))
+
+	deliver := func(txID string) int {
+		body := `{"input_OriginalConversationID": "conv", "input_TransactionID": "` + txID + `"}`
+		rec := httptest.NewRecorder()
+		c.CallbackServeHTTP(rec, newCallbackRequest(context.Background(), body))
+		return rec.Code
+	}
+
+	// the first callback occupies the only worker, two more fill the queue
+	if code := deliver("fail"); code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 before the handler ran", code)
+	}
+	waitFor(t, func() bool { return len(c.callbackPool.queue) == 0 })
+	for _, id := range []string{"tx-2", "tx-3"} {
+		if code := deliver(id); code != http.StatusOK {
+			t.Fatalf("%s: status = %d, want 200", id, code)
+		}
+	}
+	if code := deliver("tx-4"); code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d with a full queue, want 503", code)
+	}
+
+	close(block)
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if got := atomic.LoadInt32(&handled); got != 3 {
+		t.Errorf("handled = %d after Close, want 3", got)
+	}
+	if len(failed) != 1 || failed[0] != "fail" {
+		t.Errorf("errors reported for %v, want [fail]", failed)
+	}
+	if code := deliver("tx-5"); code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d after Close, want 503", code)
+	}
+}
+
+func TestShutdownGivesUp(t *testing.T) {
+	g := newTestGateway(t)
+
+	block := make(chan struct{})
+	defer close(block)
+	handler := PushCallbackFunc(func(request PushCallbackRequest) (PushCallbackResponse, error) {
+		<-block
+		return PushCallbackResponse{}, nil
+	})
+	c := g.client(WithCallbackHandler(handler), WithAsyncCallbacks(1, 1, nil))
+	c.CallbackServeHTTP(httptest.NewRecorder(), newCallbackRequest(context.Background(), testCallbackBody))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
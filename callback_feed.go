@@ -0,0 +1,202 @@
+package mpesa
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// callbackFeed publishes the decoded callbacks on the channels returned by
+// Callbacks and DisburseCallbacks. The channels are made on first use.
+type callbackFeed struct {
+	mu             sync.Mutex
+	closed         bool
+	buffer         int
+	rejectWhenFull bool
+	dropped        uint64
+	push           chan PushCallbackRequest
+	disburse       chan DisburseCallbackRequest
+
+	// pushReserved and disburseReserved count the room taken on the channels
+	// by the callbacks being handled, when rejectWhenFull is set.
+	pushReserved     int
+	disburseReserved int
+}
+
+// Callbacks returns the channel the push callbacks are published on after they
+// are decoded, whether or not a PushCallbackHandler is set as well. It returns
+// nil unless WithCallbackChannel is used. The channel is closed by Close.
+func (c *Client) Callbacks() <-chan PushCallbackRequest {
+	f := c.callbackFeed
+	if f == nil {
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.push == nil {
+		f.push = make(chan PushCallbackRequest, f.buffer)
+		if f.closed {
+			close(f.push)
+		}
+	}
+
+	return f.push
+}
+
+// DisburseCallbacks is like Callbacks for the disbursement results.
+func (c *Client) DisburseCallbacks() <-chan DisburseCallbackRequest {
+	f := c.callbackFeed
+	if f == nil {
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.disburse == nil {
+		f.disburse = make(chan DisburseCallbackRequest, f.buffer)
+		if f.closed {
+			close(f.disburse)
+		}
+	}
+
+	return f.disburse
+}
+
+// DroppedCallbacks returns the number of callbacks that could not be published
+// because nobody asked for the channel yet or its buffer was full.
+func (c *Client) DroppedCallbacks() uint64 {
+	if c.callbackFeed == nil {
+		return 0
+	}
+
+	return atomic.LoadUint64(&c.callbackFeed.dropped)
+}
+
+// reservePush makes room on the push channel for a callback about to be
+// handled, when the feed rejects the callbacks it can not take. The room is
+// checked before the handler runs so that a rejected callback had no side
+// effect when the gateway delivers it again. It returns false when the
+// callback must be rejected. A reservation is used by publishPush or given
+// back by unreservePush.
+func (f *callbackFeed) reservePush() bool {
+	if f == nil || !f.rejectWhenFull {
+		return true
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.reserve(&f.pushReserved, f.push != nil, len(f.push), cap(f.push))
+}
+
+// reserveDisburse is like reservePush for the disbursement results.
+func (f *callbackFeed) reserveDisburse() bool {
+	if f == nil || !f.rejectWhenFull {
+		return true
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.reserve(&f.disburseReserved, f.disburse != nil, len(f.disburse), cap(f.disburse))
+}
+
+// reserve takes room on a channel holding queued callbacks out of capacity, a
+// callback rejected for lack of room is counted as dropped. It must be called
+// with mu held.
+func (f *callbackFeed) reserve(reserved *int, made bool, queued, capacity int) bool {
+	if !made || f.closed || queued+*reserved >= capacity {
+		atomic.AddUint64(&f.dropped, 1)
+		return false
+	}
+	*reserved++
+
+	return true
+}
+
+// unreservePush gives back the room reserved for a callback that was not
+// handled.
+func (f *callbackFeed) unreservePush() {
+	if f == nil || !f.rejectWhenFull {
+		return
+	}
+
+	f.mu.Lock()
+	f.pushReserved--
+	f.mu.Unlock()
+}
+
+// unreserveDisburse is like unreservePush for the disbursement results.
+func (f *callbackFeed) unreserveDisburse() {
+	if f == nil || !f.rejectWhenFull {
+		return
+	}
+
+	f.mu.Lock()
+	f.disburseReserved--
+	f.mu.Unlock()
+}
+
+// publishPush sends a handled request without blocking, in the room reserved
+// by reservePush when the feed rejects the callbacks it can not take. A
+// callback that does not fit, or arrives once the feed is closed, is dropped:
+// it can no longer be rejected.
+func (f *callbackFeed) publishPush(request PushCallbackRequest) {
+	if f == nil {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.rejectWhenFull {
+		f.pushReserved--
+	}
+	if f.push != nil && !f.closed {
+		select {
+		case f.push <- request:
+			return
+		default:
+		}
+	}
+
+	atomic.AddUint64(&f.dropped, 1)
+}
+
+// publishDisburse is like publishPush for the disbursement results.
+func (f *callbackFeed) publishDisburse(request DisburseCallbackRequest) {
+	if f == nil {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.rejectWhenFull {
+		f.disburseReserved--
+	}
+	if f.disburse != nil && !f.closed {
+		select {
+		case f.disburse <- request:
+			return
+		default:
+		}
+	}
+
+	atomic.AddUint64(&f.dropped, 1)
+}
+
+// close closes the channels, later callbacks are dropped.
+func (f *callbackFeed) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return
+	}
+	f.closed = true
+	if f.push != nil {
+		close(f.push)
+	}
+	if f.disburse != nil {
+		close(f.disburse)
+	}
+}
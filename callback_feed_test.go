@@ -0,0 +1,205 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCallbacksChannel(t *testing.T) {
+	g := newTestGateway(t)
+
+	called := false
+	handler := PushCallbackFunc(func(request PushCallbackRequest) (PushCallbackResponse, error) {
+		called = true
+		return PushCallbackResponse{ResponseCode: SUCCESS_CODE}, nil
+	})
+	c := g.client(WithCallbackHandler(handler), WithCallbackChannel(1, false))
+
+	ch := c.Callbacks()
+	deliver := func() int {
+		rec := httptest.NewRecorder()
+		c.CallbackServeHTTP(rec, newCallbackRequest(context.Background(), testCallbackBody))
+		return rec.Code
+	}
+
+	if code := deliver(); code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	if got := <-ch; got.TransactionID != "tx-1" || !called {
+		t.Errorf("published %+v, handler called = %v, want both to receive the callback", got, called)
+	}
+
+	// the buffer holds one callback, the next one is dropped
+	deliver()
+	if code := deliver(); code != http.StatusOK {
+		t.Errorf("status = %d when dropping, want 200", code)
+	}
+	if got := c.DroppedCallbacks(); got != 1 {
+		t.Errorf("DroppedCallbacks() = %d, want 1", got)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	_ = c.Close()
+	<-ch
+	if _, ok := <-ch; ok {
+		t.Error("channel still open after Close")
+	}
+	if code := deliver(); code != http.StatusOK || c.DroppedCallbacks() != 2 {
+		t.Errorf("after Close: status = %d, dropped = %d", code, c.DroppedCallbacks())
+	}
+}
+
+func TestCallbacksChannelRejectWhenFull(t *testing.T) {
+	g := newTestGateway(t)
+	c := g.client(WithCallbackChannel(0, true))
+
+	// nobody listens on an unbuffered channel
+	_ = c.Callbacks()
+	rec := httptest.NewRecorder()
+	c.CallbackServeHTTP(rec, newCallbackRequest(context.Background(), testCallbackBody))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+}
+
+func TestCallbacksChannelRejectsBeforeHandling(t *testing.T) {
+	var pushes, disbursements int
+	var fail bool
+	c := newTestGateway(t).client(
+		WithCallbackHandler(PushCallbackFunc(func(request PushCallbackRequest) (PushCallbackResponse, error) {
+			pushes++
+			if fail {
+				return PushCallbackResponse{}, errors.New("handler failed")
+			}
+			return PushCallbackResponse{ResponseCode: SUCCESS_CODE}, nil
+		})),
+		WithDisburseCallbackHandler(DisburseCallbackFunc(func(_ context.Context, request DisburseCallbackRequest) (DisburseCallbackResponse, error) {
+			disbursements++
+			return DisburseCallbackResponse{ResponseCode: SUCCESS_CODE}, nil
+		})),
+		WithCallbackChannel(1, true),
+	)
+	pushCh, disburseCh := c.Callbacks(), c.DisburseCallbacks()
+	deliverPush := func() int {
+		rec := httptest.NewRecorder()
+		c.CallbackServeHTTP(rec, newCallbackRequest(context.Background(), testCallbackBody))
+		return rec.Code
+	}
+	deliverDisburse := func() int {
+		rec := httptest.NewRecorder()
+		c.DisburseCallbackServeHTTP(rec, newCallbackRequest(context.Background(),
+			`{"input_OriginalConversationID": "conv-1", "input_TransactionStatus": "Completed"}`))
+		return rec.Code
+	}
+
+	if code := deliverPush(); code != http.StatusOK {
+		t.Fatalf("push status = %d, want 200", code)
+	}
+	if code := deliverPush(); code != http.StatusServiceUnavailable || pushes != 1 {
+		t.Errorf("push status = %d, handler calls = %d, want 503 without calling the handler", code, pushes)
+	}
+	if code := deliverDisburse(); code != http.StatusOK {
+		t.Fatalf("disburse status = %d, want 200", code)
+	}
+	if code := deliverDisburse(); code != http.StatusServiceUnavailable || disbursements != 1 {
+		t.Errorf("disburse status = %d, handler calls = %d, want 503 without calling the handler", code, disbursements)
+	}
+
+	<-pushCh
+	<-disburseCh
+	fail = true
+	if code := deliverPush(); code == http.StatusOK || code == http.StatusServiceUnavailable {
+		t.Errorf("push status = %d with a failing handler", code)
+	}
+	fail = false
+	if code := deliverPush(); code != http.StatusOK || pushes != 3 {
+		t.Errorf("push status = %d, handler calls = %d, want the room of the failed callback given back", code, pushes)
+	}
+	if code := deliverDisburse(); code != http.StatusOK || disbursements != 2 {
+		t.Errorf("disburse status = %d, handler calls = %d, want 200 once drained", code, disbursements)
+	}
+	if got := c.DroppedCallbacks(); got != 2 {
+		t.Errorf("DroppedCallbacks() = %d, want 2", got)
+	}
+}
+
+func TestDisburseCallbacksChannel(t *testing.T) {
+	g := newTestGateway(t)
+	c := g.client(WithCallbackChannel(1, false))
+
+	ch := c.DisburseCallbacks()
+	rec := httptest.NewRecorder()
+	c.DisburseCallbackServeHTTP(rec, newCallbackRequest(context.Background(),
+		`{"input_OriginalConversationID": "conv-1", "input_TransactionStatus": "Completed"}`))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 without a handler", rec.Code)
+	}
+	if got := <-ch; got.TransactionStatus != "Completed" {
+		t.Errorf("published %+v", got)
+	}
+	if c.Callbacks() == nil || newTestGateway(t).client().Callbacks() != nil {
+		t.Error("Callbacks() should be nil only without WithCallbackChannel")
+	}
+}
+
+func TestCallbacksChannelDedup(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		handler bool
+		async   bool
+	}{
+		{name: "handler", handler: true},
+		{name: "async handler", handler: true, async: true},
+		{name: "channel only"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGateway(t)
+			opts := []ClientOption{WithCallbackChannel(4, false), WithCallbackDedup(NewMemoryCallbackStore(), 0)}
+			if tt.handler {
+				opts = append(opts, WithCallbackHandler(PushCallbackFunc(func(request PushCallbackRequest) (PushCallbackResponse, error) {
+					return successAck(request), nil
+				})))
+			}
+			if tt.async {
+				opts = append(opts, WithAsyncCallbacks(1, 4, nil))
+			}
+			c := g.client(opts...)
+			ch := c.Callbacks()
+
+			for i := 0; i < 2; i++ {
+				rec := httptest.NewRecorder()
+				c.CallbackServeHTTP(rec, newCallbackRequest(context.Background(), testCallbackBody))
+				if rec.Code != http.StatusOK {
+					t.Fatalf("delivery %d: status = %d, want 200", i, rec.Code)
+				}
+				// the worker saves the acknowledgement before the re-delivery
+				for deadline := time.Now().Add(5 * time.Second); tt.async && i == 0; time.Sleep(time.Millisecond) {
+					if _, ok := c.savedAck(context.Background(), PushCallbackRequest{TransactionID: "tx-1"}); ok {
+						break
+					}
+					if time.Now().After(deadline) {
+						t.Fatal("the asynchronous callback was not handled")
+					}
+				}
+			}
+			if err := c.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			received := 0
+			for range ch {
+				received++
+			}
+			if received != 1 {
+				t.Errorf("callbacks received = %d, want the re-delivery left out", received)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Code returns ResultCode as a ResponseCode.
+func (r PushCallbackRequest) Code() ResponseCode { return ResponseCode(r.ResultCode) }
+
+// AmountValue parses Amount. It fails when the callback carries no amount or
+// an invalid one.
+func (r PushCallbackRequest) AmountValue() (Amount, error) {
+	if r.Amount == "" {
+		return Amount{}, errors.New("mpesa: push callback carries no amount")
+	}
+
+	return ParseAmount(r.Amount)
+}
+
+// Time parses TransactionTime, see GatewayTimeLayout, in the location carried
+// by ctx, see LocationFromContext. The context given to the callback handlers
+// carries the location of the Client. It fails when the callback carries no
+// transaction time or an invalid one.
+func (r PushCallbackRequest) Time(ctx context.Context) (time.Time, error) {
+	loc, _ := LocationFromContext(ctx)
+
+	return r.TimeIn(loc)
+}
+
+// TimeIn is like Time but parses TransactionTime in loc.
+func (r PushCallbackRequest) TimeIn(loc *time.Location) (time.Time, error) {
+	return ParseGatewayTime(r.TransactionTime, loc)
+}
+
+// Code returns ResultCode as a ResponseCode.
+func (r DisburseCallbackRequest) Code() ResponseCode { return ResponseCode(r.ResultCode) }
+
+// Time parses TransactionTime, see GatewayTimeLayout, in the location carried
+// by ctx, see LocationFromContext. The context given to the callback handlers
+// carries the location of the Client. It fails when the callback carries no
+// transaction time or an invalid one.
+func (r DisburseCallbackRequest) Time(ctx context.Context) (time.Time, error) {
+	loc, _ := LocationFromContext(ctx)
+
+	return r.TimeIn(loc)
+}
+
+// TimeIn is like Time but parses TransactionTime in loc.
+func (r DisburseCallbackRequest) TimeIn(loc *time.Location) (time.Time, error) {
+	return ParseGatewayTime(r.TransactionTime, loc)
+}
@@ -0,0 +1,88 @@
+package mpesa
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestCallbackFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		amount  string
+		time    time.Time
+		success bool
+	}{
+		{
+			name: "TZN",
+			payload: `{"input_OriginalConversationID":"9bf42fd9c0514c7c9d84c6a1b1f5f12b","input_TransactionID":"8CW70SMRXZ",` +
+				`"input_ResultCode":"INS-0","input_ResultDesc":"Request processed successfully",` +
+				`"input_ThirdPartyConversationID":"asv02e5958774f7ba228d83d0d689761","input_Amount":"15000.00","input_TransactionTime":"20211231143000"}`,
+			amount:  "15000.00",
+			time:    time.Date(2021, 12, 31, 14, 30, 0, 0, time.UTC),
+			success: true,
+		},
+		{
+			name: "GHA",
+			payload: `{"input_OriginalConversationID":"d5b7d6e4a9a44f4c8c7b0d2f1e8a3c96","input_TransactionID":"0000000000001",` +
+				`"input_ResultCode":"INS-2006","input_ResultDesc":"Insufficient balance",` +
+				`"input_ThirdPartyConversationID":"ghs-1609","input_Amount":"12.5","input_TransactionTime":"20220105080910"}`,
+			amount: "12.50",
+			time:   time.Date(2022, 1, 5, 8, 9, 10, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var request PushCallbackRequest
+			if err := json.Unmarshal([]byte(tt.payload), &request); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+
+			amount, err := request.AmountValue()
+			if err != nil || amount.String() != tt.amount {
+				t.Errorf("AmountValue() = %v, %v, want %s", amount, err, tt.amount)
+			}
+			if got, err := request.Time(context.Background()); err != nil || !got.Equal(tt.time) {
+				t.Errorf("Time() = %v, %v, want %v", got, err, tt.time)
+			}
+			if got := request.Code().IsSuccess(); got != tt.success {
+				t.Errorf("Code().IsSuccess() = %v, want %v", got, tt.success)
+			}
+
+			// the fields are sent back as they were received
+			encoded, err := json.Marshal(request)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			var again PushCallbackRequest
+			if err := json.Unmarshal(encoded, &again); err != nil || again != request {
+				t.Errorf("round trip = %+v, %v, want %+v", again, err, request)
+			}
+		})
+	}
+}
+
+func TestCallbackFieldsErrors(t *testing.T) {
+	for _, request := range []PushCallbackRequest{
+		{},
+		{Amount: "ten", TransactionTime: "2021-12-31 14:30"},
+	} {
+		if _, err := request.AmountValue(); err == nil {
+			t.Errorf("AmountValue() of %q error = nil", request.Amount)
+		}
+		if _, err := request.Time(context.Background()); err == nil {
+			t.Errorf("Time() of %q error = nil", request.TransactionTime)
+		}
+	}
+
+	disburse := DisburseCallbackRequest{ResultCode: "INS-0", TransactionTime: "20211231"}
+	if _, err := disburse.Time(context.Background()); err == nil {
+		t.Errorf("Time() of %q error = nil", disburse.TransactionTime)
+	}
+	if !disburse.Code().IsSuccess() {
+		t.Errorf("Code().IsSuccess() = false, want INS-0 a success")
+	}
+}
@@ -0,0 +1,102 @@
+package mpesa
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+type (
+	// CallbackMeta describes the HTTP request that delivered a callback: the
+	// address of the sender, its headers, when it was received and the body
+	// as read, after the decompression of a gzip body. Header holds the
+	// headers chosen with WithCallbackMetaHeaders, all of them by default.
+	CallbackMeta struct {
+		RemoteIP   net.IP
+		Header     http.Header
+		ReceivedAt time.Time
+		Body       []byte
+	}
+
+	callbackMetaKey struct{}
+
+	// detachedContext keeps the values of a context without its deadline
+	// and cancellation.
+	detachedContext struct {
+		context.Context
+	}
+)
+
+// CallbackMetaFromContext returns the CallbackMeta of the callback whose handler
+// was given ctx by CallbackServeHTTP or DisburseCallbackServeHTTP, including the
+// handlers run by WithAsyncCallbacks.
+func CallbackMetaFromContext(ctx context.Context) (CallbackMeta, bool) {
+	meta, ok := ctx.Value(callbackMetaKey{}).(CallbackMeta)
+
+	return meta, ok
+}
+
+// WithCallbackMetaHeaders limits the headers copied into the CallbackMeta of
+// the callbacks to names, e.g. the tenant header set by a proxy.
+func WithCallbackMetaHeaders(names ...string) ClientOption {
+	return func(client *Client) {
+		client.callbackMetaHeaders = make([]string, 0, len(names))
+		for _, name := range names {
+			client.callbackMetaHeaders = append(client.callbackMetaHeaders, http.CanonicalHeaderKey(name))
+		}
+	}
+}
+
+// callbackContext adds the CallbackMeta of the callback r, its conversation
+// ids decoded in body, the location of the Client and the correlation header
+// of r, if any, to ctx.
+func (c *Client) callbackContext(ctx context.Context, r *http.Request, raw []byte, received time.Time, body interface{}) context.Context {
+	meta := CallbackMeta{RemoteIP: remoteIP(r), ReceivedAt: received, Body: raw}
+	if c.callbackMetaHeaders == nil {
+		meta.Header = r.Header.Clone()
+	} else {
+		meta.Header = make(http.Header, len(c.callbackMetaHeaders))
+		for _, name := range c.callbackMetaHeaders {
+			if values := r.Header.Values(name); len(values) > 0 {
+				meta.Header[name] = append([]string(nil), values...)
+			}
+		}
+	}
+	ctx = context.WithValue(ctx, callbackMetaKey{}, meta)
+
+	conversationID, thirdPartyID, _ := callbackIDs(body)
+	ctx = context.WithValue(ctx, conversationIDsKey{}, ConversationIDs{
+		ConversationID:           conversationID,
+		ThirdPartyConversationID: thirdPartyID,
+	})
+
+	if _, ok := LocationFromContext(ctx); !ok {
+		ctx = ContextWithLocation(ctx, c.Location())
+	}
+
+	if _, ok := CorrelationIDFromContext(ctx); !ok {
+		if id := r.Header.Get(c.correlationHeader); id != "" {
+			ctx = ContextWithCorrelationID(ctx, id)
+		}
+	}
+
+	return ctx
+}
+
+// remoteIP returns the address r was sent from, nil when it can not be
+// parsed.
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return net.ParseIP(host)
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (detachedContext) Done() <-chan struct{} { return nil }
+
+func (detachedContext) Err() error { return nil }
@@ -0,0 +1,73 @@
+package mpesa
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCallbackMeta(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []ClientOption
+		async bool
+		want  []string
+	}{
+		{name: "all headers", want: []string{"X-Tenant", "Content-Type"}},
+		{name: "chosen headers", opts: []ClientOption{WithCallbackMetaHeaders("x-tenant")}, want: []string{"X-Tenant"}},
+		{name: "async", opts: []ClientOption{WithAsyncCallbacks(1, 1, nil)}, async: true, want: []string{"X-Tenant"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGateway(t)
+			metas := make(chan CallbackMeta, 1)
+			errs := make(chan error, 1)
+			handler := PushCallbackContextFunc(func(ctx context.Context, request PushCallbackRequest) (PushCallbackResponse, error) {
+				meta, ok := CallbackMetaFromContext(ctx)
+				if !ok {
+					t.Errorf("CallbackMetaFromContext() found no meta")
+				}
+				metas <- meta
+				errs <- ctx.Err()
+				return PushCallbackResponse{ResponseCode: SUCCESS_CODE}, nil
+			})
+			c := g.client(append([]ClientOption{WithCallbackHandler(handler)}, tt.opts...)...)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			r := newCallbackRequest(ctx, testCallbackBody)
+			r.Header.Set("X-Tenant", "acme")
+			before := c.clock.Now()
+			c.CallbackServeHTTP(httptest.NewRecorder(), r)
+			// the request is gone by the time an async handler runs
+			cancel()
+			if tt.async {
+				if err := c.Close(); err != nil {
+					t.Fatalf("Close() error = %v", err)
+				}
+			}
+
+			meta := <-metas
+			if err := <-errs; err != nil {
+				t.Errorf("handler context error = %v, want it alive", err)
+			}
+			if meta.RemoteIP.String() != "192.0.2.1" {
+				t.Errorf("RemoteIP = %v, want the address of the sender", meta.RemoteIP)
+			}
+			if string(meta.Body) != testCallbackBody {
+				t.Errorf("Body = %q, want the raw callback", meta.Body)
+			}
+			if meta.ReceivedAt.Before(before) {
+				t.Errorf("ReceivedAt = %v, want the time the callback arrived", meta.ReceivedAt)
+			}
+			for _, name := range tt.want {
+				if meta.Header.Get(name) == "" {
+					t.Errorf("Header = %v, want %s", meta.Header, name)
+				}
+			}
+			if len(tt.opts) > 0 && !tt.async && len(meta.Header) != len(tt.want) {
+				t.Errorf("Header = %v, want only %v", meta.Header, tt.want)
+			}
+		})
+	}
+}
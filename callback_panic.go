@@ -0,0 +1,58 @@
+package mpesa
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// CallbackPanicError is the error a callback fails with when its handler, or
+// the processing of the callback, panicked. Kind is "push" or "disburse", Value
+// is the value passed to panic and Stack the stack of the goroutine when the
+// panic was recovered.
+type CallbackPanicError struct {
+	Kind  string
+	Value interface{}
+	Stack []byte
+}
+
+func (e *CallbackPanicError) Error() string {
+	return fmt.Sprintf("%s callback handler panicked: %v", e.Kind, e.Value)
+}
+
+// recoverCallback is deferred around the code run for a callback of kind. A
+// panic is turned into a *CallbackPanicError stored in *errp, written to the
+// logger with its stack and reported to the MetricsCollector when it is a
+// CallbackPanicObserver. http.ErrAbortHandler is panicked again, it is how a
+// handler asks the http.Server to abort the response.
+func (c *Client) recoverCallback(kind string, errp *error) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	if v == http.ErrAbortHandler {
+		panic(v)
+	}
+
+	err := &CallbackPanicError{Kind: kind, Value: v, Stack: debug.Stack()}
+	if c.records != nil {
+		c.record(context.Background(), levelWarn, "callback handler panicked",
+			"kind", kind, "panic", fmt.Sprint(v), "stack", string(err.Stack))
+	} else {
+		c.logf("%v\n%s", err, err.Stack)
+	}
+	if observer, ok := c.metrics.(CallbackPanicObserver); ok {
+		observer.ObserveCallbackPanic(kind)
+	}
+
+	*errp = err
+}
+
+// handleDisburseCallback calls handler, turning a panic into an error.
+func (c *Client) handleDisburseCallback(ctx context.Context, handler DisburseCallbackHandler,
+	request DisburseCallbackRequest) (ack DisburseCallbackResponse, err error) {
+	defer c.recoverCallback("disburse", &err)
+
+	return handler.HandleDisburseCallback(ctx, request)
+}
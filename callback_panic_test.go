@@ -0,0 +1,121 @@
+package mpesa
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCallbackServeHTTPRecoversPanics(t *testing.T) {
+	g := newTestGateway(t)
+	handler := PushCallbackFunc(func(request PushCallbackRequest) (PushCallbackResponse, error) {
+		if request.TransactionID == "panic" {
+			panic("nil map")
+		}
+		return successAck(request), nil
+	})
+	metrics := new(MemoryMetrics)
+	var logs bytes.Buffer
+	c := g.client(WithCallbackHandler(handler), WithMetrics(metrics), WithLogger(&logs))
+
+	server := httptest.NewServer(http.HandlerFunc(c.CallbackServeHTTP))
+	defer server.Close()
+
+	deliver := func(txID string) (int, PushCallbackResponse) {
+		body := `{"input_OriginalConversationID": "conv", "input_TransactionID": "` + txID + `"}`
+		res, err := server.Client().Post(server.URL, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST %s error = %v", txID, err)
+		}
+		defer res.Body.Close()
+
+		var ack PushCallbackResponse
+		_ = json.NewDecoder(res.Body).Decode(&ack)
+		return res.StatusCode, ack
+	}
+
+	status, ack := deliver("panic")
+	if status != http.StatusInternalServerError || ack.ResponseCode != string(callbackHandlerFailed) || ack.OriginalConversationID != "conv" {
+		t.Errorf("panicking callback = %d %+v, want 500 with a failure acknowledgement", status, ack)
+	}
+	if status, ack := deliver("tx-2"); status != http.StatusOK || ack.ResponseCode != SUCCESS_CODE {
+		t.Errorf("next callback = %d %+v, want it served", status, ack)
+	}
+
+	if panics := metrics.CallbackPanics(); len(panics) != 1 || panics[0] != "push" {
+		t.Errorf("CallbackPanics() = %v, want one push panic", panics)
+	}
+	var panicErr *CallbackPanicError
+	if callbacks := metrics.Callbacks(); len(callbacks) != 2 || !errors.As(callbacks[0].Err, &panicErr) || panicErr.Value != "nil map" {
+		t.Errorf("Callbacks() = %+v, want the panic observed first", callbacks)
+	}
+	if !strings.Contains(logs.String(), "push callback handler panicked: nil map") || !strings.Contains(logs.String(), "goroutine") {
+		t.Errorf("logs = %q, want the panic logged with its stack", logs.String())
+	}
+}
+
+func TestDisburseCallbackServeHTTPRecoversPanics(t *testing.T) {
+	g := newTestGateway(t)
+	handler := DisburseCallbackFunc(func(ctx context.Context, request DisburseCallbackRequest) (DisburseCallbackResponse, error) {
+		panic(errors.New("boom"))
+	})
+	metrics := new(MemoryMetrics)
+	c := g.client(WithDisburseCallbackHandler(handler), WithMetrics(metrics), WithLogger(&bytes.Buffer{}))
+
+	rec := httptest.NewRecorder()
+	c.DisburseCallbackServeHTTP(rec, newCallbackRequest(context.Background(), testCallbackBody))
+
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), string(callbackHandlerFailed)) {
+		t.Errorf("response = %d %s, want 500 with a failure acknowledgement", rec.Code, rec.Body)
+	}
+	if panics := metrics.CallbackPanics(); len(panics) != 1 || panics[0] != "disburse" {
+		t.Errorf("CallbackPanics() = %v, want one disburse panic", panics)
+	}
+}
+
+func TestAsyncCallbacksRecoverPanics(t *testing.T) {
+	g := newTestGateway(t)
+	handler := PushCallbackFunc(func(request PushCallbackRequest) (PushCallbackResponse, error) {
+		if request.TransactionID == "panic" {
+			panic("nil map")
+		}
+		return successAck(request), nil
+	})
+
+	var mu sync.Mutex
+	var reported []error
+	onError := func(request PushCallbackRequest, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported = append(reported, err)
+	}
+	metrics := new(MemoryMetrics)
+	c := g.client(WithCallbackHandler(handler), WithAsyncCallbacks(1, 4, onError),
+		WithMetrics(metrics), WithLogger(&bytes.Buffer{}))
+
+	for _, txID := range []string{"panic", "tx-2", "panic"} {
+		body := `{"input_OriginalConversationID": "conv", "input_TransactionID": "` + txID + `"}`
+		rec := httptest.NewRecorder()
+		c.CallbackServeHTTP(rec, newCallbackRequest(context.Background(), body))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, want 200", txID, rec.Code)
+		}
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	var panicErr *CallbackPanicError
+	if len(reported) != 2 || !errors.As(reported[1], &panicErr) {
+		t.Errorf("errors reported = %v, want both panics reported and the worker kept running", reported)
+	}
+	if panics := metrics.CallbackPanics(); len(panics) != 2 {
+		t.Errorf("CallbackPanics() = %v, want 2", panics)
+	}
+}
@@ -0,0 +1,145 @@
+package mpesa
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// CallbackRequestError is returned by ParsePushCallbackRequest for a request
+// that is not a valid callback. StatusCode is the status to answer it with.
+type CallbackRequestError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *CallbackRequestError) Error() string {
+	return fmt.Sprintf("mpesa: callback rejected with %d: %s", e.StatusCode, e.Message)
+}
+
+// ParsePushCallback decodes the push callback read from r, e.g. the body of a
+// message delivered through a queue rather than to CallbackServeHTTP.
+func ParsePushCallback(r io.Reader) (PushCallbackRequest, error) {
+	var request PushCallbackRequest
+	if err := decodeCallback(r, &request); err != nil {
+		return PushCallbackRequest{}, err
+	}
+
+	return request, nil
+}
+
+// ParsePushCallbackRequest decodes the push callback carried by r after the
+// checks of CallbackServeHTTP: r must be a POST with a JSON body of at most
+// limit bytes, DefaultCallbackBodyLimit when limit is zero or negative, and a
+// gzip encoded body is decompressed. The failures are reported with a
+// *CallbackRequestError. The source and authentication checks of the client
+// are not applied.
+func ParsePushCallbackRequest(r *http.Request, limit int64) (PushCallbackRequest, error) {
+	if r.Method != http.MethodPost {
+		return PushCallbackRequest{}, &CallbackRequestError{
+			StatusCode: http.StatusMethodNotAllowed,
+			Message:    fmt.Sprintf("method %s is not allowed", r.Method),
+		}
+	}
+	if limit <= 0 {
+		limit = DefaultCallbackBodyLimit
+	}
+
+	body, err := readCallbackBody(nil, r, limit)
+	if err != nil {
+		return PushCallbackRequest{}, err
+	}
+
+	request, perr := ParsePushCallback(bytes.NewReader(body))
+	if perr != nil {
+		return PushCallbackRequest{}, &CallbackRequestError{StatusCode: http.StatusBadRequest, Message: perr.Error()}
+	}
+
+	return request, nil
+}
+
+// BuildCallbackAck returns the JSON acknowledgement of the push callback
+// request with code, e.g. for a callback received through another transport
+// than CallbackServeHTTP. desc defaults to the description of code.
+func BuildCallbackAck(request PushCallbackRequest, code ResponseCode, desc string) ([]byte, error) {
+	buf, err := json.Marshal(callbackAck(request, code, desc))
+	if err != nil {
+		return nil, fmt.Errorf("mpesa: encode callback acknowledgement: %w", err)
+	}
+
+	return buf, nil
+}
+
+// callbackAck builds the acknowledgement of request, echoing its conversation
+// ids.
+func callbackAck(request PushCallbackRequest, code ResponseCode, desc string) PushCallbackResponse {
+	if desc == "" {
+		desc = code.Description()
+	}
+
+	return PushCallbackResponse{
+		OriginalConversationID:   request.OriginalConversationID,
+		ResponseCode:             string(code),
+		ResponseDesc:             desc,
+		ThirdPartyConversationID: request.ThirdPartyConversationID,
+	}
+}
+
+// decodeCallback decodes the JSON callback read from r into v, a pointer to a
+// callback request.
+func decodeCallback(r io.Reader, v interface{}) error {
+	if err := json.NewDecoder(r).Decode(v); err != nil {
+		return fmt.Errorf("mpesa: decode callback: %w", err)
+	}
+
+	return nil
+}
+
+// readCallbackBody reads the body of the callback r once its content type is
+// checked, at most limit bytes before and after a gzip decompression. w, when
+// not nil, is told to close the connection of an oversized body.
+func readCallbackBody(w http.ResponseWriter, r *http.Request, limit int64) ([]byte, *CallbackRequestError) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return nil, &CallbackRequestError{StatusCode: http.StatusUnsupportedMediaType, Message: "content type must be application/json"}
+	}
+
+	tooLarge := &CallbackRequestError{StatusCode: http.StatusRequestEntityTooLarge, Message: fmt.Sprintf("body exceeds %d bytes", limit)}
+	if r.ContentLength > limit {
+		return nil, tooLarge
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		if int64(len(body)) >= limit {
+			return nil, tooLarge
+		}
+		return nil, &CallbackRequestError{StatusCode: http.StatusBadRequest, Message: "could not read body"}
+	}
+
+	switch encoding := strings.TrimSpace(r.Header.Get("Content-Encoding")); {
+	case encoding == "" || strings.EqualFold(encoding, "identity"):
+	case gzipEncoded(r.Header):
+		if body, err = gunzip(body, limit); err != nil {
+			if errors.Is(err, errBodyTooLarge) {
+				return nil, tooLarge
+			}
+			return nil, &CallbackRequestError{StatusCode: http.StatusBadRequest, Message: "malformed gzip body"}
+		}
+		r.Header.Del("Content-Encoding")
+		r.ContentLength = int64(len(body))
+	default:
+		return nil, &CallbackRequestError{
+			StatusCode: http.StatusUnsupportedMediaType,
+			Message:    fmt.Sprintf("content encoding %s is not supported", encoding),
+		}
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	return body, nil
+}
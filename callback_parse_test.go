@@ -0,0 +1,96 @@
+package mpesa
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParsePushCallback(t *testing.T) {
+	request, err := ParsePushCallback(strings.NewReader(testCallbackBody))
+	if err != nil {
+		t.Fatalf("ParsePushCallback() error = %v", err)
+	}
+	want := PushCallbackRequest{
+		OriginalConversationID:   "conv-1",
+		TransactionID:            "tx-1",
+		ResultCode:               "INS-0",
+		ResultDesc:               "Request processed successfully",
+		ThirdPartyConversationID: "tp-1",
+	}
+	if request != want {
+		t.Errorf("ParsePushCallback() = %+v, want %+v", request, want)
+	}
+
+	if _, err := ParsePushCallback(strings.NewReader(`{"input_TransactionID":`)); err == nil {
+		t.Errorf("ParsePushCallback() of a truncated body error = nil")
+	}
+}
+
+func TestParsePushCallbackRequest(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		limit       int64
+		wantStatus  int
+	}{
+		{name: "valid", method: http.MethodPost, contentType: "application/json; charset=utf-8", body: testCallbackBody},
+		{name: "method", method: http.MethodGet, contentType: "application/json", body: testCallbackBody, wantStatus: http.StatusMethodNotAllowed},
+		{name: "content type", method: http.MethodPost, contentType: "text/plain", body: testCallbackBody, wantStatus: http.StatusUnsupportedMediaType},
+		{name: "too large", method: http.MethodPost, contentType: "application/json", body: testCallbackBody, limit: 10, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "malformed", method: http.MethodPost, contentType: "application/json", body: `{"input_TransactionID":`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/callbacks/mpesa", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+
+			request, err := ParsePushCallbackRequest(r, tt.limit)
+			if tt.wantStatus == 0 {
+				if err != nil || request.TransactionID != "tx-1" {
+					t.Errorf("ParsePushCallbackRequest() = %+v, %v", request, err)
+				}
+				return
+			}
+
+			var requestErr *CallbackRequestError
+			if !errors.As(err, &requestErr) || requestErr.StatusCode != tt.wantStatus {
+				t.Errorf("ParsePushCallbackRequest() error = %v, want a *CallbackRequestError with %d", err, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestBuildCallbackAck(t *testing.T) {
+	request := PushCallbackRequest{OriginalConversationID: "conv-1", ThirdPartyConversationID: "tp-1"}
+
+	tests := []struct {
+		code ResponseCode
+		desc string
+		want string
+	}{
+		{code: SUCCESS_CODE, want: ResponseCode(SUCCESS_CODE).Description()},
+		{code: "INS-1", desc: "ledger is down", want: "ledger is down"},
+	}
+	for _, tt := range tests {
+		buf, err := BuildCallbackAck(request, tt.code, tt.desc)
+		if err != nil {
+			t.Fatalf("BuildCallbackAck() error = %v", err)
+		}
+
+		var ack map[string]string
+		if err := json.Unmarshal(buf, &ack); err != nil {
+			t.Fatalf("acknowledgement %s: %v", buf, err)
+		}
+		if ack["output_OriginalConversationID"] != "conv-1" || ack["output_ThirdPartyConversationID"] != "tp-1" ||
+			ack["output_ResponseCode"] != string(tt.code) || ack["output_ResponseDesc"] != tt.want {
+			t.Errorf("BuildCallbackAck(%s) = %s", tt.code, buf)
+		}
+	}
+}
@@ -0,0 +1,500 @@
+package mpesa
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testCallbackBody = `{
+	"input_OriginalConversationID": "conv-1",
+	"input_TransactionID": "tx-1",
+	"input_ResultCode": "INS-0",
+	"input_ResultDesc": "Request processed successfully",
+	"input_ThirdPartyConversationID": "tp-1"
+}`
+
+func newCallbackRequest(ctx context.Context, body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/callbacks/mpesa", strings.NewReader(body)).WithContext(ctx)
+	r.Header.Set("Content-Type", "application/json")
+
+	return r
+}
+
+type ctxKey struct{}
+
+func TestCallbackServeHTTPUsesRequestContext(t *testing.T) {
+	g := newTestGateway(t)
+
+	var got interface{}
+	handler := PushCallbackContextFunc(func(ctx context.Context, request PushCallbackRequest) (PushCallbackResponse, error) {
+		got = ctx.Value(ctxKey{})
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("callback context has no deadline")
+		}
+
+		return PushCallbackResponse{
+			OriginalConversationID: request.OriginalConversationID,
+			ResponseCode:           SUCCESS_CODE,
+		}, nil
+	})
+	c := g.client(WithCallbackHandler(handler))
+
+	rec := httptest.NewRecorder()
+	ctx := context.WithValue(context.Background(), ctxKey{}, "from request")
+	c.CallbackServeHTTP(rec, newCallbackRequest(ctx, testCallbackBody))
+
+	if got != "from request" {
+		t.Errorf("context value = %v, want the one from the request context", got)
+	}
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"output_OriginalConversationID":"conv-1"`) {
+		t.Errorf("response = %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestCallbackServeHTTPCancelled(t *testing.T) {
+	g := newTestGateway(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	handler := PushCallbackContextFunc(func(ctx context.Context, request PushCallbackRequest) (PushCallbackResponse, error) {
+		// the gateway disconnects while the callback is processed
+		cancel()
+		<-ctx.Done()
+
+		return PushCallbackResponse{}, ctx.Err()
+	})
+	c := g.client(WithCallbackHandler(handler))
+
+	rec := httptest.NewRecorder()
+	c.CallbackServeHTTP(rec, newCallbackRequest(ctx, testCallbackBody))
+
+	if rec.Body.Len() != 0 || len(rec.Header()) != 0 {
+		t.Errorf("response written after cancellation: %d %v %s", rec.Code, rec.Header(), rec.Body)
+	}
+}
+
+func TestCallbackServeHTTPPlainHandler(t *testing.T) {
+	g := newTestGateway(t)
+
+	called := false
+	handler := PushCallbackFunc(func(request PushCallbackRequest) (PushCallbackResponse, error) {
+		called = true
+		return PushCallbackResponse{ResponseCode: SUCCESS_CODE}, nil
+	})
+	c := g.client(WithCallbackHandler(handler))
+
+	rec := httptest.NewRecorder()
+	c.CallbackServeHTTP(rec, newCallbackRequest(context.Background(), testCallbackBody))
+
+	if !called || rec.Code != http.StatusOK {
+		t.Errorf("called = %v, status = %d, want the handler called and 200", called, rec.Code)
+	}
+}
+
+func TestCallbackServeHTTPTrustedSources(t *testing.T) {
+	tests := []struct {
+		name       string
+		sources    []string
+		remoteAddr string
+		opts       []ClientOption
+		want       int
+	}{
+		{name: "no allowlist", remoteAddr: "203.0.113.7:4000", want: http.StatusOK},
+		{name: "exact ipv4", sources: []string{"203.0.113.7"}, remoteAddr: "203.0.113.7:4000", want: http.StatusOK},
+		{name: "ipv4 range", sources: []string{"198.51.100.1", "203.0.113.0/24"}, remoteAddr: "203.0.113.200:4000", want: http.StatusOK},
+		{name: "ipv6 range", sources: []string{"2001:db8::/32"}, remoteAddr: "[2001:db8::1]:4000", want: http.StatusOK},
+		{name: "exact ipv6", sources: []string{"2001:db8::1"}, remoteAddr: "[2001:db8::2]:4000", want: http.StatusForbidden},
+		{name: "outside range", sources: []string{"203.0.113.0/24"}, remoteAddr: "192.0.2.1:4000", want: http.StatusForbidden},
+		{
+			name:       "check disabled",
+			sources:    []string{"203.0.113.0/24"},
+			remoteAddr: "192.0.2.1:4000",
+			opts:       []ClientOption{WithTrustedSourcesCheck(false)},
+			want:       http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGateway(t)
+			called := false
+			handler := PushCallbackFunc(func(request PushCallbackRequest) (PushCallbackResponse, error) {
+				called = true
+				return PushCallbackResponse{ResponseCode: SUCCESS_CODE}, nil
+			})
+
+			conf := g.config()
+			conf.TrustedSources = tt.sources
+			opts := append([]ClientOption{WithDebugMode(false)}, tt.opts...)
+			c, err := NewClient(conf, handler, opts...)
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+
+			rec := httptest.NewRecorder()
+			r := newCallbackRequest(context.Background(), testCallbackBody)
+			r.RemoteAddr = tt.remoteAddr
+			c.CallbackServeHTTP(rec, r)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if called != (tt.want == http.StatusOK) {
+				t.Errorf("handler called = %v", called)
+			}
+		})
+	}
+}
+
+func TestNewClientRejectsMalformedTrustedSources(t *testing.T) {
+	g := newTestGateway(t)
+	conf := g.config()
+	conf.TrustedSources = []string{"10.0.0.1", "10.0.0.0/33", "localhost"}
+
+	_, err := NewClient(conf, nil, WithTrustedSourcesCheck(false))
+	if err == nil || !strings.Contains(err.Error(), `trusted source "10.0.0.0/33"`) {
+		t.Errorf("NewClient() error = %v, want the malformed entry reported", err)
+	}
+}
+
+func TestCallbackServeHTTPAuth(t *testing.T) {
+	tests := []struct {
+		name   string
+		verify CallbackVerifier
+		auth   func(r *http.Request)
+		want   int
+	}{
+		{
+			name:   "bearer token",
+			verify: CallbackBearerToken("s3cret-token"),
+			auth:   func(r *http.Request) { r.Header.Set("Authorization", "Bearer s3cret-token") },
+			want:   http.StatusOK,
+		},
+		{
+			name:   "wrong bearer token",
+			verify: CallbackBearerToken("s3cret-token"),
+			auth:   func(r *http.Request) { r.Header.Set("Authorization", "Bearer s3cret-tokem") },
+			want:   http.StatusUnauthorized,
+		},
+		{
+			name:   "missing bearer token",
+			verify: CallbackBearerToken("s3cret-token"),
+			auth:   func(r *http.Request) {},
+			want:   http.StatusUnauthorized,
+		},
+		{
+			name:   "basic auth",
+			verify: CallbackBasicAuth("mpesa", "s3cret-pass"),
+			auth:   func(r *http.Request) { r.SetBasicAuth("mpesa", "s3cret-pass") },
+			want:   http.StatusOK,
+		},
+		{
+			name:   "wrong basic auth",
+			verify: CallbackBasicAuth("mpesa", "s3cret-pass"),
+			auth:   func(r *http.Request) { r.SetBasicAuth("mpesa", "guess") },
+			want:   http.StatusUnauthorized,
+		},
+		{
+			name: "custom verifier",
+			verify: func(r *http.Request) error {
+				if r.Header.Get("X-Signature") != "ok" {
+					return errCallbackUnauthorized
+				}
+				return nil
+			},
+			auth: func(r *http.Request) { r.Header.Set("X-Signature", "nope") },
+			want: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGateway(t)
+			logs := new(bytes.Buffer)
+			called := false
+			handler := PushCallbackFunc(func(request PushCallbackRequest) (PushCallbackResponse, error) {
+				called = true
+				return PushCallbackResponse{ResponseCode: SUCCESS_CODE}, nil
+			})
+			c := g.client(WithCallbackHandler(handler), WithCallbackAuth(tt.verify), WithLogger(logs), WithDebugMode(true))
+
+			rec := httptest.NewRecorder()
+			r := newCallbackRequest(context.Background(), testCallbackBody)
+			tt.auth(r)
+			c.CallbackServeHTTP(rec, r)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if called != (tt.want == http.StatusOK) {
+				t.Errorf("handler called = %v", called)
+			}
+			if tt.want == http.StatusUnauthorized {
+				if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+					t.Errorf("Content-Type = %q, want application/json", ct)
+				}
+				if !strings.Contains(rec.Body.String(), `"error"`) {
+					t.Errorf("body = %s, want a JSON error", rec.Body)
+				}
+			}
+			if strings.Contains(logs.String(), "s3cret") {
+				t.Errorf("callback secret logged:\n%s", logs)
+			}
+		})
+	}
+}
+
+func TestCallbackServeHTTPRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		ctype   string
+		body    string
+		chunked bool
+		want    int
+	}{
+		{name: "get", method: http.MethodGet, ctype: "application/json", want: http.StatusMethodNotAllowed},
+		{name: "put", method: http.MethodPut, ctype: "application/json", body: testCallbackBody, want: http.StatusMethodNotAllowed},
+		{name: "options", method: http.MethodOptions, want: http.StatusNoContent},
+		{name: "form", method: http.MethodPost, ctype: "application/x-www-form-urlencoded", body: "a=b", want: http.StatusUnsupportedMediaType},
+		{name: "no content type", method: http.MethodPost, body: testCallbackBody, want: http.StatusUnsupportedMediaType},
+		{name: "too large", method: http.MethodPost, ctype: "application/json", body: strings.Repeat(" ", 2048) + testCallbackBody, want: http.StatusRequestEntityTooLarge},
+		{name: "too large without length", method: http.MethodPost, ctype: "application/json", body: strings.Repeat(" ", 2048) + testCallbackBody, chunked: true, want: http.StatusRequestEntityTooLarge},
+		{name: "json with charset", method: http.MethodPost, ctype: "application/json; charset=utf-8", body: testCallbackBody, want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGateway(t)
+			called := false
+			handler := PushCallbackFunc(func(request PushCallbackRequest) (PushCallbackResponse, error) {
+				called = true
+				return PushCallbackResponse{ResponseCode: SUCCESS_CODE}, nil
+			})
+			c := g.client(WithCallbackHandler(handler), WithCallbackBodyLimit(1024))
+
+			r := httptest.NewRequest(tt.method, "/callbacks/mpesa", strings.NewReader(tt.body))
+			if tt.ctype != "" {
+				r.Header.Set("Content-Type", tt.ctype)
+			}
+			if tt.chunked {
+				r.ContentLength = -1
+			}
+			rec := httptest.NewRecorder()
+			c.CallbackServeHTTP(rec, r)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if called != (tt.want == http.StatusOK) {
+				t.Errorf("handler called = %v", called)
+			}
+			if tt.want >= http.StatusBadRequest && rec.Header().Get("Content-Type") != "application/json" {
+				t.Errorf("Content-Type = %q, want a JSON error body", rec.Header().Get("Content-Type"))
+			}
+			if tt.want == http.StatusMethodNotAllowed || tt.want == http.StatusNoContent {
+				if allow := rec.Header().Get("Allow"); allow != "POST, OPTIONS" {
+					t.Errorf("Allow = %q, want POST, OPTIONS", allow)
+				}
+			}
+		})
+	}
+}
+
+func TestCallbackServeHTTPAcknowledgements(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		handlerErr error
+		wantStatus int
+		wantBody   map[string]string
+	}{
+		{
+			name:       "success",
+			body:       testCallbackBody,
+			wantStatus: http.StatusOK,
+			wantBody: map[string]string{
+				"output_OriginalConversationID":   "conv-1",
+				"output_ResponseCode":             "INS-0",
+				"output_ResponseDesc":             "Request processed successfully",
+				"output_ThirdPartyConversationID": "tp-1",
+			},
+		},
+		{
+			name:       "handler error",
+			body:       testCallbackBody,
+			handlerErr: errors.New("database is down"),
+			wantStatus: http.StatusInternalServerError,
+			wantBody: map[string]string{
+				"output_OriginalConversationID":   "conv-1",
+				"output_ResponseCode":             "INS-1",
+				"output_ResponseDesc":             "Internal Error",
+				"output_ThirdPartyConversationID": "tp-1",
+			},
+		},
+		{
+			name:       "wrong field type",
+			body:       `{"input_OriginalConversationID": "conv-1", "input_ResultCode": 0, "input_ThirdPartyConversationID": "tp-1"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody: map[string]string{
+				"output_OriginalConversationID":   "conv-1",
+				"output_ResponseCode":             "INS-21",
+				"output_ResponseDesc":             "Parameter validations failed. Please try again.",
+				"output_ThirdPartyConversationID": "tp-1",
+			},
+		},
+		{
+			name:       "malformed json",
+			body:       `{"input_OriginalConversationID": "conv-1",`,
+			wantStatus: http.StatusBadRequest,
+			wantBody: map[string]string{
+				"output_OriginalConversationID":   "",
+				"output_ResponseCode":             "INS-21",
+				"output_ResponseDesc":             "Parameter validations failed. Please try again.",
+				"output_ThirdPartyConversationID": "",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGateway(t)
+			handler := PushCallbackFunc(func(request PushCallbackRequest) (PushCallbackResponse, error) {
+				if tt.handlerErr != nil {
+					return PushCallbackResponse{}, tt.handlerErr
+				}
+				return PushCallbackResponse{
+					OriginalConversationID:   request.OriginalConversationID,
+					ResponseCode:             SUCCESS_CODE,
+					ResponseDesc:             "Request processed successfully",
+					ThirdPartyConversationID: request.ThirdPartyConversationID,
+				}, nil
+			})
+			c := g.client(WithCallbackHandler(handler))
+
+			rec := httptest.NewRecorder()
+			c.CallbackServeHTTP(rec, newCallbackRequest(context.Background(), tt.body))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", ct)
+			}
+			var got map[string]string
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decode acknowledgement %s: %v", rec.Body, err)
+			}
+			if !reflect.DeepEqual(got, tt.wantBody) {
+				t.Errorf("acknowledgement = %v, want %v", got, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestDisburseCallbackServeHTTP(t *testing.T) {
+	const payload = `{
+		"input_OriginalConversationID": "fd1e9143d22544459f7c66e1860ef276",
+		"input_TransactionID": "49XCDF6",
+		"input_ResultCode": "INS-0",
+		"input_ResultDesc": "Request processed successfully",
+		"input_ThirdPartyConversationID": "1e9b774d1da34af78412a498cbc28f5e",
+		"input_TransactionStatus": "Completed",
+		"input_Amount": "10.00",
+		"input_CustomerMSISDN": "255744553111",
+		"input_TransactionTime": "20211231143000"
+	}`
+
+	g := newTestGateway(t)
+	var got DisburseCallbackRequest
+	handler := DisburseCallbackFunc(func(ctx context.Context, request DisburseCallbackRequest) (DisburseCallbackResponse, error) {
+		got = request
+		return DisburseCallbackResponse{
+			OriginalConversationID:   request.OriginalConversationID,
+			ResponseCode:             SUCCESS_CODE,
+			ResponseDesc:             "Request processed successfully",
+			ThirdPartyConversationID: request.ThirdPartyConversationID,
+		}, nil
+	})
+	c := g.client(WithDisburseCallbackHandler(handler))
+
+	rec := httptest.NewRecorder()
+	c.DisburseCallbackServeHTTP(rec, newCallbackRequest(context.Background(), payload))
+
+	want := DisburseCallbackRequest{
+		OriginalConversationID:   "fd1e9143d22544459f7c66e1860ef276",
+		TransactionID:            "49XCDF6",
+		ResultCode:               "INS-0",
+		ResultDesc:               "Request processed successfully",
+		ThirdPartyConversationID: "1e9b774d1da34af78412a498cbc28f5e",
+		TransactionStatus:        "Completed",
+		Amount:                   MustParseAmount("10.00"),
+		CustomerMSISDN:           "255744553111",
+		TransactionTime:          "20211231143000",
+	}
+	if got != want {
+		t.Errorf("request = %+v, want %+v", got, want)
+	}
+
+	var ack DisburseCallbackResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &ack); err != nil {
+		t.Fatalf("decode acknowledgement %s: %v", rec.Body, err)
+	}
+	if rec.Code != http.StatusOK || ack.ResponseCode != SUCCESS_CODE || ack.OriginalConversationID != want.OriginalConversationID {
+		t.Errorf("acknowledgement = %d %+v", rec.Code, ack)
+	}
+}
+
+func TestCallbackServeHTTPWithoutHandler(t *testing.T) {
+	c := newTestGateway(t).client()
+
+	for name, serve := range map[string]http.HandlerFunc{
+		"push":     c.CallbackServeHTTP,
+		"disburse": c.DisburseCallbackServeHTTP,
+	} {
+		rec := httptest.NewRecorder()
+		serve(rec, newCallbackRequest(context.Background(), testCallbackBody))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want 404", name, rec.Code)
+		}
+	}
+}
+
+func TestRegisterRoutes(t *testing.T) {
+	g := newTestGateway(t)
+	push := PushCallbackFunc(func(request PushCallbackRequest) (PushCallbackResponse, error) {
+		return PushCallbackResponse{ResponseCode: SUCCESS_CODE}, nil
+	})
+	c := g.client(WithCallbackHandler(push))
+
+	mux := http.NewServeMux()
+	routes := c.RegisterRoutes(mux, "callbacks/mpesa/")
+
+	want := []CallbackRoute{
+		{Name: "push", Path: "/callbacks/mpesa/push"},
+		{Name: "disburse", Path: "/callbacks/mpesa/disburse"},
+	}
+	if !reflect.DeepEqual(routes, want) {
+		t.Fatalf("RegisterRoutes() = %+v, want %+v", routes, want)
+	}
+
+	for path, status := range map[string]int{
+		"/callbacks/mpesa/push":     http.StatusOK,
+		"/callbacks/mpesa/disburse": http.StatusNotFound,
+		"/callbacks/mpesa/b2b":      http.StatusNotFound,
+	} {
+		rec := httptest.NewRecorder()
+		r := newCallbackRequest(context.Background(), testCallbackBody)
+		r.URL.Path = path
+		mux.ServeHTTP(rec, r)
+		if rec.Code != status {
+			t.Errorf("%s: status = %d, want %d", path, rec.Code, status)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/techcraftlabs/base"
+)
+
+// ErrReservedHeader is returned when WithHeaders or WithDefaultHeaders sets a
+// header the client sets itself, Authorization or Content-Type.
+var ErrReservedHeader = errors.New("mpesa: reserved header")
+
+type (
+	// CallOption customizes the requests made with a context, see
+	// ContextWithCallOptions.
+	CallOption func(options *callOptions)
+
+	callOptions struct {
+		headers        map[string]string
+		requestOptions []base.RequestOption
+		err            error
+	}
+
+	callOptionsKey struct{}
+)
+
+// ContextWithCallOptions returns a copy of ctx carrying opts, which apply to
+// every request sent to the gateway with it, including the session request it
+// may trigger. The options already carried by ctx are kept and opts are applied
+// after them.
+//
+// The options travel with the context rather than as arguments so that the
+// methods of Service keep their signatures:
+//
+//	ctx = mpesa.ContextWithCallOptions(ctx, mpesa.WithHeaders(map[string]string{"X-Tenant": "acme"}))
+//	response, err := client.Disburse(ctx, request)
+func ContextWithCallOptions(ctx context.Context, opts ...CallOption) context.Context {
+	current := callOptionsFrom(ctx)
+	options := &callOptions{
+		headers:        make(map[string]string, len(current.headers)),
+		requestOptions: append([]base.RequestOption(nil), current.requestOptions...),
+		err:            current.err,
+	}
+	for name, value := range current.headers {
+		options.headers[name] = value
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	return context.WithValue(ctx, callOptionsKey{}, options)
+}
+
+// WithHeaders adds headers to the requests of the call. They take precedence
+// over the headers of the client, WithDefaultHeaders included. Setting the
+// Authorization or Content-Type header makes the call fail with
+// ErrReservedHeader before anything is sent.
+func WithHeaders(headers map[string]string) CallOption {
+	return func(options *callOptions) {
+		if err := checkHeaders(headers); err != nil {
+			if options.err == nil {
+				options.err = err
+			}
+			return
+		}
+
+		for name, value := range headers {
+			options.headers[http.CanonicalHeaderKey(name)] = value
+		}
+	}
+}
+
+// WithBaseRequestOption applies opt to the requests of the call after the
+// client built them. It is not checked: an option replacing the headers also
+// replaces the Authorization header, so prefer WithHeaders for headers.
+func WithBaseRequestOption(opt base.RequestOption) CallOption {
+	return func(options *callOptions) {
+		if opt != nil {
+			options.requestOptions = append(options.requestOptions, opt)
+		}
+	}
+}
+
+// callOptionsFrom returns the call options of ctx, empty ones when there are
+// none.
+func callOptionsFrom(ctx context.Context) *callOptions {
+	if options, ok := ctx.Value(callOptionsKey{}).(*callOptions); ok {
+		return options
+	}
+
+	return &callOptions{}
+}
+
+// checkHeaders rejects the headers the client sets itself.
+func checkHeaders(headers map[string]string) error {
+	for name := range headers {
+		switch http.CanonicalHeaderKey(name) {
+		case "Authorization", "Content-Type":
+			return fmt.Errorf("%w: %s is set by the client", ErrReservedHeader, http.CanonicalHeaderKey(name))
+		}
+	}
+
+	return nil
+}
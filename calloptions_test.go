@@ -0,0 +1,81 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/techcraftlabs/base"
+)
+
+func TestCallOptions(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["b2cPayment/"] = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusCreated, DisburseResponse{ResponseCode: "INS-0"})
+	}
+	tenants := recordHeader(g, "b2cPayment/", "X-Tenant")
+	regions := recordHeader(g, "b2cPayment/", "X-Region")
+	sessionTenants := recordHeader(g, "getSession/", "X-Tenant")
+	c := g.client(WithDefaultHeaders(map[string]string{"x-tenant": "default", "X-Region": "eu"}))
+
+	ctx := ContextWithCallOptions(context.Background(), WithHeaders(map[string]string{"X-Tenant": "acme"}))
+	if _, err := c.Disburse(ctx, testRequest("").DisburseRequest()); err != nil {
+		t.Fatalf("Disburse() error = %v", err)
+	}
+	if _, err := c.Disburse(context.Background(), testRequest("").DisburseRequest()); err != nil {
+		t.Fatalf("Disburse() error = %v", err)
+	}
+
+	if got := tenants(); len(got) != 2 || got[0] != "acme" || got[1] != "default" {
+		t.Errorf("X-Tenant = %q, want the call header then the default one", got)
+	}
+	if got := regions(); len(got) != 2 || got[0] != "eu" || got[1] != "eu" {
+		t.Errorf("X-Region = %q, want the default header kept", got)
+	}
+	if got := sessionTenants(); len(got) != 1 || got[0] != "acme" {
+		t.Errorf("session X-Tenant = %q, want the header of the call that fetched it", got)
+	}
+}
+
+func TestCallOptionsBaseRequestOption(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["queryTransactionStatus/"] = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, QueryTxResponse{ResponseCode: "INS-0"})
+	}
+	traces := recordHeader(g, "queryTransactionStatus/", "X-Trace")
+	c := g.client()
+
+	ctx := ContextWithCallOptions(context.Background(),
+		WithBaseRequestOption(base.WithMoreHeaders(map[string]string{"X-Trace": "t-1"})))
+	if _, err := c.QueryTx(ctx, QueryTxParams{Reference: "ref"}); err != nil {
+		t.Fatalf("QueryTx() error = %v", err)
+	}
+	if got := traces(); len(got) != 1 || got[0] != "t-1" {
+		t.Errorf("X-Trace = %q, want the header of the request option", got)
+	}
+}
+
+func TestCallOptionsReservedHeaders(t *testing.T) {
+	g := newTestGateway(t)
+	var sent int32
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&sent, 1)
+		writeJSON(w, http.StatusCreated, PushAsyncResponse{ResponseCode: "INS-0"})
+	}
+	c := g.client()
+
+	ctx := ContextWithCallOptions(context.Background(), WithHeaders(map[string]string{"authorization": "Bearer forged"}))
+	if _, err := c.PushAsync(ctx, testRequest("").PushRequest()); !errors.Is(err, ErrReservedHeader) {
+		t.Errorf("PushAsync() error = %v, want ErrReservedHeader", err)
+	}
+	if n := atomic.LoadInt32(&sent) + atomic.LoadInt32(&g.sessions); n != 0 {
+		t.Errorf("requests = %d, want nothing sent", n)
+	}
+
+	var configErr *ConfigError
+	if _, err := NewClient(g.config(), nil, WithDefaultHeaders(map[string]string{"Content-Type": "text/plain"})); !errors.As(err, &configErr) {
+		t.Errorf("NewClient(WithDefaultHeaders()) error = %v, want a *ConfigError", err)
+	}
+}
@@ -16,6 +16,12 @@ const (
 	pushPay
 	disburse
 	queryTxn
+	b2bPay
+	directDebitCreate
+	directDebitPay
+	directDebitCancel
+	directDebitQuery
+	beneficiaryName
 )
 
 type (
@@ -37,6 +43,24 @@ func (r requestType) Endpoint() string {
 	case queryTxn:
 		return "/queryTransactionStatus/"
 
+	case b2bPay:
+		return "/b2bPayment/"
+
+	case directDebitCreate:
+		return "/directDebitCreation/"
+
+	case directDebitPay:
+		return "/directDebitPayment/"
+
+	case directDebitCancel:
+		return "/directDebitCancel/"
+
+	case directDebitQuery:
+		return "/queryDirectDebit/"
+
+	case beneficiaryName:
+		return "/queryBeneficiaryName/"
+
 	default:
 		return ""
 	}
@@ -51,6 +75,9 @@ func (r requestType) Method() string {
 	case pushPay:
 		return http.MethodPost
 
+	case queryTxn, directDebitQuery:
+		return http.MethodGet
+
 	default:
 		return http.MethodPost
 
@@ -59,7 +86,9 @@ func (r requestType) Method() string {
 
 func (r requestType) Name() string {
 	return []string{"get session id", "ussd push",
-		"disbursement"}[r]
+		"disbursement", "query transaction status", "b2b payment",
+		"direct debit creation", "direct debit payment", "direct debit cancellation",
+		"query direct debit", "query beneficiary name"}[r]
 }
 
 func (r requestType) MNO() string {
@@ -77,6 +106,15 @@ func (r requestType) Group() string {
 	case disburse:
 		return "disbursement"
 
+	case queryTxn, beneficiaryName:
+		return "query"
+
+	case b2bPay:
+		return "b2b"
+
+	case directDebitCreate, directDebitPay, directDebitCancel, directDebitQuery:
+		return "direct debit"
+
 	default:
 		return ""
 	}
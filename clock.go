@@ -0,0 +1,35 @@
+package mpesa
+
+import "time"
+
+// Clock is the source of time of a Client. The session expiry, the session
+// refresh margin, the cached secrets and the waits between retries, polls and
+// refreshes all read it, so that tests can move the time forward instead of
+// sleeping.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// After returns a channel receiving the time once d has elapsed.
+	After(d time.Duration) <-chan time.Time
+}
+
+// WithClock sets the Clock of the client, the system clock by default.
+func WithClock(clock Clock) ClientOption {
+	return func(client *Client) {
+		if clock == nil {
+			client.optionErrs = append(client.optionErrs, "clock is nil")
+			return
+		}
+		client.clock = clock
+	}
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
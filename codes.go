@@ -10,15 +10,25 @@ const SUCCESS_CODE = "INS-0"
 var responseCodes = map[string]string{ //nolint:gochecknoglobals
 	"INS-0":    "Request processed successfully",
 	"INS-1":    "Internal Error",
+	"INS-2":    "Invalid API Key",
+	"INS-4":    "User is not active",
+	"INS-5":    "Transaction cancelled by customer",
 	"INS-6":    "Transaction Failed",
 	"INS-9":    "Request timeout",
 	"INS-10":   "Duplicate Transaction",
 	"INS-13":   "Invalid Shortcode Used",
+	"INS-14":   "Invalid Reference Used",
 	"INS-15":   "Invalid Amount Used",
+	"INS-16":   "Unable to handle the request due to a temporary overloading",
 	"INS-17":   "Invalid Transaction Reference. Length Should Be Between 1 and 20.",
 	"INS-18":   "Invalid TransactionID Used",
+	"INS-19":   "Invalid ThirdPartyReference Used",
 	"INS-20":   "Not All Parameters Provided. Please try again.",
 	"INS-21":   "Parameter validations failed. Please try again.",
+	"INS-22":   "Invalid Operation Type",
+	"INS-23":   "Unknown Status. Contact M-Pesa Support",
+	"INS-24":   "Invalid InitiatorIdentifier Used",
+	"INS-25":   "Invalid SecurityCredential Used",
 	"INS-26":   "Invalid Currency Used",
 	"INS-28":   "Invalid ThirdPartyConversationID Used",
 	"INS-30":   "Invalid Purchased Items Description Used",
@@ -39,8 +49,24 @@ var responseCodes = map[string]string{ //nolint:gochecknoglobals
 	"INS-996":  "API Being Used Outside Of Usage Time",
 	"INS-997":  "API Not Enabled",
 	"INS-998":  "Invalid Market",
+	"INS-2001": "Initiator authentication error.",
+	"INS-2002": "Receiver invalid.",
 	"INS-2006": "Insufficient balance",
 	"INS-2051": "MSISDN invalid.",
+	"INS-2057": "Language code invalid.",
+}
+
+// codeErrors maps the response codes that callers commonly need to tell apart
+// to the sentinel errors an *APIError with that code matches in errors.Is.
+var codeErrors = map[string]error{ //nolint:gochecknoglobals
+	"INS-2":    ErrCredentialsRejected,
+	"INS-4":    ErrCredentialsRejected,
+	"INS-10":   ErrDuplicateTransaction,
+	"INS-16":   ErrRateLimited,
+	"INS-2006": ErrInsufficientBalance,
+	"INS-2002": ErrInvalidCustomer,
+	"INS-2051": ErrInvalidCustomer,
+	"INS-2001": ErrCredentialsRejected,
 }
 
 type codes struct {
@@ -80,7 +106,49 @@ func getInstance() *codes {
 	return singleInstance
 }
 
-// ResponseCode returns the description of a response code
-func ResponseCode(code string) string {
-	return getInstance().get(code)
+// ResponseCode is the output_ResponseCode sent back by the gateway, e.g. "INS-0".
+// Codes missing from the catalogue are kept as they are and get a generic
+// description.
+type ResponseCode string
+
+// ResponseDescription returns the description of a response code.
+//
+// Deprecated: ResponseCode used to be this function and is now a type, use
+// ResponseCode(code).Description().
+func ResponseDescription(code string) string {
+	return ResponseCode(code).Description()
+}
+
+// Description returns the documented meaning of the code or "unknown code".
+func (c ResponseCode) Description() string {
+	return getInstance().get(string(c))
+}
+
+// IsSuccess reports whether the request was processed successfully.
+func (c ResponseCode) IsSuccess() bool {
+	return c == SUCCESS_CODE
+}
+
+// IsRetryable reports whether the failure is transient so that sending the same
+// request again may succeed: an internal error, a timeout or a temporary
+// overload. A duplicate transaction (INS-10) is not retryable, it means the
+// first attempt was already received.
+func (c ResponseCode) IsRetryable() bool {
+	switch c {
+	case "INS-1", "INS-9", "INS-16":
+		return true
+	default:
+		return false
+	}
+}
+
+// IsAuthFailure reports whether the gateway rejected the credentials: an invalid
+// API key, an inactive user or a failed initiator authentication.
+func (c ResponseCode) IsAuthFailure() bool {
+	switch c {
+	case "INS-2", "INS-4", "INS-2001":
+		return true
+	default:
+		return false
+	}
 }
@@ -1,6 +1,8 @@
 package mpesa
 
 import (
+	"encoding/json"
+	"strings"
 	"sync"
 	"testing"
 )
@@ -21,11 +23,21 @@ func TestResponseCode(t *testing.T) {
 			},
 			want: "Request processed successfully",
 		},
+		{
+			name: "test unknown code",
+			args: args{
+				code: "INS-12345",
+			},
+			want: "unknown code",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := ResponseCode(tt.args.code); got != tt.want {
-				t.Errorf("ResponseCode() = %v, want %v", got, tt.want)
+			if got := ResponseCode(tt.args.code).Description(); got != tt.want {
+				t.Errorf("Description() = %v, want %v", got, tt.want)
+			}
+			if got := ResponseDescription(tt.args.code); got != tt.want {
+				t.Errorf("ResponseDescription() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -54,8 +66,8 @@ func TestResponseCodeConc(t *testing.T) {
 		v := responseCodes[k]
 		go func(code, value string) {
 
-			if got := ResponseCode(code); got != value {
-				t.Errorf("ResponseCode() = %v, want %v", got, value)
+			if got := ResponseCode(code).Description(); got != value {
+				t.Errorf("Description() = %v, want %v", got, value)
 			}
 
 			t.Logf("ResponseCode() = %v\n", value)
@@ -64,3 +76,77 @@ func TestResponseCodeConc(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestResponseCodePredicates(t *testing.T) {
+	tests := []struct {
+		code        ResponseCode
+		success     bool
+		retryable   bool
+		authFailure bool
+	}{
+		{code: "INS-0", success: true},
+		{code: "INS-1", retryable: true},
+		{code: "INS-2", authFailure: true},
+		{code: "INS-4", authFailure: true},
+		{code: "INS-9", retryable: true},
+		{code: "INS-10"},
+		{code: "INS-16", retryable: true},
+		{code: "INS-2001", authFailure: true},
+		{code: "INS-2006"},
+		{code: "INS-12345"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.code), func(t *testing.T) {
+			if got := tt.code.IsSuccess(); got != tt.success {
+				t.Errorf("IsSuccess() = %v, want %v", got, tt.success)
+			}
+			if got := tt.code.IsRetryable(); got != tt.retryable {
+				t.Errorf("IsRetryable() = %v, want %v", got, tt.retryable)
+			}
+			if got := tt.code.IsAuthFailure(); got != tt.authFailure {
+				t.Errorf("IsAuthFailure() = %v, want %v", got, tt.authFailure)
+			}
+		})
+	}
+}
+
+func TestResponseCodeRoundTrip(t *testing.T) {
+	var res PushAsyncResponse
+	if err := json.Unmarshal([]byte(`{"output_ResponseCode":"INS-4242"}`), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.ResponseCode != "INS-4242" {
+		t.Errorf("ResponseCode = %q, want INS-4242", res.ResponseCode)
+	}
+
+	b, err := json.Marshal(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"output_ResponseCode":"INS-4242"`) {
+		t.Errorf("json.Marshal() = %s, want the code unchanged", b)
+	}
+}
+
+func TestResponseCodeAccessors(t *testing.T) {
+	// the fields stay strings, so that existing callers keep compiling
+	code := "INS-2006"
+	tests := []struct {
+		name string
+		got  ResponseCode
+	}{
+		{name: "session", got: SessionResponse{Code: code}.ResponseCode()},
+		{name: "push", got: PushAsyncResponse{ResponseCode: code}.Code()},
+		{name: "disburse", got: DisburseResponse{ResponseCode: code}.Code()},
+		{name: "query", got: QueryTxResponse{ResponseCode: code}.Code()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != "INS-2006" || tt.got.Description() != "Insufficient balance" {
+				t.Errorf("code = %q (%s), want INS-2006", tt.got, tt.got.Description())
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package mpesa
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigError is returned by NewClient when the Config is incomplete or
+// invalid. It lists every problem found rather than just the first one:
+// Problems describes them and Fields holds them as *FieldError, errors.As
+// matches the first one with a *FieldError target and Unwrap returns them all.
+type ConfigError struct {
+	Problems []string
+	Fields   []*FieldError
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("invalid mpesa config: %s", strings.Join(e.Problems, "; "))
+}
+
+// Unwrap returns the *FieldError of every problem.
+func (e *ConfigError) Unwrap() []error {
+	return fieldErrors(e.Fields)
+}
+
+// As sets a *FieldError target to the first problem.
+func (e *ConfigError) As(target interface{}) bool {
+	fieldErr, ok := target.(**FieldError)
+	if !ok || len(e.Fields) == 0 {
+		return false
+	}
+	*fieldErr = e.Fields[0]
+
+	return true
+}
+
+// clone returns a deep copy of conf so that a Client never shares mutable
+// state with the caller.
+func (conf *Config) clone() *Config {
+	cp := *conf
+	if conf.Endpoints != nil {
+		edps := *conf.Endpoints
+		cp.Endpoints = &edps
+	}
+	if conf.TrustedSources != nil {
+		cp.TrustedSources = append([]string(nil), conf.TrustedSources...)
+	}
+
+	return &cp
+}
+
+// validate checks that conf has everything needed to talk to the gateway.
+func (conf *Config) validate() error {
+	var v validation
+	conf.check(&v)
+	conf.checkCredentials(&v, true)
+	if conf.BasePath == "" {
+		v.add("BasePath", RuleRequired, "is required")
+	}
+
+	return newConfigError(v.fields)
+}
+
+// validate checks the client Config together with the errors recorded by the
+// options. BasePath is not required when a base URL was set with WithBaseURL,
+// PublicKey when a key was set with WithRSAPublicKey, nor the credentials when
+// they are supplied by WithSecretsProvider.
+func (c *Client) validate() error {
+	var v validation
+	for _, problem := range c.optionErrs {
+		v.add("", RuleInvalid, "%s", problem)
+	}
+	if nilHandler(c.pushCallbackFunc) {
+		v.add("", RuleRequired, "push callback handler is a nil function")
+	}
+	if fn, ok := c.disburseCallbackFunc.(DisburseCallbackFunc); ok && fn == nil {
+		v.add("", RuleRequired, "disburse callback handler is a nil function")
+	}
+	c.Conf.check(&v)
+	if c.secretsProvider == nil {
+		c.Conf.checkCredentials(&v, c.publicKey == nil)
+	}
+	lifetime := clampSessionLifetime(c.Conf.sessionLifetime())
+	if c.sessionMargin < 0 {
+		v.add("", RuleRange, "session refresh margin must not be negative, got %s", c.sessionMargin)
+	} else if lifetime > 0 && lifetime <= c.sessionMargin {
+		v.add("", RuleRange, "session lifetime of %s must be longer than the session refresh margin of %s",
+			lifetime, c.sessionMargin)
+	}
+	if c.refresher != nil {
+		if margin := c.refresher.margin; margin <= 0 {
+			v.add("", RuleRange, "auto session refresh margin must be positive, got %s", margin)
+		} else if lifetime > 0 && lifetime <= margin+margin/2+c.sessionMargin {
+			v.add("", RuleRange, "session lifetime of %s must be longer than the auto session refresh margin of %s "+
+				"plus half of it for the jitter and the session refresh margin of %s", lifetime, margin, c.sessionMargin)
+		}
+	}
+	if c.Conf.BasePath == "" && c.baseURL == "" {
+		v.add("BasePath", RuleRequired, "is required")
+	}
+
+	return newConfigError(v.fields)
+}
+
+// newConfigError returns the *ConfigError listing fields, nil when there are
+// none.
+func newConfigError(fields []*FieldError) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	problems := make([]string, len(fields))
+	for i, field := range fields {
+		problems[i] = field.problem()
+	}
+
+	return &ConfigError{Problems: problems, Fields: fields}
+}
+
+// checkCredentials adds what is missing or invalid in APIKey and, when
+// publicKey is true, in PublicKey to v.
+func (conf *Config) checkCredentials(v *validation, publicKey bool) {
+	if conf.APIKey == "" {
+		v.add("APIKey", RuleRequired, "is required")
+	}
+	if !publicKey {
+		return
+	}
+
+	if conf.PublicKey == "" {
+		v.add("PublicKey", RuleRequired, "is required")
+	} else if _, err := parsePublicKey(conf.PublicKey); err != nil {
+		v.add("PublicKey", RuleFormat, "is invalid: %v", err)
+	}
+}
+
+// check adds what is missing or invalid in conf to v, apart from BasePath and
+// the credentials whose requirements depend on the client options.
+func (conf *Config) check(v *validation) {
+	if conf.Market.Country() == "" {
+		v.add("Market", RuleUnsupported, "%d is not supported", conf.Market)
+	}
+
+	if conf.Platform != SANDBOX && conf.Platform != OPENAPI {
+		v.add("Platform", RuleUnsupported, "%d is not supported", conf.Platform)
+	}
+
+	if conf.ServiceProvideCode == "" {
+		v.add("ServiceProvideCode", RuleRequired, "is required")
+	} else if n := len(conf.ServiceProvideCode); !isNumeric(conf.ServiceProvideCode) || n < 4 || n > 12 {
+		v.add("ServiceProvideCode", RuleFormat, "must be 4 to 12 digits, got %q", conf.ServiceProvideCode)
+	}
+
+	if conf.SessionLifetime < 0 {
+		v.add("SessionLifetime", RuleRange, "must be positive, got %s", conf.SessionLifetime)
+	}
+	if conf.SessionLifetimeMinutes < 0 {
+		v.add("SessionLifetimeMinutes", RuleRange, "must not be negative, got %d", conf.SessionLifetimeMinutes)
+	}
+
+	if _, err := parseTrustedSources(conf.TrustedSources); err != nil {
+		v.add("TrustedSources", RuleFormat, "are invalid: %v", err)
+	}
+}
+
+// LoadConfig reads the Config stored in the JSON or YAML file at path. The
+// format is picked from the extension: .json, .yaml or .yml. See ReadConfig for
+// the defaults applied and the validation done. Errors are prefixed with path.
+func LoadConfig(path string) (*Config, error) {
+	var format string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		format = "json"
+	case ".yaml", ".yml":
+		format = "yaml"
+	default:
+		return nil, fmt.Errorf("%s: unknown config format, want a .json, .yaml or .yml file", path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	conf, err := ReadConfig(f, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return conf, nil
+}
+
+// ReadConfig decodes a Config from r in format, "json" or "yaml". Unknown fields
+// are rejected. Missing endpoints are filled in from DefaultEndpoints and a
+// missing session lifetime defaults to DefaultSessionLifetimeMinutes. The
+// Config is then validated the same way NewClient does.
+func ReadConfig(r io.Reader, format string) (*Config, error) {
+	conf := new(Config)
+
+	switch strings.ToLower(format) {
+	case "json":
+		dec := json.NewDecoder(r)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(conf); err != nil {
+			return nil, fmt.Errorf("decode json config: %w", err)
+		}
+
+	case "yaml", "yml":
+		dec := yaml.NewDecoder(r)
+		dec.KnownFields(true)
+		if err := dec.Decode(conf); err != nil {
+			return nil, fmt.Errorf("decode yaml config: %w", err)
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown config format %q, want json or yaml", format)
+	}
+
+	if err := conf.applyDefaults(); err != nil {
+		return nil, err
+	}
+
+	if err := conf.validate(); err != nil {
+		return nil, err
+	}
+
+	return conf, nil
+}
+
+// sessionLifetime returns SessionLifetime, or SessionLifetimeMinutes when it is
+// not set, or DefaultSessionLifetimeMinutes when neither is set.
+func (conf *Config) sessionLifetime() time.Duration {
+	switch {
+	case conf.SessionLifetime != 0:
+		return conf.SessionLifetime
+	case conf.SessionLifetimeMinutes != 0:
+		return time.Duration(conf.SessionLifetimeMinutes) * time.Minute
+	default:
+		return time.Duration(DefaultSessionLifetimeMinutes) * time.Minute
+	}
+}
+
+// applyDefaults fills the endpoints that are not set from DefaultEndpoints and
+// sets the default session lifetime.
+func (conf *Config) applyDefaults() error {
+	if conf.SessionLifetime == 0 && conf.SessionLifetimeMinutes == 0 {
+		conf.SessionLifetimeMinutes = DefaultSessionLifetimeMinutes
+	}
+
+	defaults, err := DefaultEndpoints(conf.Market, conf.Platform)
+	if err != nil {
+		return err
+	}
+
+	if conf.Endpoints == nil {
+		conf.Endpoints = defaults
+		return nil
+	}
+
+	fields := []struct{ value, fallback *string }{
+		{&conf.Endpoints.AuthEndpoint, &defaults.AuthEndpoint},
+		{&conf.Endpoints.PushEndpoint, &defaults.PushEndpoint},
+		{&conf.Endpoints.DisburseEndpoint, &defaults.DisburseEndpoint},
+		{&conf.Endpoints.QueryEndpoint, &defaults.QueryEndpoint},
+		{&conf.Endpoints.B2BEndpoint, &defaults.B2BEndpoint},
+		{&conf.Endpoints.DirectDebitCreateEndpoint, &defaults.DirectDebitCreateEndpoint},
+		{&conf.Endpoints.DirectDebitPayEndpoint, &defaults.DirectDebitPayEndpoint},
+		{&conf.Endpoints.DirectDebitCancelEndpoint, &defaults.DirectDebitCancelEndpoint},
+		{&conf.Endpoints.DirectDebitQueryEndpoint, &defaults.DirectDebitQueryEndpoint},
+		{&conf.Endpoints.BeneficiaryNameEndpoint, &defaults.BeneficiaryNameEndpoint},
+	}
+	for _, f := range fields {
+		if *f.value == "" {
+			*f.value = *f.fallback
+		}
+	}
+
+	return nil
+}
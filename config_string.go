@@ -0,0 +1,106 @@
+package mpesa
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"time"
+)
+
+// apiKeyPrefix is the number of leading characters of the API key kept by
+// Config.Redacted, for keys long enough that it leaves most of them hidden.
+const apiKeyPrefix = 4
+
+// RedactedConfig is a Config safe to log, see Config.Redacted. It marshals to
+// JSON with the field names of Config.
+type RedactedConfig struct {
+	Name                string        `json:"name,omitempty"`
+	Version             string        `json:"version,omitempty"`
+	Market              Market        `json:"market"`
+	Platform            Platform      `json:"platform"`
+	BasePath            string        `json:"base_path"`
+	Endpoints           *Endpoints    `json:"endpoints,omitempty"`
+	SessionLifetime     time.Duration `json:"session_lifetime"`
+	ServiceProviderCode string        `json:"service_provider_code"`
+	TrustedSources      []string      `json:"trusted_sources,omitempty"`
+	APIKey              string        `json:"api_key"`
+	PublicKey           string        `json:"public_key"`
+}
+
+// Redacted returns conf without its secrets, e.g. to log the configuration at
+// startup: the API key is masked but for its first characters and its length,
+// and the public key is replaced by the SHA-256 fingerprint of its DER
+// encoding. SessionLifetime is the lifetime in effect, whichever field sets it.
+//
+// json.Marshal of a Config keeps the secrets, it is the format read by
+// LoadConfig, marshal the RedactedConfig to log it as JSON.
+func (conf Config) Redacted() RedactedConfig {
+	r := RedactedConfig{
+		Name:                conf.Name,
+		Version:             conf.Version,
+		Market:              conf.Market,
+		Platform:            conf.Platform,
+		BasePath:            conf.BasePath,
+		SessionLifetime:     conf.sessionLifetime(),
+		ServiceProviderCode: conf.ServiceProvideCode,
+		APIKey:              maskAPIKey(conf.APIKey),
+		PublicKey:           publicKeyFingerprint(conf.PublicKey),
+	}
+	if conf.Endpoints != nil {
+		endpoints := *conf.Endpoints
+		r.Endpoints = &endpoints
+	}
+	if conf.TrustedSources != nil {
+		r.TrustedSources = append([]string(nil), conf.TrustedSources...)
+	}
+
+	return r
+}
+
+// String formats conf like %+v does, with the secrets redacted as in Redacted.
+func (conf Config) String() string {
+	r := conf.Redacted()
+	var endpoints Endpoints
+	if r.Endpoints != nil {
+		endpoints = *r.Endpoints
+	}
+
+	return fmt.Sprintf("{Name:%s Version:%s Market:%s Platform:%s BasePath:%s Endpoints:%+v SessionLifetime:%s "+
+		"ServiceProviderCode:%s TrustedSources:%v APIKey:%s PublicKey:%s}",
+		r.Name, r.Version, r.Market, r.Platform, r.BasePath, endpoints, r.SessionLifetime,
+		r.ServiceProviderCode, r.TrustedSources, r.APIKey, r.PublicKey)
+}
+
+// maskAPIKey keeps the first characters of key and its length. A key too short
+// to keep anything hidden is reduced to its length.
+func maskAPIKey(key string) string {
+	switch {
+	case key == "":
+		return ""
+	case len(key) <= 2*apiKeyPrefix:
+		return fmt.Sprintf("****(%d chars)", len(key))
+	default:
+		return fmt.Sprintf("%s****(%d chars)", key[:apiKeyPrefix], len(key))
+	}
+}
+
+// publicKeyFingerprint returns the SHA-256 fingerprint of the DER encoding of
+// the public key, in the format of ssh-keygen -l.
+func publicKeyFingerprint(publicKey string) string {
+	if publicKey == "" {
+		return ""
+	}
+
+	key, err := parsePublicKey(publicKey)
+	if err != nil {
+		return fmt.Sprintf("invalid (%d chars)", len(publicKey))
+	}
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return fmt.Sprintf("invalid (%d chars)", len(publicKey))
+	}
+	sum := sha256.Sum256(der)
+
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
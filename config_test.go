@@ -0,0 +1,244 @@
+package mpesa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestConfigValidate(t *testing.T) {
+	g := newTestGateway(t)
+
+	tests := []struct {
+		name   string
+		modify func(conf *Config)
+		want   string
+	}{
+		{name: "valid", modify: func(conf *Config) {}},
+		{name: "missing api key", modify: func(conf *Config) { conf.APIKey = "" }, want: "APIKey is required"},
+		{name: "missing public key", modify: func(conf *Config) { conf.PublicKey = "" }, want: "PublicKey is required"},
+		{name: "invalid public key", modify: func(conf *Config) { conf.PublicKey = "not-a-key" }, want: "PublicKey is invalid"},
+		{name: "missing base path", modify: func(conf *Config) { conf.BasePath = "" }, want: "BasePath is required"},
+		{name: "unknown market", modify: func(conf *Config) { conf.Market = Market(99) }, want: "Market 99 is not supported"},
+		{name: "unknown platform", modify: func(conf *Config) { conf.Platform = Platform(99) }, want: "Platform 99 is not supported"},
+		{name: "missing service provider code", modify: func(conf *Config) { conf.ServiceProvideCode = "" }, want: "ServiceProvideCode is required"},
+		{name: "non numeric service provider code", modify: func(conf *Config) { conf.ServiceProvideCode = "ORG001" }, want: "ServiceProvideCode must be 4 to 12 digits"},
+		{name: "negative lifetime", modify: func(conf *Config) { conf.SessionLifetimeMinutes = -1 }, want: "SessionLifetimeMinutes must not be negative"},
+		{name: "negative lifetime duration", modify: func(conf *Config) { conf.SessionLifetime = -time.Hour }, want: "SessionLifetime must be positive"},
+		{name: "default endpoints", modify: func(conf *Config) { conf.Endpoints = nil }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := g.config()
+			tt.modify(conf)
+
+			_, err := NewClient(conf, nil, WithDebugMode(false))
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("NewClient() error = %v", err)
+				}
+				return
+			}
+
+			var confErr *ConfigError
+			if !errors.As(err, &confErr) {
+				t.Fatalf("NewClient() error = %v, want *ConfigError", err)
+			}
+			if len(confErr.Problems) != 1 || !strings.Contains(confErr.Problems[0], tt.want) {
+				t.Errorf("Problems = %q, want one containing %q", confErr.Problems, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigValidateReportsEveryProblem(t *testing.T) {
+	_, err := NewClient(&Config{Endpoints: testEndpoints}, nil)
+
+	var confErr *ConfigError
+	if !errors.As(err, &confErr) {
+		t.Fatalf("NewClient() error = %v, want *ConfigError", err)
+	}
+	if len(confErr.Problems) < 4 {
+		t.Errorf("Problems = %q, want every missing field reported", confErr.Problems)
+	}
+	if errs := confErr.Unwrap(); len(errs) != len(confErr.Problems) || len(confErr.Fields) != len(errs) {
+		t.Errorf("Unwrap() = %d errors, want one per problem", len(errs))
+	}
+
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Rule != RuleRequired {
+		t.Errorf("errors.As(*FieldError) = %+v, want a missing field", fieldErr)
+	}
+
+	_, err = NewClient(&Config{Endpoints: testEndpoints}, nil, WithIdempotencyStore(nil))
+	if !errors.As(err, &confErr) || confErr.Fields[0].Field != "" || confErr.Fields[0].Rule != RuleInvalid {
+		t.Errorf("NewClient() error = %v, want the option error reported with the fields", err)
+	}
+}
+
+func TestNewClientDoesNotMutateConfig(t *testing.T) {
+	g := newTestGateway(t)
+	conf := g.config()
+	basePath := conf.BasePath
+
+	for i := 0; i < 2; i++ {
+		c, err := NewClient(conf, nil, WithDebugMode(false), WithHTTPClient(g.Client()))
+		if err != nil {
+			t.Fatalf("NewClient() #%d error = %v", i+1, err)
+		}
+
+		want := "https://" + basePath + "/sandbox/ipg/v2/vodacomTZN/"
+		if c.baseURL != want {
+			t.Errorf("client #%d baseURL = %q, want %q", i+1, c.baseURL, want)
+		}
+		if _, err := c.SessionID(context.Background()); err != nil {
+			t.Errorf("client #%d SessionID() error = %v", i+1, err)
+		}
+	}
+
+	if conf.BasePath != basePath {
+		t.Errorf("conf.BasePath = %q, want it left as %q", conf.BasePath, basePath)
+	}
+}
+
+func TestConfigRedacted(t *testing.T) {
+	g := newTestGateway(t)
+	conf := g.config()
+	conf.APIKey = "abcdefghijklmnopqrstuvwxyz012345"
+	conf.TrustedSources = []string{"10.0.0.1"}
+
+	redacted := conf.Redacted()
+	if redacted.APIKey != "abcd****(32 chars)" || !strings.HasPrefix(redacted.PublicKey, "SHA256:") {
+		t.Errorf("Redacted() = %+v, want the API key masked and the public key fingerprinted", redacted)
+	}
+	if redacted.Market != conf.Market || redacted.BasePath != conf.BasePath || redacted.SessionLifetime != conf.sessionLifetime() {
+		t.Errorf("Redacted() = %+v, want the other fields kept", redacted)
+	}
+
+	asJSON, err := json.Marshal(redacted)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	outputs := map[string]string{
+		"String": conf.String(),
+		"%v":     fmt.Sprintf("%v", conf),
+		"%+v":    fmt.Sprintf("%+v", *conf),
+		"json":   string(asJSON),
+	}
+	for name, out := range outputs {
+		if strings.Contains(out, conf.APIKey) || strings.Contains(out, conf.PublicKey) || strings.Contains(out, "efghijkl") {
+			t.Errorf("%s = %s, want the keys redacted", name, out)
+		}
+		if !strings.Contains(out, "abcd****(32 chars)") || !strings.Contains(out, redacted.PublicKey) || !strings.Contains(out, "10.0.0.1") {
+			t.Errorf("%s = %s, want the redacted keys and the trusted sources", name, out)
+		}
+	}
+
+	if got := maskAPIKey("short"); got != "****(5 chars)" {
+		t.Errorf("maskAPIKey(short) = %q, want only the length", got)
+	}
+	conf.PublicKey = "not-a-key"
+	if got := conf.Redacted().PublicKey; got != "invalid (9 chars)" {
+		t.Errorf("Redacted().PublicKey of an invalid key = %q", got)
+	}
+}
+
+func TestLoadConfigRoundTrip(t *testing.T) {
+	g := newTestGateway(t)
+	want := g.config()
+	want.Platform = OPENAPI
+	want.Market = GhanaMarket
+	want.TrustedSources = []string{"10.0.0.1", "10.0.0.2"}
+
+	marshal := map[string]func(interface{}) ([]byte, error){
+		"config.json": json.Marshal,
+		"config.yaml": yaml.Marshal,
+	}
+
+	for name, marshal := range marshal {
+		t.Run(name, func(t *testing.T) {
+			b, err := marshal(want)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			path := filepath.Join(t.TempDir(), name)
+			if err := os.WriteFile(path, b, 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := LoadConfig(path)
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("LoadConfig() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestReadConfigDefaults(t *testing.T) {
+	g := newTestGateway(t)
+	doc := fmt.Sprintf(`
+base_path: openapi.m-pesa.com
+market: tanzania
+platform: sandbox
+api_key: api-key
+public_key: %s
+service_provider_code: "000000"
+endpoints:
+  push: /c2bPayment/custom/
+`, g.pubKey)
+
+	conf, err := ReadConfig(strings.NewReader(doc), "yaml")
+	if err != nil {
+		t.Fatalf("ReadConfig() error = %v", err)
+	}
+
+	edps := *testEndpoints
+	edps.PushEndpoint = "/c2bPayment/custom/"
+	if *conf.Endpoints != edps {
+		t.Errorf("Endpoints = %+v, want %+v", conf.Endpoints, edps)
+	}
+	if conf.SessionLifetimeMinutes != DefaultSessionLifetimeMinutes {
+		t.Errorf("SessionLifetimeMinutes = %d, want %d", conf.SessionLifetimeMinutes, DefaultSessionLifetimeMinutes)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		doc  string
+		want string
+	}{
+		{name: "unknown market", file: "a.yaml", doc: "market: atlantis\n", want: `unknown market "atlantis"`},
+		{name: "unknown field", file: "a.json", doc: `{"apikey": "x"}`, want: `unknown field "apikey"`},
+		{name: "invalid config", file: "a.yml", doc: "market: TZ\nplatform: sandbox\n", want: "APIKey is required"},
+		{name: "unknown format", file: "a.toml", doc: "", want: "unknown config format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.doc), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := LoadConfig(path)
+			if err == nil || !strings.HasPrefix(err.Error(), path+": ") || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadConfig() error = %v, want %q prefixed with the path", err, tt.want)
+			}
+		})
+	}
+}
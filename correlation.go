@@ -0,0 +1,89 @@
+package mpesa
+
+import (
+	"context"
+	"strings"
+)
+
+// DefaultCorrelationHeader is the header carrying the correlation id of the
+// requests sent to the gateway, see WithCorrelationHeader.
+const DefaultCorrelationHeader = "X-Correlation-ID"
+
+type (
+	correlationIDKey   struct{}
+	conversationIDsKey struct{}
+
+	// CorrelationIDFunc returns the correlation id of the call made with ctx,
+	// or an empty string when there is none.
+	CorrelationIDFunc func(ctx context.Context) string
+
+	// ConversationIDs are the conversation ids of the callback being handled,
+	// see ConversationIDsFromContext.
+	ConversationIDs struct {
+		ConversationID           string
+		ThirdPartyConversationID string
+	}
+)
+
+// ContextWithCorrelationID returns a copy of ctx carrying id, which is sent
+// in the correlation header of the requests made with it.
+func ContextWithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationIDFromContext returns the correlation id set on ctx by
+// ContextWithCorrelationID.
+func CorrelationIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(correlationIDKey{}).(string)
+
+	return id, ok && id != ""
+}
+
+// ConversationIDsFromContext returns the conversation ids of the callback whose
+// handler was given ctx by CallbackServeHTTP or DisburseCallbackServeHTTP.
+func ConversationIDsFromContext(ctx context.Context) (ConversationIDs, bool) {
+	ids, ok := ctx.Value(conversationIDsKey{}).(ConversationIDs)
+
+	return ids, ok
+}
+
+// WithCorrelationHeader sets the header carrying the correlation id of the
+// requests, DefaultCorrelationHeader by default. The header is also read from
+// the callbacks, see WithCorrelationIDFunc.
+func WithCorrelationHeader(name string) ClientOption {
+	return func(client *Client) {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			client.optionErrs = append(client.optionErrs, "correlation header is empty")
+			return
+		}
+
+		client.correlationHeader = name
+	}
+}
+
+// WithCorrelationIDFunc sets how the correlation id of a request is read from
+// its context, e.g. from the value set by a request id middleware. It defaults
+// to the id set by ContextWithCorrelationID. No header is sent when fn returns
+// an empty string.
+//
+// The correlation header of a callback, when present, is set on the context
+// given to its handler with ContextWithCorrelationID, so that the default
+// propagates it to the requests made by the handler.
+func WithCorrelationIDFunc(fn CorrelationIDFunc) ClientOption {
+	return func(client *Client) {
+		if fn == nil {
+			client.optionErrs = append(client.optionErrs, "correlation id func is nil")
+			return
+		}
+
+		client.correlationID = fn
+	}
+}
+
+// defaultCorrelationID is the CorrelationIDFunc used when none is set.
+func defaultCorrelationID(ctx context.Context) string {
+	id, _ := CorrelationIDFromContext(ctx)
+
+	return id
+}
@@ -0,0 +1,116 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// recordHeader wraps the handler of endpoint to record the header name of the
+// requests it receives.
+func recordHeader(g *testGateway, endpoint, name string) func() []string {
+	var (
+		mu     sync.Mutex
+		values []string
+	)
+	handler := g.handlers[endpoint]
+	g.handlers[endpoint] = func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		values = append(values, r.Header.Get(name))
+		mu.Unlock()
+		handler(w, r)
+	}
+
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return append([]string(nil), values...)
+	}
+}
+
+func TestCorrelationHeader(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["b2cPayment/"] = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusCreated, DisburseResponse{ResponseCode: "INS-0"})
+	}
+	sessions := recordHeader(g, "getSession/", DefaultCorrelationHeader)
+	disbursements := recordHeader(g, "b2cPayment/", DefaultCorrelationHeader)
+	c := g.client()
+
+	ctx := ContextWithCorrelationID(context.Background(), "req-1")
+	if _, err := c.Disburse(ctx, testRequest("").DisburseRequest()); err != nil {
+		t.Fatalf("Disburse() error = %v", err)
+	}
+	if _, err := c.Disburse(context.Background(), testRequest("").DisburseRequest()); err != nil {
+		t.Fatalf("Disburse() error = %v", err)
+	}
+
+	if got := sessions(); len(got) != 1 || got[0] != "req-1" {
+		t.Errorf("session request headers = %q, want req-1", got)
+	}
+	if got := disbursements(); len(got) != 2 || got[0] != "req-1" || got[1] != "" {
+		t.Errorf("disbursement headers = %q, want req-1 then none", got)
+	}
+}
+
+func TestCorrelationIDFunc(t *testing.T) {
+	type requestIDKey struct{}
+
+	g := newTestGateway(t)
+	sessions := recordHeader(g, "getSession/", "X-Request-ID")
+	c := g.client(WithCorrelationHeader("X-Request-ID"), WithCorrelationIDFunc(func(ctx context.Context) string {
+		id, _ := ctx.Value(requestIDKey{}).(string)
+		return id
+	}))
+
+	if err := c.Ping(context.WithValue(context.Background(), requestIDKey{}, "req-2")); err != nil {
+		t.Fatalf("Ping() error = %v", err)
+	}
+	if got := sessions(); len(got) != 1 || got[0] != "req-2" {
+		t.Errorf("session request headers = %q, want req-2", got)
+	}
+
+	var configErr *ConfigError
+	for _, opt := range []ClientOption{WithCorrelationHeader(" "), WithCorrelationIDFunc(nil)} {
+		if _, err := NewClient(g.config(), nil, opt); !errors.As(err, &configErr) {
+			t.Errorf("NewClient() error = %v, want a *ConfigError", err)
+		}
+	}
+}
+
+func TestCallbackContextIDs(t *testing.T) {
+	g := newTestGateway(t)
+	var (
+		ids           ConversationIDs
+		correlationID string
+	)
+	handler := DisburseCallbackFunc(func(ctx context.Context, request DisburseCallbackRequest) (DisburseCallbackResponse, error) {
+		ids, _ = ConversationIDsFromContext(ctx)
+		correlationID, _ = CorrelationIDFromContext(ctx)
+		return DisburseCallbackResponse{ResponseCode: SUCCESS_CODE}, nil
+	})
+	c := g.client(WithDisburseCallbackHandler(handler))
+
+	r := newCallbackRequest(context.Background(), `{
+		"input_OriginalConversationID": "fd1e9143d22544459f7c66e1860ef276",
+		"input_ThirdPartyConversationID": "1e9b774d1da34af78412a498cbc28f5e",
+		"input_ResultCode": "INS-0"
+	}`)
+	r.Header.Set("X-Correlation-Id", "req-3")
+	c.DisburseCallbackServeHTTP(httptest.NewRecorder(), r)
+
+	want := ConversationIDs{
+		ConversationID:           "fd1e9143d22544459f7c66e1860ef276",
+		ThirdPartyConversationID: "1e9b774d1da34af78412a498cbc28f5e",
+	}
+	if ids != want {
+		t.Errorf("ConversationIDsFromContext() = %+v, want %+v", ids, want)
+	}
+	if correlationID != "req-3" {
+		t.Errorf("CorrelationIDFromContext() = %q, want the header of the callback", correlationID)
+	}
+}
@@ -0,0 +1,87 @@
+package mpesa
+
+import (
+	"context"
+	"crypto/rsa"
+	"fmt"
+	"strings"
+)
+
+// errSecretsProvided rejects the rotation of the credentials supplied by a
+// SecretsProvider, the provider rotates them.
+var errSecretsProvided = newConfigError([]*FieldError{{Rule: RuleInvalid, Reason: "the credentials are supplied by the secrets provider"}}) //nolint:gochecknoglobals
+
+// SetAPIKey replaces the API key of the client, e.g. when the portal rotates
+// it. The cached session is dropped so that the next request authenticates with
+// the new key, the requests in flight finish with the session they hold. It
+// returns a *ConfigError when key is empty or when the credentials are supplied
+// by a SecretsProvider.
+func (c *Client) SetAPIKey(key string) error {
+	if c.secretsProvider != nil {
+		return errSecretsProvided
+	}
+	if strings.TrimSpace(key) == "" {
+		return newConfigError([]*FieldError{{Field: "APIKey", Rule: RuleRequired, Reason: "is required"}})
+	}
+
+	c.rotateCredentials("API key", func() { c.Conf.APIKey = key })
+
+	return nil
+}
+
+// SetPublicKey replaces the public key of the client, in any of the formats
+// accepted for Config.PublicKey, like SetAPIKey replaces the API key. It
+// returns a *ConfigError when the key cannot be parsed or when the credentials
+// are supplied by a SecretsProvider.
+func (c *Client) SetPublicKey(key string) error {
+	if c.secretsProvider != nil {
+		return errSecretsProvided
+	}
+	publicKey, err := parsePublicKey(key)
+	if err != nil {
+		return newConfigError([]*FieldError{{Field: "PublicKey", Rule: RuleFormat, Reason: fmt.Sprintf("is invalid: %v", err)}})
+	}
+
+	c.rotateCredentials("public key", func() {
+		c.Conf.PublicKey = key
+		c.publicKey = publicKey
+	})
+
+	return nil
+}
+
+// rotateCredentials runs set and drops the session and the encrypted API key
+// derived from the previous credentials. The sessions fetched with the previous
+// credentials while rotating are not cached, see fetchSessionID.
+func (c *Client) rotateCredentials(name string, set func()) {
+	c.sessionMu.Lock()
+	set()
+	c.credentialsVersion++
+	c.encryptedAPIKey = new(string)
+	c.sessionID = new(string)
+	c.sessionExpiration = c.clock.Now()
+	c.sessionMu.Unlock()
+
+	c.logf("%s rotated, the next request fetches a new session", name)
+}
+
+// credentials returns the API key, the public key and the version of the
+// credentials, incremented by every rotation. They come from the
+// SecretsProvider when one was set.
+func (c *Client) credentials(ctx context.Context) (apiKey string, publicKey *rsa.PublicKey, version uint64, err error) {
+	if c.secretsProvider != nil {
+		secrets, err := c.providedSecrets(ctx)
+		if err != nil {
+			return "", nil, 0, err
+		}
+		apiKey, publicKey = secrets.apiKey, secrets.publicKey
+	}
+
+	c.sessionMu.RLock()
+	defer c.sessionMu.RUnlock()
+	if c.secretsProvider == nil {
+		apiKey, publicKey = c.Conf.APIKey, c.publicKey
+	}
+
+	return apiKey, publicKey, c.credentialsVersion, nil
+}
@@ -0,0 +1,99 @@
+package mpesa
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSetAPIKey(t *testing.T) {
+	g := newTestGateway(t)
+	var mu sync.Mutex
+	var keys []string
+	g.handlers["getSession/"] = func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&g.sessions, 1)
+		mu.Lock()
+		keys = append(keys, g.session(t, r))
+		mu.Unlock()
+		writeJSON(w, http.StatusOK, SessionResponse{Code: "INS-0", ID: fmt.Sprintf("session-%d", n)})
+	}
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusCreated, PushAsyncResponse{ResponseCode: "INS-0"})
+	}
+
+	var logs bytes.Buffer
+	c := g.client(WithLogger(&logs))
+	push := func() {
+		t.Helper()
+		if _, err := c.PushAsync(context.Background(), testRequest("tp-1").PushRequest()); err != nil {
+			t.Fatalf("PushAsync() error = %v", err)
+		}
+	}
+
+	push()
+	if err := c.SetAPIKey("rotated-key"); err != nil {
+		t.Fatalf("SetAPIKey() error = %v", err)
+	}
+	push()
+	push()
+
+	if want := []string{"api-key", "rotated-key"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("session keys = %v, want %v", keys, want)
+	}
+	if !strings.Contains(logs.String(), "API key rotated") || strings.Contains(logs.String(), "rotated-key") {
+		t.Errorf("logs = %q, want the rotation without the key", logs.String())
+	}
+
+	if err := c.SetAPIKey(" "); err == nil {
+		t.Errorf("SetAPIKey() error = nil, want an empty key rejected")
+	}
+}
+
+func TestSetPublicKey(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		if session := g.session(t, r); !strings.HasPrefix(session, "session-") {
+			t.Errorf("session = %q, want a session id", session)
+		}
+		writeJSON(w, http.StatusCreated, PushAsyncResponse{ResponseCode: "INS-0"})
+	}
+
+	c := g.client()
+	if _, err := c.PushAsync(context.Background(), testRequest("tp-1").PushRequest()); err != nil {
+		t.Fatalf("PushAsync() error = %v", err)
+	}
+
+	// the gateway decrypts with the new key from now on
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+	g.key = key
+
+	if err := c.SetPublicKey("not a key"); err == nil {
+		t.Errorf("SetPublicKey() error = nil, want the invalid key rejected")
+	}
+	if err := c.SetPublicKey(base64.StdEncoding.EncodeToString(der)); err != nil {
+		t.Fatalf("SetPublicKey() error = %v", err)
+	}
+	if _, err := c.PushAsync(context.Background(), testRequest("tp-2").PushRequest()); err != nil {
+		t.Fatalf("PushAsync() error = %v", err)
+	}
+	if n := atomic.LoadInt32(&g.sessions); n != 2 {
+		t.Errorf("sessions = %d, want a new session after the rotation", n)
+	}
+}
@@ -25,11 +25,14 @@
 package mpesa
 
 import (
+	"bytes"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/pem"
 	"fmt"
+	"strings"
 )
 
 // encryptKey ....
@@ -39,28 +42,76 @@ import (
 //4.	Generate an instance of an RSA cipher and use the Base 64 string as the input
 //5.	Encode the API Key with the RSA cipher and digest as Base64 string format
 //6.	The result is your encrypted API Key.
-func encryptKey(apiKey, pubKey string) (string, error) {
-	decodedBase64, err := base64.StdEncoding.DecodeString(pubKey)
+func encryptKey(apiKey string, publicKey *rsa.PublicKey) (string, error) {
+	msg := []byte(apiKey)
+
+	encrypted, err := rsa.EncryptPKCS1v15(rand.Reader, publicKey, msg)
+
 	if err != nil {
-		return "", fmt.Errorf("could not decode pub key to Base64 string: %w", err)
+		return "", fmt.Errorf("could not encrypt key using generated public key: %w", err)
 	}
-	publicKeyInterface, err := x509.ParsePKIXPublicKey(decodedBase64)
+	return base64.StdEncoding.EncodeToString(encrypted), nil
+}
+
+// EncryptAPIKey returns apiKey encrypted with publicKey, the bearer token of a
+// session request, as the Client encrypts it. publicKey is in any of the
+// formats accepted for Config.PublicKey.
+func EncryptAPIKey(apiKey, publicKey string) (string, error) {
+	key, err := parsePublicKey(publicKey)
 	if err != nil {
-		return "", fmt.Errorf("could not parse encoded public key: %w", err)
+		return "", err
 	}
 
-	//check if the public key is RSA public key
-	publicKey, isRSAPublicKey := publicKeyInterface.(*rsa.PublicKey)
-	if !isRSAPublicKey {
-		return "", fmt.Errorf("public key parsed is not an RSA public key : %w", err)
+	return encryptKey(apiKey, key)
+}
+
+// GenerateBearerToken returns the bearer token authenticating a request with
+// sessionID, the session id encrypted with publicKey, as the Client sends it in
+// the Authorization header after "Bearer ".
+func GenerateBearerToken(sessionID, publicKey string) (string, error) {
+	return EncryptAPIKey(sessionID, publicKey)
+}
+
+// parsePublicKey decodes the public key given by the portal, either the bare
+// Base64 encoded DER or a PEM block. The whitespace around the key and inside
+// the Base64 is ignored. The DER holds a PKIX or a PKCS #1 RSA public key.
+func parsePublicKey(pubKey string) (*rsa.PublicKey, error) {
+	pubKey = strings.TrimSpace(pubKey)
+	if strings.HasPrefix(pubKey, "-----BEGIN") {
+		block, rest := pem.Decode([]byte(pubKey))
+		if block == nil {
+			return nil, fmt.Errorf("could not decode PEM public key: malformed PEM block")
+		}
+		if len(bytes.TrimSpace(rest)) > 0 {
+			return nil, fmt.Errorf("could not decode PEM public key: unexpected data after the %s block", block.Type)
+		}
+
+		return parseDERPublicKey("PEM", block.Bytes)
 	}
 
-	msg := []byte(apiKey)
+	der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(pubKey), ""))
+	if err != nil {
+		return nil, fmt.Errorf("could not decode Base64 public key: %w", err)
+	}
 
-	encrypted, err := rsa.EncryptPKCS1v15(rand.Reader, publicKey, msg)
+	return parseDERPublicKey("Base64", der)
+}
 
+// parseDERPublicKey parses the DER of a public key found in a key of format.
+func parseDERPublicKey(format string, der []byte) (*rsa.PublicKey, error) {
+	key, err := x509.ParsePKIXPublicKey(der)
 	if err != nil {
-		return "", fmt.Errorf("could not encrypt key using generated public key: %w", err)
+		if publicKey, pkcs1Err := x509.ParsePKCS1PublicKey(der); pkcs1Err == nil {
+			return publicKey, nil
+		}
+		return nil, fmt.Errorf("could not parse the DER of the %s public key: %w", format, err)
 	}
-	return base64.StdEncoding.EncodeToString(encrypted), nil
+
+	//check if the public key is RSA public key
+	publicKey, isRSAPublicKey := key.(*rsa.PublicKey)
+	if !isRSAPublicKey {
+		return nil, fmt.Errorf("%s public key is a %T, not an RSA public key", format, key)
+	}
+
+	return publicKey, nil
 }
@@ -0,0 +1,46 @@
+package mpesa_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	mpesa "github.com/ameprizzo/mpesago"
+)
+
+// The session endpoint called without a Client, e.g. from a smoke test, and
+// the session id used to authenticate a transaction query.
+func ExampleEncryptAPIKey() {
+	publicKey := os.Getenv(mpesa.EnvPublicKey)
+
+	token, err := mpesa.EncryptAPIKey(os.Getenv(mpesa.EnvAPIKey), publicKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	request, err := http.NewRequest(http.MethodGet, "https://openapi.m-pesa.com/sandbox/ipg/v2/vodacomTZN/getSession/", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	request.Header.Set("Origin", "*")
+	request.Header.Set("Authorization", "Bearer "+token)
+
+	res, err := http.DefaultClient.Do(request)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	var session mpesa.SessionResponse
+	if err := json.NewDecoder(res.Body).Decode(&session); err != nil {
+		log.Fatal(err)
+	}
+
+	bearer, err := mpesa.GenerateBearerToken(session.ID, publicKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Authorization: Bearer " + bearer)
+}
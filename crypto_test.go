@@ -0,0 +1,138 @@
+package mpesa
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParsePublicKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	ecDER, err := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(der)
+	pemKey := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	pkcs1 := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&key.PublicKey)}))
+
+	tests := []struct {
+		name    string
+		in      string
+		wantErr string
+	}{
+		{name: "base64 DER", in: encoded},
+		{name: "wrapped base64 DER", in: "\n" + encoded[:64] + "\n" + encoded[64:] + "\n"},
+		{name: "PEM", in: pemKey},
+		{name: "PEM with whitespace", in: "\n\t " + pemKey + "\n\n"},
+		{name: "PKCS #1 PEM", in: pkcs1},
+		{name: "invalid base64", in: "not a key!", wantErr: "could not decode Base64 public key"},
+		{name: "truncated DER", in: base64.StdEncoding.EncodeToString(der[:len(der)/2]), wantErr: "could not parse the DER of the Base64 public key"},
+		{name: "corrupted PEM", in: strings.Replace(pemKey, "-----END PUBLIC KEY-----", "", 1), wantErr: "malformed PEM block"},
+		{name: "PEM with trailing data", in: pemKey + "garbage", wantErr: "unexpected data after the PUBLIC KEY block"},
+		{name: "PEM of a bad DER", in: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte("bad")})), wantErr: "could not parse the DER of the PEM public key"},
+		{name: "EC key", in: base64.StdEncoding.EncodeToString(ecDER), wantErr: "not an RSA public key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePublicKey(tt.in)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("parsePublicKey() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePublicKey() error = %v", err)
+			}
+			if !got.Equal(&key.PublicKey) {
+				t.Errorf("parsePublicKey() = a different key")
+			}
+		})
+	}
+}
+
+func TestWithRSAPublicKey(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		if session := g.session(t, r); session != "session-1" {
+			t.Errorf("session = %q, want session-1", session)
+		}
+		writeJSON(w, http.StatusCreated, PushAsyncResponse{ResponseCode: "INS-0"})
+	}
+
+	conf := g.config()
+	conf.PublicKey = ""
+	c, err := NewClient(conf, nil, WithDebugMode(false), WithHTTPClient(g.Client()), WithRSAPublicKey(&g.key.PublicKey))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	if _, err := c.PushAsync(context.Background(), testRequest("tp-1").PushRequest()); err != nil {
+		t.Fatalf("PushAsync() error = %v", err)
+	}
+
+	_, err = NewClient(conf, nil, WithDebugMode(false), WithRSAPublicKey(nil))
+	if err == nil || !strings.Contains(err.Error(), "RSA public key is nil") {
+		t.Errorf("NewClient() error = %v, want the nil key rejected", err)
+	}
+}
+
+func TestEncryptAPIKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+
+	for _, publicKey := range []string{
+		base64.StdEncoding.EncodeToString(der),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+	} {
+		for name, encrypt := range map[string]func(string, string) (string, error){
+			"EncryptAPIKey":       EncryptAPIKey,
+			"GenerateBearerToken": GenerateBearerToken,
+		} {
+			token, err := encrypt("secret", publicKey)
+			if err != nil {
+				t.Fatalf("%s() error = %v", name, err)
+			}
+			encrypted, err := base64.StdEncoding.DecodeString(token)
+			if err != nil {
+				t.Fatalf("decode %s token: %v", name, err)
+			}
+			plain, err := rsa.DecryptPKCS1v15(rand.Reader, key, encrypted)
+			if err != nil || string(plain) != "secret" {
+				t.Errorf("%s() decrypts to %q, %v, want secret", name, plain, err)
+			}
+		}
+	}
+
+	_, err = EncryptAPIKey("secret", "not a key!")
+	if _, parseErr := parsePublicKey("not a key!"); err == nil || err.Error() != parseErr.Error() {
+		t.Errorf("EncryptAPIKey() error = %v, want %v", err, parseErr)
+	}
+}
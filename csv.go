@@ -0,0 +1,214 @@
+package mpesa
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The default header names of the columns read by ParseDisburseCSV.
+const (
+	DefaultCSVMSISDNColumn       = "msisdn"
+	DefaultCSVAmountColumn       = "amount"
+	DefaultCSVReferenceColumn    = "reference"
+	DefaultCSVDescriptionColumn  = "description"
+	DefaultCSVThirdPartyIDColumn = "third_party_id"
+	DefaultCSVCurrencyColumn     = "currency"
+)
+
+type (
+	// CSVOptions configures ParseDisburseCSV. Market is the market of the
+	// disbursements, the MSISDNs are normalized and checked against it. Comma
+	// is the field delimiter, ',' when zero.
+	//
+	// The Column fields name the header of each column, the matching is not
+	// case sensitive and an empty name stands for the default one. The MSISDN
+	// and amount columns are required, the others may be left out of the file.
+	CSVOptions struct {
+		Market             Market
+		Comma              rune
+		MSISDNColumn       string
+		AmountColumn       string
+		ReferenceColumn    string
+		DescriptionColumn  string
+		ThirdPartyIDColumn string
+		CurrencyColumn     string
+	}
+
+	// RowError is a row of a CSV file that could not be turned into a
+	// request. Line is the line of the file the row starts on, counting from
+	// 1 for the header. Err is a *ValidationError listing the invalid fields,
+	// or the reason the row could not be read.
+	RowError struct {
+		Line int
+		Err  error
+	}
+)
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// ParseDisburseCSV reads disbursements from r, a CSV file with a header row
+// followed by one disbursement per row. The MSISDNs are normalized with
+// NormalizeMSISDN and the amounts parsed with ParseAmount, the spaces around
+// the fields and a byte order mark at the start of the file are ignored.
+//
+// The rows that are invalid or malformed are reported as RowErrors and left
+// out of the requests, the other rows are still read. The error is only set
+// when r can not be read or the header lacks a required column. The requests
+// of the valid rows can be passed as they are to DisburseBatch.
+func ParseDisburseCSV(r io.Reader, opts CSVOptions) ([]DisburseRequest, []RowError, error) {
+	reader := csv.NewReader(r)
+	if opts.Comma != 0 {
+		reader.Comma = opts.Comma
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, errors.New("mpesa: csv: missing header")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("mpesa: csv: read header: %w", err)
+	}
+	columns, err := opts.columns(header)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		requests []DisburseRequest
+		rowErrs  []RowError
+	)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rowErrs = append(rowErrs, RowError{Line: parseErr.StartLine, Err: parseErr.Err})
+			continue
+		}
+		if err != nil {
+			return requests, rowErrs, fmt.Errorf("mpesa: csv: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		if len(record) != len(header) {
+			rowErrs = append(rowErrs, RowError{
+				Line: line,
+				Err:  fmt.Errorf("%d fields, the header has %d", len(record), len(header)),
+			})
+			continue
+		}
+
+		request, err := columns.request(record, opts.Market)
+		if err != nil {
+			rowErrs = append(rowErrs, RowError{Line: line, Err: err})
+			continue
+		}
+		requests = append(requests, request)
+	}
+
+	return requests, rowErrs, nil
+}
+
+// csvColumns holds the index of each column in the records, -1 for the
+// optional columns missing from the header.
+type csvColumns struct {
+	msisdn, amount, reference, description, thirdPartyID, currency int
+}
+
+// columns finds the columns of opts in header.
+func (opts CSVOptions) columns(header []string) (csvColumns, error) {
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	var missing []string
+	find := func(name, fallback string, required bool) int {
+		if name == "" {
+			name = fallback
+		}
+		i, ok := index[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			if required {
+				missing = append(missing, fmt.Sprintf("%q", name))
+			}
+			return -1
+		}
+
+		return i
+	}
+
+	columns := csvColumns{
+		msisdn:       find(opts.MSISDNColumn, DefaultCSVMSISDNColumn, true),
+		amount:       find(opts.AmountColumn, DefaultCSVAmountColumn, true),
+		reference:    find(opts.ReferenceColumn, DefaultCSVReferenceColumn, false),
+		description:  find(opts.DescriptionColumn, DefaultCSVDescriptionColumn, false),
+		thirdPartyID: find(opts.ThirdPartyIDColumn, DefaultCSVThirdPartyIDColumn, false),
+		currency:     find(opts.CurrencyColumn, DefaultCSVCurrencyColumn, false),
+	}
+	if len(missing) > 0 {
+		return csvColumns{}, fmt.Errorf("mpesa: csv: header lacks the column %s", strings.Join(missing, " and "))
+	}
+
+	return columns, nil
+}
+
+// request turns record into a DisburseRequest of market. It returns a
+// *ValidationError listing every invalid field of the row.
+func (columns csvColumns) request(record []string, market Market) (DisburseRequest, error) {
+	field := func(i int) string {
+		if i < 0 {
+			return ""
+		}
+
+		return strings.TrimSpace(record[i])
+	}
+
+	request := DisburseRequest{
+		ThirdPartyID: field(columns.thirdPartyID),
+		Reference:    field(columns.reference),
+		Description:  field(columns.description),
+		Currency:     field(columns.currency),
+	}
+
+	var v validation
+	msisdn, err := NormalizeMSISDN(market, field(columns.msisdn))
+	if err != nil {
+		v.fields = append(v.fields, err.(*FieldError))
+	}
+	request.MSISDN = msisdn
+
+	amount, err := ParseAmount(field(columns.amount))
+	if err != nil {
+		v.fields = append(v.fields, err.(*FieldError))
+	} else {
+		v.checkAmount(amount)
+	}
+	request.Amount = amount
+
+	v.checkThirdPartyID(request.ThirdPartyID)
+	v.checkReference(request.Reference)
+	v.checkDescription(request.Description)
+	v.checkCurrency(request.Currency, market)
+	if err := v.err(DisburseOperation); err != nil {
+		return DisburseRequest{}, err
+	}
+
+	return request, nil
+}
@@ -0,0 +1,120 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func openCSV(t *testing.T, name string) *os.File {
+	t.Helper()
+
+	f, err := os.Open(filepath.Join("testdata", "csv", name))
+	if err != nil {
+		t.Fatalf("open fixture: %v", err)
+	}
+	t.Cleanup(func() { _ = f.Close() })
+
+	return f
+}
+
+func TestParseDisburseCSV(t *testing.T) {
+	requests, rowErrs, err := ParseDisburseCSV(openCSV(t, "payroll.csv"), CSVOptions{Market: TanzaniaMarket})
+	if err != nil || len(rowErrs) > 0 {
+		t.Fatalf("ParseDisburseCSV() error = %v, row errors = %v", err, rowErrs)
+	}
+
+	want := []DisburseRequest{
+		{MSISDN: "255754000123", Amount: MustParseAmount("1500.50"), Reference: "PAYROLL01", Description: "Salary, October"},
+		{MSISDN: "255754000124", Amount: MustParseAmount("2000"), Reference: "PAYROLL02", Description: "Bonus"},
+		{MSISDN: "255754000125", Amount: MustParseAmount("300"), Reference: "PAYROLL03", Description: `Overtime "weekend"`},
+	}
+	if len(requests) != len(want) {
+		t.Fatalf("requests = %+v, want %+v", requests, want)
+	}
+	for i := range want {
+		if requests[i] != want[i] {
+			t.Errorf("requests[%d] = %+v, want %+v", i, requests[i], want[i])
+		}
+	}
+}
+
+func TestParseDisburseCSVRowErrors(t *testing.T) {
+	requests, rowErrs, err := ParseDisburseCSV(openCSV(t, "malformed.csv"), CSVOptions{Market: TanzaniaMarket})
+	if err != nil {
+		t.Fatalf("ParseDisburseCSV() error = %v", err)
+	}
+
+	if len(requests) != 2 || requests[0].Reference != "PAYROLL01" || requests[1].Description != "Salary\nand bonus" {
+		t.Errorf("requests = %+v, want the two valid rows", requests)
+	}
+
+	wantLines := map[int]string{3: "fields", 4: "MSISDN", 5: "Amount", 6: "Amount", 7: "quote"}
+	if len(rowErrs) != len(wantLines) {
+		t.Fatalf("row errors = %v, want lines 3 to 7", rowErrs)
+	}
+	for _, rowErr := range rowErrs {
+		want, ok := wantLines[rowErr.Line]
+		if !ok || !strings.Contains(rowErr.Error(), want) {
+			t.Errorf("row error %q, want line %d to mention %q", rowErr.Error(), rowErr.Line, want)
+		}
+	}
+
+	var validationErr *ValidationError
+	if !errors.As(&rowErrs[1], &validationErr) {
+		t.Errorf("row error %v does not unwrap to a *ValidationError", rowErrs[1])
+	}
+}
+
+func TestParseDisburseCSVOptions(t *testing.T) {
+	opts := CSVOptions{
+		Market:             TanzaniaMarket,
+		Comma:              ';',
+		MSISDNColumn:       "phone",
+		AmountColumn:       "Paid",
+		ReferenceColumn:    "REF",
+		ThirdPartyIDColumn: "id",
+	}
+	requests, rowErrs, err := ParseDisburseCSV(openCSV(t, "custom.csv"), opts)
+	if err != nil || len(rowErrs) > 0 {
+		t.Fatalf("ParseDisburseCSV() error = %v, row errors = %v", err, rowErrs)
+	}
+	want := DisburseRequest{ThirdPartyID: "run-1", MSISDN: "255754000123", Amount: MustParseAmount("10"), Reference: "PAYROLL01"}
+	if len(requests) != 1 || requests[0] != want {
+		t.Errorf("requests = %+v, want %+v", requests, want)
+	}
+
+	_, _, err = ParseDisburseCSV(openCSV(t, "custom.csv"), CSVOptions{Market: TanzaniaMarket, Comma: ';'})
+	if err == nil || !strings.Contains(err.Error(), `"msisdn" and "amount"`) {
+		t.Errorf("ParseDisburseCSV() error = %v, want the missing columns reported", err)
+	}
+
+	if _, _, err := ParseDisburseCSV(strings.NewReader(""), opts); err == nil {
+		t.Errorf("ParseDisburseCSV() of an empty file error = nil, want the missing header reported")
+	}
+}
+
+func TestParseDisburseCSVBatch(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["b2cPayment/"] = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusCreated, DisburseResponse{ResponseCode: "INS-0"})
+	}
+	requests, _, err := ParseDisburseCSV(openCSV(t, "payroll.csv"), CSVOptions{Market: TanzaniaMarket})
+	if err != nil {
+		t.Fatalf("ParseDisburseCSV() error = %v", err)
+	}
+
+	result, err := g.client().DisburseBatch(context.Background(), requests, BatchOptions{})
+	if err != nil || len(result.Items) != len(requests) {
+		t.Fatalf("DisburseBatch() = %+v, %v", result, err)
+	}
+	for _, item := range result.Items {
+		if item.Err != nil {
+			t.Errorf("items[%d].Err = %v", item.Index, item.Err)
+		}
+	}
+}
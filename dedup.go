@@ -0,0 +1,267 @@
+package mpesa
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultCallbackDedupTTL is how long a delivered callback is remembered when
+// WithCallbackDedup is given no TTL.
+const DefaultCallbackDedupTTL = 24 * time.Hour
+
+// dedupPollInterval is how often a duplicate delivery checks whether the first
+// one has finished.
+const dedupPollInterval = 50 * time.Millisecond
+
+// dedupLease is how long a callback is reserved while its handler runs, which
+// is bounded by callbackTimeout. The dedup TTL only applies to the saved
+// acknowledgement, so a callback whose processing died with its instance is
+// let through again once the lease runs out.
+const dedupLease = callbackTimeout + 10*time.Second
+
+var _ CallbackStore = (*MemoryCallbackStore)(nil)
+
+// CallbackStore records the push callbacks already processed so that a
+// re-delivery is acknowledged without calling the PushCallbackHandler again.
+// Implementations must be safe for concurrent use, and Reserve must be atomic
+// across every instance sharing the store, e.g. SET NX in Redis.
+type CallbackStore interface {
+	// Reserve claims key for ttl, a lease covering the processing of the
+	// callback, and reports whether it was free. Only the caller that
+	// reserved a key runs the handler for it.
+	Reserve(ctx context.Context, key string, ttl time.Duration) (bool, error)
+
+	// Save stores the acknowledgement returned by the handler for key,
+	// replacing the reservation, for ttl.
+	Save(ctx context.Context, key string, ack PushCallbackResponse, ttl time.Duration) error
+
+	// Load returns the acknowledgement saved for key, if any.
+	Load(ctx context.Context, key string) (PushCallbackResponse, bool, error)
+
+	// Release frees key after the handler failed so a retry is processed.
+	Release(ctx context.Context, key string) error
+}
+
+// DedupKeyFunc returns the key a push callback is deduplicated on. An empty key
+// turns deduplication off for that callback.
+type DedupKeyFunc func(request PushCallbackRequest) string
+
+// defaultDedupKey keys callbacks on the transaction id, or on the conversation
+// id when the transaction id is missing.
+func defaultDedupKey(request PushCallbackRequest) string {
+	if request.TransactionID != "" {
+		return request.TransactionID
+	}
+
+	return request.OriginalConversationID
+}
+
+type callbackDedup struct {
+	store CallbackStore
+	ttl   time.Duration
+	key   DedupKeyFunc
+}
+
+// dedup calls handle unless request was already processed, in which case the
+// saved acknowledgement is returned and replayed is true. A delivery that
+// arrives while the same callback is being processed waits for that result.
+func (c *Client) dedup(ctx context.Context, request PushCallbackRequest,
+	handle func() (PushCallbackResponse, error)) (ack PushCallbackResponse, replayed bool, err error) {
+	d := c.callbackDedup
+	if d == nil {
+		ack, err = handle()
+		return ack, false, err
+	}
+
+	key := d.key(request)
+	if key == "" {
+		ack, err = handle()
+		return ack, false, err
+	}
+
+	lease := dedupLease
+	if d.ttl < lease {
+		lease = d.ttl
+	}
+
+	for {
+		reserved, err := d.store.Reserve(ctx, key, lease)
+		if err != nil {
+			return PushCallbackResponse{}, false, err
+		}
+
+		if reserved {
+			ack, err := handle()
+			if err != nil {
+				// a failed callback is retried by the gateway, let it through
+				if rerr := d.store.Release(context.Background(), key); rerr != nil {
+					c.logf("callback dedup: could not release %s: %v", key, rerr)
+				}
+
+				return ack, false, err
+			}
+
+			if err := d.store.Save(ctx, key, ack, d.ttl); err != nil {
+				c.logf("callback dedup: could not save %s: %v", key, err)
+			}
+
+			return ack, false, nil
+		}
+
+		ack, ok, err := d.store.Load(ctx, key)
+		if err != nil {
+			return PushCallbackResponse{}, false, err
+		}
+		if ok {
+			c.debugf("callback dedup: replaying the acknowledgement of %s", key)
+			return ack, true, nil
+		}
+
+		// the first delivery is still being processed
+		select {
+		case <-ctx.Done():
+			return PushCallbackResponse{}, false, ctx.Err()
+		case <-c.clock.After(dedupPollInterval):
+		}
+	}
+}
+
+// savedAck returns the acknowledgement saved for a callback already processed,
+// for the asynchronous callbacks that are acknowledged before being handled.
+func (c *Client) savedAck(ctx context.Context, request PushCallbackRequest) (PushCallbackResponse, bool) {
+	d := c.callbackDedup
+	if d == nil {
+		return PushCallbackResponse{}, false
+	}
+	key := d.key(request)
+	if key == "" {
+		return PushCallbackResponse{}, false
+	}
+
+	ack, ok, err := d.store.Load(ctx, key)
+	if err != nil {
+		c.logf("callback dedup: could not load %s: %v", key, err)
+		return PushCallbackResponse{}, false
+	}
+
+	return ack, ok
+}
+
+// MemoryCallbackStore is an in-memory CallbackStore for a single instance.
+type MemoryCallbackStore struct {
+	mu       sync.Mutex
+	entries  map[string]*memoryCallbackEntry
+	expiries expiryQueue
+	now      func() time.Time
+}
+
+type memoryCallbackEntry struct {
+	ack     *PushCallbackResponse
+	expires time.Time
+}
+
+// NewMemoryCallbackStore returns an empty MemoryCallbackStore.
+func NewMemoryCallbackStore() *MemoryCallbackStore {
+	return &MemoryCallbackStore{
+		entries: make(map[string]*memoryCallbackEntry),
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryCallbackStore) Reserve(_ context.Context, key string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.evict()
+	if _, ok := s.entries[key]; ok {
+		return false, nil
+	}
+	s.put(key, &memoryCallbackEntry{expires: s.now().Add(ttl)})
+
+	return true, nil
+}
+
+func (s *MemoryCallbackStore) Save(_ context.Context, key string, ack PushCallbackResponse, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.put(key, &memoryCallbackEntry{ack: &ack, expires: s.now().Add(ttl)})
+
+	return nil
+}
+
+func (s *MemoryCallbackStore) Load(_ context.Context, key string) (PushCallbackResponse, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok || entry.ack == nil || !s.now().Before(entry.expires) {
+		return PushCallbackResponse{}, false, nil
+	}
+
+	return *entry.ack, true, nil
+}
+
+func (s *MemoryCallbackStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+
+	return nil
+}
+
+// put stores entry under key. It must be called with mu held.
+func (s *MemoryCallbackStore) put(key string, entry *memoryCallbackEntry) {
+	s.entries[key] = entry
+	s.expiries.add(key, entry.expires)
+}
+
+// evict drops the expired entries. It must be called with mu held.
+func (s *MemoryCallbackStore) evict() {
+	now := s.now()
+	s.expiries.popExpired(now, func(key string) {
+		if entry, ok := s.entries[key]; ok && !now.Before(entry.expires) {
+			delete(s.entries, key)
+		}
+	})
+}
+
+// expiryQueue is a min-heap of keys ordered by expiration, so that expired
+// entries are dropped without scanning the others. A key whose entry was
+// replaced or removed may still be queued: popExpired hands it over anyway and
+// the caller checks the entry it holds.
+type expiryQueue []expiringKey
+
+type expiringKey struct {
+	key     string
+	expires time.Time
+}
+
+func (q expiryQueue) Len() int           { return len(q) }
+func (q expiryQueue) Less(i, j int) bool { return q[i].expires.Before(q[j].expires) }
+func (q expiryQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+
+func (q *expiryQueue) Push(x interface{}) { *q = append(*q, x.(expiringKey)) }
+
+func (q *expiryQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+
+	return item
+}
+
+// add queues key to expire at expires.
+func (q *expiryQueue) add(key string, expires time.Time) {
+	heap.Push(q, expiringKey{key: key, expires: expires})
+}
+
+// popExpired removes the keys expired at now and calls drop for each of them.
+func (q *expiryQueue) popExpired(now time.Time, drop func(key string)) {
+	for q.Len() > 0 && !now.Before((*q)[0].expires) {
+		drop(heap.Pop(q).(expiringKey).key)
+	}
+}
@@ -0,0 +1,196 @@
+package mpesa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCallbackDedup(t *testing.T) {
+	g := newTestGateway(t)
+
+	var calls int32
+	release := make(chan struct{})
+	handler := PushCallbackFunc(func(request PushCallbackRequest) (PushCallbackResponse, error) {
+		n := atomic.AddInt32(&calls, 1)
+		<-release
+
+		return PushCallbackResponse{
+			OriginalConversationID: request.OriginalConversationID,
+			ResponseCode:           SUCCESS_CODE,
+			ResponseDesc:           fmt.Sprintf("processed %d", n),
+		}, nil
+	})
+	c := g.client(WithCallbackHandler(handler), WithCallbackDedup(NewMemoryCallbackStore(), time.Hour))
+
+	const deliveries = 10
+	acks := make([]PushCallbackResponse, deliveries)
+	var wg sync.WaitGroup
+	for i := 0; i < deliveries; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			c.CallbackServeHTTP(rec, newCallbackRequest(context.Background(), testCallbackBody))
+			if rec.Code != http.StatusOK {
+				t.Errorf("delivery %d: status = %d", i, rec.Code)
+			}
+			_ = json.Unmarshal(rec.Body.Bytes(), &acks[i])
+		}(i)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("handler calls = %d, want 1", got)
+	}
+	for i, ack := range acks {
+		if ack.ResponseDesc != "processed 1" {
+			t.Errorf("delivery %d acknowledged with %+v, want the first acknowledgement", i, ack)
+		}
+	}
+}
+
+func TestCallbackDedupRetriesFailedCallbacks(t *testing.T) {
+	g := newTestGateway(t)
+
+	var calls int32
+	handler := PushCallbackFunc(func(request PushCallbackRequest) (PushCallbackResponse, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return PushCallbackResponse{}, errors.New("database is down")
+		}
+		return PushCallbackResponse{ResponseCode: SUCCESS_CODE}, nil
+	})
+	c := g.client(WithCallbackHandler(handler), WithCallbackDedup(NewMemoryCallbackStore(), 0))
+
+	for _, want := range []int{http.StatusInternalServerError, http.StatusOK, http.StatusOK} {
+		rec := httptest.NewRecorder()
+		c.CallbackServeHTTP(rec, newCallbackRequest(context.Background(), testCallbackBody))
+		if rec.Code != want {
+			t.Errorf("status = %d, want %d", rec.Code, want)
+		}
+	}
+
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("handler calls = %d, want 2", got)
+	}
+}
+
+// ttlStore records the TTLs a CallbackStore is called with.
+type ttlStore struct {
+	*MemoryCallbackStore
+	reserved, saved []time.Duration
+}
+
+func (s *ttlStore) Reserve(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	s.reserved = append(s.reserved, ttl)
+	return s.MemoryCallbackStore.Reserve(ctx, key, ttl)
+}
+
+func (s *ttlStore) Save(ctx context.Context, key string, ack PushCallbackResponse, ttl time.Duration) error {
+	s.saved = append(s.saved, ttl)
+	return s.MemoryCallbackStore.Save(ctx, key, ack, ttl)
+}
+
+func TestCallbackDedupLease(t *testing.T) {
+	g := newTestGateway(t)
+	handler := PushCallbackFunc(func(request PushCallbackRequest) (PushCallbackResponse, error) {
+		return successAck(request), nil
+	})
+
+	store := &ttlStore{MemoryCallbackStore: NewMemoryCallbackStore()}
+	c := g.client(WithCallbackHandler(handler), WithCallbackDedup(store, 0))
+	c.CallbackServeHTTP(httptest.NewRecorder(), newCallbackRequest(context.Background(), testCallbackBody))
+	if len(store.reserved) != 1 || store.reserved[0] != dedupLease || len(store.saved) != 1 || store.saved[0] != DefaultCallbackDedupTTL {
+		t.Errorf("reserved for %v and saved for %v, want the lease then the TTL", store.reserved, store.saved)
+	}
+
+	// a TTL shorter than the lease bounds the reservation too
+	store = &ttlStore{MemoryCallbackStore: NewMemoryCallbackStore()}
+	c = g.client(WithCallbackHandler(handler), WithCallbackDedup(store, time.Second))
+	c.CallbackServeHTTP(httptest.NewRecorder(), newCallbackRequest(context.Background(), testCallbackBody))
+	if len(store.reserved) != 1 || store.reserved[0] != time.Second {
+		t.Errorf("reserved for %v, want the TTL", store.reserved)
+	}
+}
+
+func TestCallbackDedupKey(t *testing.T) {
+	g := newTestGateway(t)
+
+	var calls int32
+	handler := PushCallbackFunc(func(request PushCallbackRequest) (PushCallbackResponse, error) {
+		atomic.AddInt32(&calls, 1)
+		return PushCallbackResponse{ResponseCode: SUCCESS_CODE}, nil
+	})
+	// an empty key turns deduplication off
+	key := func(request PushCallbackRequest) string { return "" }
+	c := g.client(WithCallbackHandler(handler), WithCallbackDedupKey(key), WithCallbackDedup(NewMemoryCallbackStore(), 0))
+
+	for i := 0; i < 2; i++ {
+		c.CallbackServeHTTP(httptest.NewRecorder(), newCallbackRequest(context.Background(), testCallbackBody))
+	}
+
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("handler calls = %d, want 2", got)
+	}
+}
+
+func TestMemoryCallbackStoreExpiry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2021, 12, 31, 14, 30, 0, 0, time.UTC)
+	s := NewMemoryCallbackStore()
+	s.now = func() time.Time { return now }
+
+	if ok, _ := s.Reserve(ctx, "tx-1", time.Minute); !ok {
+		t.Fatal("Reserve() = false on an empty store")
+	}
+	if ok, _ := s.Reserve(ctx, "tx-1", time.Minute); ok {
+		t.Fatal("Reserve() = true for a reserved key")
+	}
+	_ = s.Save(ctx, "tx-1", PushCallbackResponse{ResponseCode: SUCCESS_CODE}, time.Minute)
+	if ack, ok, _ := s.Load(ctx, "tx-1"); !ok || ack.ResponseCode != SUCCESS_CODE {
+		t.Fatalf("Load() = %+v, %v", ack, ok)
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok, _ := s.Load(ctx, "tx-1"); ok {
+		t.Error("Load() found an expired key")
+	}
+	if ok, _ := s.Reserve(ctx, "tx-1", time.Minute); !ok {
+		t.Error("Reserve() = false for an expired key")
+	}
+}
+
+func TestMemoryCallbackStoreEviction(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2021, 12, 31, 14, 30, 0, 0, time.UTC)
+	s := NewMemoryCallbackStore()
+	s.now = func() time.Time { return now }
+
+	for i := 0; i < 100; i++ {
+		_, _ = s.Reserve(ctx, fmt.Sprintf("tx-%d", i), time.Minute)
+	}
+	_ = s.Release(ctx, "tx-1")
+	now = now.Add(30 * time.Second)
+	_ = s.Save(ctx, "tx-0", PushCallbackResponse{ResponseCode: SUCCESS_CODE}, time.Minute)
+
+	now = now.Add(30 * time.Second)
+	if ok, _ := s.Reserve(ctx, "tx-new", time.Minute); !ok {
+		t.Fatal("Reserve() = false for a new key")
+	}
+	if len(s.entries) != 2 || len(s.expiries) != 2 {
+		t.Errorf("entries = %d, queued expiries = %d, want the 99 expired keys dropped", len(s.entries), len(s.expiries))
+	}
+	if ack, ok, _ := s.Load(ctx, "tx-0"); !ok || ack.ResponseCode != SUCCESS_CODE {
+		t.Errorf("Load() = %+v, %v, want the entry saved later kept", ack, ok)
+	}
+}
@@ -1,6 +1,10 @@
 package mpesa
 
-import "context"
+import (
+	"context"
+	"strings"
+	"time"
+)
 
 const (
 	ONCE_OFF  DirectDebitFrequency = "01"
@@ -13,6 +17,59 @@ const (
 	ON_DEMAND DirectDebitFrequency = "08"
 )
 
+const (
+	MandateStatusUnknown MandateStatus = iota
+	MandateStatusPending
+	MandateStatusActive
+	MandateStatusSuspended
+	MandateStatusCancelled
+	MandateStatusExpired
+)
+
+// MandateStatus is the state of a direct debit mandate as reported by QueryDirectDebit.
+// Statuses the package does not know about are parsed as MandateStatusUnknown.
+type MandateStatus int
+
+func (s MandateStatus) String() string {
+	switch s {
+	case MandateStatusPending:
+		return "Pending"
+	case MandateStatusActive:
+		return "Active"
+	case MandateStatusSuspended:
+		return "Suspended"
+	case MandateStatusCancelled:
+		return "Cancelled"
+	case MandateStatusExpired:
+		return "Expired"
+	default:
+		return "Unknown"
+	}
+}
+
+func (s MandateStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *MandateStatus) UnmarshalText(text []byte) error {
+	switch strings.ToLower(strings.TrimSpace(string(text))) {
+	case "pending":
+		*s = MandateStatusPending
+	case "active":
+		*s = MandateStatusActive
+	case "suspended":
+		*s = MandateStatusSuspended
+	case "cancelled", "canceled":
+		*s = MandateStatusCancelled
+	case "expired":
+		*s = MandateStatusExpired
+	default:
+		*s = MandateStatusUnknown
+	}
+
+	return nil
+}
+
 func (f DirectDebitFrequency) String() string {
 	return string(f)
 }
@@ -43,15 +100,38 @@ type DirectDebitFrequency string
 //
 // •	Create a Direct Debit mandate
 // •	Pay a mandate
+// •	Cancel a mandate
+// •	Query a mandate
 //
 // The customer is able to view and cancel the Direct Debit mandate from G2 menu accessible via USSD menu
 // or the Smartphone Application.
 type directDebit interface {
 	DirectDebitCreate(ctx context.Context, m Mode, req DirectDebitCreateRequest) (DirectDebitCreateResponse, error)
-	DirectDebitPay(ctx context.Context, m Mode, req DirectDebitPayRequest) (DirectDebitPayResponse, error)
+	DirectDebitPay(ctx context.Context, m Mode, req DirectDebitPaymentRequest) (DirectDebitPaymentResponse, error)
+	DirectDebitCancel(ctx context.Context, m Mode, req DirectDebitCancelRequest) (DirectDebitCancelResponse, error)
+	DirectDebitQuery(ctx context.Context, m Mode, req QueryDirectDebitParams) (QueryDirectDebitResponse, error)
+}
+
+// DirectDebitCreateRequest contains the details of a direct debit mandate to create.
+// MSISDN and Reference are required. FirstPaymentDate, Frequency,
+// StartRangeOfDays, EndRangeOfDays and ExpiryDate are optional and are left out
+// of the request when they hold their zero value, see DirectDebitFrequency for
+// the rules that apply to them. ServiceProviderCode overrides
+// Config.ServiceProvideCode.
+type DirectDebitCreateRequest struct {
+	MSISDN              string
+	Reference           string
+	ThirdPartyID        string
+	AgreedTC            bool
+	FirstPaymentDate    time.Time
+	Frequency           DirectDebitFrequency
+	StartRangeOfDays    int
+	EndRangeOfDays      int
+	ExpiryDate          time.Time
+	ServiceProviderCode string
 }
 
-// DirectDebitCreateRequest is the request body for creating a direct debit
+// directDebitCreateRequest is the request body for creating a direct debit
 // CustomerMSISDN	The MSISDN of the customer where funds will be debitted from.	True	^[0-9]{12,14}$	254707161122
 // Country	The country of the mobile money platform where the transaction needs happen on.	True	N/A	GHA
 // ServiceProviderCode	The shortcode of the organization where funds will be creditted to.	True	^([0-9A-Za-z]{4,12})$	ORG001
@@ -63,16 +143,16 @@ type directDebit interface {
 // StartRangeOfDays	The start range of days in the month.	False	^[0-9]{2}$	01
 // EndRangeOfDays	The end range of days in the month.	False	^[0-9]{2}$	22
 // ExpiryDate	The expiry date of the Mandate.	False	^[0-9]{8}$	20190410
-type DirectDebitCreateRequest struct {
+type directDebitCreateRequest struct {
 	AgreedTC                 string `json:"input_AgreedTC"`
 	Country                  string `json:"input_Country"`
 	CustomerMSISDN           string `json:"input_CustomerMSISDN"`
-	EndRangeOfDays           string `json:"input_EndRangeOfDays"`
-	ExpiryDate               string `json:"input_ExpiryDate"`
-	FirstPaymentDate         string `json:"input_FirstPaymentDate"`
-	Frequency                string `json:"input_Frequency"`
+	EndRangeOfDays           string `json:"input_EndRangeOfDays,omitempty"`
+	ExpiryDate               string `json:"input_ExpiryDate,omitempty"`
+	FirstPaymentDate         string `json:"input_FirstPaymentDate,omitempty"`
+	Frequency                string `json:"input_Frequency,omitempty"`
 	ServiceProviderCode      string `json:"input_ServiceProviderCode"`
-	StartRangeOfDays         string `json:"input_StartRangeOfDays"`
+	StartRangeOfDays         string `json:"input_StartRangeOfDays,omitempty"`
 	ThirdPartyConversationID string `json:"input_ThirdPartyConversationID"`
 	ThirdPartyReference      string `json:"input_ThirdPartyReference"`
 }
@@ -85,16 +165,36 @@ type DirectDebitCreateRequest struct {
 // ConversationID	The OpenAPI platform generates this as a reference to the transaction.	fd1e9143d22544459f7c66e1860ef276
 // ThirdPartyConversationID	The incoming reference from the third party system. When there are queries about transactions, this will usually be used to track a transaction.	1e9b774d1da34af78412a498cbc28f5e
 type DirectDebitCreateResponse struct {
-	ResponseCode             string `json:"output_ResponseCode"`
-	ResponseDesc             string `json:"output_ResponseDesc"`
-	TransactionReference     string `json:"output_TransactionReference"`
-	MsisdnToken              string `json:"output_MsisdnToken"`
-	ConversationID           string `json:"output_ConversationID"`
-	ThirdPartyConversationID string `json:"output_ThirdPartyConversationID"`
+	ResponseCode             string    `json:"output_ResponseCode"`
+	ResponseDesc             string    `json:"output_ResponseDesc"`
+	TransactionReference     string    `json:"output_TransactionReference"`
+	MsisdnToken              string    `json:"output_MsisdnToken"`
+	ConversationID           string    `json:"output_ConversationID"`
+	ThirdPartyConversationID string    `json:"output_ThirdPartyConversationID"`
+	OutputErr                string    `json:"output_error,omitempty"`
+	HTTP                     *HTTPInfo `json:"-"`
 }
 
-// DirectDebitPayRequest is the request body for paying a direct debit
-//  MsisdnToken	The previously returned encrypted MSISDN of the customer where funds will be debitted from. 	False	^[0-9a-zA-Z \w=]{1,26}$	cvgwUBZ3lAO9ivwhWAFeng==
+// DirectDebitPaymentRequest contains the details of a payment against an existing
+// direct debit mandate. The mandate is identified either by MandateID or by the
+// customer (MSISDN or the MsisdnToken returned on creation) together with the
+// mandate Reference. The MSISDN is normalized like for PushAsync, Currency
+// must be accepted in the market and is Market.Currency when empty, and
+// ServiceProviderCode overrides Config.ServiceProvideCode.
+type DirectDebitPaymentRequest struct {
+	MandateID           string
+	MsisdnToken         string
+	MSISDN              string
+	Reference           string
+	ThirdPartyID        string
+	Amount              Amount
+	Currency            string
+	ServiceProviderCode string
+}
+
+// directDebitPaymentRequest is the request body for paying a direct debit
+// MandateID	The mandate identifier of the direct debit being paid.	False	^[0-9a-zA-Z]{1,32}$	vgisfyn4b22w6tmqjftatq75lyuie6vc
+// MsisdnToken	The previously returned encrypted MSISDN of the customer where funds will be debitted from. 	False	^[0-9a-zA-Z \w=]{1,26}$	cvgwUBZ3lAO9ivwhWAFeng==
 // CustomerMSISDN	The MSISDN of the customer where funds will be debitted from.	False	^[0-9]{12,14}$	254707161122
 // Country	The country of the mobile money platform where the transaction needs happen on.	True	N/A	GHA
 // ServiceProviderCode	The shortcode of the organization where funds will be creditted to.	True	^([0-9A-Za-z]{4,12})$	ORG001
@@ -102,29 +202,172 @@ type DirectDebitCreateResponse struct {
 // ThirdPartyConversationID	The third party's transaction reference on their system.	True	^[0-9a-zA-Z \w+]{1,40}$	1e9b774d1da34af78412a498cbc28f5e
 // Amount	The transaction amount. This amount will be moved from the organization's account to the customer's account.	True	^\d*\.?\d+$	10.00
 // Currency	The currency in which the transaction should take place.	True	^[a-zA-Z]{1,3}$	GHS
-type DirectDebitPayRequest struct {
-	MsisdnToken              string `json:"input_MsisdnToken"`
+type directDebitPaymentRequest struct {
+	MandateID                string `json:"input_MandateID,omitempty"`
+	MsisdnToken              string `json:"input_MsisdnToken,omitempty"`
 	Amount                   string `json:"input_Amount"`
 	Country                  string `json:"input_Country"`
 	Currency                 string `json:"input_Currency"`
-	CustomerMSISDN           string `json:"input_CustomerMSISDN"`
+	CustomerMSISDN           string `json:"input_CustomerMSISDN,omitempty"`
 	ServiceProviderCode      string `json:"input_ServiceProviderCode"`
 	ThirdPartyConversationID string `json:"input_ThirdPartyConversationID"`
-	ThirdPartyReference      string `json:"input_ThirdPartyReference"`
+	ThirdPartyReference      string `json:"input_ThirdPartyReference,omitempty"`
 }
 
-// DirectDebitPayResponse is the response body for paying a direct debit
+// DirectDebitPaymentResponse is the response body for paying a direct debit
 //  ResponseCode	The result code for the transaction.	INS-0
 // ResponseDesc	The result description for the transaction.	Request processed successfully
 // TransactionID	The transaction identifier that gets generated on the Mobile Money platform. This is used to query transactions on the Mobile Money Platform.	hv9ahxcg4ccv
 // MsisdnToken	The encrypted MSISDN Token, which can be used as an identifier. Only returned in successful messages.	cvgwUBZ3lAO9ivwhWAFeng==
 // ConversationID	The OpenAPI platform generates this as a reference to the transaction.	fd1e9143d22544459f7c66e1860ef276
 // ThirdPartyConversationID	The incoming reference from the third party system. When there are queries about transactions, this will usually be used to track a transaction.	1e9b774d1da34af78412a498cbc28f5e
-type DirectDebitPayResponse struct {
-	ResponseCode             string `json:"output_ResponseCode"`
-	ResponseDesc             string `json:"output_ResponseDesc"`
-	TransactionReference     string `json:"output_TransactionReference"`
-	MsisdnToken              string `json:"output_MsisdnToken"`
-	ConversationID           string `json:"output_ConversationID"`
-	ThirdPartyConversationID string `json:"output_ThirdPartyConversationID"`
+type DirectDebitPaymentResponse struct {
+	ResponseCode             string    `json:"output_ResponseCode"`
+	ResponseDesc             string    `json:"output_ResponseDesc"`
+	TransactionID            string    `json:"output_TransactionID"`
+	MsisdnToken              string    `json:"output_MsisdnToken"`
+	ConversationID           string    `json:"output_ConversationID"`
+	ThirdPartyConversationID string    `json:"output_ThirdPartyConversationID"`
+	OutputErr                string    `json:"output_error,omitempty"`
+	HTTP                     *HTTPInfo `json:"-"`
+}
+
+// DirectDebitCancelRequest contains the details of the direct debit mandate to cancel.
+// The mandate is identified either by AgreementID or by the customer (MSISDN or the
+// MsisdnToken returned on creation) together with the mandate Reference.
+// ServiceProviderCode overrides Config.ServiceProvideCode.
+type DirectDebitCancelRequest struct {
+	AgreementID         string
+	MsisdnToken         string
+	MSISDN              string
+	Reference           string
+	ThirdPartyID        string
+	ServiceProviderCode string
+}
+
+// directDebitCancelRequest is the request body for cancelling a direct debit
+// AgreementID	The agreement identifier of the mandate to cancel.	False	^[0-9a-zA-Z]{1,32}$	vgisfyn4b22w6tmqjftatq75lyuie6vc
+// MsisdnToken	The previously returned encrypted MSISDN of the customer.	False	^[0-9a-zA-Z \w=]{1,26}$	cvgwUBZ3lAO9ivwhWAFeng==
+// CustomerMSISDN	The MSISDN of the customer that created the mandate.	False	^[0-9]{12,14}$	254707161122
+// Country	The country of the mobile money platform where the transaction needs happen on.	True	N/A	GHA
+// ServiceProviderCode	The shortcode of the organization that owns the mandate.	True	^([0-9A-Za-z]{4,12})$	ORG001
+// ThirdPartyReference	The direct debit's mandate reference	False	^[0-9a-zA-Z]{1,32}$	Test123
+// ThirdPartyConversationID	The third party's transaction reference on their system.	True	^[0-9a-zA-Z \w+]{1,40}$	1e9b774d1da34af78412a498cbc28f5e
+type directDebitCancelRequest struct {
+	AgreementID              string `json:"input_AgreementID,omitempty"`
+	MsisdnToken              string `json:"input_MsisdnToken,omitempty"`
+	CustomerMSISDN           string `json:"input_CustomerMSISDN,omitempty"`
+	Country                  string `json:"input_Country"`
+	ServiceProviderCode      string `json:"input_ServiceProviderCode"`
+	ThirdPartyReference      string `json:"input_ThirdPartyReference,omitempty"`
+	ThirdPartyConversationID string `json:"input_ThirdPartyConversationID"`
+}
+
+// DirectDebitCancelResponse is the response body for cancelling a direct debit
+// ResponseCode	The result code for the transaction.	INS-0
+// ResponseDesc	The result description for the transaction.	Request processed successfully
+// ConversationID	The OpenAPI platform generates this as a reference to the transaction.	fd1e9143d22544459f7c66e1860ef276
+// ThirdPartyConversationID	The incoming reference from the third party system.	1e9b774d1da34af78412a498cbc28f5e
+type DirectDebitCancelResponse struct {
+	ResponseCode             string    `json:"output_ResponseCode"`
+	ResponseDesc             string    `json:"output_ResponseDesc"`
+	ConversationID           string    `json:"output_ConversationID"`
+	ThirdPartyConversationID string    `json:"output_ThirdPartyConversationID"`
+	OutputErr                string    `json:"output_error,omitempty"`
+	HTTP                     *HTTPInfo `json:"-"`
+}
+
+// QueryDirectDebitParams identifies the direct debit mandate to query, either by
+// AgreementID or by the customer MSISDN together with the mandate Reference.
+// ServiceProviderCode overrides Config.ServiceProvideCode.
+type QueryDirectDebitParams struct {
+	AgreementID         string
+	MSISDN              string
+	Reference           string
+	ThirdPartyID        string
+	ServiceProviderCode string
 }
+
+// queryDirectDebitRequest is the request body for querying a direct debit
+// AgreementID	The agreement identifier of the mandate to query.	False	^[0-9a-zA-Z]{1,32}$	vgisfyn4b22w6tmqjftatq75lyuie6vc
+// CustomerMSISDN	The MSISDN of the customer that created the mandate.	False	^[0-9]{12,14}$	254707161122
+// Country	The country of the mobile money platform where the transaction needs happen on.	True	N/A	GHA
+// ServiceProviderCode	The shortcode of the organization that owns the mandate.	True	^([0-9A-Za-z]{4,12})$	ORG001
+// ThirdPartyReference	The direct debit's mandate reference	False	^[0-9a-zA-Z]{1,32}$	Test123
+// ThirdPartyConversationID	The third party's transaction reference on their system.	True	^[0-9a-zA-Z \w+]{1,40}$	1e9b774d1da34af78412a498cbc28f5e
+type queryDirectDebitRequest struct {
+	AgreementID              string `json:"input_AgreementID,omitempty"`
+	CustomerMSISDN           string `json:"input_CustomerMSISDN,omitempty"`
+	Country                  string `json:"input_Country"`
+	ServiceProviderCode      string `json:"input_ServiceProviderCode"`
+	ThirdPartyReference      string `json:"input_ThirdPartyReference,omitempty"`
+	ThirdPartyConversationID string `json:"input_ThirdPartyConversationID"`
+}
+
+// QueryDirectDebitResponse is the response body for querying a direct debit
+// ResponseCode	The result code for the transaction.	INS-0
+// ResponseDesc	The result description for the transaction.	Request processed successfully
+// AgreementID	The agreement identifier of the mandate.	vgisfyn4b22w6tmqjftatq75lyuie6vc
+// MandateStatus	The state of the mandate.	Active
+// NextPaymentDate	The date of the next charge against the mandate.	20190305
+// ConversationID	The OpenAPI platform generates this as a reference to the transaction.	fd1e9143d22544459f7c66e1860ef276
+// ThirdPartyConversationID	The incoming reference from the third party system.	1e9b774d1da34af78412a498cbc28f5e
+type QueryDirectDebitResponse struct {
+	ResponseCode             string        `json:"output_ResponseCode"`
+	ResponseDesc             string        `json:"output_ResponseDesc"`
+	AgreementID              string        `json:"output_AgreementID"`
+	MandateStatus            MandateStatus `json:"output_MandateStatus"`
+	NextPaymentDate          string        `json:"output_NextPaymentDate"`
+	ConversationID           string        `json:"output_ConversationID"`
+	ThirdPartyConversationID string        `json:"output_ThirdPartyConversationID"`
+	OutputErr                string        `json:"output_error,omitempty"`
+	HTTP                     *HTTPInfo     `json:"-"`
+
+	location *time.Location
+}
+
+// NextPayment parses NextPaymentDate as midnight in the location of the
+// Client, see Client.Location. It returns the zero time when the gateway did
+// not report a next charge date.
+func (r QueryDirectDebitResponse) NextPayment() (time.Time, error) {
+	if r.NextPaymentDate == "" {
+		return time.Time{}, nil
+	}
+
+	loc := r.location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	return time.ParseInLocation(gatewayDateLayout, r.NextPaymentDate, loc)
+}
+
+func (r *QueryDirectDebitResponse) setLocation(loc *time.Location) { r.location = loc }
+
+// Code returns ResponseCode as a ResponseCode.
+func (r DirectDebitCreateResponse) Code() ResponseCode { return ResponseCode(r.ResponseCode) }
+
+func (r *DirectDebitCreateResponse) responseCode() ResponseCode { return r.Code() }
+
+func (r *DirectDebitCreateResponse) setHTTP(info *HTTPInfo) { r.HTTP = info }
+
+// Code returns ResponseCode as a ResponseCode.
+func (r DirectDebitPaymentResponse) Code() ResponseCode { return ResponseCode(r.ResponseCode) }
+
+func (r *DirectDebitPaymentResponse) responseCode() ResponseCode { return r.Code() }
+
+func (r *DirectDebitPaymentResponse) setHTTP(info *HTTPInfo) { r.HTTP = info }
+
+// Code returns ResponseCode as a ResponseCode.
+func (r DirectDebitCancelResponse) Code() ResponseCode { return ResponseCode(r.ResponseCode) }
+
+func (r *DirectDebitCancelResponse) responseCode() ResponseCode { return r.Code() }
+
+func (r *DirectDebitCancelResponse) setHTTP(info *HTTPInfo) { r.HTTP = info }
+
+// Code returns ResponseCode as a ResponseCode.
+func (r QueryDirectDebitResponse) Code() ResponseCode { return ResponseCode(r.ResponseCode) }
+
+func (r *QueryDirectDebitResponse) responseCode() ResponseCode { return r.Code() }
+
+func (r *QueryDirectDebitResponse) setHTTP(info *HTTPInfo) { r.HTTP = info }
@@ -0,0 +1,573 @@
+package mpesa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCreateDirectDebit(t *testing.T) {
+	tests := []struct {
+		name    string
+		request DirectDebitCreateRequest
+		want    map[string]string
+	}{
+		{
+			name: "monthly",
+			request: DirectDebitCreateRequest{
+				MSISDN:           "255754000000",
+				Reference:        "Test123",
+				ThirdPartyID:     "1e9b774d1da34af78412a498cbc28f5e",
+				AgreedTC:         true,
+				FirstPaymentDate: time.Date(2019, 2, 5, 9, 0, 0, 0, time.UTC),
+				Frequency:        MONTHLY,
+				StartRangeOfDays: 1,
+				EndRangeOfDays:   22,
+				ExpiryDate:       time.Date(2019, 4, 10, 9, 0, 0, 0, time.UTC),
+			},
+			want: map[string]string{
+				"input_AgreedTC":                 "1",
+				"input_Country":                  "TZN",
+				"input_CustomerMSISDN":           "255754000000",
+				"input_EndRangeOfDays":           "22",
+				"input_ExpiryDate":               "20190410",
+				"input_FirstPaymentDate":         "20190205",
+				"input_Frequency":                "04",
+				"input_ServiceProviderCode":      "000000",
+				"input_StartRangeOfDays":         "01",
+				"input_ThirdPartyConversationID": "1e9b774d1da34af78412a498cbc28f5e",
+				"input_ThirdPartyReference":      "Test123",
+			},
+		},
+		{
+			name: "service provider code override",
+			request: DirectDebitCreateRequest{
+				MSISDN:              "255754000000",
+				Reference:           "Test123",
+				ThirdPartyID:        "1e9b774d1da34af78412a498cbc28f5e",
+				AgreedTC:            true,
+				ServiceProviderCode: "171717",
+			},
+			want: map[string]string{
+				"input_AgreedTC":                 "1",
+				"input_Country":                  "TZN",
+				"input_CustomerMSISDN":           "255754000000",
+				"input_ServiceProviderCode":      "171717",
+				"input_ThirdPartyConversationID": "1e9b774d1da34af78412a498cbc28f5e",
+				"input_ThirdPartyReference":      "Test123",
+			},
+		},
+		{
+			name: "optional fields not set",
+			request: DirectDebitCreateRequest{
+				MSISDN:       "255754000000",
+				Reference:    "Test123",
+				ThirdPartyID: "1e9b774d1da34af78412a498cbc28f5e",
+			},
+			want: map[string]string{
+				"input_AgreedTC":                 "0",
+				"input_Country":                  "TZN",
+				"input_CustomerMSISDN":           "255754000000",
+				"input_ServiceProviderCode":      "000000",
+				"input_ThirdPartyConversationID": "1e9b774d1da34af78412a498cbc28f5e",
+				"input_ThirdPartyReference":      "Test123",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGateway(t)
+			g.handlers["directDebitCreation/"] = func(w http.ResponseWriter, r *http.Request) {
+				var payload map[string]string
+				if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+					t.Errorf("decode payload: %v", err)
+				}
+				if !reflect.DeepEqual(payload, tt.want) {
+					t.Errorf("payload = %v, want %v", payload, tt.want)
+				}
+
+				writeJSON(w, http.StatusCreated, DirectDebitCreateResponse{
+					ResponseCode:             "INS-0",
+					ResponseDesc:             "Request processed successfully",
+					TransactionReference:     "vgisfyn4b22w6tmqjftatq75lyuie6vc",
+					MsisdnToken:              "cvgwUBZ3lAO9ivwhWAFeng==",
+					ConversationID:           "fd1e9143d22544459f7c66e1860ef276",
+					ThirdPartyConversationID: "1e9b774d1da34af78412a498cbc28f5e",
+				})
+			}
+
+			response, err := g.client().CreateDirectDebit(context.Background(), tt.request)
+			if err != nil {
+				t.Fatalf("CreateDirectDebit() error = %v", err)
+			}
+			if response.TransactionReference != "vgisfyn4b22w6tmqjftatq75lyuie6vc" || response.MsisdnToken != "cvgwUBZ3lAO9ivwhWAFeng==" {
+				t.Errorf("CreateDirectDebit() = %+v", response)
+			}
+		})
+	}
+}
+
+func TestCreateDirectDebitRequiredFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		request DirectDebitCreateRequest
+		wantErr string
+	}{
+		{name: "no msisdn", request: DirectDebitCreateRequest{Reference: "Test123", ThirdPartyID: "tp-1"}, wantErr: "MSISDN is required"},
+		{name: "msisdn of another market", request: DirectDebitCreateRequest{MSISDN: "233241234567", Reference: "Test123", ThirdPartyID: "tp-1"}, wantErr: "MSISDN must start with the dialing prefix 255"},
+		{name: "no reference", request: DirectDebitCreateRequest{MSISDN: "255754000000", ThirdPartyID: "tp-1"}, wantErr: "Reference is required"},
+		{name: "invalid reference", request: DirectDebitCreateRequest{MSISDN: "255754000000", Reference: "Test-123", ThirdPartyID: "tp-1"}, wantErr: `Reference character '-' is not allowed`},
+		{name: "long third party id", request: DirectDebitCreateRequest{MSISDN: "255754000000", Reference: "Test123", ThirdPartyID: strings.Repeat("t", 41)}, wantErr: "ThirdPartyID must be at most 40 characters"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGateway(t)
+			var sent int32
+			g.handlers["directDebitCreation/"] = func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&sent, 1)
+			}
+
+			_, err := g.client().CreateDirectDebit(context.Background(), tt.request)
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("CreateDirectDebit() error = %v, want a *ValidationError with %q", err, tt.wantErr)
+			}
+			if n := atomic.LoadInt32(&sent); n != 0 {
+				t.Errorf("direct debit creations sent = %d, want 0", n)
+			}
+		})
+	}
+}
+
+func TestCreateDirectDebitRangeOfDays(t *testing.T) {
+	tests := []struct {
+		name    string
+		start   int
+		end     int
+		wantErr string
+	}{
+		{name: "start before the first day", start: -1, end: 22, wantErr: "StartRangeOfDays must be between 1 and 31, or 0 when not set, got -1"},
+		{name: "start after the last day", start: 32, end: 22, wantErr: "StartRangeOfDays must be between 1 and 31, or 0 when not set, got 32"},
+		{name: "end after the last day", start: 1, end: 32, wantErr: "EndRangeOfDays must be between 1 and 31, or 0 when not set, got 32"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGateway(t)
+			var sent int32
+			g.handlers["directDebitCreation/"] = func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&sent, 1)
+			}
+
+			_, err := g.client().CreateDirectDebit(context.Background(), DirectDebitCreateRequest{
+				MSISDN:           "255754000000",
+				Reference:        "Test123",
+				ThirdPartyID:     "1e9b774d1da34af78412a498cbc28f5e",
+				Frequency:        MONTHLY,
+				StartRangeOfDays: tt.start,
+				EndRangeOfDays:   tt.end,
+			})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("CreateDirectDebit() error = %v, want %q", err, tt.wantErr)
+			}
+			if n := atomic.LoadInt32(&sent); n != 0 {
+				t.Errorf("direct debit creations sent = %d, want 0", n)
+			}
+		})
+	}
+}
+
+func TestDirectDebitPayment(t *testing.T) {
+	tests := []struct {
+		name    string
+		request DirectDebitPaymentRequest
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name: "mandate id",
+			request: DirectDebitPaymentRequest{
+				MandateID:    "vgisfyn4b22w6tmqjftatq75lyuie6vc",
+				ThirdPartyID: "1e9b774d1da34af78412a498cbc28f5e",
+				Amount:       MustParseAmount("10"),
+			},
+			want: map[string]string{
+				"input_MandateID":                "vgisfyn4b22w6tmqjftatq75lyuie6vc",
+				"input_Amount":                   "10.00",
+				"input_Country":                  "TZN",
+				"input_Currency":                 "TZS",
+				"input_ServiceProviderCode":      "000000",
+				"input_ThirdPartyConversationID": "1e9b774d1da34af78412a498cbc28f5e",
+			},
+		},
+		{
+			name: "msisdn and reference",
+			request: DirectDebitPaymentRequest{
+				MSISDN:       "255754000000",
+				Reference:    "Test123",
+				ThirdPartyID: "1e9b774d1da34af78412a498cbc28f5e",
+				Amount:       MustParseAmount("2500.5"),
+			},
+			want: map[string]string{
+				"input_CustomerMSISDN":           "255754000000",
+				"input_ThirdPartyReference":      "Test123",
+				"input_Amount":                   "2500.50",
+				"input_Country":                  "TZN",
+				"input_Currency":                 "TZS",
+				"input_ServiceProviderCode":      "000000",
+				"input_ThirdPartyConversationID": "1e9b774d1da34af78412a498cbc28f5e",
+			},
+		},
+		{
+			name: "currency and service provider code override",
+			request: DirectDebitPaymentRequest{
+				MandateID:           "vgisfyn4b22w6tmqjftatq75lyuie6vc",
+				ThirdPartyID:        "1e9b774d1da34af78412a498cbc28f5e",
+				Amount:              MustParseAmount("10"),
+				Currency:            "TZS",
+				ServiceProviderCode: "171717",
+			},
+			want: map[string]string{
+				"input_MandateID":                "vgisfyn4b22w6tmqjftatq75lyuie6vc",
+				"input_Amount":                   "10.00",
+				"input_Country":                  "TZN",
+				"input_Currency":                 "TZS",
+				"input_ServiceProviderCode":      "171717",
+				"input_ThirdPartyConversationID": "1e9b774d1da34af78412a498cbc28f5e",
+			},
+		},
+		{
+			name: "msisdn token and reference",
+			request: DirectDebitPaymentRequest{
+				MsisdnToken:  "cvgwUBZ3lAO9ivwhWAFeng==",
+				Reference:    "Test123",
+				ThirdPartyID: "1e9b774d1da34af78412a498cbc28f5e",
+				Amount:       MustParseAmount("10"),
+			},
+			want: map[string]string{
+				"input_MsisdnToken":              "cvgwUBZ3lAO9ivwhWAFeng==",
+				"input_ThirdPartyReference":      "Test123",
+				"input_Amount":                   "10.00",
+				"input_Country":                  "TZN",
+				"input_Currency":                 "TZS",
+				"input_ServiceProviderCode":      "000000",
+				"input_ThirdPartyConversationID": "1e9b774d1da34af78412a498cbc28f5e",
+			},
+		},
+		{
+			name: "local msisdn",
+			request: DirectDebitPaymentRequest{
+				MSISDN:       "0754 000 000",
+				Reference:    "Test123",
+				ThirdPartyID: "1e9b774d1da34af78412a498cbc28f5e",
+				Amount:       MustParseAmount("10"),
+			},
+			want: map[string]string{
+				"input_CustomerMSISDN":           "255754000000",
+				"input_ThirdPartyReference":      "Test123",
+				"input_Amount":                   "10.00",
+				"input_Country":                  "TZN",
+				"input_Currency":                 "TZS",
+				"input_ServiceProviderCode":      "000000",
+				"input_ThirdPartyConversationID": "1e9b774d1da34af78412a498cbc28f5e",
+			},
+		},
+		{
+			name: "msisdn without reference",
+			request: DirectDebitPaymentRequest{
+				MSISDN:       "255754000000",
+				ThirdPartyID: "1e9b774d1da34af78412a498cbc28f5e",
+				Amount:       MustParseAmount("10"),
+			},
+			wantErr: "either the mandate id or the customer msisdn",
+		},
+		{
+			name: "reference without customer",
+			request: DirectDebitPaymentRequest{
+				Reference:    "Test123",
+				ThirdPartyID: "1e9b774d1da34af78412a498cbc28f5e",
+				Amount:       MustParseAmount("10"),
+			},
+			wantErr: "either the mandate id or the customer msisdn",
+		},
+		{
+			name: "msisdn of another market",
+			request: DirectDebitPaymentRequest{
+				MSISDN:       "233241234567",
+				Reference:    "Test123",
+				ThirdPartyID: "1e9b774d1da34af78412a498cbc28f5e",
+				Amount:       MustParseAmount("10"),
+			},
+			wantErr: "MSISDN must start with the dialing prefix 255",
+		},
+		{
+			name: "zero amount",
+			request: DirectDebitPaymentRequest{
+				MandateID:    "vgisfyn4b22w6tmqjftatq75lyuie6vc",
+				ThirdPartyID: "1e9b774d1da34af78412a498cbc28f5e",
+			},
+			wantErr: "Amount must be positive",
+		},
+		{
+			name: "currency of another market",
+			request: DirectDebitPaymentRequest{
+				MandateID:    "vgisfyn4b22w6tmqjftatq75lyuie6vc",
+				ThirdPartyID: "1e9b774d1da34af78412a498cbc28f5e",
+				Amount:       MustParseAmount("10"),
+				Currency:     "USD",
+			},
+			wantErr: `Currency must be one of TZS`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGateway(t)
+			var sent int32
+			g.handlers["directDebitPayment/"] = func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&sent, 1)
+				var payload map[string]string
+				if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+					t.Errorf("decode payload: %v", err)
+				}
+				if !reflect.DeepEqual(payload, tt.want) {
+					t.Errorf("payload = %v, want %v", payload, tt.want)
+				}
+
+				writeJSON(w, http.StatusCreated, DirectDebitPaymentResponse{
+					ResponseCode:             "INS-0",
+					ResponseDesc:             "Request processed successfully",
+					TransactionID:            "hv9ahxcg4ccv",
+					ConversationID:           "fd1e9143d22544459f7c66e1860ef276",
+					ThirdPartyConversationID: "1e9b774d1da34af78412a498cbc28f5e",
+				})
+			}
+
+			response, err := g.client().DirectDebitPayment(context.Background(), tt.request)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("DirectDebitPayment() error = %v, want %q", err, tt.wantErr)
+				}
+				if n := atomic.LoadInt32(&sent); n != 0 {
+					t.Errorf("direct debit payments sent = %d, want 0", n)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DirectDebitPayment() error = %v", err)
+			}
+			if response.TransactionID != "hv9ahxcg4ccv" {
+				t.Errorf("DirectDebitPayment() transaction id = %s, want hv9ahxcg4ccv", response.TransactionID)
+			}
+		})
+	}
+}
+
+func TestCancelDirectDebit(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["directDebitCancel/"] = func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("decode payload: %v", err)
+		}
+
+		want := map[string]string{
+			"input_AgreementID":              "vgisfyn4b22w6tmqjftatq75lyuie6vc",
+			"input_MsisdnToken":              "cvgwUBZ3lAO9ivwhWAFeng==",
+			"input_Country":                  "TZN",
+			"input_ServiceProviderCode":      "000000",
+			"input_ThirdPartyConversationID": "1e9b774d1da34af78412a498cbc28f5e",
+		}
+		if !reflect.DeepEqual(payload, want) {
+			t.Errorf("payload = %v, want %v", payload, want)
+		}
+
+		writeJSON(w, http.StatusOK, DirectDebitCancelResponse{
+			ResponseCode:             "INS-0",
+			ResponseDesc:             "Request processed successfully",
+			ConversationID:           "fd1e9143d22544459f7c66e1860ef276",
+			ThirdPartyConversationID: "1e9b774d1da34af78412a498cbc28f5e",
+		})
+	}
+
+	c := g.client()
+	response, err := c.CancelDirectDebit(context.Background(), DirectDebitCancelRequest{
+		AgreementID:  "vgisfyn4b22w6tmqjftatq75lyuie6vc",
+		MsisdnToken:  "cvgwUBZ3lAO9ivwhWAFeng==",
+		ThirdPartyID: "1e9b774d1da34af78412a498cbc28f5e",
+	})
+	if err != nil {
+		t.Fatalf("CancelDirectDebit() error = %v", err)
+	}
+	if response.ResponseCode != "INS-0" {
+		t.Errorf("CancelDirectDebit() response code = %s, want INS-0", response.ResponseCode)
+	}
+}
+
+func TestCancelDirectDebitOutputErr(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["directDebitCancel/"] = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusBadRequest, DirectDebitCancelResponse{
+			ResponseCode: "INS-50",
+			OutputErr:    "MSISDN Token and MSISDN provided does not Match",
+		})
+	}
+
+	c := g.client()
+	_, err := c.CancelDirectDebit(context.Background(), DirectDebitCancelRequest{
+		MSISDN:    "255754000000",
+		Reference: "Test123",
+	})
+
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		t.Fatalf("CancelDirectDebit() error = %v, want *APIError", err)
+	}
+	if apiErr.Code != "INS-50" {
+		t.Errorf("APIError.Code = %s, want INS-50", apiErr.Code)
+	}
+
+	_, err = c.CancelDirectDebit(context.Background(), DirectDebitCancelRequest{MSISDN: "255754000000"})
+	if err == nil {
+		t.Error("CancelDirectDebit() without a mandate reference error = nil, want an error")
+	}
+}
+
+func TestQueryDirectDebit(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["queryDirectDebit/"] = func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("input_AgreementID"); got != "vgisfyn4b22w6tmqjftatq75lyuie6vc" {
+			t.Errorf("input_AgreementID = %q", got)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+			"output_ResponseCode": "INS-0",
+			"output_ResponseDesc": "Request processed successfully",
+			"output_AgreementID": "vgisfyn4b22w6tmqjftatq75lyuie6vc",
+			"output_MandateStatus": "ACTIVE",
+			"output_NextPaymentDate": "20190305"
+		}`))
+	}
+
+	c := g.client()
+	response, err := c.QueryDirectDebit(context.Background(), QueryDirectDebitParams{
+		AgreementID: "vgisfyn4b22w6tmqjftatq75lyuie6vc",
+	})
+	if err != nil {
+		t.Fatalf("QueryDirectDebit() error = %v", err)
+	}
+
+	if response.MandateStatus != MandateStatusActive {
+		t.Errorf("MandateStatus = %v, want %v", response.MandateStatus, MandateStatusActive)
+	}
+	next, err := response.NextPayment()
+	if err != nil || next.Format("2006-01-02") != "2019-03-05" {
+		t.Errorf("NextPayment() = %v, %v, want 2019-03-05", next, err)
+	}
+}
+
+func TestDirectDebitServiceProviderCode(t *testing.T) {
+	g := newTestGateway(t)
+	var codes []string
+	record := func(w http.ResponseWriter, r *http.Request) {
+		code := r.URL.Query().Get("input_ServiceProviderCode")
+		if code == "" {
+			var payload map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&payload)
+			code = payload["input_ServiceProviderCode"]
+		}
+		codes = append(codes, code)
+		writeJSON(w, http.StatusOK, map[string]string{"output_ResponseCode": "INS-0"})
+	}
+	for _, path := range []string{"directDebitCreation/", "directDebitPayment/", "directDebitCancel/", "queryDirectDebit/", "queryBeneficiaryName/"} {
+		g.handlers[path] = record
+	}
+
+	c := g.client()
+	ctx := context.Background()
+	calls := func(code string) []error {
+		_, createErr := c.CreateDirectDebit(ctx, DirectDebitCreateRequest{MSISDN: "255754000000", Reference: "Test123", ThirdPartyID: "tp-1", ServiceProviderCode: code})
+		_, payErr := c.DirectDebitPayment(ctx, DirectDebitPaymentRequest{MandateID: "mandate", ThirdPartyID: "tp-1", Amount: MustParseAmount("10"), ServiceProviderCode: code})
+		_, cancelErr := c.CancelDirectDebit(ctx, DirectDebitCancelRequest{AgreementID: "agreement", ThirdPartyID: "tp-1", ServiceProviderCode: code})
+		_, queryErr := c.QueryDirectDebit(ctx, QueryDirectDebitParams{AgreementID: "agreement", ThirdPartyID: "tp-1", ServiceProviderCode: code})
+		_, nameErr := c.QueryBeneficiary(ctx, QueryBeneficiaryParams{MSISDN: "255754000000", ServiceProviderCode: code})
+		return []error{createErr, payErr, cancelErr, queryErr, nameErr}
+	}
+
+	for _, err := range calls("171717") {
+		if err != nil {
+			t.Fatalf("error = %v", err)
+		}
+	}
+	if want := []string{"171717", "171717", "171717", "171717", "171717"}; !reflect.DeepEqual(codes, want) {
+		t.Errorf("input_ServiceProviderCode = %v, want the override on every request", codes)
+	}
+
+	for i, err := range calls("ORG001") {
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) || !strings.Contains(err.Error(), "ServiceProviderCode") {
+			t.Errorf("call %d error = %v, want the short code rejected", i, err)
+		}
+	}
+	if len(codes) != 5 {
+		t.Errorf("requests sent = %d, want the invalid short codes rejected before being sent", len(codes))
+	}
+}
+
+func TestDirectDebitConversationID(t *testing.T) {
+	g := newTestGateway(t)
+	var ids []string
+	record := func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		ids = append(ids, payload["input_ThirdPartyConversationID"])
+		writeJSON(w, http.StatusCreated, map[string]string{"output_ResponseCode": "INS-0"})
+	}
+	g.handlers["directDebitCreation/"] = record
+	g.handlers["directDebitPayment/"] = record
+
+	ctx := context.Background()
+	c := g.client()
+	created, err := c.CreateDirectDebit(ctx, DirectDebitCreateRequest{MSISDN: "255754000000", Reference: "Test123"})
+	if err != nil {
+		t.Fatalf("CreateDirectDebit() error = %v", err)
+	}
+	paid, err := c.DirectDebitPayment(ctx, DirectDebitPaymentRequest{MandateID: "mandate", Amount: MustParseAmount("10")})
+	if err != nil {
+		t.Fatalf("DirectDebitPayment() error = %v", err)
+	}
+
+	if len(ids) != 2 {
+		t.Fatalf("requests sent = %d, want 2", len(ids))
+	}
+	for i, id := range ids {
+		if len(id) != 32 || id[12] != '4' {
+			t.Errorf("request %d: ThirdPartyConversationID = %q, want a generated UUID v4", i, id)
+		}
+	}
+	if created.ThirdPartyConversationID != ids[0] || paid.ThirdPartyConversationID != ids[1] {
+		t.Errorf("responses carry %q and %q, want the generated %q", created.ThirdPartyConversationID, paid.ThirdPartyConversationID, ids)
+	}
+
+	c = g.client(WithAutoConversationID(false))
+	_, createErr := c.CreateDirectDebit(ctx, DirectDebitCreateRequest{MSISDN: "255754000000", Reference: "Test123"})
+	_, payErr := c.DirectDebitPayment(ctx, DirectDebitPaymentRequest{MandateID: "mandate", Amount: MustParseAmount("10")})
+	for _, err := range []error{createErr, payErr} {
+		var fieldErr *FieldError
+		if !errors.As(err, &fieldErr) || fieldErr.Field != "ThirdPartyID" {
+			t.Errorf("error = %v, want a ThirdPartyID *FieldError", err)
+		}
+	}
+	if len(ids) != 2 {
+		t.Errorf("requests sent = %d, want the ones without ThirdPartyID rejected", len(ids))
+	}
+}
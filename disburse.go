@@ -2,7 +2,23 @@ package mpesa
 
 import "context"
 
+// DisburseRequest transfers Amount to the wallet of the customer owning MSISDN.
+// Description lists the paid items. ThirdPartyID, echoed back in the response
+// and in the callback, and Reference correlate the result with the request.
+// Currency is Market.Currency when empty, it must be one of Market.Currencies
+// otherwise. ServiceProviderCode, the short code debited with the amount,
+// overrides Config.ServiceProvideCode when set. IdempotencyKey is the business
+// key of the disbursement, e.g. the id of a payout, see WithIdempotencyStore.
+// It is not sent to the gateway.
 type DisburseRequest struct {
+	ThirdPartyID        string `json:"id,omitempty"`
+	Reference           string `json:"reference,omitempty"`
+	Amount              Amount `json:"amount"`
+	MSISDN              string `json:"msisdn,omitempty"`
+	Description         string `json:"description,omitempty"`
+	Currency            string `json:"currency,omitempty"`
+	ServiceProviderCode string `json:"service_provider_code,omitempty"`
+	IdempotencyKey      string `json:"idempotency_key,omitempty"`
 }
 
 type disburser interface {
@@ -10,3 +26,54 @@ type disburser interface {
 }
 
 type DisburseFunc func(ctx context.Context, mode Mode, request DisburseRequest) (DisburseResponse, error)
+
+var _ DisburseCallbackHandler = (*DisburseCallbackFunc)(nil)
+
+type (
+	// DisburseCallbackRequest is the result of a disbursement posted by the
+	// gateway to the result URL.
+	//
+	// OriginalConversationID	The conversation id of the Disburse request the result is for.	fd1e9143d22544459f7c66e1860ef276
+	// TransactionID	The M-Pesa transaction id of the disbursement.	49XCDF6
+	// ResultCode	The outcome of the disbursement, INS-0 on success.	INS-0
+	// ResultDesc	The description of ResultCode.	Request processed successfully
+	// ThirdPartyConversationID	The ThirdPartyID sent with the Disburse request.	1e9b774d1da34af78412a498cbc28f5e
+	// TransactionStatus	The final status of the transaction.	Completed
+	// Amount	The amount credited to the customer.	10.00
+	// CustomerMSISDN	The MSISDN the funds were credited to.	255744553111
+	// TransactionTime	When the gateway completed the transaction.	20211231143000
+	DisburseCallbackRequest struct {
+		OriginalConversationID   string `json:"input_OriginalConversationID"`
+		TransactionID            string `json:"input_TransactionID"`
+		ResultCode               string `json:"input_ResultCode"`
+		ResultDesc               string `json:"input_ResultDesc"`
+		ThirdPartyConversationID string `json:"input_ThirdPartyConversationID"`
+		TransactionStatus        string `json:"input_TransactionStatus"`
+		Amount                   Amount `json:"input_Amount"`
+		CustomerMSISDN           string `json:"input_CustomerMSISDN"`
+		TransactionTime          string `json:"input_TransactionTime"`
+	}
+
+	// DisburseCallbackResponse is the acknowledgement of a DisburseCallbackRequest.
+	DisburseCallbackResponse struct {
+		OriginalConversationID   string `json:"output_OriginalConversationID"`
+		ResponseCode             string `json:"output_ResponseCode"`
+		ResponseDesc             string `json:"output_ResponseDesc"`
+		ThirdPartyConversationID string `json:"output_ThirdPartyConversationID"`
+	}
+
+	// DisburseCallbackHandler processes the disbursement results received by
+	// DisburseCallbackServeHTTP. The context is cancelled when the gateway
+	// disconnects, the server shuts down or the callback timeout expires.
+	DisburseCallbackHandler interface {
+		HandleDisburseCallback(ctx context.Context, request DisburseCallbackRequest) (DisburseCallbackResponse, error)
+	}
+
+	// DisburseCallbackFunc adapts a function to DisburseCallbackHandler. A nil
+	// DisburseCallbackFunc is rejected at construction.
+	DisburseCallbackFunc func(ctx context.Context, request DisburseCallbackRequest) (DisburseCallbackResponse, error)
+)
+
+func (f DisburseCallbackFunc) HandleDisburseCallback(ctx context.Context, request DisburseCallbackRequest) (DisburseCallbackResponse, error) {
+	return f(ctx, request)
+}
@@ -0,0 +1,28 @@
+// Package mpesa is a client of the M-Pesa open API gateway of Vodacom and
+// Vodafone: customer payments, disbursements, business payments, reversals,
+// direct debits and transaction queries, and the callbacks the gateway sends
+// back.
+//
+// # Errors
+//
+// The errors returned by the Client tell how far a request went, so that the
+// callers can decide whether to retry, alert or give up:
+//
+//   - *ValidationError and *FieldError: the request was rejected before
+//     anything was sent. *ConfigError is the same for NewClient and the
+//     configuration loaders. Sending the same request again fails the same way.
+//   - *TransportError: the request got no response, e.g. a refused
+//     connection, a DNS or TLS failure or a timeout. The request may have
+//     reached the gateway, the outcome of a payment is unknown and must be
+//     checked with QueryTx. errors.Is(err, context.DeadlineExceeded) and
+//     os.IsTimeout work through it.
+//   - *GatewayError: a response was received but it is not one of the
+//     gateway, e.g. the HTML page of a load balancer or a truncated body.
+//   - *APIError: the gateway answered and rejected the request. Code is its
+//     response code, and errors.Is matches the sentinels such as
+//     ErrInsufficientBalance or ErrRateLimited.
+//
+// ErrCircuitOpen, ErrDryRun and ErrReservedHeader are returned without
+// anything being sent. DefaultRetryable retries the *TransportError, the
+// transient *GatewayError and the *APIError with a retryable status or code.
+package mpesa
@@ -0,0 +1,93 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/techcraftlabs/base"
+)
+
+// dryRunSessionID is the session id the requests are authenticated with in
+// dry-run mode.
+const dryRunSessionID = "dry-run"
+
+// ErrDryRun is matched by the *DryRunError returned by the operations of a
+// Client in dry-run mode.
+var ErrDryRun = errors.New("mpesa: dry run")
+
+// DryRunError is returned instead of a response by the operations of a Client
+// created with WithDryRun. It holds the request that would have been sent, the
+// Body is byte for byte the one the gateway would have received.
+//
+// It is an error rather than a response so that code unaware of the dry-run
+// mode never takes a transaction that was not sent for one that went through.
+type DryRunError struct {
+	Operation string
+	Method    string
+	URL       string
+	Header    http.Header
+	Body      []byte
+}
+
+func (e *DryRunError) Error() string {
+	return fmt.Sprintf("mpesa: dry run of %s request: %s %s", e.Operation, e.Method, e.URL)
+}
+
+func (e *DryRunError) Is(target error) bool {
+	return target == ErrDryRun
+}
+
+// WithDryRun makes the operations build their request as usual, run the
+// interceptors and the wire dump, then return a *DryRunError holding the
+// request instead of sending it. No session is fetched: the requests carry a
+// bearer token made from a placeholder session id, so the Client works
+// offline. SessionID returns the session request it would send.
+//
+// The rate limiter, the circuit breaker, the retries and the automatic session
+// refresh are off in dry-run mode.
+func WithDryRun(dryRun bool) ClientOption {
+	return func(client *Client) {
+		client.dryRun = dryRun
+	}
+}
+
+// dryRunTransport stands in for the network in dry-run mode. It fails every
+// request with a *DryRunError.
+type dryRunTransport struct{}
+
+func (dryRunTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		_ = req.Body.Close()
+	}
+
+	return nil, &DryRunError{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Header: req.Header.Clone(),
+		Body:   body,
+	}
+}
+
+// doDryRun performs re through the transport chain ending with dryRunTransport
+// and returns the *DryRunError named after the operation.
+func (c *Client) doDryRun(ctx context.Context, requestType requestType, re *base.Request) error {
+	_, err := c.base.Do(withCapture(ctx, new(capture)), re, nil)
+
+	var dryRunErr *DryRunError
+	if !errors.As(err, &dryRunErr) {
+		return fmt.Errorf("could not perform %s request: %w", requestType.Name(), err)
+	}
+
+	named := *dryRunErr
+	named.Operation = requestType.Name()
+
+	return &named
+}
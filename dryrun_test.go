@@ -0,0 +1,66 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	g := newTestGateway(t)
+	var sent []byte
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		sent, _ = io.ReadAll(r.Body)
+		writeJSON(w, http.StatusCreated, PushAsyncResponse{ResponseCode: "INS-0"})
+	}
+	request := Request{ThirdPartyID: "tp-1", Reference: "ref1", Amount: MustParseAmount("10"), MSISDN: "255754000123", Description: "goods"}
+
+	if _, err := g.client().PushAsync(context.Background(), request.PushRequest()); err != nil {
+		t.Fatalf("PushAsync() error = %v", err)
+	}
+
+	c := g.client(WithDryRun(true), WithRetry(RetryPolicy{MaxAttempts: 3}))
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		t.Error("dry run sent the push request")
+	}
+	_, err := c.PushAsync(context.Background(), request.PushRequest())
+
+	var dryRun *DryRunError
+	if !errors.As(err, &dryRun) || !errors.Is(err, ErrDryRun) {
+		t.Fatalf("PushAsync() error = %v, want a *DryRunError", err)
+	}
+	if string(dryRun.Body) != string(sent) {
+		t.Errorf("Body = %s, want the body sent without dry run %s", dryRun.Body, sent)
+	}
+	if dryRun.Operation != "ussd push" || dryRun.Method != http.MethodPost ||
+		!strings.HasSuffix(dryRun.URL, "/c2bPayment/singleStage/") {
+		t.Errorf("DryRunError = %+v, want the push request", dryRun)
+	}
+	if !strings.HasPrefix(dryRun.Header.Get("Authorization"), "Bearer ") {
+		t.Errorf("Authorization = %q, want a bearer token", dryRun.Header.Get("Authorization"))
+	}
+	if g.sessions != 1 {
+		t.Errorf("sessions = %d, want only the one fetched without dry run", g.sessions)
+	}
+}
+
+func TestDryRunQuery(t *testing.T) {
+	g := newTestGateway(t)
+	c := g.client(WithDryRun(true))
+
+	_, err := c.QueryTx(context.Background(), QueryTxParams{Reference: "ref1", ConversationID: "conv-1"})
+
+	var dryRun *DryRunError
+	if !errors.As(err, &dryRun) {
+		t.Fatalf("QueryTx() error = %v, want a *DryRunError", err)
+	}
+	if dryRun.Method != http.MethodGet || !strings.Contains(dryRun.URL, "input_QueryReference=ref1") || len(dryRun.Body) != 0 {
+		t.Errorf("DryRunError = %+v, want the query parameters in the URL", dryRun)
+	}
+	if g.sessions != 0 {
+		t.Errorf("sessions = %d, want 0", g.sessions)
+	}
+}
@@ -0,0 +1,113 @@
+package mpesa
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithWireDump writes every exchange with the gateway to w exactly as it went
+// over the wire: the method, URL, headers and body of the request, then the
+// status and body of the response. It works regardless of the debug mode and
+// of the logger.
+//
+// Each exchange is written to w in a single Write, so dumps of concurrent
+// requests never interleave. The Authorization header is always masked, the
+// other secrets are masked as in the logger output unless redaction was turned
+// off with WithRedaction.
+func WithWireDump(w io.Writer) ClientOption {
+	return func(client *Client) {
+		if w == nil {
+			client.optionErrs = append(client.optionErrs, "wire dump writer is nil")
+			return
+		}
+
+		client.wireDump = w
+	}
+}
+
+// dumpTransport writes the requests and the responses passing through it to
+// the wire dump writer.
+type dumpTransport struct {
+	next http.RoundTripper
+	c    *Client
+	mu   sync.Mutex
+	w    io.Writer
+	now  func() time.Time
+}
+
+func (t *dumpTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		if reqBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		_ = req.Body.Close()
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	start := t.now()
+	res, err := t.next.RoundTrip(req)
+	elapsed := t.now().Sub(start)
+
+	var frame strings.Builder
+	fmt.Fprintf(&frame, ">>> mpesa request %s %s\n", req.Method, req.URL)
+	writeHeaders(&frame, req.Header)
+	fmt.Fprintf(&frame, "\n%s\n", reqBody)
+
+	if err != nil {
+		fmt.Fprintf(&frame, "<<< mpesa response error after %s: %v\n", elapsed, err)
+	} else {
+		resBody, readErr := io.ReadAll(res.Body)
+		_ = res.Body.Close()
+		res.Body = io.NopCloser(bytes.NewReader(resBody))
+
+		fmt.Fprintf(&frame, "<<< mpesa response %s after %s\n", res.Status, elapsed)
+		writeHeaders(&frame, res.Header)
+		fmt.Fprintf(&frame, "\n%s\n", resBody)
+		if readErr != nil {
+			fmt.Fprintf(&frame, "(body read error: %v)\n", readErr)
+			err, res = readErr, nil
+		}
+	}
+	frame.WriteString("---\n")
+
+	t.write(frame.String())
+
+	return res, err
+}
+
+// write masks the secrets of dump and writes it in one go.
+func (t *dumpTransport) write(dump string) {
+	if t.c.noRedaction {
+		dump = authorizationHeader.ReplaceAllString(dump, "${1}${2}"+redacted)
+	} else {
+		dump = redact(dump, t.c.secrets()...)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, _ = io.WriteString(t.w, dump)
+}
+
+// writeHeaders writes header sorted by name, one per line.
+func writeHeaders(w io.Writer, header http.Header) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, value := range header[name] {
+			fmt.Fprintf(w, "%s: %s\n", name, value)
+		}
+	}
+}
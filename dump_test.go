@@ -0,0 +1,103 @@
+package mpesa
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWithWireDump(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"output_ResponseCode": "INS-13",
+			"output_ResponseDesc": "Invalid Shortcode Used",
+			"output_Unknown":      "kept",
+		})
+	}
+
+	var dump bytes.Buffer
+	c := g.client(WithWireDump(&dump))
+	_, _ = c.PushAsync(context.Background(), PushRequest{ThirdPartyID: "tp-1", Amount: MustParseAmount("10"), MSISDN: "255754000123"})
+
+	out := dump.String()
+	for _, want := range []string{
+		">>> mpesa request GET " + g.URL + "/sandbox/ipg/v2/vodacomTZN/getSession/\n",
+		">>> mpesa request POST " + g.URL + "/sandbox/ipg/v2/vodacomTZN/c2bPayment/singleStage/\n",
+		`"input_ThirdPartyConversationID":"tp-1"`,
+		`"input_CustomerMSISDN":"*********123"`,
+		"<<< mpesa response 400 Bad Request",
+		`"output_Unknown":"kept"`,
+		"Authorization: Bearer " + redacted,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("dump does not contain %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "session-1") {
+		t.Errorf("dump contains the session id:\n%s", out)
+	}
+}
+
+func TestWireDumpWithoutRedaction(t *testing.T) {
+	g := newTestGateway(t)
+
+	var dump bytes.Buffer
+	c := g.client(WithWireDump(&dump), WithRedaction(false))
+	if _, err := c.SessionID(context.Background()); err != nil {
+		t.Fatalf("SessionID() error = %v", err)
+	}
+
+	out := dump.String()
+	if !strings.Contains(out, "Authorization: Bearer "+redacted) {
+		t.Errorf("dump does not mask the Authorization header:\n%s", out)
+	}
+	if !strings.Contains(out, "session-1") {
+		t.Errorf("dump does not contain the session id:\n%s", out)
+	}
+}
+
+func TestWireDumpFrames(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["b2cPayment/"] = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, DisburseResponse{ResponseCode: "INS-0"})
+	}
+
+	var dump bytes.Buffer
+	c := g.client(WithWireDump(&dump))
+	if _, err := c.SessionID(context.Background()); err != nil {
+		t.Fatalf("SessionID() error = %v", err)
+	}
+
+	const calls = 20
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, _ = c.Disburse(context.Background(), testRequest(fmt.Sprintf("tp-%d", i)).DisburseRequest())
+		}(i)
+	}
+	wg.Wait()
+
+	frames := strings.Split(strings.TrimSuffix(dump.String(), "---\n"), "---\n")
+	if len(frames) != calls+1 {
+		t.Fatalf("frames = %d, want %d", len(frames), calls+1)
+	}
+	for _, frame := range frames {
+		if !strings.HasPrefix(frame, ">>> mpesa request") || strings.Count(frame, "<<< mpesa response") != 1 {
+			t.Errorf("frame is not a single exchange:\n%s", frame)
+		}
+	}
+}
+
+func TestWithWireDumpRejectsNilWriter(t *testing.T) {
+	g := newTestGateway(t)
+	if _, err := NewClient(g.config(), nil, WithWireDump(nil)); err == nil {
+		t.Error("NewClient() error = nil, want an error for a nil writer")
+	}
+}
@@ -0,0 +1,32 @@
+package mpesa
+
+import "fmt"
+
+// DefaultEndpoints returns the endpoint paths documented in the OpenAPI portal
+// for market on platform. The paths are appended to the base URL, which already
+// carries the platform and market, e.g.
+// https://openapi.m-pesa.com/sandbox/ipg/v2/vodacomTZN/c2bPayment/singleStage/
+//
+// An error is returned when market or platform is not supported.
+func DefaultEndpoints(market Market, platform Platform) (*Endpoints, error) {
+	if market.URLContextValue() == "" {
+		return nil, fmt.Errorf("mpesa: no default endpoints for market %d", market)
+	}
+
+	if platform != SANDBOX && platform != OPENAPI {
+		return nil, fmt.Errorf("mpesa: no default endpoints for platform %d", platform)
+	}
+
+	return &Endpoints{
+		AuthEndpoint:              sessionID.Endpoint(),
+		PushEndpoint:              pushPay.Endpoint(),
+		DisburseEndpoint:          disburse.Endpoint(),
+		QueryEndpoint:             queryTxn.Endpoint(),
+		B2BEndpoint:               b2bPay.Endpoint(),
+		DirectDebitCreateEndpoint: directDebitCreate.Endpoint(),
+		DirectDebitPayEndpoint:    directDebitPay.Endpoint(),
+		DirectDebitCancelEndpoint: directDebitCancel.Endpoint(),
+		DirectDebitQueryEndpoint:  directDebitQuery.Endpoint(),
+		BeneficiaryNameEndpoint:   beneficiaryName.Endpoint(),
+	}, nil
+}
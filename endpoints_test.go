@@ -0,0 +1,72 @@
+package mpesa
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDefaultEndpoints(t *testing.T) {
+	tests := []struct {
+		name     string
+		market   Market
+		platform Platform
+		wantErr  bool
+	}{
+		{name: "ghana sandbox", market: GhanaMarket, platform: SANDBOX},
+		{name: "ghana openapi", market: GhanaMarket, platform: OPENAPI},
+		{name: "tanzania sandbox", market: TanzaniaMarket, platform: SANDBOX},
+		{name: "tanzania openapi", market: TanzaniaMarket, platform: OPENAPI},
+		{name: "unknown market", market: Market(99), platform: SANDBOX, wantErr: true},
+		{name: "unknown platform", market: TanzaniaMarket, platform: Platform(99), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DefaultEndpoints(tt.market, tt.platform)
+			if tt.wantErr {
+				if err == nil || got != nil {
+					t.Fatalf("DefaultEndpoints() = %+v, %v, want an error", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DefaultEndpoints() error = %v", err)
+			}
+			if *got != *testEndpoints {
+				t.Errorf("DefaultEndpoints() = %+v, want %+v", got, testEndpoints)
+			}
+		})
+	}
+}
+
+func TestNewClientEndpoints(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["customSession/"] = g.handlers["getSession/"]
+	delete(g.handlers, "getSession/")
+
+	t.Run("defaults when nil", func(t *testing.T) {
+		conf := g.config()
+		conf.Endpoints = nil
+		c, err := NewClient(conf, nil, WithDebugMode(false), WithHTTPClient(g.Client()))
+		if err != nil {
+			t.Fatalf("NewClient() error = %v", err)
+		}
+		if *c.Conf.Endpoints != *testEndpoints {
+			t.Errorf("Endpoints = %+v, want the defaults", c.Conf.Endpoints)
+		}
+	})
+
+	t.Run("explicit wins", func(t *testing.T) {
+		conf := g.config()
+		edps := *testEndpoints
+		edps.AuthEndpoint = "/customSession/"
+		conf.Endpoints = &edps
+		c, err := NewClient(conf, nil, WithDebugMode(false), WithHTTPClient(g.Client()))
+		if err != nil {
+			t.Fatalf("NewClient() error = %v", err)
+		}
+		if _, err := c.SessionID(context.Background()); err != nil {
+			t.Errorf("SessionID() error = %v, want it sent to /customSession/", err)
+		}
+	})
+}
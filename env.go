@@ -0,0 +1,122 @@
+package mpesa
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Environment variable names read by ConfigFromEnv, without the prefix.
+const (
+	EnvAPIKey              = "API_KEY"
+	EnvPublicKey           = "PUBLIC_KEY"
+	EnvMarket              = "MARKET"
+	EnvPlatform            = "PLATFORM"
+	EnvServiceProviderCode = "SERVICE_PROVIDER_CODE"
+	EnvSessionLifetime     = "SESSION_LIFETIME_MINUTES"
+	EnvBasePath            = "BASE_PATH"
+	EnvTrustedSources      = "TRUSTED_SOURCES"
+	EnvAuthEndpoint        = "AUTH_ENDPOINT"
+	EnvPushEndpoint        = "PUSH_ENDPOINT"
+	EnvDisburseEndpoint    = "DISBURSE_ENDPOINT"
+	EnvQueryEndpoint       = "QUERY_ENDPOINT"
+)
+
+// ConfigFromEnv builds a Config from environment variables named prefix
+// followed by an underscore and one of the Env constants, e.g.
+// ConfigFromEnv("MPESA") reads MPESA_API_KEY, MPESA_PUBLIC_KEY and so on.
+//
+// The API key, public key, market, platform, service provider code and base
+// path are required. The market and platform accept the names understood by
+// ParseMarket and ParsePlatform. TRUSTED_SOURCES is a comma-separated list and
+// SESSION_LIFETIME_MINUTES defaults to DefaultSessionLifetimeMinutes. The
+// endpoints default to DefaultEndpoints and each ENDPOINT variable overrides
+// one of them.
+//
+// Every missing or invalid variable is reported in a single *ConfigError, and
+// the resulting Config is validated the same way NewClient does.
+func ConfigFromEnv(prefix string) (*Config, error) {
+	if prefix != "" && !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+
+	var v validation
+	lookup := func(name string, required bool) string {
+		value, ok := os.LookupEnv(prefix + name)
+		value = strings.TrimSpace(value)
+		if required && (!ok || value == "") {
+			v.add(prefix+name, RuleRequired, "is not set")
+		}
+
+		return value
+	}
+
+	conf := &Config{
+		APIKey:                 lookup(EnvAPIKey, true),
+		PublicKey:              lookup(EnvPublicKey, true),
+		ServiceProvideCode:     lookup(EnvServiceProviderCode, true),
+		BasePath:               lookup(EnvBasePath, true),
+		SessionLifetimeMinutes: DefaultSessionLifetimeMinutes,
+	}
+
+	marketOK, platformOK := false, false
+	if s := lookup(EnvMarket, true); s != "" {
+		market, err := ParseMarket(s)
+		if err != nil {
+			v.add(prefix+EnvMarket, RuleUnsupported, "is invalid: %v", err)
+		}
+		conf.Market, marketOK = market, err == nil
+	}
+
+	if s := lookup(EnvPlatform, true); s != "" {
+		platform, err := ParsePlatform(s)
+		if err != nil {
+			v.add(prefix+EnvPlatform, RuleUnsupported, "is invalid: %v", err)
+		}
+		conf.Platform, platformOK = platform, err == nil
+	}
+
+	if s := lookup(EnvSessionLifetime, false); s != "" {
+		minutes, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			v.add(prefix+EnvSessionLifetime, RuleFormat, "must be a number of minutes, got %q", s)
+		}
+		conf.SessionLifetimeMinutes = minutes
+	}
+
+	if s := lookup(EnvTrustedSources, false); s != "" {
+		for _, source := range strings.Split(s, ",") {
+			if source = strings.TrimSpace(source); source != "" {
+				conf.TrustedSources = append(conf.TrustedSources, source)
+			}
+		}
+	}
+
+	if marketOK && platformOK {
+		conf.Endpoints, _ = DefaultEndpoints(conf.Market, conf.Platform)
+		overrides := []struct {
+			name  string
+			field *string
+		}{
+			{EnvAuthEndpoint, &conf.Endpoints.AuthEndpoint},
+			{EnvPushEndpoint, &conf.Endpoints.PushEndpoint},
+			{EnvDisburseEndpoint, &conf.Endpoints.DisburseEndpoint},
+			{EnvQueryEndpoint, &conf.Endpoints.QueryEndpoint},
+		}
+		for _, o := range overrides {
+			if s := lookup(o.name, false); s != "" {
+				*o.field = s
+			}
+		}
+	}
+
+	if err := newConfigError(v.fields); err != nil {
+		return nil, err
+	}
+
+	if err := conf.validate(); err != nil {
+		return nil, err
+	}
+
+	return conf, nil
+}
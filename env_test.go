@@ -0,0 +1,71 @@
+package mpesa
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	g := newTestGateway(t)
+
+	t.Setenv("MPESA_API_KEY", "api-key")
+	t.Setenv("MPESA_PUBLIC_KEY", g.pubKey)
+	t.Setenv("MPESA_MARKET", "TZ")
+	t.Setenv("MPESA_PLATFORM", "production")
+	t.Setenv("MPESA_SERVICE_PROVIDER_CODE", "000000")
+	t.Setenv("MPESA_BASE_PATH", "openapi.m-pesa.com")
+	t.Setenv("MPESA_SESSION_LIFETIME_MINUTES", "120")
+	t.Setenv("MPESA_TRUSTED_SOURCES", "10.0.0.1, 10.0.0.2,")
+	t.Setenv("MPESA_PUSH_ENDPOINT", "/c2bPayment/custom/")
+
+	conf, err := ConfigFromEnv("MPESA")
+	if err != nil {
+		t.Fatalf("ConfigFromEnv() error = %v", err)
+	}
+
+	edps := *testEndpoints
+	edps.PushEndpoint = "/c2bPayment/custom/"
+	want := &Config{
+		Endpoints:              &edps,
+		BasePath:               "openapi.m-pesa.com",
+		Market:                 TanzaniaMarket,
+		Platform:               OPENAPI,
+		APIKey:                 "api-key",
+		PublicKey:              g.pubKey,
+		SessionLifetimeMinutes: 120,
+		ServiceProvideCode:     "000000",
+		TrustedSources:         []string{"10.0.0.1", "10.0.0.2"},
+	}
+	if !reflect.DeepEqual(conf, want) {
+		t.Errorf("ConfigFromEnv() = %+v, want %+v", conf, want)
+	}
+}
+
+func TestConfigFromEnvErrors(t *testing.T) {
+	t.Setenv("APP_MPESA_MARKET", "atlantis")
+	t.Setenv("APP_MPESA_PLATFORM", "sandbox")
+	t.Setenv("APP_MPESA_SESSION_LIFETIME_MINUTES", "an hour")
+
+	_, err := ConfigFromEnv("APP_MPESA_")
+
+	var confErr *ConfigError
+	if !errors.As(err, &confErr) {
+		t.Fatalf("ConfigFromEnv() error = %v, want *ConfigError", err)
+	}
+
+	msg := confErr.Error()
+	for _, want := range []string{
+		"APP_MPESA_API_KEY is not set",
+		"APP_MPESA_PUBLIC_KEY is not set",
+		"APP_MPESA_SERVICE_PROVIDER_CODE is not set",
+		"APP_MPESA_BASE_PATH is not set",
+		`unknown market "atlantis"`,
+		"APP_MPESA_SESSION_LIFETIME_MINUTES",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error %q does not mention %q", msg, want)
+		}
+	}
+}
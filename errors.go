@@ -0,0 +1,273 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/techcraftlabs/base"
+)
+
+// Sentinel errors matched by an *APIError in errors.Is, based on the gateway
+// response code or, for ErrSessionInvalid and ErrRateLimited, a 401 or a 429
+// status as well. ErrCredentialsRejected is matched by the codes of
+// ResponseCode.IsAuthFailure: the API key, the user or the initiator was
+// rejected, which a new session does not fix.
+var (
+	ErrSessionInvalid       = errors.New("mpesa: session rejected by the gateway")
+	ErrCredentialsRejected  = errors.New("mpesa: credentials rejected by the gateway")
+	ErrDuplicateTransaction = errors.New("mpesa: duplicate transaction")
+	ErrInsufficientBalance  = errors.New("mpesa: insufficient balance")
+	ErrInvalidCustomer      = errors.New("mpesa: invalid customer msisdn")
+	ErrRateLimited          = errors.New("mpesa: rate limited by the gateway")
+)
+
+// APIError is returned when the gateway rejects a request. Operation names the
+// request that failed, Code is the gateway response code when one was sent back,
+// Description is the reason reported by the gateway and StatusCode is the HTTP
+// status of the response. RetryAfter is the delay asked for by the gateway in
+// the Retry-After header of a throttled response, 0 when none was given.
+// GatewayRequestID and GatewayHeaders are the ids of the response to quote to
+// the gateway operator, see WithGatewayHeaders.
+type APIError struct {
+	Operation        string
+	Code             string
+	Description      string
+	StatusCode       int
+	RetryAfter       time.Duration
+	GatewayRequestID string
+	GatewayHeaders   http.Header
+}
+
+func (e *APIError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("could not perform %s request: %s", e.Operation, e.Description)
+	}
+
+	return fmt.Sprintf("could not perform %s request: %s (%s)", e.Operation, e.Description, e.Code)
+}
+
+// Is reports whether the error matches one of the sentinel errors.
+func (e *APIError) Is(target error) bool {
+	if target == ErrSessionInvalid && e.StatusCode == http.StatusUnauthorized {
+		return true
+	}
+	if target == ErrRateLimited && e.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+
+	sentinel, ok := codeErrors[e.Code]
+
+	return ok && sentinel == target
+}
+
+// The rules a FieldError reports as violated.
+const (
+	RuleRequired    = "required"
+	RuleFormat      = "format"
+	RuleLength      = "length"
+	RuleRange       = "range"
+	RuleUnsupported = "unsupported"
+	RuleExclusive   = "exclusive"
+	RuleInvalid     = "invalid"
+)
+
+// FieldError is returned when a field of a request or of a Config is rejected
+// before anything is sent. Field is the name of the field in the Go struct, or
+// of the environment variable for ConfigFromEnv, and is empty for a problem
+// with the options given to NewClient. Rule is the rule violated, e.g.
+// RuleRequired, and Reason describes the problem.
+type FieldError struct {
+	Field  string
+	Rule   string
+	Reason string
+}
+
+func (e *FieldError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("invalid option: %s", e.Reason)
+	}
+
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+}
+
+// problem describes the error as a sentence starting with the field, as listed
+// in ConfigError.Problems.
+func (e *FieldError) problem() string {
+	if e.Field == "" {
+		return e.Reason
+	}
+
+	return e.Field + " " + e.Reason
+}
+
+// fieldErrors returns fields as a slice of errors.
+func fieldErrors(fields []*FieldError) []error {
+	errs := make([]error, len(fields))
+	for i, field := range fields {
+		errs[i] = field
+	}
+
+	return errs
+}
+
+// gatewayErrorSnippet is the number of bytes of the body kept in a
+// GatewayError.
+const gatewayErrorSnippet = 512
+
+var errEmptyBody = errors.New("empty response body")
+
+// GatewayError is returned when the gateway answered with something other than
+// a JSON response, e.g. the HTML error page of a load balancer during a
+// maintenance window, an empty body or a truncated one. StatusCode and
+// ContentType describe the response, Snippet holds the start of its body and
+// Err is the decoding error. GatewayRequestID and GatewayHeaders are set like
+// those of an *APIError.
+type GatewayError struct {
+	Operation        string
+	StatusCode       int
+	ContentType      string
+	Snippet          string
+	Err              error
+	GatewayRequestID string
+	GatewayHeaders   http.Header
+}
+
+func newGatewayError(operation string, captured *capture, err error) *GatewayError {
+	snippet := captured.body
+	if len(snippet) > gatewayErrorSnippet {
+		snippet = snippet[:gatewayErrorSnippet]
+	}
+
+	return &GatewayError{
+		Operation:   operation,
+		StatusCode:  captured.status,
+		ContentType: captured.header.Get("Content-Type"),
+		Snippet:     string(snippet),
+		Err:         err,
+	}
+}
+
+func (e *GatewayError) Error() string {
+	contentType := e.ContentType
+	if contentType == "" {
+		contentType = "no content type"
+	}
+
+	return fmt.Sprintf("could not perform %s request: unexpected gateway response with status %d (%s): %v: %q",
+		e.Operation, e.StatusCode, contentType, e.Err, e.Snippet)
+}
+
+func (e *GatewayError) Unwrap() error {
+	return e.Err
+}
+
+// TransportError is returned when a request got no response from the gateway:
+// the connection was refused or reset, the DNS lookup or the TLS handshake
+// failed, or the request timed out or was canceled. Operation names the
+// request, Host is the host it was sent to and Err is the error of the
+// http.Client, usually a *url.Error. The request may have reached the gateway
+// before the failure, its outcome is unknown.
+//
+// errors.Is(err, context.DeadlineExceeded) and errors.As into a net.Error see
+// through the wrapping, and Timeout makes os.IsTimeout work on it.
+type TransportError struct {
+	Operation string
+	Host      string
+	Err       error
+}
+
+// newTransportError wraps err, the error returned by the http.Client for a
+// request of operation.
+func newTransportError(operation string, err error) *TransportError {
+	transportErr := &TransportError{Operation: operation, Err: err}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		if u, perr := url.Parse(urlErr.URL); perr == nil {
+			transportErr.Host = u.Host
+		}
+	}
+
+	return transportErr
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("could not perform %s request: %v", e.Operation, e.Err)
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout reports whether the request failed because it timed out.
+func (e *TransportError) Timeout() bool {
+	var timeout interface{ Timeout() bool }
+
+	return errors.Is(e.Err, context.DeadlineExceeded) || (errors.As(e.Err, &timeout) && timeout.Timeout())
+}
+
+// unsentError wraps an error raised before a request was handed to the
+// http.Client, the gateway never saw the request.
+type unsentError struct {
+	err error
+}
+
+func (e *unsentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *unsentError) Unwrap() error {
+	return e.err
+}
+
+// unsent wraps err, when not nil, as the error of a request that was not sent.
+func unsent(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &unsentError{err: err}
+}
+
+// notSent reports whether err was raised before the request was sent.
+func notSent(err error) bool {
+	var unsentErr *unsentError
+
+	return errors.As(err, &unsentErr)
+}
+
+// checkResponse returns an *APIError when the gateway reported an error in
+// output_error, sent back a response code other than SUCCESS_CODE or answered
+// with a server error and no response code.
+func checkResponse(ctx context.Context, operation string, res *base.Response, code ResponseCode, desc, outputErr string) error {
+	serverErr := res != nil && res.StatusCode >= http.StatusInternalServerError
+	if outputErr == "" && code.IsSuccess() {
+		return nil
+	}
+	if outputErr == "" && code == "" && !serverErr {
+		return nil
+	}
+
+	apiErr := &APIError{Operation: operation, Code: string(code), Description: outputErr}
+	apiErr.GatewayRequestID, apiErr.GatewayHeaders = gatewayIDsFromContext(ctx)
+	if res != nil {
+		apiErr.StatusCode = res.StatusCode
+		apiErr.RetryAfter = retryAfter(res.HeaderMap["retry-after"], time.Now())
+	}
+
+	if apiErr.Description == "" {
+		apiErr.Description = desc
+	}
+	if apiErr.Description == "" && code != "" {
+		apiErr.Description = code.Description()
+	}
+	if apiErr.Description == "" {
+		apiErr.Description = http.StatusText(apiErr.StatusCode)
+	}
+
+	return apiErr
+}
@@ -0,0 +1,301 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDisburseAPIErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		response DisburseResponse
+		want     error
+		wantCode string
+		wantText string
+	}{
+		{
+			name:     "insufficient balance",
+			status:   http.StatusUnprocessableEntity,
+			response: DisburseResponse{ResponseCode: "INS-2006", ResponseDesc: "Insufficient balance"},
+			want:     ErrInsufficientBalance,
+			wantCode: "INS-2006",
+			wantText: "could not perform disburse request: Insufficient balance (INS-2006)",
+		},
+		{
+			name:     "duplicate transaction",
+			status:   http.StatusConflict,
+			response: DisburseResponse{ResponseCode: "INS-10"},
+			want:     ErrDuplicateTransaction,
+			wantCode: "INS-10",
+			wantText: "could not perform disburse request: Duplicate Transaction (INS-10)",
+		},
+		{
+			name:     "invalid customer",
+			status:   http.StatusBadRequest,
+			response: DisburseResponse{ResponseCode: "INS-2051", ResponseDesc: "MSISDN invalid."},
+			want:     ErrInvalidCustomer,
+			wantCode: "INS-2051",
+		},
+		{
+			name:     "initiator authentication error",
+			status:   http.StatusBadRequest,
+			response: DisburseResponse{ResponseCode: "INS-2001"},
+			want:     ErrCredentialsRejected,
+			wantCode: "INS-2001",
+		},
+		{
+			name:     "output error",
+			status:   http.StatusBadRequest,
+			response: DisburseResponse{OutputErr: "Bad request"},
+			wantText: "could not perform disburse request: Bad request",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGateway(t)
+			g.handlers["b2cPayment/"] = func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, tt.status, tt.response)
+			}
+
+			_, err := g.client().Disburse(context.Background(), DisburseRequest{Amount: MustParseAmount("1000"), MSISDN: "255765123456"})
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("Disburse() error = %v, want *APIError", err)
+			}
+			if apiErr.Code != tt.wantCode || apiErr.StatusCode != tt.status {
+				t.Errorf("Code, StatusCode = %s, %d, want %s, %d", apiErr.Code, apiErr.StatusCode, tt.wantCode, tt.status)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("errors.Is(%v, %v) = false", err, tt.want)
+			}
+			if tt.wantText != "" && err.Error() != tt.wantText {
+				t.Errorf("Error() = %q, want %q", err.Error(), tt.wantText)
+			}
+			for _, other := range []error{ErrSessionInvalid, ErrCredentialsRejected, ErrDuplicateTransaction, ErrInsufficientBalance, ErrInvalidCustomer} {
+				if other != tt.want && errors.Is(err, other) {
+					t.Errorf("errors.Is(%v, %v) = true", err, other)
+				}
+			}
+		})
+	}
+}
+
+func TestSessionIDAPIError(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["getSession/"] = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusUnauthorized, SessionResponse{Code: "INS-2", Description: "Invalid API Key"})
+	}
+
+	_, err := g.client().SessionID(context.Background())
+	if !errors.Is(err, ErrSessionInvalid) {
+		t.Errorf("SessionID() error = %v, want ErrSessionInvalid", err)
+	}
+	if !errors.Is(err, ErrCredentialsRejected) {
+		t.Errorf("SessionID() error = %v, want ErrCredentialsRejected", err)
+	}
+}
+
+func TestMoreOperationsAPIErrors(t *testing.T) {
+	ctx := context.Background()
+	operations := []struct {
+		path string
+		call func(c *Client) error
+	}{
+		{"b2bPayment/", func(c *Client) error {
+			_, err := c.B2BPayment(ctx, Request{ThirdPartyID: "tp-1", Reference: "ref", Amount: MustParseAmount("1000"),
+				ReceiverPartyCode: "000001"})
+			return err
+		}},
+		{"directDebitCreation/", func(c *Client) error {
+			_, err := c.CreateDirectDebit(ctx, DirectDebitCreateRequest{MSISDN: "255754000000", Reference: "ref", ThirdPartyID: "tp-1"})
+			return err
+		}},
+		{"directDebitPayment/", func(c *Client) error {
+			_, err := c.DirectDebitPayment(ctx, DirectDebitPaymentRequest{MandateID: "mandate", ThirdPartyID: "tp-1",
+				Amount: MustParseAmount("10")})
+			return err
+		}},
+		{"directDebitCancel/", func(c *Client) error {
+			_, err := c.CancelDirectDebit(ctx, DirectDebitCancelRequest{AgreementID: "agreement", ThirdPartyID: "tp-1"})
+			return err
+		}},
+		{"queryDirectDebit/", func(c *Client) error {
+			_, err := c.QueryDirectDebit(ctx, QueryDirectDebitParams{AgreementID: "agreement"})
+			return err
+		}},
+		{"queryBeneficiaryName/", func(c *Client) error {
+			_, err := c.QueryBeneficiaryName(ctx, "255754000000")
+			return err
+		}},
+	}
+
+	for _, op := range operations {
+		t.Run(op.path, func(t *testing.T) {
+			g := newTestGateway(t)
+			g.handlers[op.path] = func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, http.StatusOK, map[string]string{
+					"output_ResponseCode": "INS-2006",
+					"output_ResponseDesc": "Insufficient balance",
+				})
+			}
+
+			err := op.call(g.client())
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.Code != "INS-2006" || apiErr.StatusCode != http.StatusOK ||
+				apiErr.Description != "Insufficient balance" {
+				t.Fatalf("error = %#v, want an *APIError for INS-2006", err)
+			}
+			if !errors.Is(err, ErrInsufficientBalance) {
+				t.Errorf("errors.Is(%v, ErrInsufficientBalance) = false", err)
+			}
+		})
+	}
+}
+
+func TestGatewayErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		endpoint    string
+		status      int
+		contentType string
+		body        string
+		call        func(c *Client) error
+		wantStatus  int
+		wantSnippet string
+	}{
+		{
+			name:        "html 502",
+			endpoint:    "c2bPayment/singleStage/",
+			status:      http.StatusBadGateway,
+			contentType: "text/html",
+			body:        "<html><body><h1>502 Bad Gateway</h1></body></html>",
+			call: func(c *Client) error {
+				_, err := c.PushAsync(context.Background(), testRequest("tp-1").PushRequest())
+				return err
+			},
+			wantStatus:  http.StatusBadGateway,
+			wantSnippet: "<html><body><h1>502 Bad Gateway</h1></body></html>",
+		},
+		{
+			name:        "empty 200",
+			endpoint:    "getSession/",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			call: func(c *Client) error {
+				_, err := c.SessionID(context.Background())
+				return err
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:        "truncated json",
+			endpoint:    "queryTransactionStatus/",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        `{"output_ResponseCode":"INS-0","output_Respon`,
+			call: func(c *Client) error {
+				_, err := c.QueryTx(context.Background(), QueryTxParams{Reference: "ref"})
+				return err
+			},
+			wantStatus:  http.StatusOK,
+			wantSnippet: `{"output_ResponseCode":"INS-0","output_Respon`,
+		},
+		{
+			name:        "plain text disbursement",
+			endpoint:    "b2cPayment/",
+			status:      http.StatusServiceUnavailable,
+			contentType: "text/plain",
+			body:        strings.Repeat("maintenance ", 100),
+			call: func(c *Client) error {
+				_, err := c.Disburse(context.Background(), testRequest("tp-1").DisburseRequest())
+				return err
+			},
+			wantStatus:  http.StatusServiceUnavailable,
+			wantSnippet: strings.Repeat("maintenance ", 100)[:gatewayErrorSnippet],
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGateway(t)
+			g.handlers[tt.endpoint] = func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}
+
+			err := tt.call(g.client())
+
+			var gatewayErr *GatewayError
+			if !errors.As(err, &gatewayErr) {
+				t.Fatalf("error = %v, want a *GatewayError", err)
+			}
+			if gatewayErr.StatusCode != tt.wantStatus || gatewayErr.ContentType != tt.contentType {
+				t.Errorf("status = %d, content type = %q, want %d and %q",
+					gatewayErr.StatusCode, gatewayErr.ContentType, tt.wantStatus, tt.contentType)
+			}
+			if gatewayErr.Snippet != tt.wantSnippet {
+				t.Errorf("Snippet = %q, want %q", gatewayErr.Snippet, tt.wantSnippet)
+			}
+		})
+	}
+}
+
+func TestTransportErrors(t *testing.T) {
+	t.Run("connection refused", func(t *testing.T) {
+		g := newTestGateway(t)
+		c := g.client()
+		host := g.Listener.Addr().String()
+		g.Close()
+
+		_, err := c.SessionID(context.Background())
+
+		var transportErr *TransportError
+		if !errors.As(err, &transportErr) {
+			t.Fatalf("SessionID() error = %v, want a *TransportError", err)
+		}
+		if transportErr.Operation != sessionID.Name() || transportErr.Host != host {
+			t.Errorf("TransportError = %+v, want the session request to %s", transportErr, host)
+		}
+		var apiErr *APIError
+		if errors.As(err, &apiErr) || transportErr.Timeout() {
+			t.Errorf("error = %v, want neither an *APIError nor a timeout", err)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		g := newTestGateway(t)
+		release := make(chan struct{})
+		defer close(release)
+		g.handlers["getSession/"] = func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		_, err := g.client().SessionID(ctx)
+
+		var transportErr *TransportError
+		if !errors.As(err, &transportErr) {
+			t.Fatalf("SessionID() error = %v, want a *TransportError", err)
+		}
+		if !errors.Is(err, context.DeadlineExceeded) || !os.IsTimeout(transportErr) {
+			t.Errorf("SessionID() error = %v, want a timeout matching context.DeadlineExceeded", err)
+		}
+		if DefaultRetryable(err) {
+			t.Errorf("DefaultRetryable(%v) = true, want the expired context not retried", err)
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package mpesa
+
+import (
+	"context"
+	"net/http"
+)
+
+// defaultGatewayHeaders are the response headers carrying the ids the gateway
+// operator asks for in a support request, see WithGatewayHeaders.
+var defaultGatewayHeaders = []string{ //nolint:gochecknoglobals
+	"X-Request-Id",
+	"X-Correlation-Id",
+	"X-Amzn-Requestid",
+	"X-Amz-Apigw-Id",
+	"X-Amzn-Trace-Id",
+}
+
+// WithGatewayHeaders sets the response headers kept as the gateway ids of the
+// responses, in order of preference. They default to X-Request-ID,
+// X-Correlation-ID, X-Amzn-RequestId, X-Amz-Apigw-Id and X-Amzn-Trace-Id.
+//
+// The headers found are set on HTTPInfo.GatewayHeaders, on the GatewayHeaders
+// of an *APIError or a *GatewayError, and the first one on their
+// GatewayRequestID, which is also logged with the outcome of the request.
+// Calling it without names keeps none.
+func WithGatewayHeaders(names ...string) ClientOption {
+	return func(client *Client) {
+		client.gatewayHeaders = make([]string, 0, len(names))
+		for _, name := range names {
+			if name == "" {
+				client.optionErrs = append(client.optionErrs, "gateway header name is empty")
+				continue
+			}
+			client.gatewayHeaders = append(client.gatewayHeaders, http.CanonicalHeaderKey(name))
+		}
+	}
+}
+
+// gatewayIDs returns the gateway headers found in header and the value of the
+// first one.
+func (c *Client) gatewayIDs(header http.Header) (string, http.Header) {
+	var (
+		requestID string
+		found     http.Header
+	)
+	for _, name := range c.gatewayHeaders {
+		values := header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		if found == nil {
+			found, requestID = make(http.Header), values[0]
+		}
+		found[name] = append([]string(nil), values...)
+	}
+
+	return requestID, found
+}
+
+// gatewayError is newGatewayError with the gateway ids of the response.
+func (c *Client) gatewayError(operation string, captured *capture, err error) *GatewayError {
+	gatewayErr := newGatewayError(operation, captured, err)
+	gatewayErr.GatewayRequestID, gatewayErr.GatewayHeaders = c.gatewayIDs(captured.header)
+
+	return gatewayErr
+}
+
+// gatewayResponded records the gateway ids of a response on the operation
+// carried by ctx, if any, for the errors built from the response and for the
+// log of the outcome.
+func gatewayResponded(ctx context.Context, requestID string, header http.Header) {
+	op, ok := ctx.Value(operationKey{}).(*operation)
+	if !ok {
+		return
+	}
+
+	op.mu.Lock()
+	op.gatewayRequestID, op.gatewayHeaders = requestID, header
+	op.mu.Unlock()
+}
+
+// gatewayIDsFromContext returns the gateway ids of the last response received
+// by the operation carried by ctx.
+func gatewayIDsFromContext(ctx context.Context) (string, http.Header) {
+	op, ok := ctx.Value(operationKey{}).(*operation)
+	if !ok {
+		return "", nil
+	}
+
+	op.mu.Lock()
+	defer op.mu.Unlock()
+
+	return op.gatewayRequestID, op.gatewayHeaders
+}
@@ -0,0 +1,62 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestGatewayRequestID(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["queryTransactionStatus/"] = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Amzn-RequestId", "amzn-"+r.URL.Query().Get("input_QueryReference"))
+		w.Header().Set("X-Trace", "trace-1")
+		switch r.URL.Query().Get("input_QueryReference") {
+		case "rejected":
+			writeJSON(w, http.StatusBadRequest, QueryTxResponse{ResponseCode: "INS-13", ResponseDesc: "Invalid Shortcode Used"})
+		case "maintenance":
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte("<html>Bad Gateway</html>"))
+		default:
+			writeJSON(w, http.StatusOK, QueryTxResponse{ResponseCode: "INS-0", ResponseTransactionStatus: "Completed"})
+		}
+	}
+	ctx := context.Background()
+
+	c := g.client()
+	response, err := c.QueryTx(ctx, QueryTxParams{Reference: "ok"})
+	if err != nil {
+		t.Fatalf("QueryTx() error = %v", err)
+	}
+	if response.HTTP.GatewayRequestID != "amzn-ok" || len(response.HTTP.GatewayHeaders) != 1 {
+		t.Errorf("HTTP = %+v, want the X-Amzn-RequestId kept", response.HTTP)
+	}
+
+	var apiErr *APIError
+	_, err = c.QueryTx(ctx, QueryTxParams{Reference: "rejected"})
+	if !errors.As(err, &apiErr) || apiErr.GatewayRequestID != "amzn-rejected" {
+		t.Errorf("QueryTx() error = %#v, want the gateway request id of the rejection", err)
+	}
+
+	var gatewayErr *GatewayError
+	_, err = c.QueryTx(ctx, QueryTxParams{Reference: "maintenance"})
+	if !errors.As(err, &gatewayErr) || gatewayErr.GatewayRequestID != "amzn-maintenance" {
+		t.Errorf("QueryTx() error = %#v, want the gateway request id of the error page", err)
+	}
+
+	c = g.client(WithGatewayHeaders("x-trace", "X-Amzn-RequestId"))
+	response, err = c.QueryTx(ctx, QueryTxParams{Reference: "ok"})
+	if err != nil {
+		t.Fatalf("QueryTx() error = %v", err)
+	}
+	if response.HTTP.GatewayRequestID != "trace-1" || response.HTTP.GatewayHeaders.Get("X-Amzn-RequestId") != "amzn-ok" {
+		t.Errorf("HTTP = %+v, want the chosen headers in order", response.HTTP)
+	}
+
+	c = g.client(WithGatewayHeaders())
+	if response, _ := c.QueryTx(ctx, QueryTxParams{Reference: "ok"}); response.HTTP.GatewayRequestID != "" {
+		t.Errorf("GatewayRequestID = %q, want none kept", response.HTTP.GatewayRequestID)
+	}
+}
@@ -1,5 +1,7 @@
 module github.com/ameprizzo/mpesago
 
-go 1.17
+go 1.21
 
 require github.com/techcraftlabs/base v0.0.4
+
+require gopkg.in/yaml.v3 v3.0.1
@@ -0,0 +1,42 @@
+package mpesa
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxResponseBody is the largest decompressed gateway response accepted.
+const maxResponseBody int64 = 10 << 20
+
+var errBodyTooLarge = errors.New("body too large")
+
+// gzipEncoded reports whether the Content-Encoding of header is gzip.
+func gzipEncoded(header http.Header) bool {
+	return strings.EqualFold(strings.TrimSpace(header.Get("Content-Encoding")), "gzip")
+}
+
+// gunzip decompresses body. It returns errBodyTooLarge when the decompressed
+// body is longer than limit and an error wrapping the gzip error when body is
+// malformed.
+func gunzip(body []byte, limit int64) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("malformed gzip body: %w", err)
+	}
+	defer zr.Close()
+
+	plain, err := io.ReadAll(io.LimitReader(zr, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("malformed gzip body: %w", err)
+	}
+	if int64(len(plain)) > limit {
+		return nil, errBodyTooLarge
+	}
+
+	return plain, nil
+}
@@ -0,0 +1,110 @@
+package mpesa
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func gzipped(t *testing.T, s string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestGzipResponses(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Accept-Encoding"); got != "gzip" {
+			t.Errorf("Accept-Encoding = %q, want gzip", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		_, _ = w.Write(gzipped(t, `{"output_ResponseCode":"INS-0","output_ConversationID":"conv-1"}`))
+	}
+
+	c := g.client()
+	response, err := c.PushAsync(context.Background(), testRequest("tp-1").PushRequest())
+	if err != nil {
+		t.Fatalf("PushAsync() error = %v", err)
+	}
+	if response.ConversationID != "conv-1" {
+		t.Errorf("ConversationID = %q, want conv-1", response.ConversationID)
+	}
+	if !strings.Contains(string(response.HTTP.Body), "conv-1") {
+		t.Errorf("HTTP.Body = %q, want the decompressed body", response.HTTP.Body)
+	}
+}
+
+func TestMalformedGzipResponse(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["b2cPayment/"] = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		_, _ = w.Write([]byte(`{"output_ResponseCode":"INS-0"}`))
+	}
+
+	_, err := g.client().Disburse(context.Background(), testRequest("tp-1").DisburseRequest())
+
+	var gatewayErr *GatewayError
+	if !errors.As(err, &gatewayErr) || !errors.Is(err, gzip.ErrHeader) {
+		t.Fatalf("Disburse() error = %v, want a *GatewayError wrapping gzip.ErrHeader", err)
+	}
+	if gatewayErr.StatusCode != http.StatusOK {
+		t.Errorf("StatusCode = %d, want 200", gatewayErr.StatusCode)
+	}
+}
+
+func TestGzipCallbacks(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     []byte
+		encoding string
+		limit    int64
+		want     int
+	}{
+		{"compressed", gzipped(t, testCallbackBody), "gzip", DefaultCallbackBodyLimit, http.StatusOK},
+		{"malformed", []byte(testCallbackBody), "gzip", DefaultCallbackBodyLimit, http.StatusBadRequest},
+		{"too large once decompressed", gzipped(t, testCallbackBody+strings.Repeat(" ", 4096)), "gzip", 1024, http.StatusRequestEntityTooLarge},
+		{"unsupported encoding", []byte(testCallbackBody), "br", DefaultCallbackBodyLimit, http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGateway(t)
+			var handled PushCallbackRequest
+			c := g.client(WithCallbackBodyLimit(tt.limit), WithCallbackHandler(PushCallbackFunc(
+				func(request PushCallbackRequest) (PushCallbackResponse, error) {
+					handled = request
+					return PushCallbackResponse{ResponseCode: "INS-0"}, nil
+				})))
+
+			r := httptest.NewRequest(http.MethodPost, "/callbacks/mpesa", bytes.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			r.Header.Set("Content-Encoding", tt.encoding)
+			rec := httptest.NewRecorder()
+			c.CallbackServeHTTP(rec, r)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if tt.want == http.StatusOK && handled.TransactionID == "" {
+				t.Errorf("handled callback = %+v, want the decoded callback", handled)
+			}
+		})
+	}
+}
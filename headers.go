@@ -0,0 +1,143 @@
+package mpesa
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+// DefaultOrigin is the Origin header sent to the gateway unless WithOrigin is
+// used.
+const DefaultOrigin = "*"
+
+// modulePath is the path of this module, used to find its version in the build
+// information of the binary.
+const modulePath = "github.com/ameprizzo/mpesago"
+
+var (
+	libraryVersionOnce sync.Once
+	libraryVersionText string
+)
+
+// LibraryVersion returns the version of this module the binary was built with,
+// e.g. "v1.2.0", read from the build information. It is "devel" when the
+// version is not known, e.g. in a build from a local checkout.
+func LibraryVersion() string {
+	libraryVersionOnce.Do(func() {
+		libraryVersionText = "devel"
+
+		info, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+		module := &info.Main
+		for _, dep := range info.Deps {
+			if dep.Path == modulePath {
+				module = dep
+				if dep.Replace != nil {
+					module = dep.Replace
+				}
+				break
+			}
+		}
+		if module.Path == modulePath && module.Version != "" && module.Version != "(devel)" {
+			libraryVersionText = module.Version
+		}
+	})
+
+	return libraryVersionText
+}
+
+// WithOrigin sets the Origin header sent to the gateway, DefaultOrigin by
+// default. An empty origin leaves the header out.
+func WithOrigin(origin string) ClientOption {
+	return func(client *Client) {
+		client.origin = strings.TrimSpace(origin)
+	}
+}
+
+// WithDefaultHeaders adds headers to every request sent to the gateway, e.g.
+// the tenant header of a proxy. They take precedence over the Origin, the
+// User-Agent and the correlation headers, and are overridden by the headers of
+// the call options. The Authorization and Content-Type headers are reserved.
+func WithDefaultHeaders(headers map[string]string) ClientOption {
+	return func(client *Client) {
+		if err := checkHeaders(headers); err != nil {
+			client.optionErrs = append(client.optionErrs, err.Error())
+			return
+		}
+
+		if client.defaultHeaders == nil {
+			client.defaultHeaders = make(map[string]string, len(headers))
+		}
+		for name, value := range headers {
+			client.defaultHeaders[http.CanonicalHeaderKey(name)] = value
+		}
+	}
+}
+
+// WithUserAgent replaces the User-Agent header sent to the gateway, see
+// WithUserAgentSuffix to add to the default one instead.
+func WithUserAgent(userAgent string) ClientOption {
+	return func(client *Client) {
+		userAgent = strings.TrimSpace(userAgent)
+		if userAgent == "" {
+			client.optionErrs = append(client.optionErrs, "user agent is empty")
+			return
+		}
+
+		client.userAgent = userAgent
+	}
+}
+
+// WithUserAgentSuffix appends suffix, e.g. "payroll-worker/3", to the
+// User-Agent header sent to the gateway.
+func WithUserAgentSuffix(suffix string) ClientOption {
+	return func(client *Client) {
+		client.userAgentSuffix = strings.TrimSpace(suffix)
+	}
+}
+
+// defaultUserAgent returns the User-Agent identifying the application of conf
+// and this library, "<Name>/<Version> mpesago/<LibraryVersion> Go/<version>".
+// The application part is left out when conf has no Name.
+func defaultUserAgent(conf *Config) string {
+	var products []string
+	if name := strings.TrimSpace(conf.Name); name != "" {
+		product := strings.ReplaceAll(name, " ", "-")
+		if version := strings.TrimSpace(conf.Version); version != "" {
+			product += "/" + version
+		}
+		products = append(products, product)
+	}
+	products = append(products, "mpesago/"+LibraryVersion(), "Go/"+runtime.Version())
+
+	return strings.Join(products, " ")
+}
+
+// requestHeaders returns the headers of a request made with ctx: the ones
+// set by the client, then the ones of WithDefaultHeaders and last the ones of
+// the call options of ctx.
+func (c *Client) requestHeaders(ctx context.Context, token string) map[string]string {
+	headers := map[string]string{"User-Agent": c.userAgent}
+	if c.origin != "" {
+		headers["Origin"] = c.origin
+	}
+	if id := c.correlationID(ctx); id != "" {
+		headers[http.CanonicalHeaderKey(c.correlationHeader)] = id
+	}
+	for name, value := range c.defaultHeaders {
+		headers[name] = value
+	}
+	for name, value := range callOptionsFrom(ctx).headers {
+		headers[name] = value
+	}
+	headers["Content-Type"] = "application/json"
+	headers["Authorization"] = fmt.Sprintf("Bearer %s", token)
+
+	return headers
+}
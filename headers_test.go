@@ -0,0 +1,131 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"runtime"
+	"testing"
+)
+
+func TestUserAgent(t *testing.T) {
+	lib := "mpesago/" + LibraryVersion() + " Go/" + runtime.Version()
+	tests := []struct {
+		name string
+		conf func(conf *Config)
+		opts []ClientOption
+		want string
+	}{
+		{
+			name: "application",
+			conf: func(conf *Config) { conf.Name, conf.Version = "Payroll Service", "2.1.0" },
+			want: "Payroll-Service/2.1.0 " + lib,
+		},
+		{
+			name: "no version",
+			conf: func(conf *Config) { conf.Name, conf.Version = "payroll", "" },
+			want: "payroll " + lib,
+		},
+		{
+			name: "no name",
+			conf: func(conf *Config) { conf.Name, conf.Version = "", "2.1.0" },
+			want: lib,
+		},
+		{
+			name: "suffix",
+			conf: func(conf *Config) { conf.Name, conf.Version = "payroll", "2.1.0" },
+			opts: []ClientOption{WithUserAgentSuffix("worker/3")},
+			want: "payroll/2.1.0 " + lib + " worker/3",
+		},
+		{
+			name: "override",
+			conf: func(conf *Config) { conf.Name, conf.Version = "payroll", "2.1.0" },
+			opts: []ClientOption{WithUserAgent("acme/1")},
+			want: "acme/1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGateway(t)
+			sessions := recordHeader(g, "getSession/", "User-Agent")
+			conf := g.config()
+			tt.conf(conf)
+			c, err := NewClient(conf, nil, append([]ClientOption{WithHTTPClient(g.Client())}, tt.opts...)...)
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+
+			if err := c.Ping(context.Background()); err != nil {
+				t.Fatalf("Ping() error = %v", err)
+			}
+			if got := sessions(); len(got) != 1 || got[0] != tt.want {
+				t.Errorf("User-Agent = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	var configErr *ConfigError
+	if _, err := NewClient(newTestGateway(t).config(), nil, WithUserAgent(" ")); !errors.As(err, &configErr) {
+		t.Errorf("NewClient(WithUserAgent(\" \")) error = %v, want a *ConfigError", err)
+	}
+}
+
+func TestOriginHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []ClientOption
+		want    string
+		present bool
+	}{
+		{name: "default", want: DefaultOrigin, present: true},
+		{name: "set", opts: []ClientOption{WithOrigin("https://payroll.example.com")}, want: "https://payroll.example.com", present: true},
+		{name: "removed", opts: []ClientOption{WithOrigin("")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGateway(t)
+			var origins []string
+			var present []bool
+			record := func(next http.HandlerFunc) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					_, ok := r.Header["Origin"]
+					origins, present = append(origins, r.Header.Get("Origin")), append(present, ok)
+					next(w, r)
+				}
+			}
+			g.handlers["getSession/"] = record(g.handlers["getSession/"])
+			g.handlers["c2bPayment/singleStage/"] = record(func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, http.StatusCreated, PushAsyncResponse{ResponseCode: "INS-0"})
+			})
+			g.handlers["b2cPayment/"] = record(func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, http.StatusCreated, DisburseResponse{ResponseCode: "INS-0"})
+			})
+			g.handlers["queryTransactionStatus/"] = record(func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, http.StatusOK, QueryTxResponse{ResponseCode: "INS-0"})
+			})
+			c := g.client(tt.opts...)
+
+			ctx := context.Background()
+			if _, err := c.PushAsync(ctx, testRequest("").PushRequest()); err != nil {
+				t.Fatalf("PushAsync() error = %v", err)
+			}
+			if _, err := c.Disburse(ctx, testRequest("").DisburseRequest()); err != nil {
+				t.Fatalf("Disburse() error = %v", err)
+			}
+			if _, err := c.QueryTx(ctx, QueryTxParams{Reference: "ref"}); err != nil {
+				t.Fatalf("QueryTx() error = %v", err)
+			}
+
+			if len(origins) != 4 {
+				t.Fatalf("requests = %d, want the session and 3 operations", len(origins))
+			}
+			for i := range origins {
+				if origins[i] != tt.want || present[i] != tt.present {
+					t.Errorf("request %d Origin = %q (present %v), want %q (present %v)", i, origins[i], present[i], tt.want, tt.present)
+				}
+			}
+		})
+	}
+}
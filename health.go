@@ -0,0 +1,131 @@
+package mpesa
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/techcraftlabs/base"
+)
+
+// HealthFailureThreshold is the number of consecutive failed requests after
+// which a Client reports itself unhealthy, whatever the state of its session.
+const HealthFailureThreshold = 3
+
+// HealthStatus is the health of the M-Pesa leg of a service, as seen by the
+// requests a Client sent to the gateway.
+//
+// Healthy is false once ConsecutiveFailures reaches HealthFailureThreshold, or
+// when the client holds no valid session and the last request failed. A client
+// that holds no session because it has not sent anything yet, or because its
+// session expired while idle, is healthy.
+type HealthStatus struct {
+	Healthy               bool      `json:"healthy"`
+	SessionValid          bool      `json:"session_valid"`
+	SessionExpiresAt      time.Time `json:"session_expires_at"`
+	LastRequestError      string    `json:"last_request_error,omitempty"`
+	LastRequestErrorAt    time.Time `json:"last_request_error_at"`
+	LastSuccessfulRequest time.Time `json:"last_successful_request"`
+	ConsecutiveFailures   int       `json:"consecutive_failures"`
+	Failures              uint64    `json:"failures"`
+}
+
+// healthTracker records the outcome of the requests sent by Client.do.
+type healthTracker struct {
+	mu                  sync.Mutex
+	lastErr             string
+	lastErrAt           time.Time
+	lastSuccess         time.Time
+	consecutiveFailures int
+	failures            uint64
+}
+
+func (h *healthTracker) success(at time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastSuccess = at
+	h.consecutiveFailures = 0
+}
+
+func (h *healthTracker) failure(at time.Time, reason string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastErr, h.lastErrAt = reason, at
+	h.consecutiveFailures++
+	h.failures++
+}
+
+// Health returns the health of the client: its session and the outcome of the
+// last requests sent to the gateway. It sends nothing, use Ping to check the
+// credentials end to end.
+func (c *Client) Health(ctx context.Context) HealthStatus {
+	c.health.mu.Lock()
+	status := HealthStatus{
+		LastRequestError:      c.health.lastErr,
+		LastRequestErrorAt:    c.health.lastErrAt,
+		LastSuccessfulRequest: c.health.lastSuccess,
+		ConsecutiveFailures:   c.health.consecutiveFailures,
+		Failures:              c.health.failures,
+	}
+	c.health.mu.Unlock()
+
+	status.SessionValid = c.HasValidSession()
+	if status.SessionValid {
+		status.SessionExpiresAt = c.SessionExpiresAt()
+	}
+	status.Healthy = status.ConsecutiveFailures < HealthFailureThreshold &&
+		(status.SessionValid || status.ConsecutiveFailures == 0)
+
+	return status
+}
+
+// HealthHandler returns an http.Handler writing the HealthStatus of the client
+// as JSON, with the status 200 when it is healthy and 503 otherwise.
+func (c *Client) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := c.Health(r.Context())
+
+		code := http.StatusOK
+		if !status.Healthy {
+			code = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(status)
+	})
+}
+
+// recordHealth updates the health of the client with the outcome of a request
+// sent by do. The failures are the ones counted by the circuit breaker plus
+// the rejected session requests, bad credentials leave the client unable to
+// send anything. Canceled and throttled requests are not counted.
+func (c *Client) recordHealth(requestType requestType, outcome breakerOutcome, res *base.Response, err error, v interface{}) {
+	now := c.clock.Now()
+	switch {
+	case outcome == breakerIgnored:
+		return
+	case outcome == breakerFailure && err != nil:
+		c.health.failure(now, fmt.Sprintf("%s: %v", requestType.Name(), err))
+	case outcome == breakerFailure:
+		c.health.failure(now, fmt.Sprintf("%s: %s", requestType.Name(), failureReason(res, v)))
+	case requestType == sessionID && res.StatusCode >= http.StatusBadRequest:
+		c.health.failure(now, fmt.Sprintf("%s: %s", requestType.Name(), failureReason(res, v)))
+	default:
+		c.health.success(now)
+	}
+}
+
+// failureReason describes a response that failed without an error.
+func failureReason(res *base.Response, v interface{}) string {
+	if coded, ok := v.(codedResponse); ok && coded.responseCode() != "" {
+		return fmt.Sprintf("HTTP %d, response code %s", res.StatusCode, coded.responseCode())
+	}
+
+	return fmt.Sprintf("HTTP %d", res.StatusCode)
+}
@@ -0,0 +1,96 @@
+package mpesa
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHealth(t *testing.T) {
+	g := newTestGateway(t)
+	failing := false
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			writeJSON(w, http.StatusInternalServerError, PushAsyncResponse{ResponseCode: "INS-1", ResponseDesc: "Internal Error"})
+			return
+		}
+		writeJSON(w, http.StatusCreated, PushAsyncResponse{ResponseCode: "INS-0"})
+	}
+	c := g.client()
+
+	serve := func() (int, HealthStatus) {
+		rec := httptest.NewRecorder()
+		c.HealthHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+		var status HealthStatus
+		if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+			t.Fatalf("decode health status: %v", err)
+		}
+		if got := rec.Header().Get("Content-Type"); got != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", got)
+		}
+
+		return rec.Code, status
+	}
+
+	if code, status := serve(); code != http.StatusOK || !status.Healthy || status.SessionValid {
+		t.Errorf("health before the first request = %d, %+v, want healthy without a session", code, status)
+	}
+
+	if _, err := c.PushAsync(context.Background(), testRequest("tp-1").PushRequest()); err != nil {
+		t.Fatalf("PushAsync() error = %v", err)
+	}
+	code, status := serve()
+	if code != http.StatusOK || !status.Healthy || !status.SessionValid || status.SessionExpiresAt.IsZero() {
+		t.Errorf("health after a push = %d, %+v, want healthy with a session", code, status)
+	}
+	if status.LastSuccessfulRequest.IsZero() || status.ConsecutiveFailures != 0 {
+		t.Errorf("health after a push = %+v, want the success recorded", status)
+	}
+
+	failing = true
+	for i := 0; i < HealthFailureThreshold; i++ {
+		if _, err := c.PushAsync(context.Background(), testRequest("tp-2").PushRequest()); err == nil {
+			t.Fatalf("PushAsync() error = nil, want the gateway failure")
+		}
+		if i == 0 {
+			if _, status := serve(); !status.Healthy || status.ConsecutiveFailures != 1 {
+				t.Errorf("health after one failure = %+v, want healthy with a valid session", status)
+			}
+		}
+	}
+	code, status = serve()
+	if code != http.StatusServiceUnavailable || status.Healthy {
+		t.Errorf("health after %d failures = %d, %+v, want unhealthy", HealthFailureThreshold, code, status)
+	}
+	if status.ConsecutiveFailures != HealthFailureThreshold || status.Failures != HealthFailureThreshold ||
+		!strings.Contains(status.LastRequestError, "ussd push") || status.LastRequestErrorAt.IsZero() {
+		t.Errorf("health after %d failures = %+v, want the failures of the push recorded", HealthFailureThreshold, status)
+	}
+
+	failing = false
+	if _, err := c.PushAsync(context.Background(), testRequest("tp-3").PushRequest()); err != nil {
+		t.Fatalf("PushAsync() error = %v", err)
+	}
+	if code, status := serve(); code != http.StatusOK || status.ConsecutiveFailures != 0 || status.Failures != HealthFailureThreshold {
+		t.Errorf("health after a recovery = %d, %+v, want healthy", code, status)
+	}
+}
+
+func TestHealthRejectedSession(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["getSession/"] = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusUnauthorized, SessionResponse{Code: "INS-2", Description: "Invalid API Key"})
+	}
+	c := g.client()
+
+	if err := c.EnsureSession(context.Background()); err == nil {
+		t.Fatalf("EnsureSession() error = nil, want the bad credentials reported")
+	}
+	status := c.Health(context.Background())
+	if status.Healthy || status.SessionValid || !strings.Contains(status.LastRequestError, "session") {
+		t.Errorf("Health() = %+v, want unhealthy after the session was rejected", status)
+	}
+}
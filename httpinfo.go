@@ -0,0 +1,129 @@
+package mpesa
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+)
+
+// DefaultResponseBodyLimit is the number of bytes of the raw response body kept
+// in HTTPInfo unless WithResponseBodyLimit is used.
+const DefaultResponseBodyLimit = 16 << 10
+
+// HTTPInfo describes the HTTP response a gateway response was decoded from.
+// It is set on the HTTP field of the responses, before the body is decoded, so
+// that it is there even when decoding fails. Body holds the raw body, cut to
+// the limit set with WithResponseBodyLimit, in which case Truncated is true.
+// GatewayHeaders holds the headers chosen with WithGatewayHeaders and
+// GatewayRequestID the first of them, the id to quote to the gateway operator.
+type HTTPInfo struct {
+	StatusCode       int
+	Header           http.Header
+	Body             []byte
+	Truncated        bool
+	GatewayRequestID string
+	GatewayHeaders   http.Header
+}
+
+// WithResponseBodyLimit sets how many bytes of the raw response body are kept
+// in HTTPInfo, DefaultResponseBodyLimit by default. 0 keeps none, negative
+// values are ignored.
+func WithResponseBodyLimit(limit int) ClientOption {
+	return func(client *Client) {
+		if limit < 0 {
+			return
+		}
+		client.responseBodyLimit = limit
+	}
+}
+
+// httpResponse is implemented by the responses carrying an HTTPInfo.
+type httpResponse interface {
+	setHTTP(info *HTTPInfo)
+}
+
+type captureKey struct{}
+
+// capture is the response recorded by captureTransport.
+type capture struct {
+	done   bool
+	status int
+	header http.Header
+	body   []byte
+	err    error
+}
+
+// info returns the HTTPInfo of the captured response with the body cut to
+// limit bytes.
+func (c *capture) info(limit int) *HTTPInfo {
+	info := &HTTPInfo{StatusCode: c.status, Header: c.header}
+	body := c.body
+	if len(body) > limit {
+		body, info.Truncated = body[:limit], true
+	}
+	info.Body = append([]byte(nil), body...)
+
+	return info
+}
+
+// withCapture returns ctx asking captureTransport to record the response of
+// the request made with it in capture.
+func withCapture(ctx context.Context, capture *capture) context.Context {
+	return context.WithValue(ctx, captureKey{}, capture)
+}
+
+// captureTransport records the status, the headers and the body of the
+// responses whose request context was made by withCapture. It asks for gzip
+// responses and decompresses them, a malformed one is recorded as the error
+// of the capture and handed on with an empty body.
+type captureTransport struct {
+	next http.RoundTripper
+}
+
+func (t *captureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	captured, ok := req.Context().Value(captureKey{}).(*capture)
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+
+	if req.Header.Get("Accept-Encoding") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		return res, err
+	}
+
+	body, err := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	captured.done = true
+	captured.status = res.StatusCode
+	captured.header = res.Header.Clone()
+	captured.body = body
+
+	if gzipEncoded(res.Header) {
+		plain, err := gunzip(body, maxResponseBody)
+		if err != nil {
+			captured.err = err
+			plain = nil
+		} else {
+			captured.body = plain
+		}
+
+		res.Header.Del("Content-Encoding")
+		res.Header.Del("Content-Length")
+		res.ContentLength = int64(len(plain))
+		res.Uncompressed = true
+		body = plain
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	return res, nil
+}
@@ -0,0 +1,66 @@
+package mpesa
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestResponseHTTPInfo(t *testing.T) {
+	g := newTestGateway(t)
+	body := `{"output_ResponseCode":"INS-13","output_ResponseDesc":"Invalid Shortcode Used","output_NewField":"x"}`
+	g.handlers["b2cPayment/"] = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-Id", "req-1")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(body))
+	}
+
+	c := g.client()
+	response, err := c.Disburse(context.Background(), testRequest("tp-1").DisburseRequest())
+	if err == nil {
+		t.Fatal("Disburse() error = nil")
+	}
+	if response.ResponseCode != "INS-13" {
+		t.Errorf("ResponseCode = %q, want INS-13", response.ResponseCode)
+	}
+
+	info := response.HTTP
+	if info == nil {
+		t.Fatal("HTTP = nil")
+	}
+	if info.StatusCode != http.StatusBadRequest || info.Header.Get("X-Request-Id") != "req-1" {
+		t.Errorf("HTTP = %+v, want the 400 and its headers", info)
+	}
+	if string(info.Body) != body || info.Truncated {
+		t.Errorf("Body = %q, Truncated = %v, want the raw body", info.Body, info.Truncated)
+	}
+
+	session, err := c.SessionID(context.Background())
+	if err != nil {
+		t.Fatalf("SessionID() error = %v", err)
+	}
+	if session.HTTP == nil || !strings.Contains(string(session.HTTP.Body), "output_SessionID") {
+		t.Errorf("SessionID() HTTP = %+v, want the raw body", session.HTTP)
+	}
+}
+
+func TestWithResponseBodyLimit(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, PushAsyncResponse{ResponseCode: "INS-0", ResponseDesc: strings.Repeat("a", 100)})
+	}
+
+	c := g.client(WithResponseBodyLimit(10))
+	response, err := c.PushAsync(context.Background(), testRequest("tp-1").PushRequest())
+	if err != nil {
+		t.Fatalf("PushAsync() error = %v", err)
+	}
+	if response.ResponseDesc != strings.Repeat("a", 100) {
+		t.Errorf("ResponseDesc = %q, want the full description", response.ResponseDesc)
+	}
+	if len(response.HTTP.Body) != 10 || !response.HTTP.Truncated {
+		t.Errorf("Body = %q, Truncated = %v, want 10 bytes", response.HTTP.Body, response.HTTP.Truncated)
+	}
+}
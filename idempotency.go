@@ -0,0 +1,196 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+var _ IdempotencyStore = (*MemoryIdempotencyStore)(nil)
+
+// ErrInFlight is returned by Disburse when the IdempotencyKey of the request is
+// reserved by a disbursement that has not completed, or whose outcome is
+// unknown.
+var ErrInFlight = errors.New("mpesa: idempotency key is in flight")
+
+// IdempotencyStore records the disbursements sent for a business key, e.g. the
+// id of a payout, so that a worker restarting in the middle of a run does not
+// pay the same payout twice. Implementations must be safe for concurrent use,
+// and Reserve must be atomic across every instance sharing the store, e.g. SET
+// NX in Redis. The keys must be kept at least as long as a payout may be
+// retried.
+type IdempotencyStore interface {
+	// Reserve claims key before the disbursement is sent. It returns false
+	// when key was free and is now reserved, true when the disbursement of
+	// key completed, and ErrInFlight when key is reserved and not completed.
+	Reserve(ctx context.Context, key string) (alreadyDone bool, err error)
+
+	// MarkDone stores the response of the disbursement of key, which is then
+	// completed.
+	MarkDone(ctx context.Context, key string, result DisburseResponse) error
+
+	// Load returns the response stored for key by MarkDone, if any.
+	Load(ctx context.Context, key string) (DisburseResponse, bool, error)
+
+	// Release frees key so that the disbursement is sent again by the next
+	// Disburse call with that key.
+	Release(ctx context.Context, key string) error
+}
+
+// WithIdempotencyStore guards the disbursements having an IdempotencyKey with
+// store. A key that was completed is answered with the stored response without
+// calling the gateway, one that is reserved fails with ErrInFlight.
+//
+// A disbursement rejected before it was sent, or turned down by the gateway
+// with a code meaning it was not carried out, e.g. an invalid parameter,
+// releases its key so that it can be retried. When its outcome is unknown,
+// e.g. after a timeout, a duplicate transaction or insufficient balance
+// reported by the gateway, or a response that could not be decoded, the key
+// stays reserved: the
+// caller checks the transaction with QueryTx, then releases the key or leaves
+// it reserved.
+func WithIdempotencyStore(store IdempotencyStore) ClientOption {
+	return func(client *Client) {
+		if store == nil {
+			client.optionErrs = append(client.optionErrs, "idempotency store is nil")
+			return
+		}
+		client.idempotencyStore = store
+	}
+}
+
+// idempotentDisburse is Disburse guarded by the IdempotencyStore.
+func (c *Client) idempotentDisburse(ctx context.Context, request DisburseRequest) (DisburseResponse, error) {
+	store, key := c.idempotencyStore, request.IdempotencyKey
+
+	done, err := store.Reserve(ctx, key)
+	if err != nil {
+		return DisburseResponse{}, fmt.Errorf("disbursement %q: %w", key, err)
+	}
+	if done {
+		response, ok, err := store.Load(ctx, key)
+		if err != nil {
+			return DisburseResponse{}, fmt.Errorf("disbursement %q: %w", key, err)
+		}
+		if !ok {
+			return DisburseResponse{}, fmt.Errorf("disbursement %q: completed without a stored response", key)
+		}
+		c.debugf("idempotency: replaying the response of %s", key)
+
+		return response, nil
+	}
+
+	response, err := c.disburse(ctx, request)
+	if err != nil {
+		if rejected(err) {
+			if rerr := store.Release(context.Background(), key); rerr != nil {
+				c.logf("idempotency: could not release %s: %v", key, rerr)
+			}
+		}
+
+		return response, err
+	}
+
+	if err := store.MarkDone(context.Background(), key, response); err != nil {
+		c.logf("idempotency: could not mark %s done: %v", key, err)
+	}
+
+	return response, nil
+}
+
+// notExecutedCodes are the response codes with which the gateway turns a
+// request down before carrying it out: invalid credentials, parameters or
+// market, and breached limits. Any other code, e.g. INS-10 or INS-2006, may
+// come with a transaction on the gateway and leaves the outcome unknown.
+var notExecutedCodes = map[ResponseCode]bool{ //nolint:gochecknoglobals
+	"INS-2": true, "INS-4": true, "INS-13": true, "INS-14": true, "INS-15": true,
+	"INS-17": true, "INS-18": true, "INS-19": true, "INS-20": true, "INS-21": true,
+	"INS-22": true, "INS-24": true, "INS-25": true, "INS-26": true, "INS-28": true,
+	"INS-30": true, "INS-990": true, "INS-991": true, "INS-992": true, "INS-993": true,
+	"INS-994": true, "INS-995": true, "INS-996": true, "INS-997": true, "INS-998": true,
+	"INS-2001": true, "INS-2002": true, "INS-2051": true, "INS-2057": true,
+}
+
+// rejected reports whether err means that a request was not carried out: it
+// was rejected by the validation, it failed before being handed to the
+// http.Client, e.g. on an open circuit or while fetching the session, the
+// session was rejected with a 401, or the gateway turned it down with one of
+// notExecutedCodes. Any other error leaves the outcome unknown.
+func rejected(err error) bool {
+	var (
+		apiErr        *APIError
+		validationErr *ValidationError
+		fieldErr      *FieldError
+	)
+	switch {
+	case errors.As(err, &validationErr), errors.As(err, &fieldErr), errors.Is(err, ErrDryRun),
+		errors.Is(err, ErrReservedHeader), notSent(err):
+		return true
+	case errors.As(err, &apiErr):
+		return apiErr.Operation == sessionID.Name() ||
+			(apiErr.Code == "" && apiErr.StatusCode == http.StatusUnauthorized) ||
+			notExecutedCodes[ResponseCode(apiErr.Code)]
+	default:
+		return false
+	}
+}
+
+// MemoryIdempotencyStore is an in-memory IdempotencyStore for a single
+// instance. It keeps every key for the life of the process.
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	entries map[string]*DisburseResponse
+}
+
+// NewMemoryIdempotencyStore returns an empty MemoryIdempotencyStore.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{entries: make(map[string]*DisburseResponse)}
+}
+
+func (s *MemoryIdempotencyStore) Reserve(_ context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, ok := s.entries[key]
+	switch {
+	case !ok:
+		s.entries[key] = nil
+		return false, nil
+	case result == nil:
+		return false, ErrInFlight
+	default:
+		return true, nil
+	}
+}
+
+func (s *MemoryIdempotencyStore) MarkDone(_ context.Context, key string, result DisburseResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[key] = &result
+
+	return nil
+}
+
+func (s *MemoryIdempotencyStore) Load(_ context.Context, key string) (DisburseResponse, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := s.entries[key]
+	if result == nil {
+		return DisburseResponse{}, false, nil
+	}
+
+	return *result, true, nil
+}
+
+func (s *MemoryIdempotencyStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+
+	return nil
+}
@@ -0,0 +1,187 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+func TestIdempotentDisburse(t *testing.T) {
+	g := newTestGateway(t)
+	var sent int32
+	status, code := http.StatusCreated, "INS-0"
+	g.handlers["b2cPayment/"] = func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&sent, 1)
+		writeJSON(w, status, DisburseResponse{ResponseCode: code, ConversationID: string(rune('a' + n))})
+	}
+	store := NewMemoryIdempotencyStore()
+	c := g.client(WithIdempotencyStore(store))
+
+	request := testRequest("").DisburseRequest()
+	request.IdempotencyKey = "payout-1"
+	first, err := c.Disburse(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Disburse() error = %v", err)
+	}
+	second, err := c.Disburse(context.Background(), request)
+	if err != nil {
+		t.Fatalf("second Disburse() error = %v", err)
+	}
+	if atomic.LoadInt32(&sent) != 1 || second.ConversationID != first.ConversationID {
+		t.Errorf("disbursements sent = %d, second response = %+v, want the first response replayed", sent, second)
+	}
+
+	// a disbursement without a key is not guarded
+	request.IdempotencyKey = ""
+	if _, err := c.Disburse(context.Background(), request); err != nil || atomic.LoadInt32(&sent) != 2 {
+		t.Errorf("Disburse() without a key = %v, sent = %d, want it sent", err, sent)
+	}
+
+	t.Run("rejected", func(t *testing.T) {
+		status, code = http.StatusBadRequest, "INS-13"
+		request.IdempotencyKey = "payout-2"
+		for i := 0; i < 2; i++ {
+			var apiErr *APIError
+			if _, err := c.Disburse(context.Background(), request); !errors.As(err, &apiErr) {
+				t.Errorf("Disburse() error = %v, want the *APIError of the gateway", err)
+			}
+		}
+		if n := atomic.LoadInt32(&sent); n != 4 {
+			t.Errorf("disbursements sent = %d, want the rejected key released and sent again", n)
+		}
+	})
+
+	t.Run("code with an unknown outcome", func(t *testing.T) {
+		for i, unknown := range []string{"INS-10", "INS-2006"} {
+			status, code = http.StatusBadRequest, unknown
+			request.IdempotencyKey = "payout-" + unknown
+			if _, err := c.Disburse(context.Background(), request); err == nil {
+				t.Fatalf("Disburse() with %s error = nil, want the *APIError of the gateway", unknown)
+			}
+			if _, err := c.Disburse(context.Background(), request); !errors.Is(err, ErrInFlight) {
+				t.Errorf("second Disburse() with %s error = %v, want ErrInFlight", unknown, err)
+			}
+			if n := atomic.LoadInt32(&sent); n != int32(5+i) {
+				t.Errorf("disbursements sent = %d, want the key of %s left reserved", n, unknown)
+			}
+		}
+	})
+
+	t.Run("unknown outcome", func(t *testing.T) {
+		status, code = http.StatusServiceUnavailable, "INS-1"
+		request.IdempotencyKey = "payout-3"
+		if _, err := c.Disburse(context.Background(), request); err == nil {
+			t.Fatalf("Disburse() error = nil, want the internal error")
+		}
+		status, code = http.StatusCreated, "INS-0"
+		if _, err := c.Disburse(context.Background(), request); !errors.Is(err, ErrInFlight) {
+			t.Errorf("Disburse() error = %v, want ErrInFlight", err)
+		}
+		if n := atomic.LoadInt32(&sent); n != 7 {
+			t.Errorf("disbursements sent = %d, want the unknown outcome left reserved", n)
+		}
+
+		if err := store.Release(context.Background(), "payout-3"); err != nil {
+			t.Fatalf("Release() error = %v", err)
+		}
+		if _, err := c.Disburse(context.Background(), request); err != nil {
+			t.Errorf("Disburse() after Release error = %v", err)
+		}
+	})
+}
+
+func TestIdempotentDisburseNotSent(t *testing.T) {
+	g := newTestGateway(t)
+	var sent int32
+	g.handlers["b2cPayment/"] = func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&sent, 1)
+		writeJSON(w, http.StatusCreated, DisburseResponse{ResponseCode: "INS-0"})
+	}
+	session := g.handlers["getSession/"]
+	var sessionFailed int32
+	g.handlers["getSession/"] = func(w http.ResponseWriter, r *http.Request) {
+		if atomic.CompareAndSwapInt32(&sessionFailed, 0, 1) {
+			// the connection drops before the session is answered
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		session(w, r)
+	}
+	c := g.client(WithIdempotencyStore(NewMemoryIdempotencyStore()))
+
+	request := testRequest("").DisburseRequest()
+	request.IdempotencyKey = "payout-1"
+
+	var transportErr *TransportError
+	if _, err := c.Disburse(context.Background(), request); !errors.As(err, &transportErr) {
+		t.Fatalf("Disburse() error = %v, want the *TransportError of the session", err)
+	}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.Disburse(canceled, request); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Disburse() error = %v, want context.Canceled", err)
+	}
+	if _, err := c.Disburse(context.Background(), request); err != nil {
+		t.Errorf("Disburse() after the unsent attempts error = %v, want the key released", err)
+	}
+	if n := atomic.LoadInt32(&sent); n != 1 {
+		t.Errorf("disbursements sent = %d, want 1", n)
+	}
+}
+
+func TestIdempotentDisburseBatch(t *testing.T) {
+	g := newTestGateway(t)
+	var sent int32
+	g.handlers["b2cPayment/"] = func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&sent, 1)
+		writeJSON(w, http.StatusCreated, DisburseResponse{ResponseCode: "INS-0"})
+	}
+	store := NewMemoryIdempotencyStore()
+
+	requests := make([]DisburseRequest, 5)
+	for i := range requests {
+		requests[i] = testRequest("").DisburseRequest()
+		requests[i].IdempotencyKey = string(rune('a' + i))
+	}
+
+	// a worker restarting after the first three payouts generates new
+	// ThirdPartyIDs but keeps the keys
+	if _, err := g.client(WithIdempotencyStore(store)).DisburseBatch(context.Background(), requests[:3], BatchOptions{}); err != nil {
+		t.Fatalf("DisburseBatch() error = %v", err)
+	}
+	result, err := g.client(WithIdempotencyStore(store)).DisburseBatch(context.Background(), requests, BatchOptions{})
+	if err != nil {
+		t.Fatalf("DisburseBatch() error = %v", err)
+	}
+	if result.Succeeded != 5 || atomic.LoadInt32(&sent) != 5 {
+		t.Errorf("succeeded = %d, sent = %d, want the first three payouts sent once", result.Succeeded, sent)
+	}
+}
+
+func TestMemoryIdempotencyStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryIdempotencyStore()
+
+	if done, err := s.Reserve(ctx, "k"); done || err != nil {
+		t.Fatalf("Reserve() = %v, %v, want the key reserved", done, err)
+	}
+	if _, err := s.Reserve(ctx, "k"); !errors.Is(err, ErrInFlight) {
+		t.Errorf("Reserve() of a reserved key error = %v, want ErrInFlight", err)
+	}
+	if _, ok, _ := s.Load(ctx, "k"); ok {
+		t.Errorf("Load() of a reserved key found a response")
+	}
+
+	if err := s.MarkDone(ctx, "k", DisburseResponse{ConversationID: "c"}); err != nil {
+		t.Fatalf("MarkDone() error = %v", err)
+	}
+	if done, err := s.Reserve(ctx, "k"); !done || err != nil {
+		t.Errorf("Reserve() of a done key = %v, %v, want it done", done, err)
+	}
+	if response, ok, err := s.Load(ctx, "k"); !ok || err != nil || response.ConversationID != "c" {
+		t.Errorf("Load() = %+v, %v, %v, want the stored response", response, ok, err)
+	}
+}
@@ -0,0 +1,75 @@
+package mpesa
+
+import "context"
+
+type (
+	// BeforeRequestHook is called before a request of operation op is sent to
+	// the gateway. payload is the adapted payload, as sent on the wire, and is
+	// nil for SessionID. The returned context is used for the request and for
+	// the next hooks. An error aborts the call before anything is sent and is
+	// returned to the caller.
+	BeforeRequestHook func(ctx context.Context, op string, payload interface{}) (context.Context, error)
+
+	// AfterResponseHook is called once the request of operation op is done.
+	// response is a pointer to the decoded response, err is the error of the
+	// exchange: a transport failure, a throttled or rejected session, or the
+	// error of a BeforeRequestHook. The gateway response code is left in
+	// response.
+	AfterResponseHook func(ctx context.Context, op string, response interface{}, err error)
+
+	// Interceptor wraps the requests sent to the gateway. Either hook can be
+	// nil.
+	Interceptor struct {
+		BeforeRequest BeforeRequestHook
+		AfterResponse AfterResponseHook
+	}
+)
+
+// WithInterceptors adds interceptors around every request sent to the gateway,
+// retries included. The BeforeRequest hooks are called in the order given and
+// the AfterResponse hooks in the reverse order, so that the first interceptor
+// wraps the others. When a BeforeRequest hook fails, only the interceptors
+// before it see the AfterResponse. The option can be used more than once, the
+// interceptors are appended.
+func WithInterceptors(interceptors ...Interceptor) ClientOption {
+	return func(client *Client) {
+		client.interceptors = append(client.interceptors, interceptors...)
+	}
+}
+
+// intercept calls fn between the interceptor hooks. response is passed to the
+// AfterResponse hooks once fn returns.
+func (c *Client) intercept(ctx context.Context, requestType requestType, payload, response interface{},
+	fn func(ctx context.Context) error) error {
+	op := requestType.Name()
+
+	var (
+		reached int
+		err     error
+	)
+	for ; reached < len(c.interceptors); reached++ {
+		before := c.interceptors[reached].BeforeRequest
+		if before == nil {
+			continue
+		}
+		var next context.Context
+		if next, err = before(ctx, op, payload); err != nil {
+			break
+		}
+		if next != nil {
+			ctx = next
+		}
+	}
+
+	if err == nil {
+		err = fn(ctx)
+	}
+
+	for i := reached - 1; i >= 0; i-- {
+		if after := c.interceptors[i].AfterResponse; after != nil {
+			after(ctx, op, response, err)
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,100 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+type interceptorKey struct{}
+
+func TestInterceptorsOrderAndPayload(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, PushAsyncResponse{ResponseCode: "INS-0", ConversationID: "conv-1"})
+	}
+
+	var calls []string
+	record := func(name string) Interceptor {
+		return Interceptor{
+			BeforeRequest: func(ctx context.Context, op string, payload interface{}) (context.Context, error) {
+				calls = append(calls, "before "+name+" "+op)
+				if op == pushPay.Name() {
+					if p, ok := payload.(pushPayRequest); !ok || p.Currency != "TZS" || p.ThirdPartyConversationID != "tp-1" {
+						t.Errorf("payload = %#v, want the adapted push request", payload)
+					}
+				}
+
+				return context.WithValue(ctx, interceptorKey{}, name), nil
+			},
+			AfterResponse: func(ctx context.Context, op string, response interface{}, err error) {
+				calls = append(calls, "after "+name+" "+op)
+				if ctx.Value(interceptorKey{}) != "second" {
+					t.Errorf("context value = %v, want the one set by the last hook", ctx.Value(interceptorKey{}))
+				}
+				if res, ok := response.(*PushAsyncResponse); ok && res.ConversationID != "conv-1" {
+					t.Errorf("response = %+v, want the decoded response", res)
+				}
+			},
+		}
+	}
+
+	c := g.client(WithInterceptors(record("first")), WithInterceptors(record("second")))
+	if _, err := c.PushAsync(context.Background(), testRequest("tp-1").PushRequest()); err != nil {
+		t.Fatalf("PushAsync() error = %v", err)
+	}
+
+	// the session is fetched from within the push request
+	want := []string{
+		"before first ussd push",
+		"before second ussd push",
+		"before first get session id",
+		"before second get session id",
+		"after second get session id",
+		"after first get session id",
+		"after second ussd push",
+		"after first ussd push",
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+}
+
+func TestInterceptorAbortsRequest(t *testing.T) {
+	g := newTestGateway(t)
+	var sent int32
+	g.handlers["b2cPayment/"] = func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&sent, 1)
+	}
+
+	denied := errors.New("denied by policy")
+	var afterErrs []error
+	c := g.client(WithInterceptors(
+		Interceptor{
+			AfterResponse: func(ctx context.Context, op string, response interface{}, err error) {
+				afterErrs = append(afterErrs, err)
+			},
+		},
+		Interceptor{
+			BeforeRequest: func(ctx context.Context, op string, payload interface{}) (context.Context, error) {
+				return nil, denied
+			},
+			AfterResponse: func(ctx context.Context, op string, response interface{}, err error) {
+				t.Error("AfterResponse of the failing interceptor called")
+			},
+		},
+	))
+
+	if _, err := c.Disburse(context.Background(), testRequest("tp-1").DisburseRequest()); !errors.Is(err, denied) {
+		t.Fatalf("Disburse() error = %v, want %v", err, denied)
+	}
+	if n := atomic.LoadInt32(&sent); n != 0 || atomic.LoadInt32(&g.sessions) != 0 {
+		t.Errorf("requests sent = %d, sessions = %d, want none", n, atomic.LoadInt32(&g.sessions))
+	}
+	if len(afterErrs) != 1 || !errors.Is(afterErrs[0], denied) {
+		t.Errorf("AfterResponse errors = %v, want [%v]", afterErrs, denied)
+	}
+}
@@ -0,0 +1,158 @@
+package mpesa
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// logLevel is the level of a structured record, mapped to the slog levels.
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+)
+
+// recordLogger receives the structured records of the client, attrs are
+// alternating keys and values. It is set by WithSlog.
+type recordLogger interface {
+	logRecord(ctx context.Context, level logLevel, msg string, attrs ...interface{})
+}
+
+// logf writes a formatted line to the client logger, or an info record when
+// a structured logger is set.
+func (c *Client) logf(format string, args ...interface{}) {
+	if c.records != nil {
+		c.record(context.Background(), levelInfo, fmt.Sprintf(format, args...))
+		return
+	}
+
+	_, _ = fmt.Fprintf(c.base.Logger, "mpesa: "+format+"\n", args...)
+}
+
+// debugf is like logf but only writes when debug mode is on, as a debug
+// record when a structured logger is set.
+func (c *Client) debugf(format string, args ...interface{}) {
+	if !c.base.DebugMode {
+		return
+	}
+
+	if c.records != nil {
+		c.record(context.Background(), levelDebug, fmt.Sprintf(format, args...))
+		return
+	}
+
+	c.logf(format, args...)
+}
+
+// record writes a structured record when a structured logger is set, and
+// nothing otherwise. The market and the operation carried by ctx, if any, are
+// added to attrs, and the message and the string and error values are
+// redacted like the lines of the logger.
+func (c *Client) record(ctx context.Context, level logLevel, msg string, attrs ...interface{}) {
+	if c.records == nil {
+		return
+	}
+
+	all := make([]interface{}, 0, len(attrs)+4)
+	if op, ok := ctx.Value(operationKey{}).(*operation); ok {
+		all = append(all, "op", op.requestType.Name())
+	}
+	all = append(all, "market", c.Conf.Market.String())
+	if id := c.correlationID(ctx); id != "" {
+		all = append(all, "correlation_id", id)
+	}
+	all = append(all, attrs...)
+
+	if !c.noRedaction {
+		secrets := c.secrets()
+		msg = redact(msg, secrets...)
+		for i := 1; i < len(all); i += 2 {
+			switch v := all[i].(type) {
+			case string:
+				all[i] = redact(v, secrets...)
+			case error:
+				all[i] = redact(v.Error(), secrets...)
+			}
+		}
+	}
+
+	c.records.logRecord(ctx, level, msg, all...)
+}
+
+// payloadWriter turns the requests, responses and callbacks dumped by the base
+// client in debug mode into debug records carrying the dump as payload.
+type payloadWriter struct {
+	records recordLogger
+}
+
+func (w payloadWriter) Write(p []byte) (int, error) {
+	w.records.logRecord(context.Background(), levelDebug, "payload", "payload", strings.TrimSpace(string(p)))
+
+	return len(p), nil
+}
+
+const redacted = "[REDACTED]"
+
+var (
+	authorizationHeader = regexp.MustCompile(`(?i)(authorization"?\s*[:=]\s*"?)(bearer\s+|basic\s+)?[^\s",]+`)
+	sessionIDField      = regexp.MustCompile(`("output_SessionID"\s*:\s*")[^"]*(")`)
+	msisdn              = regexp.MustCompile(`\b\d{9,11}(\d{3})\b`)
+)
+
+// redactingWriter masks secrets and customer details before they reach the
+// underlying writer. The Authorization header, the session id, the API key
+// and all but the last 3 digits of MSISDNs are masked.
+type redactingWriter struct {
+	w       io.Writer
+	secrets func() []string
+}
+
+func (r *redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.w, redact(string(p), r.secrets()...)); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// redact masks the Authorization header, session ids, MSISDNs and every
+// occurrence of secrets in s.
+func redact(s string, secrets ...string) string {
+	for _, secret := range secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, redacted)
+		}
+	}
+
+	s = authorizationHeader.ReplaceAllString(s, "${1}${2}"+redacted)
+	s = sessionIDField.ReplaceAllString(s, "${1}"+redacted+"${2}")
+	s = msisdn.ReplaceAllStringFunc(s, func(number string) string {
+		return strings.Repeat("*", len(number)-3) + number[len(number)-3:]
+	})
+
+	return s
+}
+
+// secrets returns the values that must never be written to the logger.
+func (c *Client) secrets() []string {
+	c.sessionMu.RLock()
+	defer c.sessionMu.RUnlock()
+
+	secrets := []string{c.Conf.APIKey}
+	if c.sessionID != nil {
+		secrets = append(secrets, *c.sessionID)
+	}
+	if c.encryptedAPIKey != nil {
+		secrets = append(secrets, *c.encryptedAPIKey)
+	}
+	if c.secretsCache != nil {
+		secrets = append(secrets, c.secretsCache.apiKey)
+	}
+
+	return secrets
+}
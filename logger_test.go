@@ -0,0 +1,90 @@
+package mpesa
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		secrets []string
+		want    string
+	}{
+		{
+			name: "bearer token",
+			in:   "Authorization: Bearer c2Vzc2lvbi0x\r\nOrigin: *",
+			want: "Authorization: Bearer [REDACTED]\r\nOrigin: *",
+		},
+		{
+			name: "session id field",
+			in:   `{"output_ResponseCode":"INS-0","output_SessionID":"01a2b3c4"}`,
+			want: `{"output_ResponseCode":"INS-0","output_SessionID":"[REDACTED]"}`,
+		},
+		{
+			name: "msisdn",
+			in:   `{"input_CustomerMSISDN":"255754000123"}`,
+			want: `{"input_CustomerMSISDN":"*********123"}`,
+		},
+		{
+			name:    "api key",
+			in:      "api key is a1b2c3d4e5",
+			secrets: []string{"a1b2c3d4e5"},
+			want:    "api key is [REDACTED]",
+		},
+		{
+			name: "nothing to redact",
+			in:   `{"output_ResponseCode":"INS-0","input_Amount":"1000.00"}`,
+			want: `{"output_ResponseCode":"INS-0","input_Amount":"1000.00"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redact(tt.in, tt.secrets...); got != tt.want {
+				t.Errorf("redact() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDebugOutputIsRedacted(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusCreated, PushAsyncResponse{ResponseCode: "INS-0"})
+	}
+
+	logs := new(bytes.Buffer)
+	c := g.client(WithDebugMode(true), WithLogger(logs))
+	c.Conf.APIKey = "a1b2c3d4e5f6"
+
+	_, err := c.PushAsync(context.Background(), PushRequest{
+		Amount: MustParseAmount("1000"),
+		MSISDN: "255754000123",
+	})
+	if err != nil {
+		t.Fatalf("PushAsync() error = %v", err)
+	}
+	c.debugf("configured api key %s", c.Conf.APIKey)
+
+	out := logs.String()
+	for _, secret := range []string{"a1b2c3d4e5f6", "session-1", "255754000123"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("logs contain %q:\n%s", secret, out)
+		}
+	}
+	if !strings.Contains(out, "Bearer "+redacted) {
+		t.Errorf("logs do not contain a redacted bearer token:\n%s", out)
+	}
+
+	logs.Reset()
+	raw := g.client(WithDebugMode(true), WithLogger(logs), WithRedaction(false))
+	raw.debugf("configured api key %s", raw.Conf.APIKey)
+	if !strings.Contains(logs.String(), raw.Conf.APIKey) {
+		t.Errorf("logs with redaction off = %q, want the raw api key", logs.String())
+	}
+}
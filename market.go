@@ -1,88 +1,230 @@
 package mpesa
 
 import (
+	"encoding"
+	"fmt"
 	"strings"
 )
 
-var _ market = (*Market)(nil)
+var (
+	_ market                   = (*Market)(nil)
+	_ fmt.Stringer             = (*Market)(nil)
+	_ encoding.TextMarshaler   = (*Market)(nil)
+	_ encoding.TextUnmarshaler = (*Market)(nil)
+)
 
 const (
-	GhanaMarket    = Market(0)
-	TanzaniaMarket = Market(1)
+	GhanaMarket      = Market(0)
+	TanzaniaMarket   = Market(1)
+	DRCMarket        = Market(2)
+	LesothoMarket    = Market(3)
+	MozambiqueMarket = Market(4)
+	EgyptMarket      = Market(5)
 )
 
 type market interface {
 	URLContextValue() string
 	Country() string
 	Currency() string
+	Currencies() []string
+	DialingPrefix() string
 	Description() string
 }
 
 type Market int
 
+// MarketFmt returns the Market named by marketString or Market(-1) when it
+// is not known. See ParseMarket for the accepted names.
 func MarketFmt(marketString string) Market {
-	if strings.ToLower(marketString) == "ghana" {
-		return GhanaMarket
+	m, err := ParseMarket(marketString)
+	if err != nil {
+		return Market(-1)
+	}
+
+	return m
+}
+
+// ParseMarket returns the Market named by s. The match is case-insensitive and
+// accepts the country name, the ISO 3166 alpha-2 code, the input_Country value
+// and the URL context value, e.g. "tanzania", "TZ", "TZN" or "vodacomTZN".
+func ParseMarket(s string) (Market, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "ghana", "gh", "gha", "vodafonegha":
+		return GhanaMarket, nil
+	case "tanzania", "tz", "tzn", "tza", "vodacomtzn":
+		return TanzaniaMarket, nil
+	case "drc", "congo", "cd", "cod", "vodacomdrc":
+		return DRCMarket, nil
+	case "lesotho", "ls", "les", "lso", "vodacomles":
+		return LesothoMarket, nil
+	case "mozambique", "mz", "moz", "vodacommoz":
+		return MozambiqueMarket, nil
+	case "egypt", "eg", "egy", "vodafoneegy":
+		return EgyptMarket, nil
+	default:
+		return Market(-1), fmt.Errorf("mpesa: unknown market %q", s)
+	}
+}
+
+// String returns the input_Country value of the market, e.g. "TZN".
+func (m Market) String() string {
+	if c := m.Country(); c != "" {
+		return c
 	}
 
-	if strings.ToLower(marketString) == "tanzania" {
-		return TanzaniaMarket
+	return fmt.Sprintf("Market(%d)", int(m))
+}
+
+// MarshalText encodes the market as its input_Country value.
+func (m Market) MarshalText() ([]byte, error) {
+	if m.Country() == "" {
+		return nil, fmt.Errorf("mpesa: unknown market %d", int(m))
 	}
 
-	return Market(-1)
+	return []byte(m.Country()), nil
+}
+
+// UnmarshalText decodes any of the names accepted by ParseMarket.
+func (m *Market) UnmarshalText(text []byte) error {
+	parsed, err := ParseMarket(string(text))
+	if err != nil {
+		return err
+	}
+
+	*m = parsed
+
+	return nil
 }
 
 func (m Market) URLContextValue() string {
 	switch m {
-
-	//ghana
-	case 0:
+	case GhanaMarket:
 		return "vodafoneGHA"
-		//tanzania
-	case 1:
+	case TanzaniaMarket:
 		return "vodacomTZN"
+	case DRCMarket:
+		return "vodacomDRC"
+	case LesothoMarket:
+		return "vodacomLES"
+	case MozambiqueMarket:
+		return "vodacomMOZ"
+	case EgyptMarket:
+		return "vodafoneEGY"
 	default:
 		return ""
 	}
 }
 
+// Country returns the value of input_Country for the market.
 func (m Market) Country() string {
 	switch m {
-
-	//ghana
-	case 0:
+	case GhanaMarket:
 		return "GHA"
-		//tanzania
-	case 1:
+	case TanzaniaMarket:
 		return "TZN"
+	case DRCMarket:
+		return "DRC"
+	case LesothoMarket:
+		return "LES"
+	case MozambiqueMarket:
+		return "MOZ"
+	case EgyptMarket:
+		return "EGY"
 	default:
 		return ""
 	}
 }
 
+// Currency returns the ISO 4217 code sent as input_Currency for the market.
 func (m Market) Currency() string {
 	switch m {
-
-	//ghana
-	case 0:
+	case GhanaMarket:
 		return "GHS"
-		//tanzania
-	case 1:
+	case TanzaniaMarket:
 		return "TZS"
+	case DRCMarket:
+		return "USD"
+	case LesothoMarket:
+		return "LSL"
+	case MozambiqueMarket:
+		return "MZN"
+	case EgyptMarket:
+		return "EGP"
 	default:
 		return ""
 	}
 }
 
-func (m Market) Description() string {
+// Currencies returns the ISO 4217 codes accepted as input_Currency for the
+// market, starting with Currency. Only DRC accepts another currency, CDF.
+func (m Market) Currencies() []string {
+	switch m {
+	case DRCMarket:
+		return []string{"USD", "CDF"}
+	default:
+		if currency := m.Currency(); currency != "" {
+			return []string{currency}
+		}
+		return nil
+	}
+}
+
+// mobilePlan returns the number of digits of the mobile numbers of the market
+// after the dialing prefix and the prefixes they start with.
+func (m Market) mobilePlan() (digits int, prefixes []string) {
+	switch m {
+	case GhanaMarket:
+		return 9, []string{"20", "23", "24", "25", "26", "27", "28", "50", "53", "54", "55", "56", "57", "59"}
+	case TanzaniaMarket:
+		return 9, []string{"6", "7"}
+	case DRCMarket:
+		return 9, []string{"8", "9"}
+	case LesothoMarket:
+		return 8, []string{"5", "6"}
+	case MozambiqueMarket:
+		return 9, []string{"82", "83", "84", "85", "86", "87"}
+	case EgyptMarket:
+		return 10, []string{"10", "11", "12", "15"}
+	default:
+		return 0, nil
+	}
+}
+
+// DialingPrefix returns the international dialing prefix of the market without
+// the leading "+", e.g. "255" for Tanzania.
+func (m Market) DialingPrefix() string {
 	switch m {
+	case GhanaMarket:
+		return "233"
+	case TanzaniaMarket:
+		return "255"
+	case DRCMarket:
+		return "243"
+	case LesothoMarket:
+		return "266"
+	case MozambiqueMarket:
+		return "258"
+	case EgyptMarket:
+		return "20"
+	default:
+		return ""
+	}
+}
 
-	//ghana
-	case 0:
+func (m Market) Description() string {
+	switch m {
+	case GhanaMarket:
 		return "Vodafone Ghana"
-		//tanzania
-	case 1:
+	case TanzaniaMarket:
 		return "Vodacom Tanzania"
+	case DRCMarket:
+		return "Vodacom DRC"
+	case LesothoMarket:
+		return "Vodacom Lesotho"
+	case MozambiqueMarket:
+		return "Vodacom Mozambique"
+	case EgyptMarket:
+		return "Vodafone Egypt"
 	default:
 		return ""
 	}
@@ -0,0 +1,174 @@
+package mpesa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestMarkets(t *testing.T) {
+	tests := []struct {
+		market   Market
+		basePath string
+		country  string
+		currency string
+		prefix   string
+	}{
+		{GhanaMarket, "/sandbox/ipg/v2/vodafoneGHA/", "GHA", "GHS", "233"},
+		{TanzaniaMarket, "/sandbox/ipg/v2/vodacomTZN/", "TZN", "TZS", "255"},
+		{DRCMarket, "/sandbox/ipg/v2/vodacomDRC/", "DRC", "USD", "243"},
+		{LesothoMarket, "/sandbox/ipg/v2/vodacomLES/", "LES", "LSL", "266"},
+		{MozambiqueMarket, "/sandbox/ipg/v2/vodacomMOZ/", "MOZ", "MZN", "258"},
+		{EgyptMarket, "/sandbox/ipg/v2/vodafoneEGY/", "EGY", "EGP", "20"},
+	}
+
+	g := newTestGateway(t)
+	var payload map[string]string
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		payload = nil
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		writeJSON(w, http.StatusCreated, PushAsyncResponse{ResponseCode: "INS-0"})
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.market.Description(), func(t *testing.T) {
+			c := g.client(WithMarket(tt.market))
+
+			if want := "https://" + g.Listener.Addr().String() + tt.basePath; c.baseURL != want {
+				t.Errorf("baseURL = %q, want %q", c.baseURL, want)
+			}
+			if got := tt.market.DialingPrefix(); got != tt.prefix {
+				t.Errorf("DialingPrefix() = %q, want %q", got, tt.prefix)
+			}
+
+			_, err := c.PushAsync(context.Background(), PushRequest{Amount: MustParseAmount("10"), MSISDN: testMSISDN(tt.market), Reference: "ref", ThirdPartyID: "tp"})
+			if err != nil {
+				t.Fatalf("PushAsync() error = %v", err)
+			}
+			if payload["input_Country"] != tt.country || payload["input_Currency"] != tt.currency {
+				t.Errorf("input_Country, input_Currency = %q, %q, want %q, %q",
+					payload["input_Country"], payload["input_Currency"], tt.country, tt.currency)
+			}
+		})
+	}
+}
+
+func TestMarketCurrencies(t *testing.T) {
+	g := newTestGateway(t)
+	var payload map[string]string
+	var sent int
+	g.handlers["b2cPayment/"] = func(w http.ResponseWriter, r *http.Request) {
+		sent++
+		payload = nil
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		writeJSON(w, http.StatusCreated, DisburseResponse{ResponseCode: "INS-0"})
+	}
+
+	tests := []struct {
+		market   Market
+		currency string
+		want     string
+		wantErr  bool
+	}{
+		{market: DRCMarket, want: "USD"},
+		{market: DRCMarket, currency: "USD", want: "USD"},
+		{market: DRCMarket, currency: "CDF", want: "CDF"},
+		{market: DRCMarket, currency: "cdf", wantErr: true},
+		{market: TanzaniaMarket, currency: "TZS", want: "TZS"},
+		{market: TanzaniaMarket, currency: "USD", wantErr: true},
+		{market: GhanaMarket, currency: "TZS", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.market.Description()+" "+tt.currency, func(t *testing.T) {
+			sent = 0
+			c := g.client(WithMarket(tt.market))
+			_, err := c.Disburse(context.Background(), DisburseRequest{
+				ThirdPartyID: "tp",
+				Amount:       MustParseAmount("10"),
+				MSISDN:       testMSISDN(tt.market),
+				Currency:     tt.currency,
+			})
+			if tt.wantErr {
+				var fieldErr *FieldError
+				if !errors.As(err, &fieldErr) || fieldErr.Field != "Currency" {
+					t.Errorf("Disburse() error = %v, want the currency rejected", err)
+				}
+				if sent != 0 {
+					t.Errorf("disbursements sent = %d, want 0", sent)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Disburse() error = %v", err)
+			}
+			if payload["input_Currency"] != tt.want {
+				t.Errorf("input_Currency = %q, want %q", payload["input_Currency"], tt.want)
+			}
+		})
+	}
+
+	for _, m := range []Market{GhanaMarket, TanzaniaMarket, DRCMarket, LesothoMarket, MozambiqueMarket, EgyptMarket} {
+		if currencies := m.Currencies(); len(currencies) == 0 || currencies[0] != m.Currency() {
+			t.Errorf("%s Currencies() = %v, want %s first", m, currencies, m.Currency())
+		}
+	}
+}
+
+func TestParseMarket(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Market
+		wantErr bool
+	}{
+		{in: "TZ", want: TanzaniaMarket},
+		{in: "TZN", want: TanzaniaMarket},
+		{in: "tanzania", want: TanzaniaMarket},
+		{in: "vodacomTZN", want: TanzaniaMarket},
+		{in: "GHA", want: GhanaMarket},
+		{in: " Ghana ", want: GhanaMarket},
+		{in: "cod", want: DRCMarket},
+		{in: "LS", want: LesothoMarket},
+		{in: "mozambique", want: MozambiqueMarket},
+		{in: "EGY", want: EgyptMarket},
+		{in: "kenya", wantErr: true},
+		{in: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseMarket(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMarket(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseMarket(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarketText(t *testing.T) {
+	for _, m := range []Market{GhanaMarket, TanzaniaMarket, DRCMarket, LesothoMarket, MozambiqueMarket, EgyptMarket} {
+		text, err := m.MarshalText()
+		if err != nil {
+			t.Fatalf("%v.MarshalText() error = %v", m, err)
+		}
+
+		var got Market
+		if err := got.UnmarshalText(text); err != nil || got != m {
+			t.Errorf("UnmarshalText(%q) = %v, %v, want %v", text, got, err, m)
+		}
+	}
+
+	if _, err := Market(99).MarshalText(); err == nil {
+		t.Error("Market(99).MarshalText() error = nil, want an error")
+	}
+
+	var m Market
+	if err := json.Unmarshal([]byte(`"atlantis"`), &m); err == nil {
+		t.Error("json.Unmarshal(atlantis) error = nil, want an error")
+	}
+}
@@ -0,0 +1,241 @@
+package mpesa
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type (
+	// MetricsCollector receives the outcome of the gateway operations and of
+	// the callbacks, e.g. to feed Prometheus counters and histograms.
+	//
+	// ObserveRequest is called once per gateway operation of Client, after
+	// the internal retries. retries is the number of extra
+	// attempts made, for the retry policy and for the session renewal. code is
+	// empty when no response code was received.
+	//
+	// ObserveCallback is called once per request to CallbackServeHTTP, with
+	// kind "push", and DisburseCallbackServeHTTP, with kind "disburse". err is
+	// not nil when the callback was rejected or its handler failed.
+	//
+	// The methods are called synchronously and must not block.
+	MetricsCollector interface {
+		ObserveRequest(op string, code ResponseCode, duration time.Duration, retries int, err error)
+		ObserveCallback(kind string, duration time.Duration, err error)
+	}
+
+	// CallbackPanicObserver is implemented by the MetricsCollector that count
+	// the callback handlers that panicked. ObserveCallbackPanic is called with
+	// the kind of the callback, "push" or "disburse", for every panic
+	// recovered, in addition to ObserveCallback.
+	CallbackPanicObserver interface {
+		ObserveCallbackPanic(kind string)
+	}
+
+	// NopMetrics is the MetricsCollector used when none is set, it discards
+	// everything.
+	NopMetrics struct{}
+
+	// RequestRecord is an operation recorded by MemoryMetrics.
+	RequestRecord struct {
+		Operation string
+		Code      ResponseCode
+		Duration  time.Duration
+		Retries   int
+		Err       error
+	}
+
+	// CallbackRecord is a callback recorded by MemoryMetrics.
+	CallbackRecord struct {
+		Kind     string
+		Duration time.Duration
+		Err      error
+	}
+
+	// MemoryMetrics is a MetricsCollector keeping every observation in memory,
+	// for tests and examples.
+	MemoryMetrics struct {
+		mu        sync.Mutex
+		requests  []RequestRecord
+		callbacks []CallbackRecord
+		panics    []string
+	}
+)
+
+var (
+	_ MetricsCollector      = NopMetrics{}
+	_ MetricsCollector      = (*MemoryMetrics)(nil)
+	_ CallbackPanicObserver = (*MemoryMetrics)(nil)
+)
+
+func (NopMetrics) ObserveRequest(string, ResponseCode, time.Duration, int, error) {}
+
+func (NopMetrics) ObserveCallback(string, time.Duration, error) {}
+
+func (m *MemoryMetrics) ObserveRequest(op string, code ResponseCode, duration time.Duration, retries int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests = append(m.requests, RequestRecord{Operation: op, Code: code, Duration: duration, Retries: retries, Err: err})
+}
+
+func (m *MemoryMetrics) ObserveCallback(kind string, duration time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.callbacks = append(m.callbacks, CallbackRecord{Kind: kind, Duration: duration, Err: err})
+}
+
+func (m *MemoryMetrics) ObserveCallbackPanic(kind string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.panics = append(m.panics, kind)
+}
+
+// Requests returns a copy of the recorded operations, oldest first.
+func (m *MemoryMetrics) Requests() []RequestRecord {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]RequestRecord(nil), m.requests...)
+}
+
+// Callbacks returns a copy of the recorded callbacks, oldest first.
+func (m *MemoryMetrics) Callbacks() []CallbackRecord {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]CallbackRecord(nil), m.callbacks...)
+}
+
+// CallbackPanics returns the kinds of the callbacks whose handler panicked,
+// oldest first.
+func (m *MemoryMetrics) CallbackPanics() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]string(nil), m.panics...)
+}
+
+// WithMetrics reports the outcome of the operations and of the callbacks to
+// collector.
+func WithMetrics(collector MetricsCollector) ClientOption {
+	return func(client *Client) {
+		if collector == nil {
+			client.optionErrs = append(client.optionErrs, "metrics collector is nil")
+			return
+		}
+
+		client.metrics = collector
+	}
+}
+
+type operationKey struct{}
+
+// operation follows a call to a gateway operation, for its span and its
+// metrics.
+type operation struct {
+	c           *Client
+	ctx         context.Context
+	requestType requestType
+	start       time.Time
+	span        *tracedSpan
+
+	mu               sync.Mutex
+	retries          int
+	gatewayRequestID string
+	gatewayHeaders   http.Header
+}
+
+// startOperation starts following a call of requestType. The returned context
+// carries the operation so that the retries made under it are counted.
+func (c *Client) startOperation(ctx context.Context, requestType requestType) (context.Context, *operation) {
+	op := &operation{c: c, requestType: requestType, start: c.clock.Now()}
+	ctx, op.span = c.startSpan(ctx, "mpesa "+requestType.Name(), requestType.Name())
+	op.ctx = context.WithValue(ctx, operationKey{}, op)
+	c.record(op.ctx, levelDebug, "request started")
+
+	return op.ctx, op
+}
+
+// retried counts an extra attempt of the operation carried by ctx, if any.
+func retried(ctx context.Context) {
+	op, ok := ctx.Value(operationKey{}).(*operation)
+	if !ok {
+		return
+	}
+
+	op.mu.Lock()
+	op.retries++
+	op.mu.Unlock()
+}
+
+// finish ends the span and reports the metrics of the operation.
+func (op *operation) finish(conversationID, thirdPartyID string, code ResponseCode, err error) {
+	op.span.finish(conversationID, thirdPartyID, code, err)
+
+	op.mu.Lock()
+	retries, gatewayRequestID := op.retries, op.gatewayRequestID
+	op.mu.Unlock()
+
+	duration := op.c.clock.Now().Sub(op.start)
+	op.c.metrics.ObserveRequest(op.requestType.Name(), code, duration, retries, err)
+
+	if op.c.records != nil {
+		level, attrs := levelInfo, []interface{}{
+			"conversation_id", conversationID, "third_party_conversation_id", thirdPartyID,
+			"response_code", string(code), "duration_ms", duration.Milliseconds(), "retries", retries,
+		}
+		if gatewayRequestID != "" {
+			attrs = append(attrs, "gateway_request_id", gatewayRequestID)
+		}
+		if err != nil {
+			level, attrs = levelWarn, append(attrs, "error", err)
+		}
+		op.c.record(op.ctx, level, "request finished", attrs...)
+	}
+}
+
+// callbackKind returns the kind of callback reported to the MetricsCollector.
+func callbackKind(body interface{}) string {
+	if _, ok := body.(*DisburseCallbackRequest); ok {
+		return "disburse"
+	}
+
+	return "push"
+}
+
+// statusWriter records the status written to a callback response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	return w.ResponseWriter.Write(p)
+}
+
+// err returns an error when the callback was answered with a client or server
+// error status.
+func (w *statusWriter) err() error {
+	if w.status < http.StatusBadRequest {
+		return nil
+	}
+
+	return fmt.Errorf("callback answered with status %d", w.status)
+}
@@ -0,0 +1,107 @@
+package mpesa
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMetricsRequests(t *testing.T) {
+	g, _ := queryGateway(t,
+		QueryTxResponse{ResponseCode: "INS-9", ResponseDesc: "Request timeout"},
+		QueryTxResponse{ResponseCode: "INS-0", ResponseTransactionStatus: "Completed"},
+	)
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusBadRequest, PushAsyncResponse{ResponseCode: "INS-6"})
+	}
+
+	metrics := &MemoryMetrics{}
+	c := g.client(WithMetrics(metrics), WithRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}))
+
+	if _, err := c.QueryTx(context.Background(), QueryTxParams{Reference: "ref"}); err != nil {
+		t.Fatalf("QueryTx() error = %v", err)
+	}
+	if _, err := c.PushAsync(context.Background(), testRequest("tp-1").PushRequest()); err == nil {
+		t.Fatal("PushAsync() error = nil")
+	}
+
+	requests := metrics.Requests()
+	want := []RequestRecord{
+		{Operation: "get session id", Code: "INS-0"},
+		{Operation: "query transaction status", Code: "INS-0", Retries: 1},
+		{Operation: "ussd push", Code: "INS-6"},
+	}
+	if len(requests) != len(want) {
+		t.Fatalf("requests = %+v, want %d", requests, len(want))
+	}
+	for i, got := range requests {
+		if got.Operation != want[i].Operation || got.Code != want[i].Code || got.Retries != want[i].Retries {
+			t.Errorf("request %d = %+v, want %+v", i, got, want[i])
+		}
+		if (got.Err != nil) != (want[i].Code == "INS-6") {
+			t.Errorf("request %d error = %v", i, got.Err)
+		}
+	}
+}
+
+func TestMetricsCallbacks(t *testing.T) {
+	g := newTestGateway(t)
+	metrics := &MemoryMetrics{}
+	c := g.client(WithMetrics(metrics), WithCallbackHandler(PushCallbackContextFunc(
+		func(ctx context.Context, request PushCallbackRequest) (PushCallbackResponse, error) {
+			return PushCallbackResponse{ResponseCode: "INS-0"}, nil
+		})))
+
+	c.CallbackServeHTTP(httptest.NewRecorder(), newCallbackRequest(context.Background(), testCallbackBody))
+
+	rejected := newCallbackRequest(context.Background(), testCallbackBody)
+	rejected.Header.Set("Content-Type", "text/plain")
+	c.CallbackServeHTTP(httptest.NewRecorder(), rejected)
+
+	callbacks := metrics.Callbacks()
+	if len(callbacks) != 2 {
+		t.Fatalf("callbacks = %+v, want 2", callbacks)
+	}
+	if callbacks[0].Kind != "push" || callbacks[0].Err != nil {
+		t.Errorf("accepted callback = %+v", callbacks[0])
+	}
+	if callbacks[1].Kind != "push" || callbacks[1].Err == nil {
+		t.Errorf("rejected callback = %+v, want an error", callbacks[1])
+	}
+}
+
+func TestMetricsMoreOperations(t *testing.T) {
+	g := newTestGateway(t)
+	for _, path := range []string{"b2bPayment/", "directDebitCancel/", "queryBeneficiaryName/"} {
+		g.handlers[path] = func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, http.StatusOK, map[string]string{"output_ResponseCode": "INS-0"})
+		}
+	}
+
+	metrics := &MemoryMetrics{}
+	c := g.client(WithMetrics(metrics))
+	ctx := context.Background()
+
+	if _, err := c.B2BPayment(ctx, Request{ThirdPartyID: "tp-1", Reference: "ref", Amount: MustParseAmount("1000"), ReceiverPartyCode: "000001"}); err != nil {
+		t.Fatalf("B2BPayment() error = %v", err)
+	}
+	if _, err := c.CancelDirectDebit(ctx, DirectDebitCancelRequest{AgreementID: "agreement", ThirdPartyID: "tp-1"}); err != nil {
+		t.Fatalf("CancelDirectDebit() error = %v", err)
+	}
+	if _, err := c.QueryBeneficiaryName(ctx, "255754000000"); err != nil {
+		t.Fatalf("QueryBeneficiaryName() error = %v", err)
+	}
+
+	requests := metrics.Requests()
+	want := []string{"get session id", "b2b payment", "direct debit cancellation", "query beneficiary name"}
+	if len(requests) != len(want) {
+		t.Fatalf("requests = %+v, want %d", requests, len(want))
+	}
+	for i, got := range requests {
+		if got.Operation != want[i] || got.Code != "INS-0" || got.Err != nil {
+			t.Errorf("request %d = %+v, want %s", i, got, want[i])
+		}
+	}
+}
@@ -0,0 +1,200 @@
+// Package mpesamock provides a mock of mpesa.Service for the tests of code
+// using the mpesa client.
+//
+// Stub the operations a test expects by setting the matching Func field, every
+// call is recorded and a call to an operation that was not stubbed fails the
+// test:
+//
+//	mock := mpesamock.New(t)
+//	mock.PushAsyncFunc = func(ctx context.Context, request mpesa.PushRequest) (mpesa.PushAsyncResponse, error) {
+//		return mpesa.PushAsyncResponse{ResponseCode: "INS-0"}, nil
+//	}
+//	checkout := NewCheckout(mock)
+package mpesamock
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+
+	mpesa "github.com/ameprizzo/mpesago"
+)
+
+var _ mpesa.Service = (*Service)(nil)
+
+// ErrUnexpectedCall is returned by the operations that were not stubbed.
+var ErrUnexpectedCall = errors.New("mpesamock: unexpected call")
+
+// TB is the part of testing.TB used to report unexpected calls.
+type TB interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// Call is a call recorded by Service. Args holds the arguments after the
+// context, or the request for the callback handlers.
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+// Service is a mock of mpesa.Service. The operations call the Func field of
+// the same name, an operation whose Func field is nil reports an unexpected
+// call to the TB given to New and returns ErrUnexpectedCall, or answers 500
+// for the callback handlers. Set the Func fields before the mock is used.
+type Service struct {
+	QueryTxFunc                   func(ctx context.Context, params mpesa.QueryTxParams) (mpesa.QueryTxResponse, error)
+	SessionIDFunc                 func(ctx context.Context) (mpesa.SessionResponse, error)
+	PushAsyncFunc                 func(ctx context.Context, request mpesa.PushRequest) (mpesa.PushAsyncResponse, error)
+	DisburseFunc                  func(ctx context.Context, request mpesa.DisburseRequest) (mpesa.DisburseResponse, error)
+	B2BPaymentFunc                func(ctx context.Context, request mpesa.Request) (mpesa.B2BResponse, error)
+	CreateDirectDebitFunc         func(ctx context.Context, request mpesa.DirectDebitCreateRequest) (mpesa.DirectDebitCreateResponse, error)
+	DirectDebitPaymentFunc        func(ctx context.Context, request mpesa.DirectDebitPaymentRequest) (mpesa.DirectDebitPaymentResponse, error)
+	CancelDirectDebitFunc         func(ctx context.Context, request mpesa.DirectDebitCancelRequest) (mpesa.DirectDebitCancelResponse, error)
+	QueryDirectDebitFunc          func(ctx context.Context, params mpesa.QueryDirectDebitParams) (mpesa.QueryDirectDebitResponse, error)
+	QueryBeneficiaryNameFunc      func(ctx context.Context, msisdn string) (mpesa.BeneficiaryNameResponse, error)
+	CallbackServeHTTPFunc         http.HandlerFunc
+	DisburseCallbackServeHTTPFunc http.HandlerFunc
+
+	t     TB
+	mu    sync.Mutex
+	calls []Call
+}
+
+// New returns a Service reporting the unexpected calls to t.
+func New(t TB) *Service {
+	return &Service{t: t}
+}
+
+// Calls returns the calls made so far, oldest first.
+func (s *Service) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Call(nil), s.calls...)
+}
+
+// CallsTo returns the calls made so far to method, oldest first.
+func (s *Service) CallsTo(method string) []Call {
+	var calls []Call
+	for _, call := range s.Calls() {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+
+	return calls
+}
+
+// record records a call to method and reports whether it was stubbed.
+func (s *Service) record(method string, stubbed bool, args ...interface{}) bool {
+	s.mu.Lock()
+	s.calls = append(s.calls, Call{Method: method, Args: args})
+	s.mu.Unlock()
+
+	if !stubbed && s.t != nil {
+		s.t.Helper()
+		s.t.Errorf("mpesamock: unexpected call to %s(%v), set %sFunc to stub it", method, args, method)
+	}
+
+	return stubbed
+}
+
+func (s *Service) QueryTx(ctx context.Context, params mpesa.QueryTxParams) (mpesa.QueryTxResponse, error) {
+	if !s.record("QueryTx", s.QueryTxFunc != nil, params) {
+		return mpesa.QueryTxResponse{}, ErrUnexpectedCall
+	}
+
+	return s.QueryTxFunc(ctx, params)
+}
+
+func (s *Service) SessionID(ctx context.Context) (mpesa.SessionResponse, error) {
+	if !s.record("SessionID", s.SessionIDFunc != nil) {
+		return mpesa.SessionResponse{}, ErrUnexpectedCall
+	}
+
+	return s.SessionIDFunc(ctx)
+}
+
+func (s *Service) PushAsync(ctx context.Context, request mpesa.PushRequest) (mpesa.PushAsyncResponse, error) {
+	if !s.record("PushAsync", s.PushAsyncFunc != nil, request) {
+		return mpesa.PushAsyncResponse{}, ErrUnexpectedCall
+	}
+
+	return s.PushAsyncFunc(ctx, request)
+}
+
+func (s *Service) Disburse(ctx context.Context, request mpesa.DisburseRequest) (mpesa.DisburseResponse, error) {
+	if !s.record("Disburse", s.DisburseFunc != nil, request) {
+		return mpesa.DisburseResponse{}, ErrUnexpectedCall
+	}
+
+	return s.DisburseFunc(ctx, request)
+}
+
+func (s *Service) B2BPayment(ctx context.Context, request mpesa.Request) (mpesa.B2BResponse, error) {
+	if !s.record("B2BPayment", s.B2BPaymentFunc != nil, request) {
+		return mpesa.B2BResponse{}, ErrUnexpectedCall
+	}
+
+	return s.B2BPaymentFunc(ctx, request)
+}
+
+func (s *Service) CreateDirectDebit(ctx context.Context, request mpesa.DirectDebitCreateRequest) (mpesa.DirectDebitCreateResponse, error) {
+	if !s.record("CreateDirectDebit", s.CreateDirectDebitFunc != nil, request) {
+		return mpesa.DirectDebitCreateResponse{}, ErrUnexpectedCall
+	}
+
+	return s.CreateDirectDebitFunc(ctx, request)
+}
+
+func (s *Service) DirectDebitPayment(ctx context.Context, request mpesa.DirectDebitPaymentRequest) (mpesa.DirectDebitPaymentResponse, error) {
+	if !s.record("DirectDebitPayment", s.DirectDebitPaymentFunc != nil, request) {
+		return mpesa.DirectDebitPaymentResponse{}, ErrUnexpectedCall
+	}
+
+	return s.DirectDebitPaymentFunc(ctx, request)
+}
+
+func (s *Service) CancelDirectDebit(ctx context.Context, request mpesa.DirectDebitCancelRequest) (mpesa.DirectDebitCancelResponse, error) {
+	if !s.record("CancelDirectDebit", s.CancelDirectDebitFunc != nil, request) {
+		return mpesa.DirectDebitCancelResponse{}, ErrUnexpectedCall
+	}
+
+	return s.CancelDirectDebitFunc(ctx, request)
+}
+
+func (s *Service) QueryDirectDebit(ctx context.Context, params mpesa.QueryDirectDebitParams) (mpesa.QueryDirectDebitResponse, error) {
+	if !s.record("QueryDirectDebit", s.QueryDirectDebitFunc != nil, params) {
+		return mpesa.QueryDirectDebitResponse{}, ErrUnexpectedCall
+	}
+
+	return s.QueryDirectDebitFunc(ctx, params)
+}
+
+func (s *Service) QueryBeneficiaryName(ctx context.Context, msisdn string) (mpesa.BeneficiaryNameResponse, error) {
+	if !s.record("QueryBeneficiaryName", s.QueryBeneficiaryNameFunc != nil, msisdn) {
+		return mpesa.BeneficiaryNameResponse{}, ErrUnexpectedCall
+	}
+
+	return s.QueryBeneficiaryNameFunc(ctx, msisdn)
+}
+
+func (s *Service) CallbackServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.record("CallbackServeHTTP", s.CallbackServeHTTPFunc != nil, r) {
+		http.Error(w, ErrUnexpectedCall.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.CallbackServeHTTPFunc(w, r)
+}
+
+func (s *Service) DisburseCallbackServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.record("DisburseCallbackServeHTTP", s.DisburseCallbackServeHTTPFunc != nil, r) {
+		http.Error(w, ErrUnexpectedCall.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.DisburseCallbackServeHTTPFunc(w, r)
+}
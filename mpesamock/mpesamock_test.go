@@ -0,0 +1,64 @@
+package mpesamock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	mpesa "github.com/ameprizzo/mpesago"
+)
+
+// recordingTB records the errors reported by the mock.
+type recordingTB struct {
+	errors []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestStubbedCalls(t *testing.T) {
+	mock := New(t)
+	mock.PushAsyncFunc = func(ctx context.Context, request mpesa.PushRequest) (mpesa.PushAsyncResponse, error) {
+		return mpesa.PushAsyncResponse{ResponseCode: "INS-0", ThirdPartyConversationID: request.ThirdPartyID}, nil
+	}
+
+	var svc mpesa.Service = mock
+	response, err := svc.PushAsync(context.Background(), mpesa.PushRequest{ThirdPartyID: "tp-1"})
+	if err != nil || response.ThirdPartyConversationID != "tp-1" {
+		t.Fatalf("PushAsync() = %+v, %v", response, err)
+	}
+
+	calls := mock.CallsTo("PushAsync")
+	if len(calls) != 1 || calls[0].Args[0].(mpesa.PushRequest).ThirdPartyID != "tp-1" {
+		t.Errorf("calls = %+v, want the PushAsync call", calls)
+	}
+}
+
+func TestUnexpectedCalls(t *testing.T) {
+	tb := &recordingTB{}
+	mock := New(tb)
+
+	if _, err := mock.Disburse(context.Background(), mpesa.DisburseRequest{}); !errors.Is(err, ErrUnexpectedCall) {
+		t.Errorf("Disburse() error = %v, want ErrUnexpectedCall", err)
+	}
+
+	rec := httptest.NewRecorder()
+	mock.CallbackServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/callbacks", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("CallbackServeHTTP() status = %d, want 500", rec.Code)
+	}
+
+	if len(tb.errors) != 2 || !strings.Contains(tb.errors[0], "unexpected call to Disburse") {
+		t.Errorf("reported errors = %q, want the two unexpected calls", tb.errors)
+	}
+	if got := len(mock.Calls()); got != 2 {
+		t.Errorf("calls = %d, want 2", got)
+	}
+}
@@ -0,0 +1,122 @@
+package mpesatest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mpesa "github.com/ameprizzo/mpesago"
+)
+
+// PushCallback returns the callback the gateway sends with the result code of
+// the push answered with response. An empty code is a success.
+func PushCallback(response mpesa.PushAsyncResponse, code mpesa.ResponseCode) mpesa.PushCallbackRequest {
+	return completePushCallback(mpesa.PushCallbackRequest{
+		OriginalConversationID:   response.ConversationID,
+		ResultCode:               string(code),
+		ThirdPartyConversationID: response.ThirdPartyConversationID,
+	})
+}
+
+// DisburseCallback returns the callback the gateway sends with the result code
+// of the disbursement answered with response. An empty code is a success.
+func DisburseCallback(response mpesa.DisburseResponse, code mpesa.ResponseCode) mpesa.DisburseCallbackRequest {
+	return completeDisburseCallback(mpesa.DisburseCallbackRequest{
+		OriginalConversationID:   response.ConversationID,
+		TransactionID:            response.TransactionID,
+		ResultCode:               string(code),
+		ThirdPartyConversationID: response.ThirdPartyConversationID,
+	})
+}
+
+// SimulateCallback posts cb to handler, typically Client.CallbackServeHTTP, the
+// way the gateway does and returns the acknowledgement. The empty fields of cb
+// are filled in: the ids are generated, the result code is INS-0 and the result
+// description the one of the result code.
+func SimulateCallback(t testing.TB, handler http.Handler, cb mpesa.PushCallbackRequest) *http.Response {
+	t.Helper()
+
+	return post(t, handler, completePushCallback(cb))
+}
+
+// SimulateDisburseCallback posts cb to handler, typically
+// Client.DisburseCallbackServeHTTP, the way the gateway does and returns the
+// acknowledgement. The empty fields of cb are filled in as by SimulateCallback,
+// the transaction status follows the result code and the transaction time is
+// the current time.
+func SimulateDisburseCallback(t testing.TB, handler http.Handler, cb mpesa.DisburseCallbackRequest) *http.Response {
+	t.Helper()
+
+	return post(t, handler, completeDisburseCallback(cb))
+}
+
+func completePushCallback(cb mpesa.PushCallbackRequest) mpesa.PushCallbackRequest {
+	cb.OriginalConversationID, cb.TransactionID, cb.ThirdPartyConversationID, cb.ResultCode, cb.ResultDesc = completeResult(
+		cb.OriginalConversationID, cb.TransactionID, cb.ThirdPartyConversationID, cb.ResultCode, cb.ResultDesc)
+
+	return cb
+}
+
+func completeDisburseCallback(cb mpesa.DisburseCallbackRequest) mpesa.DisburseCallbackRequest {
+	cb.OriginalConversationID, cb.TransactionID, cb.ThirdPartyConversationID, cb.ResultCode, cb.ResultDesc = completeResult(
+		cb.OriginalConversationID, cb.TransactionID, cb.ThirdPartyConversationID, cb.ResultCode, cb.ResultDesc)
+
+	if cb.TransactionStatus == "" {
+		cb.TransactionStatus = transactionStatus(mpesa.ResponseCode(cb.ResultCode))
+	}
+	if cb.TransactionTime == "" {
+		cb.TransactionTime = time.Now().Format(mpesa.GatewayTimeLayout)
+	}
+
+	return cb
+}
+
+// completeResult fills in the fields the push and the disburse callbacks
+// share.
+func completeResult(conversationID, transactionID, thirdPartyID, code, desc string) (string, string, string, string, string) {
+	if conversationID == "" {
+		conversationID = newID()
+	}
+	if transactionID == "" {
+		transactionID = newTransactionID()
+	}
+	if thirdPartyID == "" {
+		thirdPartyID = newID()
+	}
+	if code == "" {
+		code = mpesa.SUCCESS_CODE
+	}
+	if desc == "" {
+		desc = mpesa.ResponseCode(code).Description()
+	}
+
+	return conversationID, transactionID, thirdPartyID, code, desc
+}
+
+// transactionStatus returns the status of a transaction completed with code.
+func transactionStatus(code mpesa.ResponseCode) string {
+	if code.IsSuccess() {
+		return "Completed"
+	}
+
+	return "Failed"
+}
+
+func post(t testing.TB, handler http.Handler, cb interface{}) *http.Response {
+	t.Helper()
+
+	body, err := json.Marshal(cb)
+	if err != nil {
+		t.Fatalf("mpesatest: marshal callback: %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+
+	return rec.Result()
+}
@@ -0,0 +1,76 @@
+package mpesatest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	mpesa "github.com/ameprizzo/mpesago"
+)
+
+func TestSimulateCallback(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	var handled mpesa.PushCallbackRequest
+	client, err := srv.NewClient(mpesa.PushCallbackFunc(func(request mpesa.PushCallbackRequest) (mpesa.PushCallbackResponse, error) {
+		handled = request
+		return mpesa.PushCallbackResponse{
+			OriginalConversationID: request.OriginalConversationID,
+			ResponseCode:           "INS-0",
+		}, nil
+	}))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	response, err := client.PushAsync(context.Background(), mpesa.PushRequest{ThirdPartyID: "tp-1", Amount: mpesa.MustParseAmount("10"), MSISDN: "255700000001"})
+	if err != nil {
+		t.Fatalf("PushAsync() error = %v", err)
+	}
+
+	res := SimulateCallback(t, http.HandlerFunc(client.CallbackServeHTTP), PushCallback(response, "INS-5"))
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", res.StatusCode)
+	}
+
+	var ack mpesa.PushCallbackResponse
+	if err := json.NewDecoder(res.Body).Decode(&ack); err != nil {
+		t.Fatalf("decode ack: %v", err)
+	}
+	if ack.OriginalConversationID != response.ConversationID {
+		t.Errorf("ack = %+v, want conversation id %q", ack, response.ConversationID)
+	}
+	if handled.ResultCode != "INS-5" || handled.ResultDesc == "" || handled.TransactionID == "" ||
+		handled.ThirdPartyConversationID != "tp-1" {
+		t.Errorf("handled = %+v, want a failed callback for tp-1", handled)
+	}
+}
+
+func TestSimulateDisburseCallback(t *testing.T) {
+	var handled mpesa.DisburseCallbackRequest
+	srv := NewServer()
+	defer srv.Close()
+
+	client, err := srv.NewClient(nil, mpesa.WithDisburseCallbackHandler(mpesa.DisburseCallbackFunc(
+		func(ctx context.Context, request mpesa.DisburseCallbackRequest) (mpesa.DisburseCallbackResponse, error) {
+			handled = request
+			return mpesa.DisburseCallbackResponse{ResponseCode: "INS-0"}, nil
+		})))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	res := SimulateDisburseCallback(t, http.HandlerFunc(client.DisburseCallbackServeHTTP), mpesa.DisburseCallbackRequest{
+		ThirdPartyConversationID: "tp-1",
+		Amount:                   mpesa.MustParseAmount("10.00"),
+	})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", res.StatusCode)
+	}
+	if handled.ResultCode != "INS-0" || handled.TransactionStatus != "Completed" || handled.TransactionTime == "" ||
+		handled.OriginalConversationID == "" || handled.Amount != mpesa.MustParseAmount("10.00") {
+		t.Errorf("handled = %+v, want a completed callback with the overridden fields", handled)
+	}
+}
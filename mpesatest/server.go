@@ -0,0 +1,595 @@
+// Package mpesatest provides a fake M-Pesa gateway for integration tests.
+//
+// The Server implements the session, push, disburse and query endpoints of the
+// gateway: it checks the bearer tokens, issues session ids, decodes the
+// payloads sent by the client and answers with the Scenario picked for the
+// request. Point a real Client at it with the Config and the ClientOptions of
+// the Server:
+//
+//	srv := mpesatest.NewServer(mpesatest.WithMSISDNScenario("255700000001", mpesatest.InsufficientBalance))
+//	defer srv.Close()
+//	client, err := srv.NewClient(nil)
+//
+// SimulateCallback and SimulateDisburseCallback test the callback handlers
+// without a Server.
+package mpesatest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	mpesa "github.com/ameprizzo/mpesago"
+)
+
+const (
+	// DefaultAPIKey is the API key accepted by a Server unless WithAPIKey is
+	// used.
+	DefaultAPIKey = "mpesatest-api-key"
+
+	// ServiceProviderCode is the service provider code of the Config of a
+	// Server.
+	ServiceProviderCode = "000000"
+
+	basePath = "/sandbox/ipg/v2/vodacomTZN/"
+)
+
+// Scenario is how the Server answers a request.
+//
+// Code is the output_ResponseCode sent back, INS-0 when empty, and Description
+// its output_ResponseDesc, the description of Code when empty. Status is the
+// HTTP status, by default 201 for a successful push or disbursement, 200 for
+// the other successful requests and 400 for a failure. The answer is sent after
+// Delay, or never when Timeout is set: the request then hangs until the client
+// gives up.
+//
+// CallbackCode is the result code of the callback delivered after a successful
+// push or disbursement, INS-0 when empty.
+type Scenario struct {
+	Code         mpesa.ResponseCode
+	Description  string
+	Status       int
+	Delay        time.Duration
+	Timeout      bool
+	CallbackCode mpesa.ResponseCode
+}
+
+// Common scenarios.
+var (
+	Success             = Scenario{}
+	InsufficientBalance = Scenario{Code: "INS-2006", Status: http.StatusUnprocessableEntity}
+	InvalidMSISDN       = Scenario{Code: "INS-2051"}
+	DuplicateTx         = Scenario{Code: "INS-10", Status: http.StatusConflict}
+	InternalError       = Scenario{Code: "INS-1", Status: http.StatusInternalServerError}
+	Timeout             = Scenario{Timeout: true}
+	CancelledByCustomer = Scenario{CallbackCode: "INS-5"}
+)
+
+// Option configures a Server.
+type Option func(s *Server)
+
+// WithAPIKey sets the API key the session requests must be authenticated with.
+func WithAPIKey(apiKey string) Option {
+	return func(s *Server) {
+		s.apiKey = apiKey
+	}
+}
+
+// WithDefaultScenario sets the Scenario of the push, disburse and query
+// requests that no other scenario applies to, Success by default.
+func WithDefaultScenario(scenario Scenario) Option {
+	return func(s *Server) {
+		s.defaultScenario = scenario
+	}
+}
+
+// WithMSISDNScenario answers the push and disburse requests for msisdn with
+// scenario.
+func WithMSISDNScenario(msisdn string, scenario Scenario) Option {
+	return func(s *Server) {
+		s.msisdnScenarios[msisdn] = scenario
+	}
+}
+
+// WithAmountScenario answers the push and disburse requests for amount, as
+// sent on the wire e.g. "10.00", with scenario. A scenario set for the MSISDN
+// takes precedence.
+func WithAmountScenario(amount string, scenario Scenario) Option {
+	return func(s *Server) {
+		s.amountScenarios[amount] = scenario
+	}
+}
+
+// WithSessionScenario answers the session requests with scenario.
+func WithSessionScenario(scenario Scenario) Option {
+	return func(s *Server) {
+		s.sessionScenario = scenario
+	}
+}
+
+// WithCallbackURL delivers the result of every successful push to url, delay
+// after the push was answered.
+func WithCallbackURL(url string, delay time.Duration) Option {
+	return func(s *Server) {
+		s.callbackURL, s.callbackDelay = url, delay
+	}
+}
+
+// WithDisburseCallbackURL delivers the result of every successful disbursement
+// to url, delay after the disbursement was answered.
+func WithDisburseCallbackURL(url string, delay time.Duration) Option {
+	return func(s *Server) {
+		s.disburseCallbackURL, s.disburseCallbackDelay = url, delay
+	}
+}
+
+// Received is a request received by the Server. Payload holds the decoded
+// body, or the query parameters of a query.
+type Received struct {
+	Operation string
+	Header    http.Header
+	Payload   map[string]string
+}
+
+// Delivery is a callback delivered by the Server. Err is set when the callback
+// could not be sent, Status holds the status it was answered with otherwise.
+type Delivery struct {
+	URL    string
+	Body   []byte
+	Status int
+	Err    error
+}
+
+// transaction is a push or a disbursement accepted by the Server.
+type transaction struct {
+	conversationID string
+	thirdPartyID   string
+	transactionID  string
+	status         string
+}
+
+// Server is a fake M-Pesa gateway.
+type Server struct {
+	*httptest.Server
+
+	key                   *rsa.PrivateKey
+	publicKey             string
+	apiKey                string
+	defaultScenario       Scenario
+	sessionScenario       Scenario
+	msisdnScenarios       map[string]Scenario
+	amountScenarios       map[string]Scenario
+	callbackURL           string
+	callbackDelay         time.Duration
+	disburseCallbackURL   string
+	disburseCallbackDelay time.Duration
+
+	closing   chan struct{}
+	closeOnce sync.Once
+	callbacks sync.WaitGroup
+
+	mu           sync.Mutex
+	sessions     map[string]bool
+	seq          int
+	transactions []transaction
+	received     []Received
+	deliveries   []Delivery
+}
+
+// NewServer starts a Server configured with opts. Close it when done.
+func NewServer(opts ...Option) *Server {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(fmt.Sprintf("mpesatest: generate key: %v", err))
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		panic(fmt.Sprintf("mpesatest: marshal public key: %v", err))
+	}
+
+	s := &Server{
+		key:             key,
+		publicKey:       base64.StdEncoding.EncodeToString(der),
+		apiKey:          DefaultAPIKey,
+		msisdnScenarios: map[string]Scenario{},
+		amountScenarios: map[string]Scenario{},
+		closing:         make(chan struct{}),
+		sessions:        map[string]bool{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	return s
+}
+
+// Close stops the pending callbacks and the hung requests, then shuts the
+// server down.
+func (s *Server) Close() {
+	s.closeOnce.Do(func() { close(s.closing) })
+	s.callbacks.Wait()
+	s.Server.Close()
+}
+
+// BaseURL returns the URL to pass to mpesa.WithBaseURL.
+func (s *Server) BaseURL() string {
+	return s.URL + basePath
+}
+
+// Config returns a Config accepted by the Server.
+func (s *Server) Config() *mpesa.Config {
+	return &mpesa.Config{
+		Name:               "mpesatest",
+		Version:            "1",
+		Market:             mpesa.TanzaniaMarket,
+		Platform:           mpesa.SANDBOX,
+		APIKey:             s.apiKey,
+		PublicKey:          s.publicKey,
+		SessionLifetime:    time.Hour,
+		ServiceProvideCode: ServiceProviderCode,
+	}
+}
+
+// ClientOptions returns the options pointing a Client at the Server.
+func (s *Server) ClientOptions() []mpesa.ClientOption {
+	return []mpesa.ClientOption{mpesa.WithBaseURL(s.BaseURL()), mpesa.WithDebugMode(false)}
+}
+
+// NewClient returns a Client talking to the Server, opts are applied after
+// ClientOptions.
+func (s *Server) NewClient(handler mpesa.PushCallbackHandler, opts ...mpesa.ClientOption) (*mpesa.Client, error) {
+	return mpesa.NewClient(s.Config(), handler, append(s.ClientOptions(), opts...)...)
+}
+
+// InvalidateSessions rejects the sessions issued so far, the next requests
+// made with them are answered with 401.
+func (s *Server) InvalidateSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions = map[string]bool{}
+}
+
+// Received returns the requests received so far, oldest first.
+func (s *Server) Received() []Received {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Received(nil), s.received...)
+}
+
+// Deliveries returns the callbacks delivered so far, oldest first.
+func (s *Server) Deliveries() []Delivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Delivery(nil), s.deliveries...)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	endpoint := strings.TrimPrefix(r.URL.Path, basePath)
+	switch {
+	case endpoint == "getSession/" && r.Method == http.MethodGet:
+		s.serveSession(w, r)
+	case endpoint == "c2bPayment/singleStage/" && r.Method == http.MethodPost:
+		s.serveTransaction(w, r, "push")
+	case endpoint == "b2cPayment/" && r.Method == http.MethodPost:
+		s.serveTransaction(w, r, "disburse")
+	case endpoint == "queryTransactionStatus/" && r.Method == http.MethodGet:
+		s.serveQuery(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) serveSession(w http.ResponseWriter, r *http.Request) {
+	s.record("session", r, nil)
+
+	if token, ok := s.bearer(r); !ok || token != s.apiKey {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"output_error": "Invalid API key"})
+		return
+	}
+
+	scenario := s.sessionScenario
+	if !s.wait(r, scenario) {
+		return
+	}
+
+	response := map[string]string{
+		"output_ResponseCode": string(code(scenario)),
+		"output_ResponseDesc": description(scenario),
+	}
+	if code(scenario).IsSuccess() {
+		s.mu.Lock()
+		s.seq++
+		id := fmt.Sprintf("mpesatest-session-%d", s.seq)
+		s.sessions[id] = true
+		s.mu.Unlock()
+
+		response["output_SessionID"] = id
+	}
+
+	writeJSON(w, status(scenario, http.StatusOK), response)
+}
+
+func (s *Server) serveTransaction(w http.ResponseWriter, r *http.Request, operation string) {
+	var payload map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"output_error": "Bad request"})
+		return
+	}
+	s.record(operation, r, payload)
+
+	if !s.authorized(w, r) {
+		return
+	}
+
+	scenario := s.scenario(payload)
+	if !s.wait(r, scenario) {
+		return
+	}
+
+	thirdPartyID := payload["input_ThirdPartyConversationID"]
+	response := map[string]string{
+		"output_ResponseCode":             string(code(scenario)),
+		"output_ResponseDesc":             description(scenario),
+		"output_ThirdPartyConversationID": thirdPartyID,
+	}
+	if !code(scenario).IsSuccess() {
+		writeJSON(w, status(scenario, http.StatusBadRequest), response)
+		return
+	}
+
+	callbackCode := scenario.CallbackCode
+	if callbackCode == "" {
+		callbackCode = mpesa.SUCCESS_CODE
+	}
+	tx := transaction{
+		conversationID: newID(),
+		thirdPartyID:   thirdPartyID,
+		transactionID:  newTransactionID(),
+		status:         transactionStatus(callbackCode),
+	}
+	s.mu.Lock()
+	s.transactions = append(s.transactions, tx)
+	s.mu.Unlock()
+
+	response["output_ConversationID"] = tx.conversationID
+	response["output_TransactionID"] = tx.transactionID
+	writeJSON(w, status(scenario, http.StatusCreated), response)
+
+	if operation == "push" && s.callbackURL != "" {
+		s.deliver(s.callbackURL, s.callbackDelay, completePushCallback(mpesa.PushCallbackRequest{
+			OriginalConversationID:   tx.conversationID,
+			TransactionID:            tx.transactionID,
+			ResultCode:               string(callbackCode),
+			ThirdPartyConversationID: thirdPartyID,
+		}))
+	}
+	if operation == "disburse" && s.disburseCallbackURL != "" {
+		// the client only sends valid amounts
+		amount, _ := mpesa.ParseAmount(payload["input_Amount"])
+		s.deliver(s.disburseCallbackURL, s.disburseCallbackDelay, completeDisburseCallback(mpesa.DisburseCallbackRequest{
+			OriginalConversationID:   tx.conversationID,
+			TransactionID:            tx.transactionID,
+			ResultCode:               string(callbackCode),
+			ThirdPartyConversationID: thirdPartyID,
+			Amount:                   amount,
+			CustomerMSISDN:           payload["input_CustomerMSISDN"],
+		}))
+	}
+}
+
+func (s *Server) serveQuery(w http.ResponseWriter, r *http.Request) {
+	payload := map[string]string{}
+	for key := range r.URL.Query() {
+		payload[key] = r.URL.Query().Get(key)
+	}
+	s.record("query", r, payload)
+
+	if !s.authorized(w, r) {
+		return
+	}
+
+	scenario := s.defaultScenario
+	if !s.wait(r, scenario) {
+		return
+	}
+	if !code(scenario).IsSuccess() {
+		writeJSON(w, status(scenario, http.StatusBadRequest), map[string]string{
+			"output_ResponseCode": string(code(scenario)),
+			"output_ResponseDesc": description(scenario),
+		})
+		return
+	}
+
+	reference := payload["input_QueryReference"]
+	s.mu.Lock()
+	var found *transaction
+	for i, tx := range s.transactions {
+		if reference != "" && (reference == tx.conversationID || reference == tx.transactionID || reference == tx.thirdPartyID) {
+			found = &s.transactions[i]
+		}
+	}
+	s.mu.Unlock()
+
+	if found == nil {
+		invalid := mpesa.ResponseCode("INS-14")
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"output_ResponseCode": string(invalid),
+			"output_ResponseDesc": invalid.Description(),
+		})
+		return
+	}
+
+	writeJSON(w, status(scenario, http.StatusOK), map[string]string{
+		"output_ResponseCode":              string(mpesa.SUCCESS_CODE),
+		"output_ResponseDesc":              description(scenario),
+		"output_ResponseTransactionStatus": found.status,
+		"output_ConversationID":            found.conversationID,
+		"output_ThirdPartyConversationID":  payload["input_ThirdPartyConversationID"],
+	})
+}
+
+// scenario picks the Scenario of a push or a disbursement.
+func (s *Server) scenario(payload map[string]string) Scenario {
+	if scenario, ok := s.msisdnScenarios[payload["input_CustomerMSISDN"]]; ok {
+		return scenario
+	}
+	if scenario, ok := s.amountScenarios[payload["input_Amount"]]; ok {
+		return scenario
+	}
+
+	return s.defaultScenario
+}
+
+// wait applies the Delay and the Timeout of scenario. It returns false when
+// the request must not be answered.
+func (s *Server) wait(r *http.Request, scenario Scenario) bool {
+	if scenario.Timeout {
+		select {
+		case <-r.Context().Done():
+		case <-s.closing:
+		}
+		return false
+	}
+
+	if scenario.Delay <= 0 {
+		return true
+	}
+
+	select {
+	case <-time.After(scenario.Delay):
+		return true
+	case <-r.Context().Done():
+		return false
+	case <-s.closing:
+		return false
+	}
+}
+
+// authorized checks that the bearer token of r holds a session issued by the
+// Server and answers 401 when it does not.
+func (s *Server) authorized(w http.ResponseWriter, r *http.Request) bool {
+	session, ok := s.bearer(r)
+	if ok {
+		s.mu.Lock()
+		ok = s.sessions[session]
+		s.mu.Unlock()
+	}
+
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"output_error": "Session ID is invalid"})
+	}
+
+	return ok
+}
+
+// bearer decrypts the bearer token of r.
+func (s *Server) bearer(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return "", false
+	}
+
+	encrypted, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(header, "Bearer "))
+	if err != nil {
+		return "", false
+	}
+	plain, err := rsa.DecryptPKCS1v15(rand.Reader, s.key, encrypted)
+	if err != nil {
+		return "", false
+	}
+
+	return string(plain), true
+}
+
+func (s *Server) record(operation string, r *http.Request, payload map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.received = append(s.received, Received{Operation: operation, Header: r.Header.Clone(), Payload: payload})
+}
+
+// deliver posts body to url after delay, in the background.
+func (s *Server) deliver(url string, delay time.Duration, body interface{}) {
+	buf, _ := json.Marshal(body)
+
+	s.callbacks.Add(1)
+	go func() {
+		defer s.callbacks.Done()
+
+		select {
+		case <-time.After(delay):
+		case <-s.closing:
+			return
+		}
+
+		delivery := Delivery{URL: url, Body: buf}
+		res, err := http.Post(url, "application/json", bytes.NewReader(buf)) //nolint:gosec,noctx
+		if err != nil {
+			delivery.Err = err
+		} else {
+			delivery.Status = res.StatusCode
+			_ = res.Body.Close()
+		}
+
+		s.mu.Lock()
+		s.deliveries = append(s.deliveries, delivery)
+		s.mu.Unlock()
+	}()
+}
+
+func code(scenario Scenario) mpesa.ResponseCode {
+	if scenario.Code == "" {
+		return mpesa.SUCCESS_CODE
+	}
+
+	return scenario.Code
+}
+
+func description(scenario Scenario) string {
+	if scenario.Description == "" {
+		return code(scenario).Description()
+	}
+
+	return scenario.Description
+}
+
+// status returns the HTTP status of scenario, or fallback when it has none.
+func status(scenario Scenario, fallback int) int {
+	if scenario.Status != 0 {
+		return scenario.Status
+	}
+
+	return fallback
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+func newTransactionID() string {
+	return strings.ToUpper(newID()[:10])
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
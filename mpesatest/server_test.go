@@ -0,0 +1,120 @@
+package mpesatest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mpesa "github.com/ameprizzo/mpesago"
+)
+
+func TestPushWithCallback(t *testing.T) {
+	callbacks := make(chan mpesa.PushCallbackRequest, 1)
+	handler := mpesa.PushCallbackFunc(func(request mpesa.PushCallbackRequest) (mpesa.PushCallbackResponse, error) {
+		callbacks <- request
+		return mpesa.PushCallbackResponse{ResponseCode: "INS-0"}, nil
+	})
+
+	var client *mpesa.Client
+	callbackSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client.CallbackServeHTTP(w, r)
+	}))
+	defer callbackSrv.Close()
+
+	srv := NewServer(WithCallbackURL(callbackSrv.URL, 10*time.Millisecond))
+	defer srv.Close()
+
+	client, err := srv.NewClient(handler)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	response, err := client.PushAsync(context.Background(), mpesa.PushRequest{
+		ThirdPartyID: "tp-1",
+		Reference:    "ref1",
+		Amount:       mpesa.MustParseAmount("1000"),
+		MSISDN:       "255700000001",
+		Description:  "test",
+	})
+	if err != nil {
+		t.Fatalf("PushAsync() error = %v", err)
+	}
+	if response.ConversationID == "" || response.HTTP.StatusCode != http.StatusCreated {
+		t.Fatalf("PushAsync() = %+v, want a conversation id and a 201", response)
+	}
+
+	select {
+	case callback := <-callbacks:
+		if callback.OriginalConversationID != response.ConversationID || callback.ThirdPartyConversationID != "tp-1" {
+			t.Errorf("callback = %+v, want the pushed transaction", callback)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no callback delivered")
+	}
+
+	query, err := client.QueryTx(context.Background(), mpesa.QueryTxParams{OriginalConversationID: "tp-1", ConversationID: "tp-2"})
+	if err != nil {
+		t.Fatalf("QueryTx() error = %v", err)
+	}
+	if query.ResponseTransactionStatus != "Completed" {
+		t.Errorf("ResponseTransactionStatus = %q, want Completed", query.ResponseTransactionStatus)
+	}
+}
+
+func TestScenarios(t *testing.T) {
+	srv := NewServer(
+		WithMSISDNScenario("255700000002", InsufficientBalance),
+		WithAmountScenario("5.00", Timeout),
+	)
+	defer srv.Close()
+
+	client, err := srv.NewClient(nil)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	_, err = client.Disburse(context.Background(), mpesa.DisburseRequest{ThirdPartyID: "tp-1", Amount: mpesa.MustParseAmount("100"), MSISDN: "255700000002"})
+	if !errors.Is(err, mpesa.ErrInsufficientBalance) {
+		t.Errorf("Disburse() error = %v, want ErrInsufficientBalance", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = client.PushAsync(ctx, mpesa.PushRequest{ThirdPartyID: "tp-2", Amount: mpesa.MustParseAmount("5"), MSISDN: "255700000003"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("PushAsync() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestInvalidatedSession(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	client, err := srv.NewClient(nil)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	request := mpesa.PushRequest{ThirdPartyID: "tp-1", Amount: mpesa.MustParseAmount("10"), MSISDN: "255700000001"}
+	if _, err := client.PushAsync(context.Background(), request); err != nil {
+		t.Fatalf("PushAsync() error = %v", err)
+	}
+
+	srv.InvalidateSessions()
+	if _, err := client.PushAsync(context.Background(), request); err != nil {
+		t.Fatalf("PushAsync() after invalidation error = %v", err)
+	}
+
+	var sessions int
+	for _, received := range srv.Received() {
+		if received.Operation == "session" {
+			sessions++
+		}
+	}
+	if sessions != 2 {
+		t.Errorf("session requests = %d, want 2", sessions)
+	}
+}
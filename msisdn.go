@@ -0,0 +1,73 @@
+package mpesa
+
+import "strings"
+
+// NormalizeMSISDN returns raw in the international format without the leading
+// + accepted by the gateway, e.g. "255712345678" for "0712 345-678",
+// "+255 712 345 678" or "00255712345678" in Tanzania. The spaces, dashes, dots
+// and brackets are dropped, a leading + or 00 is dropped and a leading 0 is
+// replaced with the dialing prefix of market.
+//
+// It returns a *FieldError for the MSISDN field when the number is not a
+// mobile number of market: it must start with the dialing prefix, have the
+// length of the national numbers and a known mobile prefix.
+//
+// PushAsync, Disburse, CreateDirectDebit, DirectDebitPayment,
+// QueryBeneficiaryName and the request builders normalize the MSISDN this way
+// unless turned off with WithMSISDNNormalization.
+func NormalizeMSISDN(market Market, raw string) (string, error) {
+	msisdn := normalizeMSISDN(raw, market)
+
+	var v validation
+	v.checkMSISDN(msisdn, market)
+	v.checkMobile(msisdn, market)
+	if len(v.fields) > 0 {
+		return "", v.fields[0]
+	}
+
+	return msisdn, nil
+}
+
+// normalizeMSISDN returns msisdn in the international format of market,
+// without separators.
+func normalizeMSISDN(msisdn string, market Market) string {
+	msisdn = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(msisdn))
+
+	switch {
+	case strings.HasPrefix(msisdn, "+"):
+		return msisdn[1:]
+	case strings.HasPrefix(msisdn, "00"):
+		return msisdn[2:]
+	case strings.HasPrefix(msisdn, "0"):
+		return market.DialingPrefix() + msisdn[1:]
+	default:
+		return msisdn
+	}
+}
+
+// checkMobile checks msisdn against the numbering plan of market, unless
+// checkMSISDN already rejected it.
+func (v *validation) checkMobile(msisdn string, market Market) {
+	digits, prefixes := market.mobilePlan()
+	if v.has("MSISDN") || digits == 0 {
+		return
+	}
+
+	national := strings.TrimPrefix(msisdn, market.DialingPrefix())
+	if len(national) != digits {
+		v.add("MSISDN", RuleFormat, "must have %d digits after the dialing prefix %s, got %d", digits, market.DialingPrefix(), len(national))
+		return
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(national, prefix) {
+			return
+		}
+	}
+	v.add("MSISDN", RuleFormat, "is not a mobile number of %s, got %q", market.Description(), msisdn)
+}
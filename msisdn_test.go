@@ -0,0 +1,151 @@
+package mpesa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeMSISDN(t *testing.T) {
+	tests := []struct {
+		market  Market
+		raw     string
+		want    string
+		wantErr string
+	}{
+		{market: TanzaniaMarket, raw: "0712345678", want: "255712345678"},
+		{market: TanzaniaMarket, raw: "+255712345678", want: "255712345678"},
+		{market: TanzaniaMarket, raw: "255 712-345-678", want: "255712345678"},
+		{market: TanzaniaMarket, raw: "00255 (712) 345.678", want: "255712345678"},
+		{market: TanzaniaMarket, raw: "0612345678", want: "255612345678"},
+		{market: GhanaMarket, raw: "024 123 4567", want: "233241234567"},
+		{market: DRCMarket, raw: "+243 81 234 5678", want: "243812345678"},
+		{market: LesothoMarket, raw: "5812 3456", wantErr: "dialing prefix"},
+		{market: LesothoMarket, raw: "05812 3456", want: "26658123456"},
+		{market: MozambiqueMarket, raw: "084 123 4567", want: "258841234567"},
+		{market: EgyptMarket, raw: "010 1234 5678", want: "201012345678"},
+		{market: TanzaniaMarket, raw: "071234567", wantErr: "9 digits"},
+		{market: TanzaniaMarket, raw: "0212345678", wantErr: "not a mobile number"},
+		{market: TanzaniaMarket, raw: "+254712345678", wantErr: "dialing prefix"},
+		{market: TanzaniaMarket, raw: "0712 ABC 678", wantErr: "digits"},
+		{market: TanzaniaMarket, raw: " ", wantErr: "required"},
+	}
+
+	for _, tt := range tests {
+		got, err := NormalizeMSISDN(tt.market, tt.raw)
+		if tt.wantErr != "" {
+			var fieldErr *FieldError
+			if !errors.As(err, &fieldErr) || fieldErr.Field != "MSISDN" || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NormalizeMSISDN(%s, %q) error = %v, want an MSISDN error with %q", tt.market, tt.raw, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("NormalizeMSISDN(%s, %q) = %q, %v, want %q", tt.market, tt.raw, got, err, tt.want)
+		}
+	}
+}
+
+func TestMSISDNNormalization(t *testing.T) {
+	g := newTestGateway(t)
+	var sent string
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		sent = payload["input_CustomerMSISDN"]
+		writeJSON(w, http.StatusCreated, PushAsyncResponse{ResponseCode: "INS-0"})
+	}
+
+	request := testRequest("tp-1").PushRequest()
+	request.MSISDN = "+255 754 000 123"
+	if _, err := g.client().PushAsync(context.Background(), request); err != nil {
+		t.Fatalf("PushAsync() error = %v", err)
+	}
+	if sent != "255754000123" {
+		t.Errorf("input_CustomerMSISDN = %q, want the normalized number", sent)
+	}
+
+	// a number outside of the known mobile prefixes is rejected, unless the
+	// caller validates the numbers
+	request.MSISDN = "255254000123"
+	if _, err := g.client().PushAsync(context.Background(), request); err == nil {
+		t.Errorf("PushAsync() error = nil, want the number rejected")
+	}
+	if _, err := g.client(WithMSISDNNormalization(false)).PushAsync(context.Background(), request); err != nil {
+		t.Fatalf("PushAsync() error = %v", err)
+	}
+	if sent != "255254000123" {
+		t.Errorf("input_CustomerMSISDN = %q, want the number as given", sent)
+	}
+
+	request.MSISDN = "0754000123"
+	if _, err := g.client(WithMSISDNNormalization(false)).PushAsync(context.Background(), request); err == nil {
+		t.Errorf("PushAsync() error = nil, want the local number rejected")
+	}
+}
+
+func TestDisburseCallbackNormalizedMSISDN(t *testing.T) {
+	body := strings.Replace(testCallbackBody, `"input_ResultCode"`, `"input_CustomerMSISDN": "+255 744 553 111", "input_ResultCode"`, 1)
+
+	for _, tt := range []struct {
+		opts []ClientOption
+		want string
+	}{
+		{want: "255744553111"},
+		{opts: []ClientOption{WithMSISDNNormalization(false)}, want: "+255 744 553 111"},
+	} {
+		var got string
+		handler := DisburseCallbackFunc(func(ctx context.Context, request DisburseCallbackRequest) (DisburseCallbackResponse, error) {
+			got = request.CustomerMSISDN
+			return DisburseCallbackResponse{ResponseCode: SUCCESS_CODE}, nil
+		})
+		c := newTestGateway(t).client(append(tt.opts, WithDisburseCallbackHandler(handler))...)
+
+		c.DisburseCallbackServeHTTP(httptest.NewRecorder(), newCallbackRequest(context.Background(), body))
+		if got != tt.want {
+			t.Errorf("CustomerMSISDN = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestDirectDebitAndBeneficiaryNormalizedMSISDN(t *testing.T) {
+	g := newTestGateway(t)
+	var sent []string
+	record := func(w http.ResponseWriter, r *http.Request) {
+		msisdn := r.URL.Query().Get("input_CustomerMSISDN")
+		if msisdn == "" {
+			var payload map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&payload)
+			msisdn = payload["input_CustomerMSISDN"]
+		}
+		sent = append(sent, msisdn)
+		writeJSON(w, http.StatusOK, map[string]string{"output_ResponseCode": "INS-0"})
+	}
+	g.handlers["directDebitCreation/"] = record
+	g.handlers["queryBeneficiaryName/"] = record
+
+	ctx := context.Background()
+	c := g.client()
+	if _, err := c.CreateDirectDebit(ctx, DirectDebitCreateRequest{MSISDN: "0754 000 123", Reference: "Test123"}); err != nil {
+		t.Fatalf("CreateDirectDebit() error = %v", err)
+	}
+	if _, err := c.QueryBeneficiaryName(ctx, "+255 754 000 123"); err != nil {
+		t.Fatalf("QueryBeneficiaryName() error = %v", err)
+	}
+	if want := []string{"255754000123", "255754000123"}; !reflect.DeepEqual(sent, want) {
+		t.Errorf("input_CustomerMSISDN = %q, want %q", sent, want)
+	}
+
+	raw := g.client(WithMSISDNNormalization(false))
+	if _, err := raw.QueryBeneficiaryName(ctx, "0754000123"); err == nil {
+		t.Errorf("QueryBeneficiaryName() error = nil, want the local number rejected")
+	}
+	if len(sent) != 2 {
+		t.Errorf("requests sent = %d, want 2", len(sent))
+	}
+}
@@ -1,8 +1,15 @@
 package mpesa
 
 import (
+	"crypto/rsa"
+	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/techcraftlabs/base"
 )
 
 // ClientOption is a setter func to set DisburseClient details like
@@ -15,6 +22,140 @@ func WithCallbackHandler(handler PushCallbackHandler) ClientOption {
 	}
 }
 
+// WithCallbackDedup turns on the deduplication of push callbacks. A callback
+// whose key was processed within ttl is acknowledged with the saved response
+// instead of calling the PushCallbackHandler again, and concurrent deliveries
+// of the same callback result in a single handler call. A ttl below 1 means
+// DefaultCallbackDedupTTL. The ttl applies to the saved acknowledgements, a
+// callback being processed is only reserved for a short lease so that it is
+// processed again if its instance dies before saving. Callbacks are keyed on
+// the transaction id unless WithCallbackDedupKey is used.
+func WithCallbackDedup(store CallbackStore, ttl time.Duration) ClientOption {
+	return func(client *Client) {
+		if store == nil {
+			return
+		}
+		if ttl < 1 {
+			ttl = DefaultCallbackDedupTTL
+		}
+
+		client.callbackDedup = &callbackDedup{store: store, ttl: ttl, key: defaultDedupKey}
+	}
+}
+
+// WithCallbackDedupKey sets the function push callbacks are deduplicated on.
+// It has no effect unless WithCallbackDedup is used as well, in any order.
+func WithCallbackDedupKey(key DedupKeyFunc) ClientOption {
+	return func(client *Client) {
+		if key == nil {
+			return
+		}
+		client.dedupKey = key
+	}
+}
+
+// WithAsyncCallbacks makes CallbackServeHTTP acknowledge a push callback as
+// soon as it is decoded and hand it to a pool of workers running the
+// PushCallbackHandler in the background. At most queueLen callbacks wait for a
+// worker, the ones arriving when the queue is full are answered with 503 so the
+// gateway delivers them again later. Handler errors go to onError, or to the
+// logger when onError is nil. Close and Shutdown wait for the queue to drain.
+func WithAsyncCallbacks(workers, queueLen int, onError CallbackErrorHook) ClientOption {
+	return func(client *Client) {
+		client.callbackPool = newCallbackPool(workers, queueLen, onError)
+	}
+}
+
+// WithCallbackChannel publishes the decoded callbacks on the channels returned
+// by Callbacks and DisburseCallbacks, made with the given buffer size on first
+// use. Publishing never blocks the HTTP handler: a callback that does not fit
+// is dropped and counted in DroppedCallbacks, or answered with 503 so that the
+// gateway delivers it again when rejectWhenFull is set. The room is then
+// checked before the callback is handled, a rejected callback does not reach
+// the handlers.
+func WithCallbackChannel(buffer int, rejectWhenFull bool) ClientOption {
+	return func(client *Client) {
+		if buffer < 0 {
+			buffer = 0
+		}
+		client.callbackFeed = &callbackFeed{buffer: buffer, rejectWhenFull: rejectWhenFull}
+	}
+}
+
+// WithCallbackRegistry lets WaitForCallback wait for the push callback of a
+// PushAsync call. PushAsync records the Request.ThirdPartyID and the callbacks
+// received by CallbackServeHTTP are matched on it. Entries, including callbacks
+// nobody waited for, are dropped after ttl; a ttl below 1 means
+// DefaultCallbackRegistryTTL.
+func WithCallbackRegistry(ttl time.Duration) ClientOption {
+	return func(client *Client) {
+		if ttl < 1 {
+			ttl = DefaultCallbackRegistryTTL
+		}
+		client.callbackRegistry = newCallbackRegistry(ttl, realClock{})
+	}
+}
+
+// WithRetry retries SessionID and QueryTx as described by policy. PushAsync and
+// Disburse are never retried.
+func WithRetry(policy RetryPolicy) ClientOption {
+	return func(client *Client) {
+		client.retryPolicy = &policy
+	}
+}
+
+// WithAutoConversationID turns the generation of the missing ThirdPartyID by
+// PushAsync, Disburse, B2BPayment, CreateDirectDebit and DirectDebitPayment on
+// or off. It is on by default, when off a request without ThirdPartyID is
+// rejected before anything is sent.
+func WithAutoConversationID(enabled bool) ClientOption {
+	return func(client *Client) {
+		client.noAutoConversationID = !enabled
+	}
+}
+
+// WithMSISDNNormalization turns the normalization of the MSISDN of the pushes,
+// the disbursements, the direct debit creations and payments, the beneficiary
+// name queries and the disbursement callbacks by NormalizeMSISDN on or off. It
+// is on by default, when off the MSISDN is sent as given and only checked to be
+// an international number of the market, for callers validating the numbers
+// themselves.
+func WithMSISDNNormalization(enabled bool) ClientOption {
+	return func(client *Client) {
+		client.rawMSISDN = !enabled
+	}
+}
+
+// WithRSAPublicKey sets the public key encrypting the API key and the session
+// ids, parsed by the caller. It takes precedence over Config.PublicKey, which
+// may then be left empty.
+func WithRSAPublicKey(key *rsa.PublicKey) ClientOption {
+	return func(client *Client) {
+		if key == nil {
+			client.optionErrs = append(client.optionErrs, "RSA public key is nil")
+			return
+		}
+		client.publicKey = key
+	}
+}
+
+// WithSessionRejectedHook calls hook whenever the gateway rejects a session
+// before its expiration and the client re-authenticates to retry the request.
+// operation names the rejected request.
+func WithSessionRejectedHook(hook func(operation string)) ClientOption {
+	return func(client *Client) {
+		client.onSessionRejected = hook
+	}
+}
+
+// WithDisburseCallbackHandler sets the handler of the disbursement results
+// received by DisburseCallbackServeHTTP.
+func WithDisburseCallbackHandler(handler DisburseCallbackHandler) ClientOption {
+	return func(client *Client) {
+		client.disburseCallbackFunc = handler
+	}
+}
+
 // WithApiPlatform .....
 func WithApiPlatform(platform Platform) ClientOption {
 	return func(client *Client) {
@@ -30,7 +171,9 @@ func WithMarket(market Market) ClientOption {
 	}
 }
 
-// WithDebugMode set debug mode to true or false
+// WithDebugMode set debug mode to true or false. Debug mode is off by default,
+// when on the requests, responses and callbacks are dumped to the logger set
+// by WithLogger.
 func WithDebugMode(debugMode bool) ClientOption {
 	return func(client *Client) {
 		client.base.DebugMode = debugMode
@@ -51,15 +194,74 @@ func WithLogger(out io.Writer) ClientOption {
 	}
 }
 
-// WithHTTPClient when called unset the present http.Client and replace it
-// with c. In case user tries to pass a nil value referencing the pkg
-// i.e WithHTTPClient(nil), it will be ignored and the pkg wont be replaced
-// Note: the new pkg Transport will be modified. It will be wrapped by another
-// middleware that enables pkg to
-func WithHTTPClient(httpClient *http.Client) ClientOption {
+// WithRedaction turns the masking of secrets in the logger output on or off.
+// Redaction is on by default: the Authorization header, the API key, the session
+// id and all but the last 3 digits of MSISDNs are masked. Only turn it off for
+// local debugging.
+func WithRedaction(enabled bool) ClientOption {
+	return func(client *Client) {
+		client.noRedaction = !enabled
+	}
+}
 
-	// TODO check if its really necessary to set the default Timeout to 1 minute
+// WithCallbackAuth makes CallbackServeHTTP authenticate every callback with
+// verify before the body is decoded. Use CallbackBearerToken or CallbackBasicAuth
+// for credentials configured on the result URL, or any custom verifier. Failed
+// verification is answered with 401 and the PushCallbackHandler is not called.
+func WithCallbackAuth(verify CallbackVerifier) ClientOption {
+	return func(client *Client) {
+		client.callbackAuth = verify
+	}
+}
 
+// WithCallbackBodyLimit sets the largest callback body in bytes that
+// CallbackServeHTTP accepts, DefaultCallbackBodyLimit by default. Larger bodies
+// are rejected with 413. Values below 1 are ignored.
+func WithCallbackBodyLimit(limit int64) ClientOption {
+	return func(client *Client) {
+		if limit < 1 {
+			return
+		}
+		client.callbackBodyLimit = limit
+	}
+}
+
+// WithTrustedSourcesCheck turns the Config.TrustedSources check on the callback
+// endpoint on or off. The check is on by default whenever TrustedSources is set;
+// turn it off for local development where callbacks come from other addresses.
+func WithTrustedSourcesCheck(enabled bool) ClientOption {
+	return func(client *Client) {
+		client.noSourceCheck = !enabled
+	}
+}
+
+// WithBaseURL sets the full URL the endpoints are appended to, e.g.
+// "http://127.0.0.1:8080/sandbox/ipg/v2/vodacomTZN/". It replaces the
+// https://<BasePath>/<platform>/ipg/v2/<market>/ URL built from the Config and
+// is meant for mock gateways in tests. rawURL must be absolute with an http or
+// https scheme, otherwise NewClient returns a *ConfigError.
+func WithBaseURL(rawURL string) ClientOption {
+	return func(client *Client) {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			client.optionErrs = append(client.optionErrs, fmt.Sprintf("base URL is invalid: %v", err))
+			return
+		}
+
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			client.optionErrs = append(client.optionErrs,
+				fmt.Sprintf("base URL %q must be an absolute http or https URL", rawURL))
+			return
+		}
+
+		client.baseURL = u.String()
+	}
+}
+
+// WithHTTPClient sends every gateway request with httpClient. A nil httpClient
+// is ignored. httpClient is copied before use, the client keeps wrapping its
+// Transport to detect throttled responses but never modifies httpClient itself.
+func WithHTTPClient(httpClient *http.Client) ClientOption {
 	return func(client *Client) {
 		if httpClient == nil {
 			return
@@ -68,3 +270,41 @@ func WithHTTPClient(httpClient *http.Client) ClientOption {
 		client.base.Http = httpClient
 	}
 }
+
+// WithBaseClient replaces the base.Client sending the gateway requests with a
+// copy of baseClient, keeping its logger, debug mode and http.Client. The
+// callback replier and receiver are built from it as well. baseClient itself is
+// never modified. WithLogger, WithDebugMode and WithHTTPClient change the copy
+// when they come after WithBaseClient, and are overridden by it otherwise. A nil
+// baseClient is rejected by NewClient.
+func WithBaseClient(baseClient *base.Client) ClientOption {
+	return func(client *Client) {
+		if baseClient == nil {
+			client.optionErrs = append(client.optionErrs, "base client is nil")
+			return
+		}
+
+		b := *baseClient
+		if b.Http == nil {
+			b.Http = &http.Client{}
+		}
+		if b.Logger == nil {
+			b.Logger = os.Stderr
+		}
+		client.base = &b
+	}
+}
+
+// WithRoundTripper sends every gateway request through transport, in place of
+// the Transport of the http.Client set with WithHTTPClient or of the default
+// one. It can be combined with WithHTTPClient in any order.
+func WithRoundTripper(transport http.RoundTripper) ClientOption {
+	return func(client *Client) {
+		if transport == nil {
+			client.optionErrs = append(client.optionErrs, "round tripper is nil")
+			return
+		}
+
+		client.roundTripper = transport
+	}
+}
@@ -0,0 +1,22 @@
+module github.com/ameprizzo/mpesago/otelmpesa
+
+go 1.21
+
+require (
+	github.com/ameprizzo/mpesago v0.0.0
+	go.opentelemetry.io/otel v1.24.0
+	go.opentelemetry.io/otel/trace v1.24.0
+)
+
+require (
+	github.com/go-logr/logr v1.4.1 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/techcraftlabs/base v0.0.4 // indirect
+	go.opentelemetry.io/otel/metric v1.24.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+// Until a tagged version of mpesago is published and required above, the
+// module is built against the checkout it lives in. The release commit drops
+// the replace.
+replace github.com/ameprizzo/mpesago => ../
@@ -0,0 +1,101 @@
+// Package otelmpesa traces the mpesa client with OpenTelemetry.
+//
+// It is a separate module so that the OpenTelemetry dependencies are only
+// pulled in by the users of tracing:
+//
+//	client, err := mpesa.NewClient(conf, handler, otelmpesa.WithTracing(provider))
+package otelmpesa
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ameprizzo/mpesago"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/ameprizzo/mpesago/otelmpesa"
+
+var _ mpesa.Tracer = (*Tracer)(nil)
+
+type (
+	// Tracer implements mpesa.Tracer with an OpenTelemetry tracer.
+	Tracer struct {
+		tracer     trace.Tracer
+		propagator propagation.TextMapPropagator
+	}
+
+	// Option configures a Tracer.
+	Option func(*Tracer)
+
+	span struct {
+		span trace.Span
+	}
+)
+
+// WithPropagator propagates the trace context with propagator instead of the
+// global propagator returned by otel.GetTextMapPropagator.
+func WithPropagator(propagator propagation.TextMapPropagator) Option {
+	return func(t *Tracer) {
+		t.propagator = propagator
+	}
+}
+
+// NewTracer returns a Tracer creating its spans with provider, or with the
+// global provider when provider is nil.
+func NewTracer(provider trace.TracerProvider, opts ...Option) *Tracer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+
+	t := &Tracer{tracer: provider.Tracer(instrumentationName)}
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
+}
+
+// WithTracing is mpesa.WithTracer with a Tracer built by NewTracer.
+func WithTracing(provider trace.TracerProvider, opts ...Option) mpesa.ClientOption {
+	return mpesa.WithTracer(NewTracer(provider, opts...))
+}
+
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, mpesa.Span) {
+	ctx, s := t.tracer.Start(ctx, name)
+
+	return ctx, span{s}
+}
+
+func (t *Tracer) Inject(ctx context.Context, header http.Header) {
+	t.textMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+func (t *Tracer) Extract(ctx context.Context, header http.Header) context.Context {
+	return t.textMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+func (t *Tracer) textMapPropagator() propagation.TextMapPropagator {
+	if t.propagator != nil {
+		return t.propagator
+	}
+
+	return otel.GetTextMapPropagator()
+}
+
+func (s span) SetAttribute(key, value string) {
+	s.span.SetAttributes(attribute.String(key, value))
+}
+
+func (s span) End(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+
+	s.span.End()
+}
@@ -0,0 +1,73 @@
+package otelmpesa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+type recordingSpan struct {
+	noop.Span
+	attrs  []attribute.KeyValue
+	errs   []error
+	status codes.Code
+	ended  bool
+}
+
+func (s *recordingSpan) SetAttributes(kv ...attribute.KeyValue) { s.attrs = append(s.attrs, kv...) }
+
+func (s *recordingSpan) RecordError(err error, _ ...trace.EventOption) { s.errs = append(s.errs, err) }
+
+func (s *recordingSpan) SetStatus(code codes.Code, _ string) { s.status = code }
+
+func (s *recordingSpan) End(...trace.SpanEndOption) { s.ended = true }
+
+func TestSpan(t *testing.T) {
+	recorded := &recordingSpan{}
+	s := span{recorded}
+
+	s.SetAttribute("mpesa.response_code", "INS-6")
+	s.End(errors.New("transaction failed"))
+
+	if len(recorded.attrs) != 1 || recorded.attrs[0] != attribute.String("mpesa.response_code", "INS-6") {
+		t.Errorf("attributes = %v", recorded.attrs)
+	}
+	if !recorded.ended || len(recorded.errs) != 1 || recorded.status != codes.Error {
+		t.Errorf("span ended %v with errors %v and status %v, want an error", recorded.ended, recorded.errs, recorded.status)
+	}
+
+	ok := &recordingSpan{}
+	span{ok}.End(nil)
+	if !ok.ended || len(ok.errs) != 0 || ok.status != codes.Unset {
+		t.Errorf("span ended %v with errors %v and status %v, want no error", ok.ended, ok.errs, ok.status)
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	tracer := NewTracer(noop.NewTracerProvider(), WithPropagator(propagation.TraceContext{}))
+
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3},
+		SpanID:     trace.SpanID{4, 5, 6},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx, _ := tracer.Start(trace.ContextWithSpanContext(context.Background(), parent), "mpesa ussd push")
+
+	header := http.Header{}
+	tracer.Inject(ctx, header)
+	if header.Get("traceparent") == "" {
+		t.Fatal("Inject() did not set traceparent")
+	}
+
+	extracted := trace.SpanContextFromContext(tracer.Extract(context.Background(), header))
+	if extracted.TraceID() != parent.TraceID() || !extracted.IsRemote() {
+		t.Errorf("Extract() span context = %+v, want trace %s", extracted, parent.TraceID())
+	}
+}
@@ -0,0 +1,68 @@
+package mpesa
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Operation names the operations taking a Request, for BuildPayload.
+type Operation int
+
+const (
+	PushOperation Operation = iota + 1
+	DisburseOperation
+	B2BOperation
+)
+
+func (o Operation) String() string {
+	switch o {
+	case PushOperation:
+		return pushPay.Name()
+	case DisburseOperation:
+		return disburse.Name()
+	case B2BOperation:
+		return b2bPay.Name()
+	default:
+		return fmt.Sprintf("Operation(%d)", int(o))
+	}
+}
+
+// BuildPayload returns the payload op sends to the gateway for request, keyed
+// by the input_ field names. It runs the adaptation used by PushAsync,
+// Disburse and B2BPayment, generating the missing ThirdPartyID, and fails the
+// same way they do on an invalid request, nothing is sent. The request of a
+// push or a disbursement is converted with Request.PushRequest or
+// Request.DisburseRequest first.
+func (c *Client) BuildPayload(op Operation, request Request) (map[string]interface{}, error) {
+	id, err := c.conversationID(request.ThirdPartyID)
+	if err != nil {
+		return nil, err
+	}
+	request.ThirdPartyID = id
+
+	var payload interface{}
+	switch op {
+	case PushOperation:
+		payload, err = c.requestAdapter.adaptPush(request.PushRequest())
+	case DisburseOperation:
+		payload, err = c.requestAdapter.adaptDisburse(request.DisburseRequest())
+	case B2BOperation:
+		payload, err = c.requestAdapter.adaptB2B(request)
+	default:
+		return nil, fmt.Errorf("unknown operation %s: accepted operations are push, disburse and b2b", op)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	buf, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal %s payload: %w", op, err)
+	}
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(buf, &fields); err != nil {
+		return nil, fmt.Errorf("could not marshal %s payload: %w", op, err)
+	}
+
+	return fields, nil
+}
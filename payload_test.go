@@ -0,0 +1,103 @@
+package mpesa
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestBuildPayloadGolden(t *testing.T) {
+	g := newTestGateway(t)
+	request := Request{
+		ThirdPartyID:      "tp-1",
+		Reference:         "ref1",
+		Amount:            MustParseAmount("1500.75"),
+		Description:       "goods",
+		ReceiverPartyCode: "000001",
+	}
+
+	for _, market := range []Market{GhanaMarket, TanzaniaMarket, DRCMarket, LesothoMarket, MozambiqueMarket, EgyptMarket} {
+		request.MSISDN = testMSISDN(market)
+		conf := g.config()
+		conf.Market = market
+		conf.Endpoints = nil
+		c, err := NewClient(conf, nil, WithDebugMode(false))
+		if err != nil {
+			t.Fatalf("NewClient(%s) error = %v", market, err)
+		}
+
+		for _, op := range []Operation{PushOperation, DisburseOperation, B2BOperation} {
+			name := strings.ReplaceAll(strings.ToLower(market.Country()+"-"+op.String()), " ", "-")
+			t.Run(name, func(t *testing.T) {
+				payload, err := c.BuildPayload(op, request)
+				if err != nil {
+					t.Fatalf("BuildPayload() error = %v", err)
+				}
+				got, err := json.MarshalIndent(payload, "", "  ")
+				if err != nil {
+					t.Fatalf("marshal payload: %v", err)
+				}
+				got = append(got, '\n')
+
+				golden := filepath.Join("testdata", "payloads", name+".json")
+				if *update {
+					if err := os.WriteFile(golden, got, 0o644); err != nil {
+						t.Fatalf("update golden file: %v", err)
+					}
+				}
+				want, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatalf("read golden file: %v", err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("payload =\n%s\nwant\n%s", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestBuildPayloadMatchesSentPayload(t *testing.T) {
+	g := newTestGateway(t)
+	var sent map[string]interface{}
+	g.handlers["b2cPayment/"] = func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &sent)
+		writeJSON(w, http.StatusCreated, DisburseResponse{ResponseCode: "INS-0"})
+	}
+
+	c := g.client()
+	request := Request{ThirdPartyID: "tp-1", Reference: "ref1", Amount: MustParseAmount("10"), MSISDN: "255754000123", Description: "salary"}
+	if _, err := c.Disburse(context.Background(), request.DisburseRequest()); err != nil {
+		t.Fatalf("Disburse() error = %v", err)
+	}
+
+	payload, err := c.BuildPayload(DisburseOperation, request)
+	if err != nil {
+		t.Fatalf("BuildPayload() error = %v", err)
+	}
+	if !reflect.DeepEqual(payload, sent) {
+		t.Errorf("BuildPayload() = %v, want the sent payload %v", payload, sent)
+	}
+}
+
+func TestBuildPayloadErrors(t *testing.T) {
+	c := newTestGateway(t).client()
+
+	if _, err := c.BuildPayload(Operation(0), Request{}); err == nil {
+		t.Error("BuildPayload(Operation(0)) error = nil, want an error")
+	}
+	if _, err := c.BuildPayload(B2BOperation, Request{ReceiverPartyCode: "abc"}); err == nil {
+		t.Error("BuildPayload() with a non numeric receiver party code error = nil, want an error")
+	}
+}
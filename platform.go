@@ -1,6 +1,16 @@
 package mpesa
 
-import "strings"
+import (
+	"encoding"
+	"fmt"
+	"strings"
+)
+
+var (
+	_ fmt.Stringer             = (*Platform)(nil)
+	_ encoding.TextMarshaler   = (*Platform)(nil)
+	_ encoding.TextUnmarshaler = (*Platform)(nil)
+)
 
 const (
 	SANDBOX Platform = iota
@@ -9,16 +19,29 @@ const (
 
 type Platform int
 
+// PlatformFmt returns the Platform named by platformString or Platform(-1)
+// when it is not known. See ParsePlatform for the accepted names.
 func PlatformFmt(platformString string) Platform {
-	if strings.ToLower(platformString) == "openapi" {
-		return OPENAPI
+	p, err := ParsePlatform(platformString)
+	if err != nil {
+		return Platform(-1)
 	}
 
-	if strings.ToLower(platformString) == "sandbox" {
-		return SANDBOX
-	}
+	return p
+}
 
-	return Platform(-1)
+// ParsePlatform returns the Platform named by s. The match is case-insensitive:
+// "sandbox", "test" and "testing" give SANDBOX while "openapi", "production",
+// "prod" and "live" give OPENAPI.
+func ParsePlatform(s string) (Platform, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "sandbox", "test", "testing":
+		return SANDBOX, nil
+	case "openapi", "production", "prod", "live":
+		return OPENAPI, nil
+	default:
+		return Platform(-1), fmt.Errorf("mpesa: unknown platform %q", s)
+	}
 }
 
 func (p Platform) String() string {
@@ -28,3 +51,24 @@ func (p Platform) String() string {
 
 	return "sandbox"
 }
+
+// MarshalText encodes the platform as "sandbox" or "openapi".
+func (p Platform) MarshalText() ([]byte, error) {
+	if p != SANDBOX && p != OPENAPI {
+		return nil, fmt.Errorf("mpesa: unknown platform %d", int(p))
+	}
+
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText decodes any of the names accepted by ParsePlatform.
+func (p *Platform) UnmarshalText(text []byte) error {
+	parsed, err := ParsePlatform(string(text))
+	if err != nil {
+		return err
+	}
+
+	*p = parsed
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package mpesa
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParsePlatform(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Platform
+		wantErr bool
+	}{
+		{in: "sandbox", want: SANDBOX},
+		{in: "SANDBOX", want: SANDBOX},
+		{in: "test", want: SANDBOX},
+		{in: "openapi", want: OPENAPI},
+		{in: "Production", want: OPENAPI},
+		{in: "live", want: OPENAPI},
+		{in: "staging", wantErr: true},
+		{in: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParsePlatform(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePlatform(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParsePlatform(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlatformText(t *testing.T) {
+	b, err := json.Marshal(struct{ P Platform }{OPENAPI})
+	if err != nil || string(b) != `{"P":"openapi"}` {
+		t.Fatalf("json.Marshal() = %s, %v", b, err)
+	}
+
+	var got struct{ P Platform }
+	if err := json.Unmarshal([]byte(`{"P":"sandbox"}`), &got); err != nil || got.P != SANDBOX {
+		t.Errorf("json.Unmarshal() = %v, %v, want sandbox", got.P, err)
+	}
+
+	if _, err := Platform(99).MarshalText(); err == nil {
+		t.Error("Platform(99).MarshalText() error = nil, want an error")
+	}
+}
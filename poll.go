@@ -0,0 +1,124 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// ErrPollAttemptsExceeded is returned by PollTx when PollOptions.MaxAttempts
+// queries did not reach a terminal status.
+var ErrPollAttemptsExceeded = errors.New("mpesa: transaction did not reach a terminal status")
+
+// txNotFoundCode is the code the gateway answers with while a fresh
+// transaction is not yet visible to QueryTx.
+const txNotFoundCode = "INS-18"
+
+// PollOptions configures PollTx. The zero value polls every 2 seconds at first,
+// backs off up to 30 seconds and never gives up before the context is done.
+type PollOptions struct {
+	// InitialInterval is the wait before the second query, 2 seconds by default.
+	InitialInterval time.Duration
+
+	// MaxInterval caps the wait between queries, 30 seconds by default.
+	MaxInterval time.Duration
+
+	// MaxAttempts is the number of queries after which PollTx gives up with
+	// ErrPollAttemptsExceeded, 0 means no limit.
+	MaxAttempts int
+
+	// NotFoundAttempts is the number of first queries for which a "transaction
+	// not found" answer is retried, 3 by default. The gateway is eventually
+	// consistent and may not know a transaction right after it was initiated.
+	NotFoundAttempts int
+
+	// Progress, when set, receives every non-terminal response.
+	Progress func(attempt int, response QueryTxResponse)
+}
+
+func (o PollOptions) withDefaults() PollOptions {
+	if o.InitialInterval <= 0 {
+		o.InitialInterval = 2 * time.Second
+	}
+	if o.MaxInterval <= 0 {
+		o.MaxInterval = 30 * time.Second
+	}
+	if o.MaxInterval < o.InitialInterval {
+		o.MaxInterval = o.InitialInterval
+	}
+	if o.NotFoundAttempts <= 0 {
+		o.NotFoundAttempts = 3
+	}
+
+	return o
+}
+
+// PollTx queries the transaction with QueryTx until its status is terminal, see
+// TransactionStatus.IsTerminal, waiting longer between queries with
+// exponential backoff and jitter. Failures with a retryable response code are
+// queried again, as are "transaction not found" answers during the first
+// PollOptions.NotFoundAttempts queries. Other errors are returned as they are.
+// PollTx stops when ctx is done.
+func (c *Client) PollTx(ctx context.Context, params QueryTxParams, opts PollOptions) (QueryTxResponse, error) {
+	opts = opts.withDefaults()
+	interval := opts.InitialInterval
+
+	var response QueryTxResponse
+	for attempt := 1; ; attempt++ {
+		var err error
+		response, err = c.QueryTx(ctx, params)
+		switch {
+		case err == nil && response.Status().IsTerminal():
+			return response, nil
+
+		case err == nil:
+			if opts.Progress != nil {
+				opts.Progress(attempt, response)
+			}
+
+		case !c.pollRetryable(err, attempt, opts):
+			return response, err
+		}
+
+		if opts.MaxAttempts > 0 && attempt >= opts.MaxAttempts {
+			return response, fmt.Errorf("%w after %d queries", ErrPollAttemptsExceeded, attempt)
+		}
+
+		select {
+		case <-ctx.Done():
+			return response, ctx.Err()
+		case <-c.clock.After(pollJitter(interval)):
+		}
+
+		interval *= 2
+		if interval > opts.MaxInterval {
+			interval = opts.MaxInterval
+		}
+	}
+}
+
+// pollRetryable reports whether PollTx queries again after err.
+func (c *Client) pollRetryable(err error, attempt int, opts PollOptions) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	if apiErr.Code == txNotFoundCode {
+		return attempt <= opts.NotFoundAttempts
+	}
+
+	return ResponseCode(apiErr.Code).IsRetryable()
+}
+
+// pollJitter returns a random wait between half and all of d.
+func pollJitter(d time.Duration) time.Duration {
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+
+	return half + time.Duration(rand.Int63n(int64(half)+1)) //nolint:gosec
+}
@@ -0,0 +1,147 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// queryGateway answers the transaction queries with responses in turn, the last
+// one is repeated.
+func queryGateway(t *testing.T, responses ...QueryTxResponse) (*testGateway, *int32) {
+	g := newTestGateway(t)
+	var queries int32
+	g.handlers["queryTransactionStatus/"] = func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&queries, 1))
+		if n > len(responses) {
+			n = len(responses)
+		}
+		status := http.StatusOK
+		if !responses[n-1].Code().IsSuccess() {
+			status = http.StatusBadRequest
+		}
+		writeJSON(w, status, responses[n-1])
+	}
+
+	return g, &queries
+}
+
+// advance fires the poll timers until done is closed.
+func advance(clk *fakeClock, done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case <-time.After(time.Millisecond):
+			clk.Advance(time.Minute)
+		}
+	}
+}
+
+func TestPollTx(t *testing.T) {
+	g, queries := queryGateway(t,
+		QueryTxResponse{ResponseCode: "INS-18", ResponseDesc: "Invalid TransactionID Used"},
+		QueryTxResponse{ResponseCode: "INS-0", ResponseTransactionStatus: "Pending"},
+		QueryTxResponse{ResponseCode: "INS-9", ResponseDesc: "Request timeout"},
+		QueryTxResponse{ResponseCode: "INS-0", ResponseTransactionStatus: "Completed"},
+	)
+	c := g.client()
+	clk := newFakeClock()
+	c.clock = clk
+
+	var progress []string
+	opts := PollOptions{
+		InitialInterval: time.Second,
+		MaxInterval:     4 * time.Second,
+		Progress: func(attempt int, response QueryTxResponse) {
+			progress = append(progress, response.ResponseTransactionStatus)
+		},
+	}
+
+	done := make(chan struct{})
+	go advance(clk, done)
+	response, err := c.PollTx(context.Background(), QueryTxParams{Reference: "ref"}, opts)
+	close(done)
+
+	if err != nil || response.ResponseTransactionStatus != "Completed" {
+		t.Fatalf("PollTx() = %+v, %v", response, err)
+	}
+	if got := atomic.LoadInt32(queries); got != 4 {
+		t.Errorf("queries = %d, want 4", got)
+	}
+	if len(progress) != 1 || progress[0] != "Pending" {
+		t.Errorf("progress = %v, want [Pending]", progress)
+	}
+}
+
+func TestPollTxStops(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []QueryTxResponse
+		opts      PollOptions
+		want      error
+		wantCode  string
+		queries   int32
+	}{
+		{
+			name:      "not found for too long",
+			responses: []QueryTxResponse{{ResponseCode: "INS-18"}},
+			opts:      PollOptions{NotFoundAttempts: 2},
+			wantCode:  "INS-18",
+			queries:   3,
+		},
+		{
+			name:      "fatal code",
+			responses: []QueryTxResponse{{ResponseCode: "INS-0", ResponseTransactionStatus: "Pending"}, {ResponseCode: "INS-13"}},
+			wantCode:  "INS-13",
+			queries:   2,
+		},
+		{
+			name:      "max attempts",
+			responses: []QueryTxResponse{{ResponseCode: "INS-0", ResponseTransactionStatus: "Pending"}},
+			opts:      PollOptions{MaxAttempts: 3},
+			want:      ErrPollAttemptsExceeded,
+			queries:   3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, queries := queryGateway(t, tt.responses...)
+			c := g.client()
+			clk := newFakeClock()
+			c.clock = clk
+
+			done := make(chan struct{})
+			go advance(clk, done)
+			_, err := c.PollTx(context.Background(), QueryTxParams{Reference: "ref"}, tt.opts)
+			close(done)
+
+			var apiErr *APIError
+			switch {
+			case tt.want != nil && !errors.Is(err, tt.want):
+				t.Errorf("PollTx() error = %v, want %v", err, tt.want)
+			case tt.wantCode != "" && (!errors.As(err, &apiErr) || apiErr.Code != tt.wantCode):
+				t.Errorf("PollTx() error = %v, want code %s", err, tt.wantCode)
+			}
+			if got := atomic.LoadInt32(queries); got != tt.queries {
+				t.Errorf("queries = %d, want %d", got, tt.queries)
+			}
+		})
+	}
+}
+
+func TestPollTxContext(t *testing.T) {
+	g, _ := queryGateway(t, QueryTxResponse{ResponseCode: "INS-0", ResponseTransactionStatus: "Pending"})
+	c := g.client()
+	c.clock = newFakeClock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.PollTx(ctx, QueryTxParams{Reference: "ref"}, PollOptions{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("PollTx() error = %v, want context.DeadlineExceeded", err)
+	}
+}
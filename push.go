@@ -5,9 +5,25 @@ import (
 	"net/http"
 )
 
-var _ PushCallbackHandler = (*PushCallbackFunc)(nil)
+var (
+	_ PushCallbackHandler        = (*PushCallbackFunc)(nil)
+	_ PushCallbackContextHandler = (*PushCallbackContextFunc)(nil)
+)
 
+// PushRequest asks the customer owning MSISDN to pay Amount with a USSD push.
+// Description lists the purchased items. ThirdPartyID, echoed back in the
+// response and in the callback, and Reference correlate the result with the
+// request. Currency is Market.Currency when empty, it must be one of
+// Market.Currencies otherwise. ServiceProviderCode, the short code credited
+// with the payment, overrides Config.ServiceProvideCode when set.
 type PushRequest struct {
+	ThirdPartyID        string `json:"id,omitempty"`
+	Reference           string `json:"reference,omitempty"`
+	Amount              Amount `json:"amount"`
+	MSISDN              string `json:"msisdn,omitempty"`
+	Description         string `json:"description,omitempty"`
+	Currency            string `json:"currency,omitempty"`
+	ServiceProviderCode string `json:"service_provider_code,omitempty"`
 }
 
 type PushResponse struct {
@@ -25,8 +41,53 @@ type PushCallbackHandler interface {
 	HandleCallback(request PushCallbackRequest) (PushCallbackResponse, error)
 }
 
+// PushCallbackFunc adapts a function to PushCallbackHandler, like
+// http.HandlerFunc does for http.Handler. NewClient and WithCallbackHandler
+// reject a nil PushCallbackFunc with a *ConfigError instead of panicking when
+// the first callback arrives.
 type PushCallbackFunc func(request PushCallbackRequest) (PushCallbackResponse, error)
 
+// PushCallbackHandlerFunc is another name for PushCallbackFunc.
+type PushCallbackHandlerFunc = PushCallbackFunc
+
 func (p PushCallbackFunc) HandleCallback(request PushCallbackRequest) (PushCallbackResponse, error) {
 	return p(request)
 }
+
+// PushCallbackContextHandler is a PushCallbackHandler that also receives the
+// context of the callback request. CallbackServeHTTP prefers HandleCallbackContext
+// when the handler implements it. The context is cancelled when the gateway
+// disconnects, the server shuts down or the callback timeout expires.
+type PushCallbackContextHandler interface {
+	PushCallbackHandler
+	HandleCallbackContext(ctx context.Context, request PushCallbackRequest) (PushCallbackResponse, error)
+}
+
+// PushCallbackContextFunc adapts a function to PushCallbackContextHandler.
+// HandleCallback calls it with context.Background. A nil PushCallbackContextFunc
+// is rejected at construction like a nil PushCallbackFunc.
+type PushCallbackContextFunc func(ctx context.Context, request PushCallbackRequest) (PushCallbackResponse, error)
+
+// PushCallbackContextHandlerFunc is another name for PushCallbackContextFunc.
+type PushCallbackContextHandlerFunc = PushCallbackContextFunc
+
+// nilHandler reports whether handler is one of the func adapters holding a nil
+// function, which would panic when called.
+func nilHandler(handler PushCallbackHandler) bool {
+	switch fn := handler.(type) {
+	case PushCallbackFunc:
+		return fn == nil
+	case PushCallbackContextFunc:
+		return fn == nil
+	default:
+		return false
+	}
+}
+
+func (p PushCallbackContextFunc) HandleCallback(request PushCallbackRequest) (PushCallbackResponse, error) {
+	return p(context.Background(), request)
+}
+
+func (p PushCallbackContextFunc) HandleCallbackContext(ctx context.Context, request PushCallbackRequest) (PushCallbackResponse, error) {
+	return p(ctx, request)
+}
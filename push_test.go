@@ -0,0 +1,93 @@
+package mpesa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPushCallbackHandlerFunc(t *testing.T) {
+	g := newTestGateway(t)
+
+	called := false
+	c, err := NewClient(g.config(), PushCallbackHandlerFunc(func(request PushCallbackRequest) (PushCallbackResponse, error) {
+		called = true
+		return PushCallbackResponse{ResponseCode: SUCCESS_CODE}, nil
+	}), WithDebugMode(false))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	c.CallbackServeHTTP(rec, newCallbackRequest(context.Background(), testCallbackBody))
+	if !called || rec.Code != http.StatusOK {
+		t.Errorf("called = %v, status = %d", called, rec.Code)
+	}
+}
+
+func TestNilPushCallbackHandlerFunc(t *testing.T) {
+	g := newTestGateway(t)
+
+	tests := []struct {
+		name    string
+		handler PushCallbackHandler
+		opts    []ClientOption
+	}{
+		{name: "func", handler: PushCallbackHandlerFunc(nil)},
+		{name: "context func", handler: PushCallbackContextHandlerFunc(nil)},
+		{name: "option", opts: []ClientOption{WithCallbackHandler(PushCallbackFunc(nil))}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewClient(g.config(), tt.handler, tt.opts...)
+
+			var confErr *ConfigError
+			if !errors.As(err, &confErr) {
+				t.Errorf("NewClient() error = %v, want *ConfigError", err)
+			}
+		})
+	}
+}
+
+func TestPushServiceProviderCode(t *testing.T) {
+	g := newTestGateway(t)
+	var codes []string
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		codes = append(codes, payload["input_ServiceProviderCode"])
+		writeJSON(w, http.StatusCreated, PushAsyncResponse{ResponseCode: "INS-0"})
+	}
+
+	c := g.client()
+	for _, code := range []string{"", "1001", "2002"} {
+		request := testRequest("tp-1").PushRequest()
+		request.ServiceProviderCode = code
+		if _, err := c.PushAsync(context.Background(), request); err != nil {
+			t.Fatalf("PushAsync(%q) error = %v", code, err)
+		}
+	}
+	if want := []string{"000000", "1001", "2002"}; !reflect.DeepEqual(codes, want) {
+		t.Errorf("input_ServiceProviderCode = %v, want %v", codes, want)
+	}
+	if n := atomic.LoadInt32(&g.sessions); n != 1 {
+		t.Errorf("sessions = %d, want the session shared by the short codes", n)
+	}
+
+	request := testRequest("tp-1").PushRequest()
+	request.ServiceProviderCode = "ORG001"
+	_, err := c.PushAsync(context.Background(), request)
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Field != "ServiceProviderCode" {
+		t.Errorf("PushAsync() error = %v, want the short code rejected", err)
+	}
+	if len(codes) != 3 {
+		t.Errorf("pushes sent = %d, want 3", len(codes))
+	}
+}
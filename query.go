@@ -3,24 +3,57 @@ package mpesa
 import (
 	"context"
 	"net/http"
+	"strings"
 )
 
 type (
-	// QueryTxParams is the parameters for querying a transaction
+	// QueryTxParams is the parameters for querying a transaction. The
+	// transaction is looked up by exactly one of TransactionID, the id
+	// generated by the mobile money platform, and OriginalConversationID, the
+	// ConversationID returned by the gateway or the ThirdPartyConversationID
+	// of the original request, which is the only handle on a push that timed
+	// out. ConversationID is the ThirdPartyConversationID of the query
+	// itself, generated when empty. ServiceProviderCode and CountryCode are
+	// optional, when empty the values from Config are used.
 	QueryTxParams struct {
-		Reference           string
-		ServiceProviderCode string
-		ConversationID      string
-		CountryCode         string
+		// Deprecated: Reference is read as TransactionID, set TransactionID or
+		// OriginalConversationID instead.
+		Reference              string
+		TransactionID          string
+		OriginalConversationID string
+		ServiceProviderCode    string
+		ConversationID         string
+		CountryCode            string
+	}
+
+	// queryTxRequest
+	//  QueryReference	Transaction ID, Conversation ID or Third Party Conversation ID of the transaction to query.	True	^[0-9a-zA-Z \w+]{1,40}$	000000000000000000001
+	//  ServiceProviderCode	The shortcode of the organization that performed the transaction.	True	^([0-9A-Za-z]{4,12})$	ORG001
+	//  ThirdPartyConversationID	The third party's transaction reference on their system.	True	^[0-9a-zA-Z \w+]{1,40}$	1e9b774d1da34af78412a498cbc28f5e
+	//  Country	The country of the mobile money platform where the transaction needs happen on.	True	N/A	GHA
+	//  QueryReferenceType	Which id QueryReference is, TransactionID or ConversationID.
+	queryTxRequest struct {
+		QueryReference           string `json:"input_QueryReference"`
+		QueryReferenceType       string `json:"input_QueryReferenceType"`
+		ServiceProviderCode      string `json:"input_ServiceProviderCode"`
+		ThirdPartyConversationID string `json:"input_ThirdPartyConversationID"`
+		Country                  string `json:"input_Country"`
 	}
 
 	// QueryTxResponse is the response from querying a transaction
+	// ResponseCode	The result code for the transaction.	INS-0
+	// ResponseDesc	The result description for the transaction.	Request processed successfully
+	// ResponseTransactionStatus	The status of the transaction being queried.	Completed
+	// ConversationID	The OpenAPI platform generates this as a reference to the transaction.	fd1e9143d22544459f7c66e1860ef276
+	// ThirdPartyConversationID	The incoming reference from the third party system.	1e9b774d1da34af78412a498cbc28f5e
 	QueryTxResponse struct {
-		ConversationID            string `json:"output_ConversationID"`
-		ResponseCode              string `json:"output_ResponseCode"`
-		ResponseDesc              string `json:"output_ResponseDesc"`
-		ResponseTransactionStatus string `json:"output_ResponseTransactionStatus"`
-		ThirdPartyConversationID  string `json:"output_ThirdPartyConversationID"`
+		ConversationID            string    `json:"output_ConversationID"`
+		ResponseCode              string    `json:"output_ResponseCode"`
+		ResponseDesc              string    `json:"output_ResponseDesc"`
+		ResponseTransactionStatus string    `json:"output_ResponseTransactionStatus"`
+		ThirdPartyConversationID  string    `json:"output_ThirdPartyConversationID"`
+		OutputErr                 string    `json:"output_error,omitempty"`
+		HTTP                      *HTTPInfo `json:"-"`
 	}
 
 	querier interface {
@@ -31,3 +64,69 @@ type (
 	QueryTxFunc       func(ctx context.Context, m Mode, req QueryTxParams) (QueryTxResponse, error)
 	QueryCallbackFunc func(ctx context.Context, req QueryTxParams) (QueryTxResponse, error)
 )
+
+// Code returns ResponseCode as a ResponseCode.
+func (r QueryTxResponse) Code() ResponseCode { return ResponseCode(r.ResponseCode) }
+
+func (r *QueryTxResponse) responseCode() ResponseCode { return r.Code() }
+
+func (r *QueryTxResponse) setHTTP(info *HTTPInfo) { r.HTTP = info }
+
+// The values of input_QueryReferenceType, telling the gateway which id
+// input_QueryReference is.
+const (
+	queryByTransactionID  = "TransactionID"
+	queryByConversationID = "ConversationID"
+)
+
+// Validate checks that exactly one of TransactionID and OriginalConversationID
+// is set, Reference counting as TransactionID, and that it fits the gateway
+// limits. It returns a *ValidationError naming the fields.
+func (params QueryTxParams) Validate() error {
+	var v validation
+	keys := params.lookupKeys()
+	switch len(keys) {
+	case 0:
+		v.add("TransactionID", RuleRequired, "or OriginalConversationID is required (Reference is a deprecated alias of TransactionID)")
+	case 1:
+		if reference, _ := params.queryReference(); len([]rune(reference)) > MaxThirdPartyIDLength {
+			v.add(keys[0], RuleLength, "must be at most %d characters, got %d", MaxThirdPartyIDLength, len([]rune(reference)))
+		}
+	default:
+		v.add(keys[0], RuleExclusive, "must not be set with %s, set only one", strings.Join(keys[1:], " and "))
+	}
+	v.checkThirdPartyID(params.ConversationID)
+	v.checkProviderCode(params.ServiceProviderCode)
+
+	return v.errFor(queryTxn.Name())
+}
+
+// lookupKeys returns the names of the lookup fields that are set.
+func (params QueryTxParams) lookupKeys() []string {
+	var keys []string
+	if params.TransactionID != "" {
+		keys = append(keys, "TransactionID")
+	}
+	if params.Reference != "" {
+		keys = append(keys, "Reference")
+	}
+	if params.OriginalConversationID != "" {
+		keys = append(keys, "OriginalConversationID")
+	}
+
+	return keys
+}
+
+// queryReference returns the lookup field that is set, sent as
+// input_QueryReference, and the qualifier sent as input_QueryReferenceType.
+// The deprecated Reference is sent as a TransactionID.
+func (params QueryTxParams) queryReference() (reference, qualifier string) {
+	switch {
+	case params.TransactionID != "":
+		return params.TransactionID, queryByTransactionID
+	case params.OriginalConversationID != "":
+		return params.OriginalConversationID, queryByConversationID
+	default:
+		return params.Reference, queryByTransactionID
+	}
+}
@@ -0,0 +1,150 @@
+package mpesa
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// errRateLimitDeadline is returned by RateLimiter.Wait when the wait for a slot
+// would outlast the context deadline.
+var errRateLimitDeadline = fmt.Errorf("mpesa: rate limit wait exceeds the context deadline: %w", context.DeadlineExceeded)
+
+// RateLimiter is a token bucket refilled at a fixed rate. One RateLimiter can be
+// shared by several Client instances using the same credentials, so that their
+// requests add up to the limit of the application on the portal.
+type RateLimiter struct {
+	rate  float64
+	burst float64
+	clock Clock
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a RateLimiter allowing rps requests per second on
+// average and bursts of up to burst requests.
+func NewRateLimiter(rps float64, burst int) (*RateLimiter, error) {
+	if rps <= 0 {
+		return nil, fmt.Errorf("rate limit must be positive, got %v", rps)
+	}
+	if burst < 1 {
+		return nil, fmt.Errorf("rate limit burst must be at least 1, got %d", burst)
+	}
+
+	return &RateLimiter{
+		rate:   rps,
+		burst:  float64(burst),
+		clock:  realClock{},
+		tokens: float64(burst),
+	}, nil
+}
+
+// Wait blocks until a request may be sent. It returns early with an error when
+// ctx is done or when its deadline would pass before a slot is available.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := l.clock.Now()
+	l.refill(now)
+	l.tokens--
+	if l.tokens >= 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	wait := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if deadline, ok := ctx.Deadline(); ok && now.Add(wait).After(deadline) {
+		l.cancel()
+		return errRateLimitDeadline
+	}
+
+	select {
+	case <-ctx.Done():
+		l.cancel()
+		return ctx.Err()
+	case <-l.clock.After(wait):
+		return nil
+	}
+}
+
+// refill adds the tokens earned since the last call. It must be called with mu
+// held.
+func (l *RateLimiter) refill(now time.Time) {
+	if !l.last.IsZero() && now.After(l.last) {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+}
+
+// cancel gives back the token taken by a Wait that gave up.
+func (l *RateLimiter) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill(l.clock.Now())
+	l.tokens++
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+}
+
+// limiter returns the RateLimiter that applies to requestType, nil when the
+// requests are not limited.
+func (c *Client) limiter(requestType requestType) *RateLimiter {
+	if requestType == sessionID && c.authLimiter != nil {
+		return c.authLimiter
+	}
+
+	return c.rateLimiter
+}
+
+// WithRateLimit limits the requests sent to the gateway to rps per second on
+// average, with bursts of up to burst requests. Requests wait for a slot, or
+// until their context is done.
+func WithRateLimit(rps float64, burst int) ClientOption {
+	return func(client *Client) {
+		limiter, err := NewRateLimiter(rps, burst)
+		if err != nil {
+			client.optionErrs = append(client.optionErrs, err.Error())
+			return
+		}
+
+		client.rateLimiter = limiter
+	}
+}
+
+// WithRateLimiter limits the requests sent to the gateway with limiter, which
+// may be shared with other clients.
+func WithRateLimiter(limiter *RateLimiter) ClientOption {
+	return func(client *Client) {
+		if limiter == nil {
+			client.optionErrs = append(client.optionErrs, "rate limiter is nil")
+			return
+		}
+
+		client.rateLimiter = limiter
+	}
+}
+
+// WithAuthRateLimiter limits the session requests with limiter instead of the
+// limiter set with WithRateLimit or WithRateLimiter.
+func WithAuthRateLimiter(limiter *RateLimiter) ClientOption {
+	return func(client *Client) {
+		if limiter == nil {
+			client.optionErrs = append(client.optionErrs, "auth rate limiter is nil")
+			return
+		}
+
+		client.authLimiter = limiter
+	}
+}
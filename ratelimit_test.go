@@ -0,0 +1,95 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterWait(t *testing.T) {
+	clk := newFakeClock()
+	l, err := NewRateLimiter(1, 2)
+	if err != nil {
+		t.Fatalf("NewRateLimiter() error = %v", err)
+	}
+	l.clock = clk
+
+	for i := 0; i < 2; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait() error = %v within the burst", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait() error = %v past the deadline, want context.DeadlineExceeded", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- l.Wait(context.Background()) }()
+	clk.waitForTimers(t, 1)
+	select {
+	case err := <-done:
+		t.Fatalf("Wait() = %v before a token was available", err)
+	default:
+	}
+	clk.Advance(time.Second)
+	if err := <-done; err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+}
+
+func TestNewRateLimiterInvalid(t *testing.T) {
+	if _, err := NewRateLimiter(0, 1); err == nil {
+		t.Error("NewRateLimiter(0, 1) error = nil")
+	}
+	if _, err := NewRateLimiter(1, 0); err == nil {
+		t.Error("NewRateLimiter(1, 0) error = nil")
+	}
+
+	g := newTestGateway(t)
+	if _, err := NewClient(g.config(), nil, WithRateLimit(-1, 1)); err == nil {
+		t.Error("NewClient() error = nil with a negative rate limit")
+	}
+}
+
+func TestSharedRateLimiter(t *testing.T) {
+	g := newTestGateway(t)
+	var pushes int32
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&pushes, 1)
+		writeJSON(w, http.StatusCreated, PushAsyncResponse{ResponseCode: "INS-0"})
+	}
+
+	clk := newFakeClock()
+	limiter, _ := NewRateLimiter(1, 1)
+	limiter.clock = clk
+	auth, _ := NewRateLimiter(100, 10)
+	first := g.client(WithRateLimiter(limiter), WithAuthRateLimiter(auth))
+	second := g.client(WithRateLimiter(limiter), WithAuthRateLimiter(auth))
+
+	if _, err := first.PushAsync(context.Background(), testRequest("tp-1").PushRequest()); err != nil {
+		t.Fatalf("PushAsync() error = %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := second.PushAsync(context.Background(), testRequest("tp-2").PushRequest())
+		done <- err
+	}()
+	clk.waitForTimers(t, 1)
+	if got := atomic.LoadInt32(&pushes); got != 1 {
+		t.Fatalf("pushes = %d before a token was available, want 1", got)
+	}
+	clk.Advance(time.Second)
+	if err := <-done; err != nil {
+		t.Fatalf("PushAsync() error = %v", err)
+	}
+	if got := atomic.LoadInt32(&pushes); got != 2 {
+		t.Errorf("pushes = %d, want 2", got)
+	}
+}
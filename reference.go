@@ -0,0 +1,73 @@
+package mpesa
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+)
+
+// MaxReferenceLength is the longest input_TransactionReference accepted by the
+// gateway.
+const MaxReferenceLength = 20
+
+// referenceRandomLength is the number of random characters of a reference made
+// by GenerateReference.
+const referenceRandomLength = 12
+
+const referenceAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// GenerateReference returns a random transaction reference starting with
+// prefix. The characters of prefix the gateway does not accept are dropped and
+// it is cut to 8 characters, the rest of the reference is 12 random uppercase
+// letters and digits.
+func GenerateReference(prefix string) string {
+	var b strings.Builder
+	for _, r := range prefix {
+		if b.Len() == MaxReferenceLength-referenceRandomLength {
+			break
+		}
+		if isReferenceChar(r) {
+			b.WriteRune(r)
+		}
+	}
+
+	// bytes from 252 up are dropped so that every character is equally likely
+	random := make([]byte, referenceRandomLength)
+	for n := 0; n < referenceRandomLength; {
+		if _, err := rand.Read(random); err != nil {
+			panic(fmt.Sprintf("mpesa: could not generate reference: %v", err))
+		}
+		for _, c := range random {
+			if n < referenceRandomLength && int(c) < 256/len(referenceAlphabet)*len(referenceAlphabet) {
+				b.WriteByte(referenceAlphabet[int(c)%len(referenceAlphabet)])
+				n++
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// ValidateReference checks that s is a transaction reference the gateway
+// accepts: 1 to MaxReferenceLength letters, digits, spaces, underscores or
+// plus signs. It returns a *FieldError otherwise.
+func ValidateReference(s string) error {
+	if s == "" {
+		return &FieldError{Field: "Reference", Rule: RuleRequired, Reason: "must not be empty"}
+	}
+	if n := len([]rune(s)); n > MaxReferenceLength {
+		return &FieldError{Field: "Reference", Rule: RuleLength, Reason: fmt.Sprintf("must be at most %d characters, got %d", MaxReferenceLength, n)}
+	}
+	for _, r := range s {
+		if !isReferenceChar(r) {
+			return &FieldError{Field: "Reference", Rule: RuleFormat, Reason: fmt.Sprintf("character %q is not allowed", r)}
+		}
+	}
+
+	return nil
+}
+
+// isReferenceChar reports whether r is allowed in a transaction reference.
+func isReferenceChar(r rune) bool {
+	return r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == ' ' || r == '_' || r == '+'
+}
@@ -0,0 +1,86 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateReference(t *testing.T) {
+	tests := []struct {
+		name      string
+		reference string
+		wantErr   string
+	}{
+		{"single character", "T", ""},
+		{"longest", strings.Repeat("a", MaxReferenceLength), ""},
+		{"allowed punctuation", "Order 12_A+B", ""},
+		{"empty", "", "must not be empty"},
+		{"too long", strings.Repeat("a", MaxReferenceLength+1), "at most 20 characters, got 21"},
+		{"dash", "INV-1", `'-' is not allowed`},
+		{"slash", "INV/1", `'/' is not allowed`},
+		{"dot", "INV.1", `'.' is not allowed`},
+		{"newline", "INV\n1", `'\n' is not allowed`},
+		{"non ascii letter", "Café", `'é' is not allowed`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateReference(tt.reference)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ValidateReference(%q) error = %v", tt.reference, err)
+				}
+				return
+			}
+
+			var fieldErr *FieldError
+			if !errors.As(err, &fieldErr) || fieldErr.Field != "Reference" || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateReference(%q) error = %v, want a Reference *FieldError containing %q", tt.reference, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGenerateReference(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{"", ""},
+		{"INV", "INV"},
+		{"ORD-2021/", "ORD2021"},
+		{"VERYLONGPREFIX", "VERYLONG"},
+	}
+
+	seen := map[string]bool{}
+	for _, tt := range tests {
+		ref := GenerateReference(tt.prefix)
+		if err := ValidateReference(ref); err != nil {
+			t.Errorf("GenerateReference(%q) = %q, invalid: %v", tt.prefix, ref, err)
+		}
+		if !strings.HasPrefix(ref, tt.want) || len(ref) != len(tt.want)+referenceRandomLength {
+			t.Errorf("GenerateReference(%q) = %q, want %q and %d random characters", tt.prefix, ref, tt.want, referenceRandomLength)
+		}
+		if seen[ref] {
+			t.Errorf("GenerateReference(%q) = %q, generated twice", tt.prefix, ref)
+		}
+		seen[ref] = true
+	}
+}
+
+func TestPushAsyncRejectsInvalidReference(t *testing.T) {
+	g := newTestGateway(t)
+	c := g.client()
+
+	_, err := c.PushAsync(context.Background(), PushRequest{ThirdPartyID: "tp-1", Reference: "INV-0001", Amount: MustParseAmount("10"), MSISDN: "255754000123"})
+
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Field != "Reference" {
+		t.Fatalf("PushAsync() error = %v, want a Reference *FieldError", err)
+	}
+	if g.sessions != 0 {
+		t.Errorf("sessions = %d, want nothing sent", g.sessions)
+	}
+}
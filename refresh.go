@@ -0,0 +1,103 @@
+package mpesa
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+const (
+	minRefreshBackoff = time.Second
+	maxRefreshBackoff = time.Minute
+)
+
+// sessionRefresher keeps the session fresh in the background so that requests
+// never pay for the SessionID round trip.
+type sessionRefresher struct {
+	margin time.Duration
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// WithAutoSessionRefresh starts a goroutine that fetches a new session id margin
+// before the current one expires. A random jitter of up to half the margin is
+// added so that several instances sharing the same credentials do not refresh
+// at the same time. Failed refreshes are written to the logger and retried with
+// an exponential backoff. Client.Close stops the goroutine. margin must be
+// positive, and the session lifetime longer than margin with its jitter plus the
+// margin of WithSessionRefreshMargin, otherwise NewClient returns a
+// *ConfigError.
+func WithAutoSessionRefresh(margin time.Duration) ClientOption {
+	return func(client *Client) {
+		client.refresher = &sessionRefresher{margin: margin}
+	}
+}
+
+func (c *Client) startSessionRefresh() {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.refresher.cancel = cancel
+	c.refresher.done = make(chan struct{})
+
+	go c.refreshSession(ctx)
+}
+
+func (c *Client) refreshSession(ctx context.Context) {
+	defer close(c.refresher.done)
+
+	backoff := minRefreshBackoff
+	var wait time.Duration
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.clock.After(wait):
+		}
+
+		_, err := c.renewSession(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			c.logf("background session refresh failed, retrying in %s: %v", backoff, err)
+			wait = backoff
+			backoff *= 2
+			if backoff > maxRefreshBackoff {
+				backoff = maxRefreshBackoff
+			}
+
+			continue
+		}
+
+		backoff = minRefreshBackoff
+		wait = c.nextRefresh()
+	}
+}
+
+// nextRefresh returns how long to wait before refreshing the current session.
+// When an expiration that was not moved forward, e.g. when the credentials
+// rotated during the fetch, is already within the margin, it waits half of the
+// time left instead, and at least minRefreshBackoff, so that it does not
+// refresh in a loop.
+func (c *Client) nextRefresh() time.Duration {
+	c.sessionMu.RLock()
+	expiration := c.sessionExpiration
+	c.sessionMu.RUnlock()
+
+	margin := c.refresher.margin
+	jitter := time.Duration(0)
+	if margin > 1 {
+		jitter = time.Duration(rand.Int63n(int64(margin / 2))) //nolint:gosec
+	}
+
+	remaining := expiration.Sub(c.clock.Now())
+	wait := remaining - margin - jitter
+	if wait < minRefreshBackoff {
+		wait = remaining / 2
+	}
+	if wait < minRefreshBackoff {
+		wait = minRefreshBackoff
+	}
+
+	return wait
+}
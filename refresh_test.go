@@ -0,0 +1,173 @@
+package mpesa
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock whose time only moves when Advance is called.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []fakeTimer
+}
+
+type fakeTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2021, 10, 1, 8, 0, 0, 0, time.UTC)}
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.now
+}
+
+func (f *fakeClock) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- f.now
+		return ch
+	}
+	f.timers = append(f.timers, fakeTimer{at: f.now.Add(d), ch: ch})
+
+	return ch
+}
+
+// Advance moves the clock forward by d and fires the timers that became due.
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = f.now.Add(d)
+	pending := f.timers[:0]
+	for _, timer := range f.timers {
+		if timer.at.After(f.now) {
+			pending = append(pending, timer)
+			continue
+		}
+		timer.ch <- f.now
+	}
+	f.timers = pending
+}
+
+// waitForTimers blocks until n timers are pending.
+func (f *fakeClock) waitForTimers(t *testing.T, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		f.mu.Lock()
+		pending := len(f.timers)
+		f.mu.Unlock()
+		if pending >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d pending timers", n)
+}
+
+func waitForSessions(t *testing.T, g *testGateway, n int32) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if atomic.LoadInt32(&g.sessions) >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d sessions, got %d", n, atomic.LoadInt32(&g.sessions))
+}
+
+func TestAutoSessionRefresh(t *testing.T) {
+	g := newTestGateway(t)
+	fc := newFakeClock()
+	margin := 5 * time.Minute
+
+	c := g.client(WithAutoSessionRefresh(margin), WithClock(fc))
+	defer c.Close()
+
+	// the first session is fetched as soon as the client starts
+	waitForSessions(t, g, 1)
+	fc.waitForTimers(t, 1)
+
+	// the refresh must not fire earlier than margin plus the maximum jitter
+	// before the margin-adjusted expiration
+	fc.Advance(time.Hour - DefaultSessionRefreshMargin - margin - margin/2 - time.Second)
+	if got := atomic.LoadInt32(&g.sessions); got != 1 {
+		t.Fatalf("sessions fetched = %d before the refresh window, want 1", got)
+	}
+
+	// and must have fired margin before the session expires
+	fc.Advance(margin/2 + time.Second)
+	waitForSessions(t, g, 2)
+
+	if err := c.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+}
+
+func TestAutoSessionRefreshMargin(t *testing.T) {
+	tests := []struct {
+		name   string
+		margin time.Duration
+		want   string
+	}{
+		{"zero", 0, "auto session refresh margin must be positive, got 0s"},
+		{"negative", -time.Minute, "auto session refresh margin must be positive, got -1m0s"},
+		{"lifetime", time.Hour, "session lifetime of 1h0m0s must be longer than the auto session refresh margin of 1h0m0s"},
+		{"longer than the lifetime", 2 * time.Hour, "must be longer than the auto session refresh margin of 2h0m0s"},
+		{"with the jitter", 40 * time.Minute, "must be longer than the auto session refresh margin of 40m0s plus half of it for the jitter and the session refresh margin of 30s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGateway(t)
+
+			_, err := NewClient(g.config(), nil, WithHTTPClient(g.Client()), WithAutoSessionRefresh(tt.margin))
+
+			var confErr *ConfigError
+			if !errors.As(err, &confErr) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("NewClient() error = %v, want a *ConfigError containing %q", err, tt.want)
+			}
+			if got := atomic.LoadInt32(&g.sessions); got != 0 {
+				t.Errorf("sessions fetched = %d, want 0", got)
+			}
+		})
+	}
+}
+
+func TestNextRefreshFloor(t *testing.T) {
+	g := newTestGateway(t)
+	fc := newFakeClock()
+	c := g.client(WithClock(fc))
+	c.refresher = &sessionRefresher{margin: 5 * time.Minute}
+
+	// an expiration that was not moved forward is already within the margin
+	c.sessionExpiration = fc.Now().Add(time.Minute)
+	if got := c.nextRefresh(); got != 30*time.Second {
+		t.Errorf("nextRefresh() = %s, want half of the time left", got)
+	}
+
+	c.sessionExpiration = fc.Now().Add(time.Second)
+	if got := c.nextRefresh(); got != minRefreshBackoff {
+		t.Errorf("nextRefresh() = %s, want %s", got, minRefreshBackoff)
+	}
+}
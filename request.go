@@ -1,7 +1,11 @@
 package mpesa
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"reflect"
 	"strings"
 
 	"github.com/techcraftlabs/base"
@@ -17,19 +21,179 @@ func (eps *Endpoints) Get(requestType requestType) string {
 
 	case disburse:
 		return eps.DisburseEndpoint
+
+	case queryTxn:
+		return eps.QueryEndpoint
+
+	case b2bPay:
+		return eps.B2BEndpoint
+
+	case directDebitCreate:
+		return eps.DirectDebitCreateEndpoint
+
+	case directDebitPay:
+		return eps.DirectDebitPayEndpoint
+
+	case directDebitCancel:
+		return eps.DirectDebitCancelEndpoint
+
+	case directDebitQuery:
+		return eps.DirectDebitQueryEndpoint
+
+	case beneficiaryName:
+		return eps.BeneficiaryNameEndpoint
 	}
 
 	return ""
 }
 
-func (c *Client) makeInternalRequest(requestType requestType, payload interface{}, opts ...base.RequestOption) *base.Request {
-	baseURL := c.Conf.BasePath
+// makeInternalRequest builds the request of requestType carrying payload,
+// authorized with the bearer token, and applies the call options of ctx.
+func (c *Client) makeInternalRequest(ctx context.Context, requestType requestType, payload interface{}, token string, opts ...base.RequestOption) (*base.Request, error) {
+	callOpts := callOptionsFrom(ctx)
+	if callOpts.err != nil {
+		return nil, callOpts.err
+	}
+
+	baseURL := c.baseURL
 	endpoints := c.Conf.Endpoints
 	edps := endpoints
 	url := appendEndpoint(baseURL, edps.Get(requestType))
 	method := requestType.Method()
+	opts = append(opts, base.WithRequestHeaders(c.requestHeaders(ctx, token)))
+	opts = append(opts, callOpts.requestOptions...)
+
+	return base.NewRequest(requestType.String(), method, url, payload, opts...), nil
+}
+
+// send is sendAuthenticated between the interceptor hooks.
+func (c *Client) send(ctx context.Context, requestType requestType, payload interface{}, v interface{}) (res *base.Response, err error) {
+	err = c.intercept(ctx, requestType, payload, v, func(ctx context.Context) error {
+		res, err = c.sendAuthenticated(ctx, requestType, payload, v)
+		return err
+	})
+	if err == nil {
+		c.locate(v)
+	}
+
+	return res, err
+}
+
+// sendAuthenticated performs an authenticated call to the gateway. The current session id is
+// encrypted into a bearer token, the request is built from payload and the
+// response body is decoded into v.
+//
+// A 401 means the session was rejected even though it had not reached its
+// expiration yet. In that case the cached session is dropped, a new one is
+// fetched and the call is retried once with the same payload, so that the
+// ThirdPartyConversationID still protects against duplicates. A second 401 is
+// returned as an *APIError matching ErrSessionInvalid.
+func (c *Client) sendAuthenticated(ctx context.Context, requestType requestType, payload interface{}, v interface{}) (*base.Response, error) {
+	res, sess, err := c.sendOnce(ctx, requestType, payload, v)
+	if err != nil {
+		return res, err
+	}
+
+	if !sessionRejected(res) {
+		return res, nil
+	}
+
+	if c.records != nil {
+		c.record(ctx, levelInfo, "session rejected, re-authenticating")
+	} else {
+		c.debugf("session rejected by %s, re-authenticating", requestType.Name())
+	}
+	if c.onSessionRejected != nil {
+		c.onSessionRejected(requestType.Name())
+	}
+
+	c.resetSession(sess)
+	c.forgetSession(ctx, sess)
+	retried(ctx)
+	rv := reflect.ValueOf(v).Elem()
+	rv.Set(reflect.Zero(rv.Type()))
+
+	res, _, err = c.sendOnce(ctx, requestType, payload, v)
+	if err != nil {
+		return res, err
+	}
+
+	if res.StatusCode == http.StatusUnauthorized {
+		return res, &APIError{
+			Operation:   requestType.Name(),
+			Description: "session rejected by the gateway",
+			StatusCode:  res.StatusCode,
+		}
+	}
+
+	return res, nil
+}
+
+// codedResponse is implemented by the responses carrying an output_ResponseCode.
+type codedResponse interface {
+	responseCode() ResponseCode
+}
+
+// sessionRejected reports whether the gateway rejected the session used for the
+// request, which it does with a 401. The response codes of
+// ResponseCode.IsAuthFailure are not: an invalid API key, an inactive user or
+// a failed initiator authentication are not fixed by a new session, and
+// sending a payment again after them would only double the traffic.
+func sessionRejected(res *base.Response) bool {
+	return res.StatusCode == http.StatusUnauthorized
+}
+
+// sendOnce is like sendAuthenticated without the retry, it also returns the
+// session id used. The errors raised before the request is handed to do, the
+// session fetch included, mark the request as not sent.
+func (c *Client) sendOnce(ctx context.Context, requestType requestType, payload interface{}, v interface{}) (*base.Response, string, error) {
+	sess, err := c.checkSessionID(ctx)
+	if err != nil {
+		return nil, sess, unsent(err)
+	}
+	_, publicKey, _, err := c.credentials(ctx)
+	if err != nil {
+		return nil, sess, unsent(err)
+	}
+	token, err := encryptKey(sess, publicKey)
+	if err != nil {
+		return nil, sess, unsent(err)
+	}
+
+	var opts []base.RequestOption
+
+	// GET requests carry the adapted payload as query parameters
+	if requestType.Method() == http.MethodGet && payload != nil {
+		params, err := queryParams(payload)
+		if err != nil {
+			return nil, sess, unsent(err)
+		}
+		opts = append(opts, base.WithQueryParams(params))
+		payload = nil
+	}
+
+	re, err := c.makeInternalRequest(ctx, requestType, payload, token, opts...)
+	if err != nil {
+		return nil, sess, unsent(err)
+	}
+	res, err := c.do(ctx, requestType, re, v)
+
+	return res, sess, err
+}
+
+// queryParams flattens the JSON representation of payload into query parameters.
+func queryParams(payload interface{}) (map[string]string, error) {
+	buf, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal query parameters: %w", err)
+	}
+
+	params := make(map[string]string)
+	if err := json.Unmarshal(buf, &params); err != nil {
+		return nil, fmt.Errorf("could not marshal query parameters: %w", err)
+	}
 
-	return base.NewRequest(requestType.String(), method, url, payload, opts...)
+	return params, nil
 }
 
 func appendEndpoint(url string, endpoint string) string {
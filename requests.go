@@ -1,19 +1,31 @@
 package mpesa
 
+import "strings"
+
 type (
+	// Request carries the details of a B2B payment, ReceiverPartyCode holds the
+	// short code of the business receiving the funds, Currency overrides
+	// Market.Currency like PushRequest.Currency does and ServiceProviderCode,
+	// the short code paying, overrides Config.ServiceProvideCode. It was used by PushAsync
+	// and Disburse too before PushRequest and DisburseRequest, see
+	// Request.PushRequest and Request.DisburseRequest to migrate.
 	Request struct {
-		ThirdPartyID string  `json:"id,omitempty"`
-		Reference    string  `json:"reference,omitempty"`
-		Amount       float64 `json:"amount,omitempty"`
-		MSISDN       string  `json:"msisdn,omitempty"`
-		Description  string  `json:"description,omitempty"`
+		ThirdPartyID        string `json:"id,omitempty"`
+		Reference           string `json:"reference,omitempty"`
+		Amount              Amount `json:"amount"`
+		MSISDN              string `json:"msisdn,omitempty"`
+		Description         string `json:"description,omitempty"`
+		ReceiverPartyCode   string `json:"receiver_party_code,omitempty"`
+		Currency            string `json:"currency,omitempty"`
+		ServiceProviderCode string `json:"service_provider_code,omitempty"`
 	}
 
 	SessionResponse struct {
-		Code        string `json:"output_ResponseCode,omitempty"`
-		Description string `json:"output_ResponseDesc,omitempty"`
-		ID          string `json:"output_SessionID,omitempty"`
-		OutputErr   string `json:"output_error,omitempty"`
+		Code        string    `json:"output_ResponseCode,omitempty"`
+		Description string    `json:"output_ResponseDesc,omitempty"`
+		ID          string    `json:"output_SessionID,omitempty"`
+		OutputErr   string    `json:"output_error,omitempty"`
+		HTTP        *HTTPInfo `json:"-"`
 	}
 
 	//  pushPayRequest
@@ -37,19 +49,25 @@ type (
 	}
 
 	PushAsyncResponse struct {
-		ResponseCode             string `json:"output_ResponseCode"`
-		ResponseDesc             string `json:"output_ResponseDesc"`
-		ConversationID           string `json:"output_ConversationID"`
-		ThirdPartyConversationID string `json:"output_ThirdPartyConversationID"`
-		OutputErr                string `json:"output_error,omitempty"`
+		ResponseCode             string    `json:"output_ResponseCode"`
+		ResponseDesc             string    `json:"output_ResponseDesc"`
+		ConversationID           string    `json:"output_ConversationID"`
+		ThirdPartyConversationID string    `json:"output_ThirdPartyConversationID"`
+		OutputErr                string    `json:"output_error,omitempty"`
+		HTTP                     *HTTPInfo `json:"-"`
 	}
 
+	// PushCallbackRequest is the result of a push sent by the gateway. Amount
+	// and TransactionTime are kept as sent, when they are sent, and read with
+	// AmountValue and Time.
 	PushCallbackRequest struct {
 		OriginalConversationID   string `json:"input_OriginalConversationID"`
 		TransactionID            string `json:"input_TransactionID"`
 		ResultCode               string `json:"input_ResultCode"`
 		ResultDesc               string `json:"input_ResultDesc"`
 		ThirdPartyConversationID string `json:"input_ThirdPartyConversationID"`
+		Amount                   string `json:"input_Amount,omitempty"`
+		TransactionTime          string `json:"input_TransactionTime,omitempty"`
 	}
 
 	PushCallbackResponse struct {
@@ -86,11 +104,113 @@ type (
 	// ConversationID	The OpenAPI platform generates this as a reference to the transaction.	fd1e9143d22544459f7c66e1860ef276
 	// ThirdPartyConversationID	The incoming reference from the third party system. When there are queries about transactions, this will usually be used to track a transaction.	1e9b774d1da34af78412a498cbc28f5e
 	DisburseResponse struct {
-		ConversationID           string `json:"output_ConversationID"`
-		ResponseCode             string `json:"output_ResponseCode"`
-		ResponseDesc             string `json:"output_ResponseDesc"`
-		TransactionID            string `json:"output_TransactionID"`
-		ThirdPartyConversationID string `json:"output_ThirdPartyConversationID"`
-		OutputErr                string `json:"output_error,omitempty"`
+		ConversationID           string    `json:"output_ConversationID"`
+		ResponseCode             string    `json:"output_ResponseCode"`
+		ResponseDesc             string    `json:"output_ResponseDesc"`
+		TransactionID            string    `json:"output_TransactionID"`
+		ThirdPartyConversationID string    `json:"output_ThirdPartyConversationID"`
+		OutputErr                string    `json:"output_error,omitempty"`
+		HTTP                     *HTTPInfo `json:"-"`
+	}
+
+	// QueryBeneficiaryParams identifies the customer whose registered name is
+	// queried by QueryBeneficiary. ServiceProviderCode overrides
+	// Config.ServiceProvideCode.
+	QueryBeneficiaryParams struct {
+		MSISDN              string
+		ServiceProviderCode string
+	}
+
+	// beneficiaryNameRequest
+	//  CustomerMSISDN	The MSISDN of the customer whose registered name is queried.	True	^[0-9]{12,14}$	254707161122
+	//  Country	The country of the mobile money platform where the transaction needs happen on.	True	N/A	GHA
+	//  ServiceProviderCode	The shortcode of the organization performing the query.	True	^([0-9A-Za-z]{4,12})$	ORG001
+	//  KycQueryType	The kind of KYC details requested, only Name is supported.	True	N/A	Name
+	//  ThirdPartyConversationID	The third party's transaction reference on their system.	True	^[0-9a-zA-Z \w+]{1,40}$	1e9b774d1da34af78412a498cbc28f5e
+	beneficiaryNameRequest struct {
+		CustomerMSISDN           string `json:"input_CustomerMSISDN"`
+		Country                  string `json:"input_Country"`
+		ServiceProviderCode      string `json:"input_ServiceProviderCode"`
+		KycQueryType             string `json:"input_KycQueryType"`
+		ThirdPartyConversationID string `json:"input_ThirdPartyConversationID"`
+	}
+
+	// BeneficiaryNameResponse ...
+	// ResponseCode	The result code for the transaction.	INS-0
+	// ResponseDesc	The result description for the transaction.	Request processed successfully
+	// CustomerFirstName	The first name registered to the MSISDN, some markets return it masked.	John
+	// CustomerLastName	The last name registered to the MSISDN, some markets return it masked.	D**
+	// ConversationID	The OpenAPI platform generates this as a reference to the transaction.	fd1e9143d22544459f7c66e1860ef276
+	// ThirdPartyConversationID	The incoming reference from the third party system.	1e9b774d1da34af78412a498cbc28f5e
+	BeneficiaryNameResponse struct {
+		ResponseCode             string    `json:"output_ResponseCode"`
+		ResponseDesc             string    `json:"output_ResponseDesc"`
+		FirstName                string    `json:"output_CustomerFirstName"`
+		LastName                 string    `json:"output_CustomerLastName"`
+		ConversationID           string    `json:"output_ConversationID"`
+		ThirdPartyConversationID string    `json:"output_ThirdPartyConversationID"`
+		OutputErr                string    `json:"output_error,omitempty"`
+		HTTP                     *HTTPInfo `json:"-"`
 	}
 )
+
+// FullName joins the first and last name returned by the gateway.
+func (r BeneficiaryNameResponse) FullName() string {
+	return strings.TrimSpace(r.FirstName + " " + r.LastName)
+}
+
+// PushRequest returns the PushRequest holding the fields of r, to migrate a
+// call to PushAsync.
+func (r Request) PushRequest() PushRequest {
+	return PushRequest{
+		ThirdPartyID:        r.ThirdPartyID,
+		Reference:           r.Reference,
+		Amount:              r.Amount,
+		MSISDN:              r.MSISDN,
+		Description:         r.Description,
+		Currency:            r.Currency,
+		ServiceProviderCode: r.ServiceProviderCode,
+	}
+}
+
+// DisburseRequest returns the DisburseRequest holding the fields of r, to
+// migrate a call to Disburse.
+func (r Request) DisburseRequest() DisburseRequest {
+	return DisburseRequest{
+		ThirdPartyID:        r.ThirdPartyID,
+		Reference:           r.Reference,
+		Amount:              r.Amount,
+		MSISDN:              r.MSISDN,
+		Description:         r.Description,
+		Currency:            r.Currency,
+		ServiceProviderCode: r.ServiceProviderCode,
+	}
+}
+
+// ResponseCode returns Code as a ResponseCode.
+func (r SessionResponse) ResponseCode() ResponseCode { return ResponseCode(r.Code) }
+
+// Code returns ResponseCode as a ResponseCode.
+func (r PushAsyncResponse) Code() ResponseCode { return ResponseCode(r.ResponseCode) }
+
+// Code returns ResponseCode as a ResponseCode.
+func (r DisburseResponse) Code() ResponseCode { return ResponseCode(r.ResponseCode) }
+
+// Code returns ResponseCode as a ResponseCode.
+func (r BeneficiaryNameResponse) Code() ResponseCode { return ResponseCode(r.ResponseCode) }
+
+func (r *SessionResponse) responseCode() ResponseCode { return r.ResponseCode() }
+
+func (r *SessionResponse) setHTTP(info *HTTPInfo) { r.HTTP = info }
+
+func (r *PushAsyncResponse) responseCode() ResponseCode { return r.Code() }
+
+func (r *PushAsyncResponse) setHTTP(info *HTTPInfo) { r.HTTP = info }
+
+func (r *DisburseResponse) responseCode() ResponseCode { return r.Code() }
+
+func (r *DisburseResponse) setHTTP(info *HTTPInfo) { r.HTTP = info }
+
+func (r *BeneficiaryNameResponse) responseCode() ResponseCode { return r.Code() }
+
+func (r *BeneficiaryNameResponse) setHTTP(info *HTTPInfo) { r.HTTP = info }
@@ -0,0 +1,140 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy configures the retries of the idempotent operations, SessionID
+// and QueryTx. PushAsync and Disburse are never retried, a blind retry could
+// charge or pay the customer twice. When the gateway throttles a request with a
+// Retry-After header, that delay replaces the backoff before the next attempt.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts including the first one. Values
+	// below 2 turn retries off.
+	MaxAttempts int
+
+	// BaseDelay is the wait before the first retry, it doubles on every retry.
+	BaseDelay time.Duration
+
+	// MaxDelay caps the wait between attempts.
+	MaxDelay time.Duration
+
+	// Jitter is the fraction, between 0 and 1, of every wait that is randomised.
+	Jitter float64
+
+	// Retryable decides whether an error is worth another attempt. It defaults
+	// to DefaultRetryable.
+	Retryable func(err error) bool
+}
+
+// DefaultRetryable reports whether err is transient: a TransportError, a
+// GatewayError without a 4xx status other than 429, a 429, a 502, 503 or 504
+// without a response code, or a response code for which
+// ResponseCode.IsRetryable is true. Context errors, ErrCircuitOpen, ErrDryRun,
+// every other APIError and the errors raised before a request was sent, e.g.
+// a ValidationError, are not retried.
+func DefaultRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrDryRun) {
+		return false
+	}
+
+	var transportErr *TransportError
+	if errors.As(err, &transportErr) {
+		return true
+	}
+
+	var gatewayErr *GatewayError
+	if errors.As(err, &gatewayErr) {
+		status := gatewayErr.StatusCode
+
+		return status == http.StatusTooManyRequests || status < http.StatusBadRequest || status >= http.StatusInternalServerError
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	if apiErr.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	if apiErr.Code != "" {
+		return ResponseCode(apiErr.Code).IsRetryable()
+	}
+
+	switch apiErr.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// retry calls fn until it succeeds, fails with an error that is not retryable,
+// the attempts run out or waiting for the next attempt would go past the
+// deadline of ctx.
+func (c *Client) retry(ctx context.Context, fn func() error) error {
+	p := c.retryPolicy
+	if p == nil || p.MaxAttempts < 2 {
+		return fn()
+	}
+
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = DefaultRetryable
+	}
+
+	delay := p.BaseDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.MaxAttempts || !retryable(err) {
+			return err
+		}
+
+		wait := p.wait(delay)
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+			wait = apiErr.RetryAfter
+		}
+		if deadline, ok := ctx.Deadline(); ok && c.clock.Now().Add(wait).After(deadline) {
+			return err
+		}
+		if c.records != nil {
+			c.record(ctx, levelDebug, "request retried", "attempt", attempt, "delay_ms", wait.Milliseconds(), "error", err)
+		} else {
+			c.debugf("attempt %d failed, retrying in %s: %v", attempt, wait, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-c.clock.After(wait):
+		}
+		retried(ctx)
+
+		delay *= 2
+		if p.MaxDelay > 0 && delay > p.MaxDelay {
+			delay = p.MaxDelay
+		}
+	}
+}
+
+// wait returns delay with the jitter fraction of it randomised.
+func (p *RetryPolicy) wait(delay time.Duration) time.Duration {
+	jitter := p.Jitter
+	if jitter <= 0 || delay <= 0 {
+		return delay
+	}
+	if jitter > 1 {
+		jitter = 1
+	}
+
+	spread := time.Duration(float64(delay) * jitter)
+
+	return delay - spread + time.Duration(rand.Int63n(int64(spread)+1)) //nolint:gosec
+}
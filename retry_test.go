@@ -0,0 +1,158 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryQueryTx(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []QueryTxResponse
+		retryable func(error) bool
+		want      int32
+		wantErr   bool
+	}{
+		{
+			name: "transient code",
+			responses: []QueryTxResponse{
+				{ResponseCode: "INS-9", ResponseDesc: "Request timeout"},
+				{ResponseCode: "INS-0", ResponseTransactionStatus: "Completed"},
+			},
+			want: 2,
+		},
+		{
+			name: "attempts exhausted",
+			responses: []QueryTxResponse{
+				{ResponseCode: "INS-16", ResponseDesc: "Unable to handle the request due to a temporary overloading"},
+			},
+			want:    3,
+			wantErr: true,
+		},
+		{
+			name: "permanent code",
+			responses: []QueryTxResponse{
+				{ResponseCode: "INS-18", ResponseDesc: "Invalid TransactionID Used"},
+				{ResponseCode: "INS-0", ResponseTransactionStatus: "Completed"},
+			},
+			want:    1,
+			wantErr: true,
+		},
+		{
+			name: "custom decision",
+			responses: []QueryTxResponse{
+				{ResponseCode: "INS-18", ResponseDesc: "Invalid TransactionID Used"},
+				{ResponseCode: "INS-0", ResponseTransactionStatus: "Completed"},
+			},
+			retryable: func(err error) bool {
+				var apiErr *APIError
+				return errors.As(err, &apiErr) && apiErr.Code == "INS-18"
+			},
+			want: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, queries := queryGateway(t, tt.responses...)
+			c := g.client(WithRetry(RetryPolicy{
+				MaxAttempts: 3,
+				BaseDelay:   time.Millisecond,
+				Retryable:   tt.retryable,
+			}))
+
+			_, err := c.QueryTx(context.Background(), QueryTxParams{Reference: "ref"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("QueryTx() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := atomic.LoadInt32(queries); got != tt.want {
+				t.Errorf("queries = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryServerError(t *testing.T) {
+	g, queries := queryGateway(t, QueryTxResponse{ResponseCode: "INS-0", ResponseTransactionStatus: "Completed"})
+	var failures int32
+	handler := g.handlers["queryTransactionStatus/"]
+	g.handlers["queryTransactionStatus/"] = func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&failures, 1) == 1 {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{})
+			return
+		}
+		handler(w, r)
+	}
+	c := g.client(WithRetry(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}))
+
+	response, err := c.QueryTx(context.Background(), QueryTxParams{Reference: "ref"})
+	if err != nil || response.ResponseTransactionStatus != "Completed" {
+		t.Fatalf("QueryTx() = %+v, %v", response, err)
+	}
+	if got := atomic.LoadInt32(queries); got != 1 {
+		t.Errorf("queries = %d, want 1", got)
+	}
+}
+
+func TestRetryDeadline(t *testing.T) {
+	g, queries := queryGateway(t, QueryTxResponse{ResponseCode: "INS-9", ResponseDesc: "Request timeout"})
+	c := g.client(WithRetry(RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, err := c.QueryTx(ctx, QueryTxParams{Reference: "ref"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != "INS-9" {
+		t.Fatalf("QueryTx() error = %v, want INS-9", err)
+	}
+	if got := atomic.LoadInt32(queries); got != 1 {
+		t.Errorf("queries = %d, want 1", got)
+	}
+}
+
+func TestRetryNotAppliedToPush(t *testing.T) {
+	g := newTestGateway(t)
+	var pushes int32
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&pushes, 1)
+		writeJSON(w, http.StatusServiceUnavailable, PushAsyncResponse{ResponseCode: "INS-16"})
+	}
+	c := g.client(WithRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}))
+
+	if _, err := c.PushAsync(context.Background(), testRequest("tp-1").PushRequest()); err == nil {
+		t.Fatal("PushAsync() error = nil")
+	}
+	if got := atomic.LoadInt32(&pushes); got != 1 {
+		t.Errorf("pushes = %d, want 1", got)
+	}
+}
+
+func TestDefaultRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"transport", &TransportError{Err: errors.New("connection reset")}, true},
+		{"transport timeout", &TransportError{Err: context.DeadlineExceeded}, false},
+		{"not sent", errors.New("could not encrypt the api key"), false},
+		{"canceled", context.Canceled, false},
+		{"retryable code", &APIError{Code: "INS-9"}, true},
+		{"permanent code", &APIError{Code: "INS-10"}, false},
+		{"bad gateway", &APIError{StatusCode: http.StatusBadGateway}, true},
+		{"unauthorized", &APIError{StatusCode: http.StatusUnauthorized}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DefaultRetryable(tt.err); got != tt.want {
+				t.Errorf("DefaultRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package mpesa
+
+// SandboxBasePath is the host of the sandbox of the gateway.
+const SandboxBasePath = "openapi.m-pesa.com"
+
+// SandboxServiceProviderCode is the service provider code of the sandbox
+// applications.
+const SandboxServiceProviderCode = "000000"
+
+// SandboxPublicKey is the public key of the sandbox published on the developer
+// portal, in the Base64 DER format it is shown in. The API keys of the sandbox
+// applications are encrypted with it.
+const SandboxPublicKey = "MIICIjANBgkqhkiG9w0BAQEFAAOCAg8AMIICCgKCAgEArv9yxA69XQKBo24BaF/D+fvlqmGdYjqLQ5WtNBb5tquqGvAvG3WMFETVUSow/LizQalxj2ElMVrUmzu5mGGkxK08bWEXF7a1DEvtVJs6nppIlFJc2SnrU14AOrIrB28ogm58JjAl5BOQawOXD5dfSk7MaAA82pVHoIqEu0FxA8BOKU+RGTihRU+ptw1j4bsAJYiPbSX6i71gfPvwHPYamM0bfI4CmlsUUR3KvCG24rB6FNPcRBhM3jDuv8ae2kC33w9hEq8qNB55uw51vK7hyXoAa+U7IqP1y6nBdlN25gkxEA8yrsl1678cspeXr+3ciRyqoRgj9RD/ONbJhhxFvt1cLBh+qwK2eqISfBb06eRnNeC71oBokDm3zyCnkOtMDGl7IvnMfZfEPFCfg5QgJVk1msPpRvQxmEsrX9MQRyFVzgy2CWNIb7c+jPapyrNwoUbANlN8adU1m6yOuoX7F49x+OjiG2se0EJ6nafeKUXw/+hiJZvELUYgzKUtMAZVTNZfT8jjb58j8GVtuS+6TM2AutbejaCV84ZK58E2CRJqhmjQibEUO6KPdD7oTlEkFy52Y1uOOBXgYpqMzufNPmfdqqqSM4dU70PO8ogyKGiLAIxCetMjjm6FCMEA3Kc8K0Ig7/XtFm9By6VxTJK1Mg36TlHaZKP6VzVLXMtesJECAwEAAQ=="
+
+// NewSandboxClient creates a Client of the sandbox of market with the API key
+// of a sandbox application. The Config is filled with the SANDBOX platform,
+// SandboxBasePath, the default endpoints of market, SandboxPublicKey,
+// SandboxServiceProviderCode and a session lifetime of
+// DefaultSessionLifetimeMinutes. opts are applied on top of it like for
+// NewClient, e.g. WithBaseURL or WithRSAPublicKey replace the host or the key,
+// and the Config is validated the same way.
+func NewSandboxClient(market Market, apiKey string, callbacker PushCallbackHandler, opts ...ClientOption) (*Client, error) {
+	conf := &Config{
+		BasePath:               SandboxBasePath,
+		Market:                 market,
+		Platform:               SANDBOX,
+		APIKey:                 apiKey,
+		PublicKey:              SandboxPublicKey,
+		SessionLifetimeMinutes: DefaultSessionLifetimeMinutes,
+		ServiceProvideCode:     SandboxServiceProviderCode,
+	}
+
+	return NewClient(conf, callbacker, opts...)
+}
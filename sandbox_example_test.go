@@ -0,0 +1,37 @@
+package mpesa_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	mpesa "github.com/ameprizzo/mpesago"
+)
+
+// A single-stage push to a customer of the Tanzanian sandbox, with the API key
+// of a sandbox application read from API_KEY. The result of the payment is
+// sent later to the callback endpoints, see Serve.
+func ExampleNewSandboxClient() {
+	handler := mpesa.PushCallbackFunc(func(request mpesa.PushCallbackRequest) (mpesa.PushCallbackResponse, error) {
+		fmt.Println("push", request.TransactionID, request.Status())
+		return mpesa.PushCallbackResponse{ResponseCode: mpesa.SUCCESS_CODE}, nil
+	})
+
+	client, err := mpesa.NewSandboxClient(mpesa.TanzaniaMarket, os.Getenv(mpesa.EnvAPIKey), handler)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+
+	response, err := client.PushAsync(context.Background(), mpesa.PushRequest{
+		Reference:   "T12344C",
+		Amount:      mpesa.MustParseAmount("10"),
+		MSISDN:      "255744553111",
+		Description: "Donation",
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(response.ConversationID)
+}
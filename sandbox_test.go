@@ -0,0 +1,48 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestNewSandboxClient(t *testing.T) {
+	c, err := NewSandboxClient(GhanaMarket, "api-key", nil, WithDebugMode(false))
+	if err != nil {
+		t.Fatalf("NewSandboxClient() error = %v", err)
+	}
+	want, _ := DefaultEndpoints(GhanaMarket, SANDBOX)
+	if c.Conf.Platform != SANDBOX || c.Conf.Market != GhanaMarket || *c.Conf.Endpoints != *want ||
+		c.baseURL != "https://openapi.m-pesa.com/sandbox/ipg/v2/vodafoneGHA/" {
+		t.Errorf("Config = %+v, base URL %s, want the sandbox of Ghana", c.Conf, c.baseURL)
+	}
+	if key, err := parsePublicKey(SandboxPublicKey); err != nil || key.N.BitLen() != 4096 {
+		t.Errorf("SandboxPublicKey does not parse as a 4096 bit RSA key: %v", err)
+	}
+
+	var confErr *ConfigError
+	if _, err := NewSandboxClient(TanzaniaMarket, "", nil); !errors.As(err, &confErr) {
+		t.Errorf("NewSandboxClient() without an API key error = %v, want a *ConfigError", err)
+	}
+}
+
+func TestNewSandboxClientOverrides(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusCreated, PushAsyncResponse{ResponseCode: "INS-0", ConversationID: "conversation"})
+	}
+
+	c, err := NewSandboxClient(TanzaniaMarket, "api-key", nil, WithDebugMode(false), WithHTTPClient(g.Client()),
+		WithBaseURL(g.URL+"/sandbox/ipg/v2/vodacomTZN/"), WithRSAPublicKey(&g.key.PublicKey))
+	if err != nil {
+		t.Fatalf("NewSandboxClient() error = %v", err)
+	}
+
+	request := testRequest("").PushRequest()
+	request.ServiceProviderCode = ""
+	response, err := c.PushAsync(context.Background(), request)
+	if err != nil || response.ConversationID != "conversation" {
+		t.Errorf("PushAsync() = %+v, %v, want the push sent to the overridden gateway", response, err)
+	}
+}
@@ -0,0 +1,313 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// The defaults of ScheduleOptions.
+const (
+	DefaultSchedulePollInterval = time.Second
+	DefaultScheduleMaxAttempts  = 5
+	DefaultScheduleRetryDelay   = 30 * time.Second
+)
+
+var _ ScheduleQueue = (*MemoryScheduleQueue)(nil)
+
+var (
+	// ErrNoScheduler is returned by the scheduling methods of a Client
+	// created without WithScheduler.
+	ErrNoScheduler = errors.New("mpesa: no scheduler, see WithScheduler")
+
+	// ErrScheduleNotFound is returned by CancelScheduled for an id that is
+	// not pending, it was never scheduled, was canceled or already ran.
+	ErrScheduleNotFound = errors.New("mpesa: scheduled disbursement not found")
+)
+
+type (
+	// ScheduleID identifies a scheduled disbursement.
+	ScheduleID string
+
+	// ScheduledDisbursement is a disbursement waiting in a ScheduleQueue to
+	// be sent at At. Attempts is the number of times it was sent and failed
+	// with an error worth another attempt.
+	ScheduledDisbursement struct {
+		ID       ScheduleID      `json:"id"`
+		Request  DisburseRequest `json:"request"`
+		At       time.Time       `json:"at"`
+		Attempts int             `json:"attempts,omitempty"`
+	}
+
+	// ScheduleQueue holds the scheduled disbursements until they are sent,
+	// e.g. in a database table so that they survive a restart. The
+	// implementations must be safe for concurrent use.
+	ScheduleQueue interface {
+		// Add stores item, replacing the item with the same ID if any.
+		Add(ctx context.Context, item ScheduledDisbursement) error
+
+		// Remove deletes the item with id and reports whether there was
+		// one. The runner removes an item before sending it, so that only
+		// one runner sends it when several share the queue.
+		Remove(ctx context.Context, id ScheduleID) (bool, error)
+
+		// Pending returns the items ordered by At.
+		Pending(ctx context.Context) ([]ScheduledDisbursement, error)
+	}
+
+	// ScheduleOptions configures the runner started by WithScheduler.
+	//
+	// PollInterval is how often the queue is checked for due items,
+	// DefaultSchedulePollInterval when zero. A disbursement failing with an
+	// error that proves it was not carried out, e.g. an open circuit, a
+	// throttled request or a temporary overload of the gateway, is sent
+	// again RetryDelay later, doubling after every attempt, at most
+	// MaxAttempts times. They default to DefaultScheduleRetryDelay and
+	// DefaultScheduleMaxAttempts.
+	//
+	// OnResult, when set, is called with the outcome of every disbursement
+	// that is not sent again. The failures are written to the logger
+	// otherwise.
+	ScheduleOptions struct {
+		PollInterval time.Duration
+		MaxAttempts  int
+		RetryDelay   time.Duration
+		OnResult     func(item ScheduledDisbursement, response DisburseResponse, err error)
+	}
+)
+
+type scheduler struct {
+	queue   ScheduleQueue
+	options ScheduleOptions
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// WithScheduler turns on ScheduleDisburse and starts a goroutine sending the
+// scheduled disbursements through Disburse once they are due. queue holds the
+// items, a MemoryScheduleQueue when nil. Close stops the goroutine after the
+// disbursement it is sending, the items not sent yet are left in queue.
+func WithScheduler(queue ScheduleQueue, options ScheduleOptions) ClientOption {
+	return func(client *Client) {
+		if queue == nil {
+			queue = NewMemoryScheduleQueue()
+		}
+		if options.PollInterval <= 0 {
+			options.PollInterval = DefaultSchedulePollInterval
+		}
+		if options.MaxAttempts <= 0 {
+			options.MaxAttempts = DefaultScheduleMaxAttempts
+		}
+		if options.RetryDelay <= 0 {
+			options.RetryDelay = DefaultScheduleRetryDelay
+		}
+
+		client.scheduler = &scheduler{queue: queue, options: options}
+	}
+}
+
+// ScheduleDisburse queues request to be sent by Disburse at at, or as soon as
+// possible when at is in the past. The ThirdPartyID is generated now when
+// request has none, so that the attempts of the disbursement share it, and the
+// request is validated like Disburse does.
+func (c *Client) ScheduleDisburse(ctx context.Context, request DisburseRequest, at time.Time) (ScheduleID, error) {
+	if c.scheduler == nil {
+		return "", ErrNoScheduler
+	}
+
+	var err error
+	request.ThirdPartyID, err = c.conversationID(request.ThirdPartyID)
+	if err != nil {
+		return "", err
+	}
+	if _, err := c.requestAdapter.adaptDisburse(request); err != nil {
+		return "", err
+	}
+
+	id, err := newConversationID()
+	if err != nil {
+		return "", err
+	}
+	item := ScheduledDisbursement{ID: ScheduleID(id), Request: request, At: at}
+	if err := c.scheduler.queue.Add(ctx, item); err != nil {
+		return "", fmt.Errorf("mpesa: schedule disbursement: %w", err)
+	}
+
+	return item.ID, nil
+}
+
+// CancelScheduled removes the scheduled disbursement id from the queue. It
+// returns ErrScheduleNotFound when the disbursement is not pending anymore.
+func (c *Client) CancelScheduled(ctx context.Context, id ScheduleID) error {
+	if c.scheduler == nil {
+		return ErrNoScheduler
+	}
+
+	removed, err := c.scheduler.queue.Remove(ctx, id)
+	if err != nil {
+		return fmt.Errorf("mpesa: cancel scheduled disbursement: %w", err)
+	}
+	if !removed {
+		return ErrScheduleNotFound
+	}
+
+	return nil
+}
+
+// PendingDisbursements returns the scheduled disbursements not sent yet,
+// ordered by the time they are due.
+func (c *Client) PendingDisbursements(ctx context.Context) ([]ScheduledDisbursement, error) {
+	if c.scheduler == nil {
+		return nil, ErrNoScheduler
+	}
+
+	return c.scheduler.queue.Pending(ctx)
+}
+
+func (c *Client) startScheduler() {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.scheduler.cancel = cancel
+	c.scheduler.done = make(chan struct{})
+
+	go c.runScheduler(ctx)
+}
+
+func (c *Client) runScheduler(ctx context.Context) {
+	defer close(c.scheduler.done)
+
+	for {
+		c.sendDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.clock.After(c.scheduler.options.PollInterval):
+		}
+	}
+}
+
+// sendDue sends the items of the queue that are due, stopping early when ctx
+// is done.
+func (c *Client) sendDue(ctx context.Context) {
+	items, err := c.scheduler.queue.Pending(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			c.logf("scheduler: could not list the pending disbursements: %v", err)
+		}
+		return
+	}
+
+	for _, item := range items {
+		if ctx.Err() != nil || item.At.After(c.clock.Now()) {
+			return
+		}
+		c.sendScheduled(item)
+	}
+}
+
+// sendScheduled sends item unless it was canceled, queueing it again when the
+// failure is worth another attempt. The disbursement is not tied to the
+// context of the runner, so that stopping the runner does not abandon it
+// half-way.
+func (c *Client) sendScheduled(item ScheduledDisbursement) {
+	s := c.scheduler
+	ctx := context.Background()
+
+	removed, err := s.queue.Remove(ctx, item.ID)
+	if err != nil {
+		c.logf("scheduler: could not take %s from the queue: %v", item.ID, err)
+		return
+	}
+	if !removed {
+		return
+	}
+
+	response, err := c.Disburse(ctx, item.Request)
+	item.Attempts++
+	if err != nil && resendable(err) && item.Attempts < s.options.MaxAttempts {
+		item.At = c.clock.Now().Add(s.options.RetryDelay << (item.Attempts - 1))
+		aerr := s.queue.Add(ctx, item)
+		if aerr == nil {
+			c.debugf("scheduler: attempt %d of %s failed, retrying at %s: %v", item.Attempts, item.ID, item.At, err)
+			return
+		}
+		c.logf("scheduler: could not queue %s again: %v", item.ID, aerr)
+	}
+
+	switch {
+	case s.options.OnResult != nil:
+		s.options.OnResult(item, response, err)
+	case err != nil:
+		c.logf("scheduler: disbursement %s failed after %d attempts: %v", item.ID, item.Attempts, err)
+	}
+}
+
+// resendable reports whether err proves that a disbursement was not carried out
+// for a reason that may go away, so that sending it again can not pay the
+// customer twice.
+func resendable(err error) bool {
+	if errors.Is(err, ErrCircuitOpen) || (notSent(err) && DefaultRetryable(err)) {
+		return true
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	return (apiErr.Operation == sessionID.Name() && DefaultRetryable(err)) ||
+		apiErr.StatusCode == http.StatusTooManyRequests || apiErr.Code == "INS-16"
+}
+
+// MemoryScheduleQueue is an in-memory ScheduleQueue. Its items are lost when
+// the process exits.
+type MemoryScheduleQueue struct {
+	mu    sync.Mutex
+	items map[ScheduleID]ScheduledDisbursement
+}
+
+// NewMemoryScheduleQueue returns an empty MemoryScheduleQueue.
+func NewMemoryScheduleQueue() *MemoryScheduleQueue {
+	return &MemoryScheduleQueue{items: make(map[ScheduleID]ScheduledDisbursement)}
+}
+
+func (q *MemoryScheduleQueue) Add(_ context.Context, item ScheduledDisbursement) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.items[item.ID] = item
+
+	return nil
+}
+
+func (q *MemoryScheduleQueue) Remove(_ context.Context, id ScheduleID) (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	_, ok := q.items[id]
+	delete(q.items, id)
+
+	return ok, nil
+}
+
+func (q *MemoryScheduleQueue) Pending(_ context.Context) ([]ScheduledDisbursement, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	items := make([]ScheduledDisbursement, 0, len(q.items))
+	for _, item := range q.items {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if !items[i].At.Equal(items[j].At) {
+			return items[i].At.Before(items[j].At)
+		}
+		return items[i].ID < items[j].ID
+	})
+
+	return items, nil
+}
@@ -0,0 +1,185 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type scheduleResult struct {
+	item     ScheduledDisbursement
+	response DisburseResponse
+	err      error
+}
+
+func newScheduleTest(t *testing.T, queue ScheduleQueue, handler http.HandlerFunc) (*Client, *fakeClock, chan scheduleResult) {
+	t.Helper()
+
+	g := newTestGateway(t)
+	g.handlers["b2cPayment/"] = handler
+	fc := newFakeClock()
+	results := make(chan scheduleResult, 10)
+	c := g.client(WithClock(fc), WithScheduler(queue, ScheduleOptions{
+		PollInterval: time.Second,
+		MaxAttempts:  3,
+		RetryDelay:   time.Minute,
+		OnResult: func(item ScheduledDisbursement, response DisburseResponse, err error) {
+			results <- scheduleResult{item, response, err}
+		},
+	}))
+	t.Cleanup(func() { _ = c.Close() })
+
+	// the runner checked the empty queue and waits for the next poll
+	fc.waitForTimers(t, 1)
+
+	return c, fc, results
+}
+
+func waitForResult(t *testing.T, results chan scheduleResult) scheduleResult {
+	t.Helper()
+
+	select {
+	case result := <-results:
+		return result
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for a scheduled disbursement")
+		return scheduleResult{}
+	}
+}
+
+func TestScheduleDisburse(t *testing.T) {
+	var sent int32
+	c, fc, results := newScheduleTest(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&sent, 1)
+		writeJSON(w, http.StatusCreated, DisburseResponse{ResponseCode: "INS-0", ConversationID: "conversation"})
+	})
+	ctx := context.Background()
+
+	later, err := c.ScheduleDisburse(ctx, testRequest("").DisburseRequest(), fc.Now().Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("ScheduleDisburse() error = %v", err)
+	}
+	first, err := c.ScheduleDisburse(ctx, testRequest("").DisburseRequest(), fc.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("ScheduleDisburse() error = %v", err)
+	}
+	canceled, err := c.ScheduleDisburse(ctx, testRequest("").DisburseRequest(), fc.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("ScheduleDisburse() error = %v", err)
+	}
+
+	pending, err := c.PendingDisbursements(ctx)
+	if err != nil || len(pending) != 3 || pending[2].ID != later || pending[0].Request.ThirdPartyID == "" {
+		t.Fatalf("PendingDisbursements() = %+v, %v, want 3 items due last", pending, err)
+	}
+
+	if err := c.CancelScheduled(ctx, canceled); err != nil {
+		t.Fatalf("CancelScheduled() error = %v", err)
+	}
+	if err := c.CancelScheduled(ctx, canceled); !errors.Is(err, ErrScheduleNotFound) {
+		t.Errorf("second CancelScheduled() error = %v, want ErrScheduleNotFound", err)
+	}
+
+	fc.Advance(time.Hour)
+	result := waitForResult(t, results)
+	if result.item.ID != first || result.err != nil || result.response.ConversationID != "conversation" {
+		t.Errorf("result = %+v, want %s sent", result, first)
+	}
+	fc.waitForTimers(t, 1)
+	if n := atomic.LoadInt32(&sent); n != 1 {
+		t.Errorf("disbursements sent = %d, want the due one only", n)
+	}
+
+	pending, _ = c.PendingDisbursements(ctx)
+	if len(pending) != 1 || pending[0].ID != later {
+		t.Errorf("PendingDisbursements() = %+v, want %s left", pending, later)
+	}
+}
+
+func TestScheduleDisburseRetries(t *testing.T) {
+	var sent int32
+	c, fc, results := newScheduleTest(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&sent, 1) {
+		case 1:
+			writeJSON(w, http.StatusTooManyRequests, DisburseResponse{})
+		case 2:
+			writeJSON(w, http.StatusCreated, DisburseResponse{ResponseCode: "INS-0"})
+		default:
+			writeJSON(w, http.StatusServiceUnavailable, DisburseResponse{ResponseCode: "INS-1", ResponseDesc: "Internal Error"})
+		}
+	})
+	ctx := context.Background()
+
+	request := testRequest("").DisburseRequest()
+	id, err := c.ScheduleDisburse(ctx, request, fc.Now())
+	if err != nil {
+		t.Fatalf("ScheduleDisburse() error = %v", err)
+	}
+
+	// the throttled attempt is queued again a minute later
+	fc.Advance(time.Second)
+	fc.waitForTimers(t, 1)
+	pending, _ := c.PendingDisbursements(ctx)
+	if len(pending) != 1 || pending[0].ID != id || pending[0].Attempts != 1 || !pending[0].At.Equal(fc.Now().Add(time.Minute)) {
+		t.Fatalf("PendingDisbursements() = %+v, want the throttled item due in a minute", pending)
+	}
+
+	fc.Advance(time.Minute)
+	result := waitForResult(t, results)
+	if result.item.ID != id || result.err != nil || result.item.Attempts != 2 {
+		t.Errorf("result = %+v, want the second attempt to succeed", result)
+	}
+
+	// an internal error may have paid the customer, it is not sent again
+	if _, err := c.ScheduleDisburse(ctx, request, fc.Now()); err != nil {
+		t.Fatalf("ScheduleDisburse() error = %v", err)
+	}
+	fc.waitForTimers(t, 1)
+	fc.Advance(time.Second)
+	result = waitForResult(t, results)
+	var apiErr *APIError
+	if !errors.As(result.err, &apiErr) || result.item.Attempts != 1 {
+		t.Errorf("result = %+v, want the internal error reported after one attempt", result)
+	}
+}
+
+func TestScheduleDisburseClose(t *testing.T) {
+	queue := NewMemoryScheduleQueue()
+	c, fc, _ := newScheduleTest(t, queue, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusCreated, DisburseResponse{ResponseCode: "INS-0"})
+	})
+
+	id, err := c.ScheduleDisburse(context.Background(), testRequest("").DisburseRequest(), fc.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("ScheduleDisburse() error = %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	pending, err := queue.Pending(context.Background())
+	if err != nil || len(pending) != 1 || pending[0].ID != id {
+		t.Errorf("Pending() after Close = %+v, %v, want %s kept", pending, err, id)
+	}
+}
+
+func TestScheduleDisburseErrors(t *testing.T) {
+	g := newTestGateway(t)
+	ctx := context.Background()
+
+	if _, err := g.client().ScheduleDisburse(ctx, testRequest("").DisburseRequest(), time.Now()); !errors.Is(err, ErrNoScheduler) {
+		t.Errorf("ScheduleDisburse() without a scheduler error = %v, want ErrNoScheduler", err)
+	}
+
+	c := g.client(WithScheduler(nil, ScheduleOptions{}))
+	defer c.Close()
+	request := testRequest("").DisburseRequest()
+	request.Amount = Amount{}
+	var validationErr *ValidationError
+	if _, err := c.ScheduleDisburse(ctx, request, time.Now().Add(time.Hour)); !errors.As(err, &validationErr) {
+		t.Errorf("ScheduleDisburse() of an invalid request error = %v, want a *ValidationError", err)
+	}
+}
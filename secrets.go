@@ -0,0 +1,139 @@
+package mpesa
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SecretsProvider supplies the credentials of the client, e.g. from a vault,
+// instead of Config.APIKey and Config.PublicKey. The public key is in any of
+// the formats accepted for Config.PublicKey.
+type SecretsProvider interface {
+	APIKey(ctx context.Context) (string, error)
+	PublicKey(ctx context.Context) (string, error)
+}
+
+// SecretsError is returned when the SecretsProvider fails to supply a secret
+// or supplies an invalid one. Secret names it, "API key" or "public key".
+type SecretsError struct {
+	Secret string
+	Err    error
+}
+
+func (e *SecretsError) Error() string {
+	return fmt.Sprintf("could not get the %s from the secrets provider: %v", e.Secret, e.Err)
+}
+
+func (e *SecretsError) Unwrap() error {
+	return e.Err
+}
+
+// StaticSecrets returns a SecretsProvider of fixed credentials, the one used
+// with Config.APIKey and Config.PublicKey when WithSecretsProvider is not set.
+func StaticSecrets(apiKey, publicKey string) SecretsProvider {
+	return staticSecrets{apiKey: apiKey, publicKey: publicKey}
+}
+
+type staticSecrets struct {
+	apiKey    string
+	publicKey string
+}
+
+func (s staticSecrets) APIKey(context.Context) (string, error) {
+	return s.apiKey, nil
+}
+
+func (s staticSecrets) PublicKey(context.Context) (string, error) {
+	return s.publicKey, nil
+}
+
+// WithSecretsProvider makes the client get its credentials from provider when
+// it first needs them, Config.APIKey and Config.PublicKey may then be left
+// empty. A public key set with WithRSAPublicKey is still preferred.
+//
+// The credentials are cached for ttl, forever when ttl is zero, and fetched
+// again once the gateway rejects them when the session is fetched. A failure
+// of provider is returned by SessionID, and the calls fetching a session, as a
+// *SecretsError.
+func WithSecretsProvider(provider SecretsProvider, ttl time.Duration) ClientOption {
+	return func(client *Client) {
+		if provider == nil {
+			client.optionErrs = append(client.optionErrs, "secrets provider is nil")
+			return
+		}
+		if ttl < 0 {
+			client.optionErrs = append(client.optionErrs, fmt.Sprintf("secrets ttl must not be negative, got %s", ttl))
+			return
+		}
+		client.secretsProvider = provider
+		client.secretsTTL = ttl
+	}
+}
+
+// cachedSecrets holds the credentials fetched from the SecretsProvider.
+type cachedSecrets struct {
+	apiKey    string
+	publicKey *rsa.PublicKey
+	expires   time.Time
+}
+
+// providedSecrets returns the credentials of the SecretsProvider, from the
+// cache while they have not expired.
+func (c *Client) providedSecrets(ctx context.Context) (*cachedSecrets, error) {
+	c.sessionMu.RLock()
+	cached := c.secretsCache
+	c.sessionMu.RUnlock()
+	if cached != nil && (cached.expires.IsZero() || c.clock.Now().Before(cached.expires)) {
+		return cached, nil
+	}
+
+	apiKey, err := c.secretsProvider.APIKey(ctx)
+	if err == nil && apiKey == "" {
+		err = errors.New("empty API key")
+	}
+	if err != nil {
+		return nil, &SecretsError{Secret: "API key", Err: err}
+	}
+
+	c.sessionMu.RLock()
+	fetched := &cachedSecrets{apiKey: apiKey, publicKey: c.publicKey}
+	c.sessionMu.RUnlock()
+	if fetched.publicKey == nil {
+		key, err := c.secretsProvider.PublicKey(ctx)
+		if err == nil {
+			fetched.publicKey, err = parsePublicKey(key)
+		}
+		if err != nil {
+			return nil, &SecretsError{Secret: "public key", Err: err}
+		}
+	}
+	if c.secretsTTL > 0 {
+		fetched.expires = c.clock.Now().Add(c.secretsTTL)
+	}
+
+	c.sessionMu.Lock()
+	c.secretsCache = fetched
+	c.sessionMu.Unlock()
+
+	return fetched, nil
+}
+
+// dropSecrets drops the cached credentials of the SecretsProvider after the
+// gateway rejected them, err is the error of the session fetch.
+func (c *Client) dropSecrets(err error) {
+	var apiErr *APIError
+	if c.secretsProvider == nil || !errors.As(err, &apiErr) {
+		return
+	}
+	if apiErr.StatusCode != http.StatusUnauthorized && !ResponseCode(apiErr.Code).IsAuthFailure() {
+		return
+	}
+
+	c.sessionMu.Lock()
+	c.secretsCache = nil
+	c.sessionMu.Unlock()
+}
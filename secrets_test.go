@@ -0,0 +1,157 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testSecrets is a SecretsProvider returning the next of keys on every call.
+type testSecrets struct {
+	mu        sync.Mutex
+	keys      []string
+	publicKey string
+	err       error
+	calls     int
+}
+
+func (s *testSecrets) APIKey(context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return "", s.err
+	}
+	key := s.keys[s.calls%len(s.keys)]
+	s.calls++
+
+	return key, nil
+}
+
+func (s *testSecrets) PublicKey(context.Context) (string, error) {
+	return s.publicKey, nil
+}
+
+func newSecretsClient(t *testing.T, g *testGateway, secrets SecretsProvider, ttl time.Duration) *Client {
+	t.Helper()
+
+	conf := g.config()
+	conf.APIKey, conf.PublicKey = "", ""
+	c, err := NewClient(conf, nil, WithDebugMode(false), WithHTTPClient(g.Client()), WithSecretsProvider(secrets, ttl))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	return c
+}
+
+func TestSecretsProvider(t *testing.T) {
+	g := newTestGateway(t)
+	var mu sync.Mutex
+	var keys []string
+	g.handlers["getSession/"] = func(w http.ResponseWriter, r *http.Request) {
+		key := g.session(t, r)
+		mu.Lock()
+		keys = append(keys, key)
+		mu.Unlock()
+		if key == "revoked-key" {
+			writeJSON(w, http.StatusUnauthorized, SessionResponse{Code: "INS-2", Description: "Invalid API Key"})
+			return
+		}
+		writeJSON(w, http.StatusOK, SessionResponse{Code: "INS-0", ID: "session-" + key})
+	}
+
+	secrets := &testSecrets{keys: []string{"revoked-key", "vault-key"}, publicKey: g.pubKey}
+	c := newSecretsClient(t, g, secrets, 0)
+
+	if _, err := c.SessionID(context.Background()); err == nil {
+		t.Fatalf("SessionID() error = nil, want the revoked key rejected")
+	}
+	for i := 0; i < 2; i++ {
+		response, err := c.SessionID(context.Background())
+		if err != nil {
+			t.Fatalf("SessionID() error = %v", err)
+		}
+		if response.ID != "session-vault-key" {
+			t.Errorf("SessionID() = %q, want session-vault-key", response.ID)
+		}
+	}
+
+	if want := "revoked-key vault-key vault-key"; strings.Join(keys, " ") != want {
+		t.Errorf("session keys = %v, want %s", keys, want)
+	}
+	if secrets.calls != 2 {
+		t.Errorf("provider calls = %d, want 2: the revoked key fetched again after the rejection only", secrets.calls)
+	}
+	if err := c.SetAPIKey("other-key"); err == nil {
+		t.Errorf("SetAPIKey() error = nil, want the provided credentials not rotated")
+	}
+}
+
+func TestSecretsProviderTTL(t *testing.T) {
+	g := newTestGateway(t)
+	secrets := &testSecrets{keys: []string{"vault-key"}, publicKey: g.pubKey}
+	c := newSecretsClient(t, g, secrets, time.Minute)
+	clk := newFakeClock()
+	c.clock = clk
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.SessionID(context.Background()); err != nil {
+			t.Fatalf("SessionID() error = %v", err)
+		}
+		clk.Advance(40 * time.Second)
+	}
+	if secrets.calls != 2 {
+		t.Errorf("provider calls = %d, want 2", secrets.calls)
+	}
+}
+
+func TestSecretsProviderErrors(t *testing.T) {
+	g := newTestGateway(t)
+	vaultDown := errors.New("vault is sealed")
+
+	tests := []struct {
+		name    string
+		secrets SecretsProvider
+		secret  string
+		wantErr error
+	}{
+		{name: "api key", secrets: &testSecrets{err: vaultDown}, secret: "API key", wantErr: vaultDown},
+		{name: "empty api key", secrets: StaticSecrets("", g.pubKey), secret: "API key"},
+		{name: "public key", secrets: StaticSecrets("vault-key", "not a key"), secret: "public key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newSecretsClient(t, g, tt.secrets, 0).SessionID(context.Background())
+
+			var secretsErr *SecretsError
+			if !errors.As(err, &secretsErr) || secretsErr.Secret != tt.secret {
+				t.Fatalf("SessionID() error = %v, want a *SecretsError for the %s", err, tt.secret)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("SessionID() error = %v, want it to wrap %v", err, tt.wantErr)
+			}
+		})
+	}
+	if n := g.sessions; n != 0 {
+		t.Errorf("sessions = %d, want 0", n)
+	}
+}
+
+func TestWithSecretsProviderInvalid(t *testing.T) {
+	g := newTestGateway(t)
+
+	for _, opt := range []ClientOption{WithSecretsProvider(nil, 0), WithSecretsProvider(StaticSecrets("k", g.pubKey), -time.Second)} {
+		conf := g.config()
+		conf.APIKey = ""
+		_, err := NewClient(conf, nil, WithDebugMode(false), opt)
+		if err == nil || !strings.Contains(err.Error(), "secrets") || !strings.Contains(err.Error(), "APIKey is required") {
+			t.Errorf("NewClient() error = %v, want the option and the missing key rejected", err)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package mpesa
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// DefaultDrainTimeout is how long Serve waits for the callbacks in progress,
+// and then for the queued asynchronous callbacks, after its context is done,
+// unless another period is set with WithDrainTimeout.
+const DefaultDrainTimeout = 30 * time.Second
+
+// The timeouts of the http.Server started by Serve. A callback handler may run
+// for up to callbackTimeout before the acknowledgement is written.
+const (
+	serveReadHeaderTimeout = 10 * time.Second
+	serveReadTimeout       = 30 * time.Second
+	serveWriteTimeout      = callbackTimeout + 10*time.Second
+	serveIdleTimeout       = 2 * time.Minute
+)
+
+type (
+	// ServeOption customizes the server started by Serve.
+	ServeOption func(options *serveOptions)
+
+	serveOptions struct {
+		prefix    string
+		certFile  string
+		keyFile   string
+		tlsConfig *tls.Config
+		drain     time.Duration
+		listener  net.Listener
+	}
+)
+
+// WithServePrefix mounts the callback endpoints under prefix, see
+// RegisterRoutes. They are mounted at the root by default, /push and
+// /disburse.
+func WithServePrefix(prefix string) ServeOption {
+	return func(options *serveOptions) {
+		options.prefix = prefix
+	}
+}
+
+// WithServeTLS serves HTTPS with the certificate and the private key read from
+// the PEM files certFile and keyFile.
+func WithServeTLS(certFile, keyFile string) ServeOption {
+	return func(options *serveOptions) {
+		options.certFile, options.keyFile = certFile, keyFile
+	}
+}
+
+// WithServeTLSConfig serves HTTPS with config, which must carry the
+// certificates unless WithServeTLS is given as well.
+func WithServeTLSConfig(config *tls.Config) ServeOption {
+	return func(options *serveOptions) {
+		options.tlsConfig = config
+	}
+}
+
+// WithDrainTimeout sets how long Serve waits for the callbacks in progress once
+// its context is done, and then for the queued asynchronous callbacks. It
+// defaults to DefaultDrainTimeout.
+func WithDrainTimeout(d time.Duration) ServeOption {
+	return func(options *serveOptions) {
+		if d > 0 {
+			options.drain = d
+		}
+	}
+}
+
+// WithServeListener serves on ln, e.g. a socket handed over by systemd, in
+// place of listening on the address given to Serve. Serve closes ln.
+func WithServeListener(ln net.Listener) ServeOption {
+	return func(options *serveOptions) {
+		options.listener = ln
+	}
+}
+
+// Serve listens on addr and serves the callback endpoints mounted by
+// RegisterRoutes until ctx is done, with timeouts suited to the gateway. It
+// serves HTTPS with WithServeTLS or WithServeTLSConfig.
+//
+// When ctx is done the server stops accepting connections and Serve waits, up
+// to the drain period set with WithDrainTimeout, for the callbacks in progress,
+// then closes the connections left. The Client is then shut down like with
+// Shutdown, waiting up to another drain period for the queued asynchronous
+// callbacks to be handled before Serve returns. It returns nil once drained,
+// or the error that stopped the server or the drain.
+//
+//	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+//	defer stop()
+//	if err := client.Serve(ctx, ":8443", mpesa.WithServeTLS("cert.pem", "key.pem")); err != nil {
+//		log.Fatal(err)
+//	}
+func (c *Client) Serve(ctx context.Context, addr string, opts ...ServeOption) error {
+	options := serveOptions{prefix: "/", drain: DefaultDrainTimeout}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	mux := http.NewServeMux()
+	c.RegisterRoutes(mux, options.prefix)
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		TLSConfig:         options.tlsConfig,
+		ReadHeaderTimeout: serveReadHeaderTimeout,
+		ReadTimeout:       serveReadTimeout,
+		WriteTimeout:      serveWriteTimeout,
+		IdleTimeout:       serveIdleTimeout,
+	}
+
+	ln := options.listener
+	if ln == nil {
+		var err error
+		if ln, err = net.Listen("tcp", addr); err != nil {
+			return fmt.Errorf("mpesa: serve callbacks: %w", err)
+		}
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		if options.tlsConfig != nil || options.certFile != "" {
+			served <- server.ServeTLS(ln, options.certFile, options.keyFile)
+			return
+		}
+		served <- server.Serve(ln)
+	}()
+
+	select {
+	case err := <-served:
+		return fmt.Errorf("mpesa: serve callbacks: %w", err)
+	case <-ctx.Done():
+	}
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), options.drain)
+	defer cancel()
+	serverErr := server.Shutdown(drainCtx)
+	if serverErr != nil {
+		// a connection still idle or in its handshake kept Shutdown waiting
+		_ = server.Close()
+	}
+
+	// the queued callbacks get a period of their own, whatever the server
+	// took to drain
+	poolCtx, poolCancel := context.WithTimeout(context.Background(), options.drain)
+	defer poolCancel()
+	clientErr := c.Shutdown(poolCtx)
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("mpesa: serve callbacks: %w", err)
+	}
+	if serverErr != nil {
+		return fmt.Errorf("mpesa: drain callbacks: %w", serverErr)
+	}
+	if clientErr != nil {
+		return fmt.Errorf("mpesa: drain callbacks: %w", clientErr)
+	}
+
+	return nil
+}
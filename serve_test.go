@@ -0,0 +1,127 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestServe(t *testing.T) {
+	g := newTestGateway(t)
+	release := make(chan struct{})
+	var handled int32
+	handler := PushCallbackFunc(func(request PushCallbackRequest) (PushCallbackResponse, error) {
+		<-release
+		atomic.AddInt32(&handled, 1)
+		return successAck(request), nil
+	})
+	c := g.client(WithCallbackHandler(handler), WithAsyncCallbacks(1, 2, nil))
+
+	// a throwaway TLS server provides a certificate and a client trusting it
+	tlsServer := httptest.NewTLSServer(http.NotFoundHandler())
+	tlsConfig, httpClient := tlsServer.TLS.Clone(), tlsServer.Client()
+	tlsServer.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	served := make(chan error, 1)
+	go func() {
+		served <- c.Serve(ctx, "", WithServeListener(ln), WithServePrefix("/callbacks/mpesa"),
+			WithServeTLSConfig(tlsConfig), WithDrainTimeout(5*time.Second))
+	}()
+
+	for _, txID := range []string{"tx-1", "tx-2"} {
+		body := `{"input_OriginalConversationID": "conv", "input_TransactionID": "` + txID + `"}`
+		res, err := httpClient.Post("https://"+ln.Addr().String()+"/callbacks/mpesa/push", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST error = %v", err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d, want the callback queued", res.StatusCode)
+		}
+	}
+
+	httpClient.CloseIdleConnections()
+	cancel()
+	select {
+	case err := <-served:
+		t.Fatalf("Serve() = %v before the queued callbacks were handled", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("Serve() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve() did not return after the drain")
+	}
+	if n := atomic.LoadInt32(&handled); n != 2 {
+		t.Errorf("handled = %d, want the queued callbacks drained", n)
+	}
+}
+
+func TestServeListenError(t *testing.T) {
+	g := newTestGateway(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer ln.Close()
+
+	err = g.client().Serve(context.Background(), ln.Addr().String())
+	if err == nil || !strings.Contains(err.Error(), "serve callbacks") {
+		t.Errorf("Serve() on a used address error = %v, want the listen error", err)
+	}
+}
+
+func TestServeDrainTimeout(t *testing.T) {
+	g := newTestGateway(t)
+	release := make(chan struct{})
+	defer close(release)
+	handler := PushCallbackFunc(func(request PushCallbackRequest) (PushCallbackResponse, error) {
+		<-release
+		return successAck(request), nil
+	})
+	c := g.client(WithCallbackHandler(handler), WithAsyncCallbacks(1, 1, nil))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- c.Serve(ctx, "", WithServeListener(ln), WithDrainTimeout(50*time.Millisecond))
+	}()
+
+	res, err := http.Post("http://"+ln.Addr().String()+"/push", "application/json", strings.NewReader(testCallbackBody))
+	if err != nil {
+		t.Fatalf("POST error = %v", err)
+	}
+	res.Body.Close()
+
+	cancel()
+	select {
+	case err := <-served:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Serve() error = %v, want the drain period exceeded", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve() did not give up after the drain period")
+	}
+}
@@ -2,24 +2,39 @@ package mpesa
 
 import (
 	"context"
+	"crypto/rsa"
+	"crypto/tls"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"sync"
 	"time"
 
 	"github.com/techcraftlabs/base"
 )
 
 var (
-	_ service = (*Client)(nil)
+	_ Service = (*Client)(nil)
 )
 
 type (
-	service interface {
+	// Service is the set of operations of Client. Depend on it rather than
+	// on *Client to swap in the mpesamock package in tests.
+	Service interface {
 		QueryTx(ctx context.Context, req QueryTxParams) (QueryTxResponse, error)
 		SessionID(ctx context.Context) (response SessionResponse, err error)
-		PushAsync(ctx context.Context, request Request) (PushAsyncResponse, error)
-		Disburse(ctx context.Context, request Request) (DisburseResponse, error)
+		PushAsync(ctx context.Context, request PushRequest) (PushAsyncResponse, error)
+		Disburse(ctx context.Context, request DisburseRequest) (DisburseResponse, error)
+		B2BPayment(ctx context.Context, request Request) (B2BResponse, error)
+		CreateDirectDebit(ctx context.Context, request DirectDebitCreateRequest) (DirectDebitCreateResponse, error)
+		DirectDebitPayment(ctx context.Context, request DirectDebitPaymentRequest) (DirectDebitPaymentResponse, error)
+		CancelDirectDebit(ctx context.Context, request DirectDebitCancelRequest) (DirectDebitCancelResponse, error)
+		QueryDirectDebit(ctx context.Context, params QueryDirectDebitParams) (QueryDirectDebitResponse, error)
+		QueryBeneficiaryName(ctx context.Context, msisdn string) (BeneficiaryNameResponse, error)
 		CallbackServeHTTP(w http.ResponseWriter, r *http.Request)
+		DisburseCallbackServeHTTP(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Config contains details initialize in mpesa portal
@@ -31,229 +46,799 @@ type (
 	//•	SessionLifetime – The session key has a finite lifetime of availability that can be configured. Once a session key has expired, the session is no longer usable, and the caller will need to authenticate again.
 	//•	TrustedSources – the originating caller can be limited to specific IP address(es) as an additional security measure.
 	//•	Products / Scope / Limits – the required API products for the application can be enabled and limits defined for each call.
+	//
+	// SessionLifetime takes precedence over SessionLifetimeMinutes, which is
+	// deprecated and only read when SessionLifetime is zero. When neither is set
+	// the lifetime is DefaultSessionLifetimeMinutes. JSON takes SessionLifetime
+	// as a number of nanoseconds, YAML as a duration such as "1h".
 	Config struct {
-		Endpoints              *Endpoints
-		Name                   string
-		Version                string
-		Description            string
-		BasePath               string
-		Market                 Market
-		Platform               Platform
-		APIKey                 string
-		PublicKey              string
-		SessionLifetimeMinutes int64
-		ServiceProvideCode     string
-		TrustedSources         []string
+		Endpoints              *Endpoints    `json:"endpoints,omitempty" yaml:"endpoints,omitempty"`
+		Name                   string        `json:"name,omitempty" yaml:"name,omitempty"`
+		Version                string        `json:"version,omitempty" yaml:"version,omitempty"`
+		Description            string        `json:"description,omitempty" yaml:"description,omitempty"`
+		BasePath               string        `json:"base_path" yaml:"base_path"`
+		Market                 Market        `json:"market" yaml:"market"`
+		Platform               Platform      `json:"platform" yaml:"platform"`
+		APIKey                 string        `json:"api_key" yaml:"api_key"`
+		PublicKey              string        `json:"public_key" yaml:"public_key"`
+		SessionLifetime        time.Duration `json:"session_lifetime,omitempty" yaml:"session_lifetime,omitempty"`
+		SessionLifetimeMinutes int64         `json:"session_lifetime_minutes,omitempty" yaml:"session_lifetime_minutes,omitempty"`
+		ServiceProvideCode     string        `json:"service_provider_code" yaml:"service_provider_code"`
+		TrustedSources         []string      `json:"trusted_sources,omitempty" yaml:"trusted_sources,omitempty"`
 	}
 
 	Endpoints struct {
-		AuthEndpoint     string
-		PushEndpoint     string
-		DisburseEndpoint string
-		QueryEndpoint    string
+		AuthEndpoint              string `json:"auth,omitempty" yaml:"auth,omitempty"`
+		PushEndpoint              string `json:"push,omitempty" yaml:"push,omitempty"`
+		DisburseEndpoint          string `json:"disburse,omitempty" yaml:"disburse,omitempty"`
+		QueryEndpoint             string `json:"query,omitempty" yaml:"query,omitempty"`
+		B2BEndpoint               string `json:"b2b,omitempty" yaml:"b2b,omitempty"`
+		DirectDebitCreateEndpoint string `json:"direct_debit_create,omitempty" yaml:"direct_debit_create,omitempty"`
+		DirectDebitPayEndpoint    string `json:"direct_debit_pay,omitempty" yaml:"direct_debit_pay,omitempty"`
+		DirectDebitCancelEndpoint string `json:"direct_debit_cancel,omitempty" yaml:"direct_debit_cancel,omitempty"`
+		DirectDebitQueryEndpoint  string `json:"direct_debit_query,omitempty" yaml:"direct_debit_query,omitempty"`
+		BeneficiaryNameEndpoint   string `json:"beneficiary_name,omitempty" yaml:"beneficiary_name,omitempty"`
 	}
 
 	Client struct {
-		Conf              *Config
-		base              *base.Client
-		encryptedAPIKey   *string
-		sessionID         *string
-		sessionExpiration time.Time
-		pushCallbackFunc  PushCallbackHandler
-		requestAdapter    *requestAdapter
-		rp                base.Replier
-		rv                base.Receiver
+		Conf                 *Config
+		baseURL              string
+		base                 *base.Client
+		encryptedAPIKey      *string
+		sessionMu            sync.RWMutex
+		refreshMu            sync.Mutex
+		sessionFlight        *sessionFlight
+		sessionID            *string
+		credentialsVersion   uint64
+		publicKey            *rsa.PublicKey
+		secretsProvider      SecretsProvider
+		secretsTTL           time.Duration
+		secretsCache         *cachedSecrets
+		sessionStore         SessionStore
+		idempotencyStore     IdempotencyStore
+		scheduler            *scheduler
+		sessionExpiration    time.Time
+		sessionLifetime      time.Duration
+		sessionMargin        time.Duration
+		refresher            *sessionRefresher
+		clock                Clock
+		closeOnce            sync.Once
+		noRedaction          bool
+		correlationHeader    string
+		callbackMetaHeaders  []string
+		gatewayHeaders       []string
+		location             *time.Location
+		userAgent            string
+		origin               string
+		defaultHeaders       map[string]string
+		userAgentSuffix      string
+		correlationID        CorrelationIDFunc
+		records              recordLogger
+		optionErrs           []string
+		trustedNets          []*net.IPNet
+		noSourceCheck        bool
+		callbackAuth         CallbackVerifier
+		callbackBodyLimit    int64
+		callbackDedup        *callbackDedup
+		dedupKey             DedupKeyFunc
+		callbackPool         *callbackPool
+		callbackFeed         *callbackFeed
+		callbackRegistry     *callbackRegistry
+		retryPolicy          *RetryPolicy
+		onSessionRejected    func(operation string)
+		breaker              *circuitBreaker
+		health               healthTracker
+		rateLimiter          *RateLimiter
+		authLimiter          *RateLimiter
+		roundTripper         http.RoundTripper
+		timeouts             TimeoutConfig
+		tracer               Tracer
+		metrics              MetricsCollector
+		interceptors         []Interceptor
+		wireDump             io.Writer
+		dryRun               bool
+		noAutoConversationID bool
+		rawMSISDN            bool
+		responseBodyLimit    int
+		proxyURL             *url.URL
+		tlsConfig            *tls.Config
+		pushCallbackFunc     PushCallbackHandler
+		disburseCallbackFunc DisburseCallbackHandler
+		requestAdapter       *requestAdapter
+		rp                   base.Replier
+		rv                   base.Receiver
 	}
 )
 
-func (c *Client) QueryTx(ctx context.Context, req QueryTxParams) (QueryTxResponse, error) {
-	//TODO implement me
-	panic("implement me")
-}
-
-func NewClient(conf *Config, callbacker PushCallbackHandler, opts ...ClientOption) *Client {
+// NewClient creates a Client from conf and the options. The resulting Config is
+// validated and a *ConfigError listing every missing or invalid field is
+// returned when it is not usable.
+//
+// conf is copied, so the caller's Config is never modified and can be reused
+// to build more clients.
+func NewClient(conf *Config, callbacker PushCallbackHandler, opts ...ClientOption) (*Client, error) {
 	enc := new(string)
 	ses := new(string)
 
-	client := new(Client)
-
-	basePath := conf.BasePath
-
-	client = &Client{
-		Conf:              conf,
-		base:              base.NewClient(),
+	client := &Client{
+		Conf:              conf.clone(),
+		base:              base.NewClient(base.WithDebugMode(false)),
 		encryptedAPIKey:   enc,
 		sessionID:         ses,
-		sessionExpiration: time.Now(),
+		sessionMargin:     DefaultSessionRefreshMargin,
+		clock:             realClock{},
+		callbackBodyLimit: DefaultCallbackBodyLimit,
+		responseBodyLimit: DefaultResponseBodyLimit,
+		metrics:           NopMetrics{},
+		correlationHeader: DefaultCorrelationHeader,
+		gatewayHeaders:    defaultGatewayHeaders,
+		origin:            DefaultOrigin,
+		correlationID:     defaultCorrelationID,
 		pushCallbackFunc:  callbacker,
 	}
 
 	for _, opt := range opts {
 		opt(client)
 	}
+	client.configureTransport()
+
+	if client.userAgent == "" {
+		client.userAgent = defaultUserAgent(client.Conf)
+	}
+	if client.userAgentSuffix != "" {
+		client.userAgent += " " + client.userAgentSuffix
+	}
+
+	// an unsupported market or platform leaves Endpoints nil and is
+	// reported by validate
+	if client.Conf.Endpoints == nil {
+		client.Conf.Endpoints, _ = DefaultEndpoints(client.Conf.Market, client.Conf.Platform)
+	}
+
+	if err := client.validate(); err != nil {
+		return nil, err
+	}
+
+	if client.publicKey == nil && client.secretsProvider == nil {
+		// the key was checked by validate
+		client.publicKey, _ = parsePublicKey(client.Conf.PublicKey)
+	}
+
+	if client.callbackDedup != nil && client.dedupKey != nil {
+		client.callbackDedup.key = client.dedupKey
+	}
+
+	if !client.noSourceCheck {
+		// the entries were checked by validate
+		client.trustedNets, _ = parseTrustedSources(client.Conf.TrustedSources)
+	}
+
+	client.throttle()
+
+	if client.records != nil {
+		client.base.Logger = payloadWriter{records: client.records}
+	}
+	if !client.noRedaction {
+		client.base.Logger = &redactingWriter{w: client.base.Logger, secrets: client.secrets}
+	}
+
+	lifetime := client.Conf.sessionLifetime()
+	client.sessionLifetime = clampSessionLifetime(lifetime)
+	if client.sessionLifetime < lifetime {
+		client.logf("session lifetime of %s exceeds the maximum of %s, using %s",
+			lifetime, client.sessionLifetime, client.sessionLifetime)
+	}
 
 	platform := client.Conf.Platform
 	market := client.Conf.Market
 
 	platformStr, marketStr := platform.String(), market.URLContextValue()
-	p := fmt.Sprintf("https://%s/%s/ipg/v2/%s/", basePath, platformStr, marketStr)
-	client.Conf.BasePath = p
+	if client.baseURL == "" {
+		client.baseURL = fmt.Sprintf("https://%s/%s/ipg/v2/%s/", client.Conf.BasePath, platformStr, marketStr)
+	}
 	client.requestAdapter = &requestAdapter{
 		platform:            platform,
 		market:              market,
-		serviceProviderCode: conf.ServiceProvideCode,
+		serviceProviderCode: client.Conf.ServiceProvideCode,
+		rawMSISDN:           client.rawMSISDN,
+		location:            client.Location(),
 	}
 
 	rp := base.NewReplier(client.base.Logger, client.base.DebugMode)
 	rv := base.NewReceiver(client.base.Logger, client.base.DebugMode)
 	client.rp = rp
 	client.rv = rv
-	return client
+
+	if client.refresher != nil && !client.dryRun {
+		client.startSessionRefresh()
+	}
+
+	if client.scheduler != nil {
+		client.startScheduler()
+	}
+
+	if client.callbackRegistry != nil {
+		client.callbackRegistry.clock = client.clock
+	}
+
+	if client.callbackPool != nil {
+		if client.callbackPool.onError == nil {
+			client.callbackPool.onError = func(request PushCallbackRequest, err error) {
+				client.logf("push callback %s failed: %v", request.OriginalConversationID, err)
+			}
+		}
+		client.callbackPool.start(client.handleAsync)
+	}
+
+	return client, nil
+}
+
+// Close stops the background work started by the Client and waits for the
+// queued asynchronous callbacks to be handled. The scheduled disbursements
+// not sent yet stay in their queue. It is safe to call Close more than once.
+func (c *Client) Close() error {
+	return c.Shutdown(context.Background())
+}
+
+// Shutdown is like Close but gives up waiting for the queued asynchronous
+// callbacks when ctx is done, returning ctx.Err(). Callbacks arriving after
+// Shutdown are rejected with 503.
+func (c *Client) Shutdown(ctx context.Context) error {
+	c.closeOnce.Do(func() {
+		if c.refresher != nil && c.refresher.cancel != nil {
+			c.refresher.cancel()
+			<-c.refresher.done
+		}
+
+		if c.scheduler != nil {
+			c.scheduler.cancel()
+			<-c.scheduler.done
+		}
+
+		if c.callbackPool != nil {
+			c.callbackPool.close()
+		}
+
+		if c.callbackFeed != nil {
+			c.callbackFeed.close()
+		}
+	})
+
+	if c.callbackPool == nil {
+		return nil
+	}
+
+	select {
+	case <-c.callbackPool.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func (c *Client) SessionID(ctx context.Context) (response SessionResponse, err error) {
+	ctx, op := c.startOperation(ctx, sessionID)
+	defer func() { op.finish("", "", response.ResponseCode(), err) }()
 
-	token, err := c.getEncryptionKey()
+	ctx, cancel := c.withTimeout(ctx, sessionID)
+	defer cancel()
+
+	err = c.retry(ctx, func() error {
+		response, err = c.fetchSessionID(ctx)
+		return err
+	})
+
+	return response, err
+}
+
+// fetchSessionID is SessionID without the retries.
+func (c *Client) fetchSessionID(ctx context.Context) (response SessionResponse, err error) {
+	response, version, err := c.requestSession(ctx)
 	if err != nil {
+		c.dropSecrets(err)
 		return response, err
 	}
-	headers := map[string]string{
-		"Content-Type":  "application/json",
-		"Origin":        "*",
-		"Authorization": fmt.Sprintf("Bearer %s", token),
+
+	c.cacheSession(ctx, response.ID, version)
+
+	return response, nil
+}
+
+// requestSession asks the gateway for a new session id without caching it. It
+// also returns the version of the credentials the session was fetched with.
+func (c *Client) requestSession(ctx context.Context) (response SessionResponse, version uint64, err error) {
+	token, version, err := c.getEncryptionKey(ctx)
+	if err != nil {
+		return response, version, err
+	}
+	re, err := c.makeInternalRequest(ctx, sessionID, nil, token)
+	if err != nil {
+		return response, version, err
+	}
+	var res *base.Response
+	err = c.intercept(ctx, sessionID, nil, &response, func(ctx context.Context) error {
+		res, err = c.do(ctx, sessionID, re, &response)
+		return err
+	})
+	if err != nil {
+		return response, version, err
+	}
+
+	if err := checkResponse(ctx, sessionID.Name(), res, response.ResponseCode(), response.Description, response.OutputErr); err != nil {
+		return response, version, err
+	}
+
+	if res.Error != nil {
+		return SessionResponse{}, version, &APIError{
+			Operation:   sessionID.Name(),
+			Description: res.Error.Error(),
+			StatusCode:  res.StatusCode,
+		}
+	}
+
+	if response.ID == "" {
+		return response, version, &APIError{
+			Operation:   sessionID.Name(),
+			Code:        string(response.ResponseCode()),
+			Description: "the response carries no session id",
+			StatusCode:  res.StatusCode,
+		}
+	}
+
+	return response, version, nil
+}
+
+// cacheSession saves the session id fetched with the credentials of version. A
+// session of rotated credentials is not cached.
+func (c *Client) cacheSession(ctx context.Context, sessID string, version uint64) {
+	expiration := c.clock.Now().Add(c.sessionLifetime - c.sessionMargin)
+	c.sessionMu.Lock()
+	current := version == c.credentialsVersion
+	if current {
+		c.sessionExpiration = expiration
+		c.sessionID = &sessID
+	}
+	c.sessionMu.Unlock()
+	if current {
+		c.saveSession(ctx, sessID, expiration)
+		c.record(ctx, levelInfo, "session refreshed", "expires_at", expiration)
+	}
+}
+
+// PushAsync sends a USSD push asking the customer to pay request.Amount, the
+// result is delivered to CallbackServeHTTP. A ThirdPartyID is generated when
+// request has none, the response carries the one used.
+func (c *Client) PushAsync(ctx context.Context, request PushRequest) (response PushAsyncResponse, err error) {
+	ctx, op := c.startOperation(ctx, pushPay)
+	defer func() {
+		if response.ThirdPartyConversationID == "" {
+			response.ThirdPartyConversationID = request.ThirdPartyID
+		}
+		op.finish(response.ConversationID, response.ThirdPartyConversationID, response.Code(), err)
+	}()
+
+	ctx, cancel := c.withTimeout(ctx, pushPay)
+	defer cancel()
+
+	request.ThirdPartyID, err = c.conversationID(request.ThirdPartyID)
+	if err != nil {
+		return PushAsyncResponse{}, err
+	}
+	payload, err := c.requestAdapter.adaptPush(request)
+	if err != nil {
+		return PushAsyncResponse{}, err
 	}
 
-	var opts []base.RequestOption
-	headersOpt := base.WithRequestHeaders(headers)
-	opts = append(opts, headersOpt)
-	re := c.makeInternalRequest(sessionID, nil, opts...)
-	res, err := c.base.Do(ctx, re, &response)
+	c.callbackRegistry.register(request.ThirdPartyID)
+	res, err := c.send(ctx, pushPay, payload, &response)
 	if err != nil {
 		return response, err
 	}
+	c.debugf("%s: status=%d response=%+v", pushPay, res.StatusCode, response)
 
-	resErr := res.Error
-	if resErr != nil {
-		return SessionResponse{}, fmt.Errorf("could not fetch session id: %w", resErr)
+	if err := checkResponse(ctx, "c2b single stage", res, response.Code(), response.ResponseDesc, response.OutputErr); err != nil {
+		return response, err
 	}
 
-	//save the session id
-	if response.OutputErr != "" {
-		err1 := fmt.Errorf("could not fetch session id: %s", response.OutputErr)
-		return response, err1
+	return response, nil
+}
+
+// Disburse transfers request.Amount to the wallet of the customer. A
+// ThirdPartyID is generated when request has none, the response carries the
+// one used. A request with an IdempotencyKey is guarded by the store of
+// WithIdempotencyStore, if any.
+func (c *Client) Disburse(ctx context.Context, request DisburseRequest) (DisburseResponse, error) {
+	if c.idempotencyStore != nil && request.IdempotencyKey != "" {
+		return c.idempotentDisburse(ctx, request)
 	}
 
-	sessLifeTimeMin := c.Conf.SessionLifetimeMinutes
-	sessID := response.ID
-	up := time.Duration(sessLifeTimeMin) * time.Minute
-	expiration := time.Now().Add(up)
-	c.sessionExpiration = expiration
-	c.sessionID = &sessID
+	return c.disburse(ctx, request)
+}
+
+func (c *Client) disburse(ctx context.Context, request DisburseRequest) (response DisburseResponse, err error) {
+	ctx, op := c.startOperation(ctx, disburse)
+	defer func() {
+		if response.ThirdPartyConversationID == "" {
+			response.ThirdPartyConversationID = request.ThirdPartyID
+		}
+		op.finish(response.ConversationID, response.ThirdPartyConversationID, response.Code(), err)
+	}()
+
+	ctx, cancel := c.withTimeout(ctx, disburse)
+	defer cancel()
+
+	request.ThirdPartyID, err = c.conversationID(request.ThirdPartyID)
+	if err != nil {
+		return DisburseResponse{}, err
+	}
+	payload, err := c.requestAdapter.adaptDisburse(request)
+	if err != nil {
+		return DisburseResponse{}, err
+	}
+
+	res, err := c.send(ctx, disburse, payload, &response)
+	if err != nil {
+		return response, err
+	}
+	c.debugf("%s: status=%d response=%+v", disburse, res.StatusCode, response)
+
+	if err := checkResponse(ctx, "disburse", res, response.Code(), response.ResponseDesc, response.OutputErr); err != nil {
+		return response, err
+	}
 
 	return response, nil
 }
 
-func (c *Client) PushAsync(ctx context.Context, request Request) (response PushAsyncResponse, err error) {
-	sess, err := c.checkSessionID()
+// LegacyPushAsync is PushAsync taking a Request.
+//
+// Deprecated: use PushAsync with request.PushRequest(). LegacyPushAsync will be
+// removed in the next release.
+func (c *Client) LegacyPushAsync(ctx context.Context, request Request) (PushAsyncResponse, error) {
+	return c.PushAsync(ctx, request.PushRequest())
+}
+
+// LegacyDisburse is Disburse taking a Request.
+//
+// Deprecated: use Disburse with request.DisburseRequest(). LegacyDisburse will
+// be removed in the next release.
+func (c *Client) LegacyDisburse(ctx context.Context, request Request) (DisburseResponse, error) {
+	return c.Disburse(ctx, request.DisburseRequest())
+}
+
+// B2BPayment transfers funds from the business' wallet to the wallet of the business
+// identified by Request.ReceiverPartyCode.
+func (c *Client) B2BPayment(ctx context.Context, request Request) (response B2BResponse, err error) {
+	ctx, op := c.startOperation(ctx, b2bPay)
+	defer func() {
+		if response.ThirdPartyConversationID == "" {
+			response.ThirdPartyConversationID = request.ThirdPartyID
+		}
+		op.finish(response.ConversationID, response.ThirdPartyConversationID, response.Code(), err)
+	}()
+
+	ctx, cancel := c.withTimeout(ctx, b2bPay)
+	defer cancel()
+
+	request.ThirdPartyID, err = c.conversationID(request.ThirdPartyID)
 	if err != nil {
-		return response, err
+		return B2BResponse{}, err
 	}
-	token, err := encryptKey(sess, c.Conf.PublicKey)
+	payload, err := c.requestAdapter.adaptB2B(request)
+	if err != nil {
+		return B2BResponse{}, err
+	}
+
+	res, err := c.send(ctx, b2bPay, payload, &response)
 	if err != nil {
 		return response, err
 	}
 
-	headers := map[string]string{
-		"Content-Type":  "application/json",
-		"Origin":        "*",
-		"Authorization": fmt.Sprintf("Bearer %s", token),
+	if err := checkResponse(ctx, b2bPay.Name(), res, response.Code(), response.ResponseDesc, response.OutputErr); err != nil {
+		return response, err
 	}
 
-	payload, err := c.requestAdapter.adapt(pushPay, request)
+	return response, nil
+}
+
+// CreateDirectDebit creates a direct debit mandate that allows the organisation to
+// debit the customer's account at the agreed frequency. A ThirdPartyID is
+// generated when the request has none, the response carries the one used.
+func (c *Client) CreateDirectDebit(ctx context.Context, request DirectDebitCreateRequest) (response DirectDebitCreateResponse, err error) {
+	ctx, op := c.startOperation(ctx, directDebitCreate)
+	defer func() {
+		if response.ThirdPartyConversationID == "" {
+			response.ThirdPartyConversationID = request.ThirdPartyID
+		}
+		op.finish(response.ConversationID, response.ThirdPartyConversationID, response.Code(), err)
+	}()
+
+	ctx, cancel := c.withTimeout(ctx, directDebitCreate)
+	defer cancel()
+
+	request.ThirdPartyID, err = c.conversationID(request.ThirdPartyID)
 	if err != nil {
-		return PushAsyncResponse{}, err
+		return DirectDebitCreateResponse{}, err
+	}
+	payload, err := c.requestAdapter.adaptDirectDebitCreate(request)
+	if err != nil {
+		return DirectDebitCreateResponse{}, err
 	}
 
-	var opts []base.RequestOption
-	headersOpt := base.WithRequestHeaders(headers)
-	opts = append(opts, headersOpt)
-	re := c.makeInternalRequest(pushPay, payload, opts...)
-	res, err := c.base.Do(ctx, re, &response)
-
+	res, err := c.send(ctx, directDebitCreate, payload, &response)
 	if err != nil {
 		return response, err
 	}
-	fmt.Printf("pushpay response: %s: %v\n", pushPay.String(), res)
 
-	if response.OutputErr != "" {
-		err1 := fmt.Errorf("could not perform c2b single stage request: %s", response.OutputErr)
-		return response, err1
+	if err := checkResponse(ctx, directDebitCreate.Name(), res, response.Code(), response.ResponseDesc, response.OutputErr); err != nil {
+		return response, err
 	}
 
 	return response, nil
 }
 
-func (c *Client) Disburse(ctx context.Context, request Request) (response DisburseResponse, err error) {
-	sess, err := c.checkSessionID()
+// DirectDebitPayment charges the customer against an existing direct debit mandate.
+// A ThirdPartyID is generated when the request has none, the response carries
+// the one used.
+func (c *Client) DirectDebitPayment(ctx context.Context, request DirectDebitPaymentRequest) (response DirectDebitPaymentResponse, err error) {
+	ctx, op := c.startOperation(ctx, directDebitPay)
+	defer func() {
+		if response.ThirdPartyConversationID == "" {
+			response.ThirdPartyConversationID = request.ThirdPartyID
+		}
+		op.finish(response.ConversationID, response.ThirdPartyConversationID, response.Code(), err)
+	}()
+
+	ctx, cancel := c.withTimeout(ctx, directDebitPay)
+	defer cancel()
+
+	request.ThirdPartyID, err = c.conversationID(request.ThirdPartyID)
 	if err != nil {
+		return DirectDebitPaymentResponse{}, err
+	}
+	payload, err := c.requestAdapter.adaptDirectDebitPayment(request)
+	if err != nil {
+		return DirectDebitPaymentResponse{}, err
+	}
+
+	res, err := c.send(ctx, directDebitPay, payload, &response)
+	if err != nil {
+		return response, err
+	}
+
+	if err := checkResponse(ctx, directDebitPay.Name(), res, response.Code(), response.ResponseDesc, response.OutputErr); err != nil {
 		return response, err
 	}
-	token, err := encryptKey(sess, c.Conf.PublicKey)
+
+	return response, nil
+}
+
+// CancelDirectDebit cancels a direct debit mandate so that the customer is no longer charged.
+func (c *Client) CancelDirectDebit(ctx context.Context, request DirectDebitCancelRequest) (response DirectDebitCancelResponse, err error) {
+	ctx, op := c.startOperation(ctx, directDebitCancel)
+	defer func() {
+		op.finish(response.ConversationID, response.ThirdPartyConversationID, response.Code(), err)
+	}()
+
+	ctx, cancel := c.withTimeout(ctx, directDebitCancel)
+	defer cancel()
+
+	payload, err := c.requestAdapter.adaptDirectDebitCancel(request)
+	if err != nil {
+		return DirectDebitCancelResponse{}, err
+	}
+
+	res, err := c.send(ctx, directDebitCancel, payload, &response)
 	if err != nil {
 		return response, err
 	}
 
-	headers := map[string]string{
-		"Content-Type":  "application/json",
-		"Origin":        "*",
-		"Authorization": fmt.Sprintf("Bearer %s", token),
+	if err := checkResponse(ctx, directDebitCancel.Name(), res, response.Code(), response.ResponseDesc, response.OutputErr); err != nil {
+		return response, err
 	}
 
-	payload, err := c.requestAdapter.adapt(disburse, request)
+	return response, nil
+}
+
+func (c *Client) QueryTx(ctx context.Context, req QueryTxParams) (response QueryTxResponse, err error) {
+	ctx, op := c.startOperation(ctx, queryTxn)
+	defer func() {
+		op.finish(response.ConversationID, response.ThirdPartyConversationID, response.Code(), err)
+	}()
+
+	ctx, cancel := c.withTimeout(ctx, queryTxn)
+	defer cancel()
+
+	payload, err := c.requestAdapter.adaptQueryTx(req)
 	if err != nil {
-		return DisburseResponse{}, err
+		return QueryTxResponse{}, err
 	}
 
-	var opts []base.RequestOption
-	headersOpt := base.WithRequestHeaders(headers)
-	opts = append(opts, headersOpt)
-	re := c.makeInternalRequest(disburse, payload, opts...)
-	res, err := c.base.Do(ctx, re, &response)
+	err = c.retry(ctx, func() error {
+		response = QueryTxResponse{}
+		res, err := c.send(ctx, queryTxn, payload, &response)
+		if err != nil {
+			return err
+		}
 
+		return checkResponse(ctx, "query transaction status", res, response.Code(), response.ResponseDesc, response.OutputErr)
+	})
+
+	return response, err
+}
+
+// QueryDirectDebit returns the state of a direct debit mandate and the date of its next charge.
+func (c *Client) QueryDirectDebit(ctx context.Context, params QueryDirectDebitParams) (response QueryDirectDebitResponse, err error) {
+	ctx, op := c.startOperation(ctx, directDebitQuery)
+	defer func() {
+		op.finish(response.ConversationID, response.ThirdPartyConversationID, response.Code(), err)
+	}()
+
+	ctx, cancel := c.withTimeout(ctx, directDebitQuery)
+	defer cancel()
+
+	payload, err := c.requestAdapter.adaptDirectDebitQuery(params)
+	if err != nil {
+		return QueryDirectDebitResponse{}, err
+	}
+
+	res, err := c.send(ctx, directDebitQuery, payload, &response)
 	if err != nil {
 		return response, err
 	}
-	fmt.Printf("disburse response: %s: %v\n", disburse.String(), res)
 
-	if response.OutputErr != "" {
-		err1 := fmt.Errorf("could not perform disburse request: %s", response.OutputErr)
-		return response, err1
+	if err := checkResponse(ctx, directDebitQuery.Name(), res, response.Code(), response.ResponseDesc, response.OutputErr); err != nil {
+		return response, err
 	}
 
 	return response, nil
 }
 
-func (c *Client) CallbackServeHTTP(writer http.ResponseWriter, request *http.Request) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+// QueryBeneficiaryName returns the name registered to msisdn. It is meant to be
+// called before Disburse so that the recipient can be confirmed, disbursements
+// to a wrong MSISDN can not be undone.
+func (c *Client) QueryBeneficiaryName(ctx context.Context, msisdn string) (BeneficiaryNameResponse, error) {
+	return c.QueryBeneficiary(ctx, QueryBeneficiaryParams{MSISDN: msisdn})
+}
+
+// QueryBeneficiary is QueryBeneficiaryName with the parameters of the query,
+// e.g. to look the customer up for another ServiceProviderCode than the
+// configured one.
+func (c *Client) QueryBeneficiary(ctx context.Context, params QueryBeneficiaryParams) (response BeneficiaryNameResponse, err error) {
+	ctx, op := c.startOperation(ctx, beneficiaryName)
+	defer func() {
+		op.finish(response.ConversationID, response.ThirdPartyConversationID, response.Code(), err)
+	}()
+
+	ctx, cancel := c.withTimeout(ctx, beneficiaryName)
 	defer cancel()
-	body := new(PushCallbackRequest)
-	_, err := c.rv.Receive(ctx, "mpesa push callback", request, body)
 
+	payload, err := c.requestAdapter.adaptBeneficiaryName(params)
 	if err != nil {
-		http.Error(writer, err.Error(), http.StatusInternalServerError)
-		return
+		return BeneficiaryNameResponse{}, err
 	}
-	reqBody := *body
 
-	resp, err := c.pushCallbackFunc.HandleCallback(reqBody)
+	res, err := c.send(ctx, beneficiaryName, payload, &response)
 	if err != nil {
-		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return response, err
+	}
+
+	if err := checkResponse(ctx, beneficiaryName.Name(), res, response.Code(), response.ResponseDesc, response.OutputErr); err != nil {
+		return response, err
+	}
+
+	return response, nil
+}
+
+// CallbackServeHTTP receives the result of a push payment and hands it to the
+// PushCallbackHandler. See serveCallback for the checks done and the
+// acknowledgements sent back. With WithCallbackDedup a re-delivered callback is
+// acknowledged without calling the handler again, publishing it on Callbacks
+// or handing it to WaitForCallback.
+//
+// With WithAsyncCallbacks the callback is acknowledged as soon as it is decoded
+// and queued for the handler, or rejected with 503 when the queue is full.
+// With WithCallbackChannel the callback is also published on Callbacks and
+// with WithCallbackRegistry it is handed to WaitForCallback, a handler is not
+// required then.
+func (c *Client) CallbackServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	handler := c.pushCallbackFunc
+	if handler == nil && c.callbackFeed == nil && c.callbackRegistry == nil {
+		http.NotFound(writer, request)
+		return
+	}
+
+	body := new(PushCallbackRequest)
+	c.serveCallback(writer, request, "mpesa push callback", body, func(ctx context.Context) (interface{}, error) {
+		if !c.callbackFeed.reservePush() {
+			return nil, &callbackAckError{status: http.StatusServiceUnavailable, code: callbackQueueFull}
+		}
+		published := false
+		defer func() {
+			if !published {
+				c.callbackFeed.unreservePush()
+			}
+		}()
+
+		resp := successAck(*body)
+		replayed := false
+		switch {
+		case handler != nil && c.callbackPool != nil:
+			if resp, replayed = c.savedAck(ctx, *body); replayed {
+				break
+			}
+			resp = successAck(*body)
+			if !c.callbackPool.enqueue(ctx, *body) {
+				return nil, &callbackAckError{status: http.StatusServiceUnavailable, code: callbackQueueFull}
+			}
+		default:
+			var err error
+			resp, replayed, err = c.dedup(ctx, *body, func() (ack PushCallbackResponse, err error) {
+				if handler == nil {
+					return successAck(*body), nil
+				}
+				defer c.recoverCallback("push", &err)
+				if h, ok := handler.(PushCallbackContextHandler); ok {
+					return h.HandleCallbackContext(ctx, *body)
+				}
+
+				return handler.HandleCallback(*body)
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// a re-delivery was already handed over the first time
+		if !replayed {
+			c.callbackRegistry.deliver(*body)
+			c.callbackFeed.publishPush(*body)
+			published = true
+		}
+
+		return resp, nil
+	})
+}
+
+// DisburseCallbackServeHTTP receives the result of a disbursement and hands it
+// to the DisburseCallbackHandler set with WithDisburseCallbackHandler and to
+// DisburseCallbacks. It answers 404 when neither is set. The checks and acknowledgements are the
+// same as for CallbackServeHTTP.
+func (c *Client) DisburseCallbackServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	handler := c.disburseCallbackFunc
+	if handler == nil && c.callbackFeed == nil {
+		http.NotFound(writer, request)
 		return
 	}
 
-	hs := base.WithMoreResponseHeaders(map[string]string{
-		"Content-Type": "application/json",
+	body := new(DisburseCallbackRequest)
+	c.serveCallback(writer, request, "mpesa disburse callback", body, func(ctx context.Context) (interface{}, error) {
+		if msisdn, err := NormalizeMSISDN(c.Conf.Market, body.CustomerMSISDN); err == nil && !c.rawMSISDN {
+			body.CustomerMSISDN = msisdn
+		}
+		if !c.callbackFeed.reserveDisburse() {
+			return nil, &callbackAckError{status: http.StatusServiceUnavailable, code: callbackQueueFull}
+		}
+		published := false
+		defer func() {
+			if !published {
+				c.callbackFeed.unreserveDisburse()
+			}
+		}()
+
+		resp := DisburseCallbackResponse(successAck(PushCallbackRequest{
+			OriginalConversationID:   body.OriginalConversationID,
+			ThirdPartyConversationID: body.ThirdPartyConversationID,
+		}))
+		if handler != nil {
+			var err error
+			if resp, err = c.handleDisburseCallback(ctx, handler, *body); err != nil {
+				return nil, err
+			}
+		}
+
+		c.callbackFeed.publishDisburse(*body)
+		published = true
+
+		return resp, nil
 	})
-	response := base.NewResponse(200, resp, hs)
-	c.rp.Reply(writer, response)
 }
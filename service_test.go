@@ -0,0 +1,1136 @@
+package mpesa
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/techcraftlabs/base"
+)
+
+var testEndpoints = &Endpoints{ //nolint:gochecknoglobals
+	AuthEndpoint:              "/getSession/",
+	PushEndpoint:              "/c2bPayment/singleStage/",
+	DisburseEndpoint:          "/b2cPayment/",
+	QueryEndpoint:             "/queryTransactionStatus/",
+	B2BEndpoint:               "/b2bPayment/",
+	DirectDebitCreateEndpoint: "/directDebitCreation/",
+	DirectDebitPayEndpoint:    "/directDebitPayment/",
+	DirectDebitCancelEndpoint: "/directDebitCancel/",
+	DirectDebitQueryEndpoint:  "/queryDirectDebit/",
+	BeneficiaryNameEndpoint:   "/queryBeneficiaryName/",
+}
+
+// testGateway is a stub of the M-Pesa gateway. It issues numbered session ids
+// and hands every other request to the registered handler for its endpoint.
+type testGateway struct {
+	*httptest.Server
+	t        *testing.T
+	key      *rsa.PrivateKey
+	pubKey   string
+	sessions int32
+	handlers map[string]http.HandlerFunc
+}
+
+func newTestGateway(t *testing.T) *testGateway {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+
+	g := &testGateway{
+		t:        t,
+		key:      key,
+		pubKey:   base64.StdEncoding.EncodeToString(der),
+		handlers: map[string]http.HandlerFunc{},
+	}
+	g.handlers["getSession/"] = func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&g.sessions, 1)
+		writeJSON(w, http.StatusOK, SessionResponse{
+			Code:        "INS-0",
+			Description: "Request processed successfully",
+			ID:          fmt.Sprintf("session-%d", n),
+		})
+	}
+
+	g.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for endpoint, handler := range g.handlers {
+			if strings.HasSuffix(r.URL.Path, endpoint) {
+				handler(w, r)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(g.Close)
+
+	return g
+}
+
+// session decrypts the bearer token of r and returns the session id it holds.
+func (g *testGateway) session(t *testing.T, r *http.Request) string {
+	t.Helper()
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	encrypted, err := base64.StdEncoding.DecodeString(token)
+	if err != nil {
+		t.Errorf("decode bearer token: %v", err)
+		return ""
+	}
+	plain, err := rsa.DecryptPKCS1v15(rand.Reader, g.key, encrypted)
+	if err != nil {
+		t.Errorf("decrypt bearer token: %v", err)
+		return ""
+	}
+
+	return string(plain)
+}
+
+func (g *testGateway) config() *Config {
+	return &Config{
+		Endpoints:              testEndpoints,
+		Name:                   "test",
+		Version:                "1",
+		BasePath:               g.Listener.Addr().String(),
+		Market:                 TanzaniaMarket,
+		Platform:               SANDBOX,
+		APIKey:                 "api-key",
+		PublicKey:              g.pubKey,
+		SessionLifetimeMinutes: 60,
+		ServiceProvideCode:     "000000",
+	}
+}
+
+func (g *testGateway) client(opts ...ClientOption) *Client {
+	g.t.Helper()
+
+	opts = append([]ClientOption{WithDebugMode(false), WithHTTPClient(g.Client())}, opts...)
+	c, err := NewClient(g.config(), nil, opts...)
+	if err != nil {
+		g.t.Fatalf("NewClient() error = %v", err)
+	}
+
+	return c
+}
+
+// testRequest returns a valid Request for every operation.
+func testRequest(thirdPartyID string) Request {
+	return Request{
+		ThirdPartyID:      thirdPartyID,
+		Reference:         "T12344C",
+		Amount:            MustParseAmount("1000"),
+		MSISDN:            "255754000123",
+		Description:       "test",
+		ReceiverPartyCode: "000001",
+	}
+}
+
+// testMSISDN returns a mobile number of market.
+func testMSISDN(market Market) string {
+	switch market {
+	case GhanaMarket:
+		return "233241234567"
+	case DRCMarket:
+		return "243812345678"
+	case LesothoMarket:
+		return "26658123456"
+	case MozambiqueMarket:
+		return "258841234567"
+	case EgyptMarket:
+		return "201012345678"
+	default:
+		return "255754000123"
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestSessionLifetimeClamp(t *testing.T) {
+	tests := []struct {
+		name     string
+		minutes  int64
+		lifetime time.Duration
+		want     time.Duration
+		warn     bool
+	}{
+		{
+			name: "zero",
+			want: time.Duration(DefaultSessionLifetimeMinutes) * time.Minute,
+		},
+		{
+			name:    "within bounds",
+			minutes: 60,
+			want:    time.Hour,
+		},
+		{
+			name:     "duration within bounds",
+			lifetime: 90 * time.Minute,
+			want:     90 * time.Minute,
+		},
+		{
+			name:    "at maximum",
+			minutes: MaxSessionLifetimeMinutes,
+			want:    time.Duration(MaxSessionLifetimeMinutes) * time.Minute,
+		},
+		{
+			name:    "above maximum",
+			minutes: MaxSessionLifetimeMinutes * 10,
+			want:    time.Duration(MaxSessionLifetimeMinutes) * time.Minute,
+			warn:    true,
+		},
+		{
+			name:     "duration above maximum",
+			lifetime: 48 * time.Hour,
+			want:     time.Duration(MaxSessionLifetimeMinutes) * time.Minute,
+			warn:     true,
+		},
+	}
+
+	g := newTestGateway(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := new(bytes.Buffer)
+			conf := g.config()
+			conf.SessionLifetimeMinutes = tt.minutes
+			conf.SessionLifetime = tt.lifetime
+			c, err := NewClient(conf, nil, WithDebugMode(false), WithLogger(logs))
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+
+			if got := c.SessionLifetime(); got != tt.want {
+				t.Errorf("SessionLifetime() = %v, want %v", got, tt.want)
+			}
+			if warned := strings.Contains(logs.String(), "exceeds the maximum"); warned != tt.warn {
+				t.Errorf("warned = %v, want %v (logs: %q)", warned, tt.warn, logs.String())
+			}
+		})
+	}
+
+	for name, modify := range map[string]func(conf *Config){
+		"negative minutes":  func(conf *Config) { conf.SessionLifetimeMinutes = -5 },
+		"negative duration": func(conf *Config) { conf.SessionLifetime = -time.Minute },
+	} {
+		t.Run(name, func(t *testing.T) {
+			conf := g.config()
+			modify(conf)
+			var confErr *ConfigError
+			if _, err := NewClient(conf, nil); !errors.As(err, &confErr) {
+				t.Errorf("NewClient() error = %v, want a *ConfigError", err)
+			}
+		})
+	}
+}
+
+func TestEnsureSession(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusCreated, PushAsyncResponse{ResponseCode: "INS-0"})
+	}
+
+	c := g.client()
+	if c.HasValidSession() || !c.SessionExpiresAt().IsZero() {
+		t.Errorf("HasValidSession(), SessionExpiresAt() = %v, %s before the first session", c.HasValidSession(), c.SessionExpiresAt())
+	}
+
+	before := time.Now()
+	for i := 0; i < 2; i++ {
+		if err := c.EnsureSession(context.Background()); err != nil {
+			t.Fatalf("EnsureSession() error = %v", err)
+		}
+	}
+	if !c.HasValidSession() {
+		t.Errorf("HasValidSession() = false after EnsureSession")
+	}
+	lifetime := time.Hour - DefaultSessionRefreshMargin
+	if expires := c.SessionExpiresAt(); expires.Before(before.Add(lifetime)) || expires.After(time.Now().Add(lifetime)) {
+		t.Errorf("SessionExpiresAt() = %s, want in %s", expires, lifetime)
+	}
+
+	if _, err := c.PushAsync(context.Background(), testRequest("tp-1").PushRequest()); err != nil {
+		t.Fatalf("PushAsync() error = %v", err)
+	}
+	if n := atomic.LoadInt32(&g.sessions); n != 1 {
+		t.Errorf("sessions = %d, want the ensured session used", n)
+	}
+
+	g.handlers["getSession/"] = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusUnauthorized, SessionResponse{Code: "INS-2", Description: "Invalid API Key"})
+	}
+	if err := g.client().EnsureSession(context.Background()); err == nil {
+		t.Errorf("EnsureSession() error = nil, want the bad credentials reported")
+	}
+}
+
+func TestPing(t *testing.T) {
+	g := newTestGateway(t)
+	c := g.client()
+
+	for i := 0; i < 2; i++ {
+		if err := c.Ping(context.Background()); err != nil {
+			t.Fatalf("Ping() error = %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&g.sessions); n != 2 {
+		t.Errorf("sessions = %d, want one per Ping", n)
+	}
+	id, ok := c.validSession()
+	if !ok || id != "session-2" {
+		t.Errorf("validSession() = %q, %v, want the session of the last Ping", id, ok)
+	}
+	expires := c.SessionExpiresAt()
+
+	failures := map[string]http.HandlerFunc{
+		"rejected": func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, http.StatusUnauthorized, SessionResponse{Code: "INS-2", Description: "Invalid API Key"})
+		},
+		"no session id": func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, http.StatusOK, SessionResponse{Code: "INS-0", Description: "Request processed successfully"})
+		},
+	}
+	for name, handler := range failures {
+		t.Run(name, func(t *testing.T) {
+			g.handlers["getSession/"] = handler
+			err := c.Ping(context.Background())
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("Ping() error = %v, want an *APIError", err)
+			}
+			if got, ok := c.validSession(); !ok || got != id || !c.SessionExpiresAt().Equal(expires) {
+				t.Errorf("validSession() = %q, %v after a failed Ping, want %q kept", got, ok, id)
+			}
+		})
+	}
+
+	g.handlers["getSession/"] = func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.Ping(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Ping() error = %v, want the deadline exceeded", err)
+	}
+}
+
+func TestSessionRefreshMargin(t *testing.T) {
+	g := newTestGateway(t)
+	fc := newFakeClock()
+	conf := g.config()
+	conf.SessionLifetime = 10 * time.Minute
+	c, err := NewClient(conf, nil, WithHTTPClient(g.Client()), WithSessionRefreshMargin(time.Minute), WithClock(fc))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	if c.sessionLifetime != 10*time.Minute {
+		t.Errorf("sessionLifetime = %s, want SessionLifetime to take precedence over SessionLifetimeMinutes", c.sessionLifetime)
+	}
+
+	if err := c.EnsureSession(context.Background()); err != nil {
+		t.Fatalf("EnsureSession() error = %v", err)
+	}
+	if want := fc.Now().Add(9 * time.Minute); !c.SessionExpiresAt().Equal(want) {
+		t.Errorf("SessionExpiresAt() = %s, want %s", c.SessionExpiresAt(), want)
+	}
+
+	fc.Advance(9*time.Minute - time.Second)
+	if !c.HasValidSession() {
+		t.Errorf("HasValidSession() = false before the margin")
+	}
+	fc.Advance(time.Second)
+	if c.HasValidSession() {
+		t.Errorf("HasValidSession() = true within the margin")
+	}
+	if err := c.EnsureSession(context.Background()); err != nil {
+		t.Fatalf("EnsureSession() error = %v", err)
+	}
+	if n := atomic.LoadInt32(&g.sessions); n != 2 {
+		t.Errorf("sessions = %d, want the session replaced within the margin", n)
+	}
+}
+
+func TestWithClockSessionExpiry(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusCreated, PushAsyncResponse{ResponseCode: "INS-0"})
+	}
+	fc := newFakeClock()
+	c := g.client(WithClock(fc))
+
+	push := func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if _, err := c.PushAsync(context.Background(), testRequest(fmt.Sprintf("tp-%d", i)).PushRequest()); err != nil {
+					t.Errorf("PushAsync() error = %v", err)
+				}
+			}(i)
+		}
+		wg.Wait()
+	}
+
+	push()
+	fc.Advance(time.Hour - DefaultSessionRefreshMargin - time.Second)
+	push()
+	if n := atomic.LoadInt32(&g.sessions); n != 1 {
+		t.Fatalf("sessions = %d before the expiry, want 1", n)
+	}
+
+	fc.Advance(time.Second)
+	push()
+	if n := atomic.LoadInt32(&g.sessions); n != 2 {
+		t.Errorf("sessions = %d after the expiry, want exactly one re-authentication", n)
+	}
+
+	if _, err := NewClient(g.config(), nil, WithClock(nil)); err == nil || !strings.Contains(err.Error(), "clock is nil") {
+		t.Errorf("NewClient(WithClock(nil)) error = %v, want the nil clock reported", err)
+	}
+}
+
+func TestSessionRefreshMarginValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		lifetime time.Duration
+		margin   time.Duration
+		want     string
+	}{
+		{"negative margin", time.Hour, -time.Second, "session refresh margin must not be negative, got -1s"},
+		{"margin equals lifetime", time.Minute, time.Minute, "session lifetime of 1m0s must be longer than the session refresh margin of 1m0s"},
+		{"lifetime shorter than the default margin", 10 * time.Second, DefaultSessionRefreshMargin, "must be longer than the session refresh margin of 30s"},
+	}
+
+	g := newTestGateway(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := g.config()
+			conf.SessionLifetime = tt.lifetime
+			_, err := NewClient(conf, nil, WithHTTPClient(g.Client()), WithSessionRefreshMargin(tt.margin))
+
+			var confErr *ConfigError
+			if !errors.As(err, &confErr) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("NewClient() error = %v, want a *ConfigError containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPushAsyncRefreshesRejectedSession(t *testing.T) {
+	g := newTestGateway(t)
+	var pushes int32
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&pushes, 1)
+		if g.session(t, r) == "session-1" {
+			writeJSON(w, http.StatusUnauthorized, PushAsyncResponse{OutputErr: "Invalid session"})
+			return
+		}
+		writeJSON(w, http.StatusCreated, PushAsyncResponse{
+			ResponseCode:             "INS-0",
+			ResponseDesc:             "Request processed successfully",
+			ConversationID:           "conversation",
+			ThirdPartyConversationID: "third-party",
+		})
+	}
+
+	c := g.client()
+	response, err := c.PushAsync(context.Background(), PushRequest{
+		ThirdPartyID: "third-party",
+		Reference:    "T12344C",
+		Amount:       MustParseAmount("1000"),
+		MSISDN:       "255754000000",
+		Description:  "test",
+	})
+	if err != nil {
+		t.Fatalf("PushAsync() error = %v", err)
+	}
+
+	if response.ResponseCode != "INS-0" || response.OutputErr != "" {
+		t.Errorf("PushAsync() response = %+v", response)
+	}
+	if got := atomic.LoadInt32(&g.sessions); got != 2 {
+		t.Errorf("sessions fetched = %d, want 2", got)
+	}
+	if got := atomic.LoadInt32(&pushes); got != 2 {
+		t.Errorf("push requests = %d, want 2", got)
+	}
+}
+
+func TestAuthFailureCodesNotRetried(t *testing.T) {
+	for _, code := range []ResponseCode{"INS-2", "INS-4", "INS-2001"} {
+		t.Run(string(code), func(t *testing.T) {
+			g := newTestGateway(t)
+			var pushes int32
+			g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&pushes, 1)
+				writeJSON(w, http.StatusBadRequest, PushAsyncResponse{ResponseCode: string(code)})
+			}
+
+			var rejected []string
+			c := g.client(WithSessionRejectedHook(func(operation string) {
+				rejected = append(rejected, operation)
+			}))
+			_, err := c.PushAsync(context.Background(), testRequest("tp-1").PushRequest())
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.Code != string(code) {
+				t.Fatalf("PushAsync() error = %v, want an *APIError with %s", err, code)
+			}
+
+			if got := atomic.LoadInt32(&pushes); got != 1 {
+				t.Errorf("pushes = %d, want the push not sent again", got)
+			}
+			if got := atomic.LoadInt32(&g.sessions); got != 1 {
+				t.Errorf("sessions fetched = %d, want the session kept", got)
+			}
+			if len(rejected) != 0 {
+				t.Errorf("rejected hook calls = %v, want none", rejected)
+			}
+		})
+	}
+}
+
+func TestMoreOperationsSessionRejected(t *testing.T) {
+	ctx := context.Background()
+	operations := []struct {
+		path string
+		call func(c *Client) error
+	}{
+		{"b2bPayment/", func(c *Client) error {
+			_, err := c.B2BPayment(ctx, Request{ThirdPartyID: "tp-1", Reference: "ref", Amount: MustParseAmount("1000"),
+				ReceiverPartyCode: "000001"})
+			return err
+		}},
+		{"directDebitCreation/", func(c *Client) error {
+			_, err := c.CreateDirectDebit(ctx, DirectDebitCreateRequest{MSISDN: "255754000000", Reference: "ref", ThirdPartyID: "tp-1"})
+			return err
+		}},
+		{"directDebitPayment/", func(c *Client) error {
+			_, err := c.DirectDebitPayment(ctx, DirectDebitPaymentRequest{MandateID: "mandate", ThirdPartyID: "tp-1",
+				Amount: MustParseAmount("10")})
+			return err
+		}},
+		{"directDebitCancel/", func(c *Client) error {
+			_, err := c.CancelDirectDebit(ctx, DirectDebitCancelRequest{AgreementID: "agreement", ThirdPartyID: "tp-1"})
+			return err
+		}},
+		{"queryDirectDebit/", func(c *Client) error {
+			_, err := c.QueryDirectDebit(ctx, QueryDirectDebitParams{AgreementID: "agreement"})
+			return err
+		}},
+		{"queryBeneficiaryName/", func(c *Client) error {
+			_, err := c.QueryBeneficiaryName(ctx, "255754000000")
+			return err
+		}},
+	}
+
+	for _, op := range operations {
+		t.Run(op.path, func(t *testing.T) {
+			g := newTestGateway(t)
+			var calls int32
+			var sessions []string
+			g.handlers[op.path] = func(w http.ResponseWriter, r *http.Request) {
+				sessions = append(sessions, g.session(t, r))
+				if atomic.AddInt32(&calls, 1) == 1 {
+					writeJSON(w, http.StatusUnauthorized, map[string]string{"output_error": "Invalid session"})
+					return
+				}
+				writeJSON(w, http.StatusOK, map[string]string{"output_ResponseCode": "INS-0"})
+			}
+
+			if err := op.call(g.client()); err != nil {
+				t.Fatalf("error = %v, want the call to succeed with a new session", err)
+			}
+			if len(sessions) != 2 || sessions[0] != "session-1" || sessions[1] != "session-2" {
+				t.Errorf("sessions used = %v, want session-1 then session-2", sessions)
+			}
+		})
+	}
+}
+
+func TestPushAsyncFailsWhenSessionKeepsBeingRejected(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusUnauthorized, PushAsyncResponse{OutputErr: "Invalid session"})
+	}
+
+	c := g.client()
+	_, err := c.PushAsync(context.Background(), testRequest("tp").PushRequest())
+	if !errors.Is(err, ErrSessionInvalid) {
+		t.Fatalf("PushAsync() error = %v, want ErrSessionInvalid", err)
+	}
+
+	if got := atomic.LoadInt32(&g.sessions); got != 2 {
+		t.Errorf("sessions fetched = %d, want 2", got)
+	}
+}
+
+func TestQueryTx(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["queryTransactionStatus/"] = func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("method = %s, want GET", r.Method)
+		}
+		if session := g.session(t, r); session != "session-1" {
+			t.Errorf("session = %s, want session-1", session)
+		}
+
+		query := r.URL.Query()
+		want := map[string]string{
+			"input_QueryReference":           "hv9ahxcg4ccv",
+			"input_ServiceProviderCode":      "000000",
+			"input_ThirdPartyConversationID": "1e9b774d1da34af78412a498cbc28f5e",
+			"input_Country":                  "TZN",
+		}
+		for key, value := range want {
+			if got := query.Get(key); got != value {
+				t.Errorf("%s = %q, want %q", key, got, value)
+			}
+		}
+
+		writeJSON(w, http.StatusOK, QueryTxResponse{
+			ConversationID:            "fd1e9143d22544459f7c66e1860ef276",
+			ResponseCode:              "INS-0",
+			ResponseDesc:              "Request processed successfully",
+			ResponseTransactionStatus: "Completed",
+			ThirdPartyConversationID:  "1e9b774d1da34af78412a498cbc28f5e",
+		})
+	}
+
+	c := g.client()
+	response, err := c.QueryTx(context.Background(), QueryTxParams{
+		Reference:      "hv9ahxcg4ccv",
+		ConversationID: "1e9b774d1da34af78412a498cbc28f5e",
+	})
+	if err != nil {
+		t.Fatalf("QueryTx() error = %v", err)
+	}
+
+	want := QueryTxResponse{
+		ConversationID:            "fd1e9143d22544459f7c66e1860ef276",
+		ResponseCode:              "INS-0",
+		ResponseDesc:              "Request processed successfully",
+		ResponseTransactionStatus: "Completed",
+		ThirdPartyConversationID:  "1e9b774d1da34af78412a498cbc28f5e",
+	}
+	if response.HTTP == nil || response.HTTP.StatusCode != http.StatusOK {
+		t.Errorf("QueryTx() HTTP = %+v, want a 200", response.HTTP)
+	}
+	response.HTTP = nil
+	if response != want {
+		t.Errorf("QueryTx() = %+v, want %+v", response, want)
+	}
+}
+
+func TestQueryTxOutputErr(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["queryTransactionStatus/"] = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusBadRequest, QueryTxResponse{OutputErr: "Invalid query reference"})
+	}
+
+	c := g.client()
+	_, err := c.QueryTx(context.Background(), QueryTxParams{Reference: "unknown"})
+	if err == nil || !strings.Contains(err.Error(), "Invalid query reference") {
+		t.Errorf("QueryTx() error = %v, want the gateway output error", err)
+	}
+
+	_, err = c.QueryTx(context.Background(), QueryTxParams{})
+	if err == nil {
+		t.Error("QueryTx() with no reference error = nil, want an error")
+	}
+}
+
+func TestQueryTxLookupKeys(t *testing.T) {
+	g := newTestGateway(t)
+	var references []string
+	g.handlers["queryTransactionStatus/"] = func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		references = append(references, query.Get("input_QueryReferenceType")+":"+query.Get("input_QueryReference"))
+		writeJSON(w, http.StatusOK, QueryTxResponse{ResponseCode: "INS-0", ResponseTransactionStatus: "Pending"})
+	}
+	c := g.client()
+
+	for _, params := range []QueryTxParams{
+		{TransactionID: "hv9ahxcg4ccv"},
+		{OriginalConversationID: "1e9b774d1da34af78412a498cbc28f5e"},
+		{Reference: "hv9ahxcg4ccw"},
+	} {
+		if _, err := c.QueryTx(context.Background(), params); err != nil {
+			t.Errorf("QueryTx(%+v) error = %v", params, err)
+		}
+	}
+	want := []string{"TransactionID:hv9ahxcg4ccv", "ConversationID:1e9b774d1da34af78412a498cbc28f5e", "TransactionID:hv9ahxcg4ccw"}
+	if !reflect.DeepEqual(references, want) {
+		t.Errorf("input_QueryReferenceType:input_QueryReference = %q, want %q", references, want)
+	}
+
+	tests := []struct {
+		params QueryTxParams
+		want   string
+	}{
+		{QueryTxParams{}, "TransactionID or OriginalConversationID is required"},
+		{QueryTxParams{TransactionID: "tx", OriginalConversationID: "conv"}, "TransactionID must not be set with OriginalConversationID, set only one"},
+		{QueryTxParams{TransactionID: "tx", Reference: "tx"}, "TransactionID must not be set with Reference, set only one"},
+		{QueryTxParams{Reference: "tx", OriginalConversationID: "conv"}, "Reference must not be set with OriginalConversationID, set only one"},
+		{QueryTxParams{OriginalConversationID: strings.Repeat("c", 41)}, "OriginalConversationID must be at most 40 characters"},
+	}
+	for _, tt := range tests {
+		_, err := c.QueryTx(context.Background(), tt.params)
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("QueryTx(%+v) error = %v, want a *ValidationError with %q", tt.params, err, tt.want)
+		}
+	}
+	if len(references) != 3 {
+		t.Errorf("queries sent = %d, want the invalid ones rejected before being sent", len(references))
+	}
+}
+
+func TestPushAsyncConcurrentSessions(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		if session := g.session(t, r); session != "session-1" {
+			t.Errorf("session = %s, want session-1", session)
+		}
+		writeJSON(w, http.StatusCreated, PushAsyncResponse{ResponseCode: "INS-0"})
+	}
+
+	c := g.client()
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			_, err := c.PushAsync(context.Background(), PushRequest{
+				ThirdPartyID: fmt.Sprintf("third-party-%d", i),
+				Reference:    fmt.Sprintf("T%d", i),
+				Amount:       MustParseAmount("1000"),
+				MSISDN:       "255754000000",
+			})
+			if err != nil {
+				t.Errorf("PushAsync() error = %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&g.sessions); got != 1 {
+		t.Errorf("sessions fetched = %d, want 1", got)
+	}
+}
+
+// blockingSessions makes the session requests of g wait for release, or for
+// the caller to give up, and answer with respond.
+func blockingSessions(g *testGateway, release <-chan struct{}, respond func(w http.ResponseWriter, n int32)) {
+	g.handlers["getSession/"] = func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&g.sessions, 1)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		respond(w, n)
+	}
+}
+
+// ensureSession fetches a session for c unless it has a valid one.
+func ensureSession(ctx context.Context, c *Client) error {
+	_, err := c.checkSessionID(ctx)
+	return err
+}
+
+func TestCheckSessionIDSharesFailedFetch(t *testing.T) {
+	g := newTestGateway(t)
+	release := make(chan struct{})
+	blockingSessions(g, release, func(w http.ResponseWriter, _ int32) {
+		writeJSON(w, http.StatusUnauthorized, SessionResponse{OutputErr: "Invalid API key"})
+	})
+	c := g.client()
+
+	errs := make(chan error, 10)
+	go func() { errs <- ensureSession(context.Background(), c) }()
+	waitForSessions(t, g, 1)
+	for i := 1; i < cap(errs); i++ {
+		go func() { errs <- ensureSession(context.Background(), c) }()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; !errors.Is(err, ErrSessionInvalid) {
+			t.Errorf("checkSessionID() error = %v, want ErrSessionInvalid", err)
+		}
+	}
+	if got := atomic.LoadInt32(&g.sessions); got != 1 {
+		t.Errorf("sessions fetched = %d, want 1", got)
+	}
+}
+
+func TestCheckSessionIDWaiterContext(t *testing.T) {
+	g := newTestGateway(t)
+	release := make(chan struct{})
+	blockingSessions(g, release, func(w http.ResponseWriter, n int32) {
+		writeJSON(w, http.StatusOK, SessionResponse{Code: "INS-0", ID: fmt.Sprintf("session-%d", n)})
+	})
+	c := g.client()
+
+	leader := make(chan error, 1)
+	go func() { leader <- ensureSession(context.Background(), c) }()
+	waitForSessions(t, g, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := ensureSession(ctx, c); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("waiting checkSessionID() error = %v, want context.DeadlineExceeded", err)
+	}
+
+	close(release)
+	if err := <-leader; err != nil {
+		t.Errorf("leading checkSessionID() error = %v", err)
+	}
+	if got := atomic.LoadInt32(&g.sessions); got != 1 {
+		t.Errorf("sessions fetched = %d, want 1", got)
+	}
+}
+
+func TestCheckSessionIDAbandonedFetch(t *testing.T) {
+	g := newTestGateway(t)
+	release := make(chan struct{})
+	blockingSessions(g, release, func(w http.ResponseWriter, n int32) {
+		writeJSON(w, http.StatusOK, SessionResponse{Code: "INS-0", ID: fmt.Sprintf("session-%d", n)})
+	})
+	c := g.client()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error, 1)
+	go func() { leader <- ensureSession(ctx, c) }()
+	waitForSessions(t, g, 1)
+
+	waiter := make(chan error, 1)
+	go func() { waiter <- ensureSession(context.Background(), c) }()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	if err := <-leader; !errors.Is(err, context.Canceled) {
+		t.Errorf("leading checkSessionID() error = %v, want context.Canceled", err)
+	}
+
+	waitForSessions(t, g, 2)
+	close(release)
+	if err := <-waiter; err != nil {
+		t.Errorf("waiting checkSessionID() error = %v, want the session fetched again", err)
+	}
+	if _, ok := c.validSession(); !ok {
+		t.Error("validSession() = false after the fetch led by the waiter")
+	}
+}
+
+func TestDebugOutputIsOptIn(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusCreated, PushAsyncResponse{ResponseCode: "INS-0"})
+	}
+
+	stdout := os.Stdout
+	read, write, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = write
+	defer func() {
+		os.Stdout = stdout
+	}()
+
+	logs := new(bytes.Buffer)
+	quiet, err := NewClient(g.config(), nil, WithHTTPClient(g.Client()), WithLogger(logs))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	if _, err := quiet.PushAsync(context.Background(), testRequest("tp").PushRequest()); err != nil {
+		t.Fatalf("PushAsync() error = %v", err)
+	}
+	if logs.Len() != 0 {
+		t.Errorf("logger output with debug off = %q, want none", logs.String())
+	}
+
+	verbose, err := NewClient(g.config(), nil, WithHTTPClient(g.Client()), WithLogger(logs), WithDebugMode(true))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	if _, err := verbose.PushAsync(context.Background(), testRequest("tp").PushRequest()); err != nil {
+		t.Fatalf("PushAsync() error = %v", err)
+	}
+	if logs.Len() == 0 {
+		t.Error("logger output with debug on is empty")
+	}
+
+	_ = write.Close()
+	os.Stdout = stdout
+	out := new(bytes.Buffer)
+	_, _ = out.ReadFrom(read)
+	if out.Len() != 0 {
+		t.Errorf("stdout output = %q, want none", out.String())
+	}
+}
+
+func TestWithBaseURL(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusCreated, PushAsyncResponse{ResponseCode: "INS-0", ConversationID: "push"})
+	}
+	g.handlers["b2cPayment/"] = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusCreated, DisburseResponse{ResponseCode: "INS-0", TransactionID: "disburse"})
+	}
+
+	// a plain HTTP listener in front of the same stub gateway
+	plain := httptest.NewServer(g.Config.Handler)
+	t.Cleanup(plain.Close)
+
+	conf := g.config()
+	conf.BasePath = ""
+	c, err := NewClient(conf, nil, WithDebugMode(false), WithBaseURL(plain.URL+"/sandbox/ipg/v2/vodacomTZN/"))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	ctx := context.Background()
+	if _, err := c.SessionID(ctx); err != nil {
+		t.Fatalf("SessionID() error = %v", err)
+	}
+	push, err := c.PushAsync(ctx, PushRequest{Amount: MustParseAmount("1000"), MSISDN: "255765123456", Reference: "ref", ThirdPartyID: "tp"})
+	if err != nil || push.ConversationID != "push" {
+		t.Fatalf("PushAsync() = %+v, %v", push, err)
+	}
+	disburse, err := c.Disburse(ctx, DisburseRequest{Amount: MustParseAmount("1000"), MSISDN: "255765123456", Reference: "ref", ThirdPartyID: "tp"})
+	if err != nil || disburse.TransactionID != "disburse" {
+		t.Fatalf("Disburse() = %+v, %v", disburse, err)
+	}
+}
+
+func TestWithBaseURLRejectsInvalidURLs(t *testing.T) {
+	g := newTestGateway(t)
+
+	for _, rawURL := range []string{"/sandbox/ipg/v2/", "127.0.0.1:8080", "ftp://127.0.0.1/", "http://%zz"} {
+		t.Run(rawURL, func(t *testing.T) {
+			_, err := NewClient(g.config(), nil, WithDebugMode(false), WithBaseURL(rawURL))
+
+			var confErr *ConfigError
+			if !errors.As(err, &confErr) || !strings.Contains(confErr.Error(), "base URL") {
+				t.Errorf("NewClient() error = %v, want a base URL *ConfigError", err)
+			}
+		})
+	}
+}
+
+// recordingTransport records the path of every request before sending it.
+type recordingTransport struct {
+	next  http.RoundTripper
+	mu    sync.Mutex
+	paths []string
+}
+
+func (rt *recordingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	rt.mu.Lock()
+	rt.paths = append(rt.paths, path.Base(r.URL.Path))
+	rt.mu.Unlock()
+
+	return rt.next.RoundTrip(r)
+}
+
+func TestWithRoundTripper(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusCreated, PushAsyncResponse{ResponseCode: "INS-0"})
+	}
+	g.handlers["b2cPayment/"] = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusCreated, DisburseResponse{ResponseCode: "INS-0"})
+	}
+	g.handlers["queryTransactionStatus/"] = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, QueryTxResponse{ResponseCode: "INS-0"})
+	}
+
+	rt := &recordingTransport{next: g.Client().Transport}
+	// the transport wins over the one of the http.Client whatever the order
+	c, err := NewClient(g.config(), nil, WithDebugMode(false), WithRoundTripper(rt), WithHTTPClient(&http.Client{}))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	ctx := context.Background()
+	if _, err := c.SessionID(ctx); err != nil {
+		t.Fatalf("SessionID() error = %v", err)
+	}
+	if _, err := c.PushAsync(ctx, testRequest("tp").PushRequest()); err != nil {
+		t.Fatalf("PushAsync() error = %v", err)
+	}
+	if _, err := c.Disburse(ctx, testRequest("tp").DisburseRequest()); err != nil {
+		t.Fatalf("Disburse() error = %v", err)
+	}
+	if _, err := c.QueryTx(ctx, QueryTxParams{Reference: "ref"}); err != nil {
+		t.Fatalf("QueryTx() error = %v", err)
+	}
+
+	want := []string{"getSession", "singleStage", "b2cPayment", "queryTransactionStatus"}
+	if !reflect.DeepEqual(rt.paths, want) {
+		t.Errorf("requests = %v, want %v", rt.paths, want)
+	}
+
+	if _, err := NewClient(g.config(), nil, WithRoundTripper(nil)); err == nil {
+		t.Error("NewClient() error = nil with a nil round tripper")
+	}
+}
+
+func TestWithBaseClient(t *testing.T) {
+	g := newTestGateway(t)
+
+	var logs bytes.Buffer
+	b := base.NewClient(base.WithDebugMode(true), base.WithLogger(&logs))
+	b.Http = g.Client()
+	transport := b.Http.Transport
+
+	c, err := NewClient(g.config(), nil, WithBaseClient(b))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	if _, err := c.SessionID(context.Background()); err != nil {
+		t.Fatalf("SessionID() error = %v", err)
+	}
+	if !strings.Contains(logs.String(), "GET SESSION ID") || strings.Contains(logs.String(), g.config().APIKey) {
+		t.Errorf("logs = %q, want the debug dump of the base client, redacted", logs.String())
+	}
+
+	if !c.base.DebugMode || b.Logger != &logs || b.Http.Transport != transport {
+		t.Errorf("base client = %+v, want it used as a copy and left alone", b)
+	}
+
+	if _, err := NewClient(g.config(), nil, WithBaseClient(nil)); err == nil {
+		t.Error("NewClient() error = nil with a nil base client")
+	}
+}
+
+func TestAutoConversationID(t *testing.T) {
+	g := newTestGateway(t)
+	var sent pushPayRequest
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&sent)
+		writeJSON(w, http.StatusCreated, PushAsyncResponse{ResponseCode: "INS-0", ConversationID: "conv-1"})
+	}
+
+	response, err := g.client().PushAsync(context.Background(), PushRequest{Amount: MustParseAmount("10"), MSISDN: "255754000123"})
+	if err != nil {
+		t.Fatalf("PushAsync() error = %v", err)
+	}
+	id := sent.ThirdPartyConversationID
+	if len(id) != 32 || id[12] != '4' {
+		t.Errorf("ThirdPartyConversationID = %q, want a UUID v4 without dashes", id)
+	}
+	if response.ThirdPartyConversationID != id {
+		t.Errorf("response ThirdPartyConversationID = %q, want the generated %q", response.ThirdPartyConversationID, id)
+	}
+
+	_, err = g.client(WithAutoConversationID(false)).Disburse(context.Background(), DisburseRequest{Amount: MustParseAmount("10"), MSISDN: "255754000123"})
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Field != "ThirdPartyID" {
+		t.Errorf("Disburse() error = %v, want a ThirdPartyID *FieldError", err)
+	}
+}
+
+func TestQueryConversationID(t *testing.T) {
+	g := newTestGateway(t)
+	var ids []string
+	record := func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Query().Get("input_ThirdPartyConversationID")
+		if id == "" {
+			var payload map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&payload)
+			id = payload["input_ThirdPartyConversationID"]
+		}
+		ids = append(ids, id)
+		writeJSON(w, http.StatusOK, map[string]string{"output_ResponseCode": "INS-0"})
+	}
+	for _, path := range []string{"queryTransactionStatus/", "directDebitCancel/", "queryDirectDebit/"} {
+		g.handlers[path] = record
+	}
+
+	ctx := context.Background()
+	c := g.client(WithAutoConversationID(false))
+	for _, id := range []string{"", "tp-1"} {
+		if _, err := c.QueryTx(ctx, QueryTxParams{TransactionID: "hv9ahxcg4ccv", ConversationID: id}); err != nil {
+			t.Fatalf("QueryTx() error = %v", err)
+		}
+		if _, err := c.CancelDirectDebit(ctx, DirectDebitCancelRequest{AgreementID: "agreement", ThirdPartyID: id}); err != nil {
+			t.Fatalf("CancelDirectDebit() error = %v", err)
+		}
+		if _, err := c.QueryDirectDebit(ctx, QueryDirectDebitParams{AgreementID: "agreement", ThirdPartyID: id}); err != nil {
+			t.Fatalf("QueryDirectDebit() error = %v", err)
+		}
+	}
+
+	if len(ids) != 6 {
+		t.Fatalf("requests sent = %d, want 6", len(ids))
+	}
+	for i, id := range ids[:3] {
+		if len(id) != 32 || id[12] != '4' {
+			t.Errorf("request %d: ThirdPartyConversationID = %q, want a generated UUID v4", i, id)
+		}
+	}
+	if want := []string{"tp-1", "tp-1", "tp-1"}; !reflect.DeepEqual(ids[3:], want) {
+		t.Errorf("ThirdPartyConversationID = %q, want the ones given kept", ids[3:])
+	}
+}
+
+func TestLegacyRequestMethods(t *testing.T) {
+	g := newTestGateway(t)
+	var pushed pushPayRequest
+	var disbursed disburseRequest
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&pushed)
+		writeJSON(w, http.StatusCreated, PushAsyncResponse{ResponseCode: "INS-0"})
+	}
+	g.handlers["b2cPayment/"] = func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&disbursed)
+		writeJSON(w, http.StatusCreated, DisburseResponse{ResponseCode: "INS-0"})
+	}
+
+	c := g.client()
+	request := testRequest("tp-1")
+	if _, err := c.LegacyPushAsync(context.Background(), request); err != nil {
+		t.Fatalf("LegacyPushAsync() error = %v", err)
+	}
+	if _, err := c.LegacyDisburse(context.Background(), request); err != nil {
+		t.Fatalf("LegacyDisburse() error = %v", err)
+	}
+
+	if pushed.ThirdPartyConversationID != "tp-1" || pushed.CustomerMSISDN != request.MSISDN ||
+		pushed.TransactionReference != request.Reference || pushed.PurchasedItemsDesc != request.Description {
+		t.Errorf("pushed = %+v, want the fields of %+v", pushed, request)
+	}
+	if disbursed.ThirdPartyConversationID != "tp-1" || disbursed.CustomerMSISDN != request.MSISDN ||
+		disbursed.TransactionReference != request.Reference || disbursed.PaymentItemsDesc != request.Description {
+		t.Errorf("disbursed = %+v, want the fields of %+v", disbursed, request)
+	}
+}
@@ -0,0 +1,171 @@
+package mpesa
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// SessionStore keeps the session ids outside of the Client, so that the
+// clients of several processes, or the successive instances of a short-lived
+// worker, share a session instead of fetching one each. The keys are derived
+// from the API key, the platform and the market, see WithSessionStore.
+//
+// Get returns an empty session id and a nil error when key is unknown or has
+// expired. The methods may be called concurrently.
+type SessionStore interface {
+	Get(ctx context.Context, key string) (sessionID string, expiry time.Time, err error)
+	Put(ctx context.Context, key, sessionID string, expiry time.Time) error
+	Delete(ctx context.Context, key string) error
+}
+
+// WithSessionStore makes the client look up the session in store before
+// fetching one from the gateway and save the fetched sessions to it. The
+// client still caches the session it uses, the store is only consulted when
+// that session is missing or about to expire, and a rejected session is
+// deleted from it.
+//
+// An error of store is written to the logger and the client falls back to
+// fetching the session itself.
+func WithSessionStore(store SessionStore) ClientOption {
+	return func(client *Client) {
+		if store == nil {
+			client.optionErrs = append(client.optionErrs, "session store is nil")
+			return
+		}
+		client.sessionStore = store
+	}
+}
+
+// MemorySessionStore is a SessionStore keeping the sessions in memory, shared
+// by the clients of a process.
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]storedSession
+	now      func() time.Time
+}
+
+type storedSession struct {
+	id     string
+	expiry time.Time
+}
+
+// NewMemorySessionStore returns an empty MemorySessionStore.
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: map[string]storedSession{}, now: time.Now}
+}
+
+// Get returns the session saved under key unless it has expired.
+func (s *MemorySessionStore) Get(_ context.Context, key string) (string, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[key]
+	if !ok {
+		return "", time.Time{}, nil
+	}
+	if !s.now().Before(session.expiry) {
+		delete(s.sessions, key)
+		return "", time.Time{}, nil
+	}
+
+	return session.id, session.expiry, nil
+}
+
+// Put saves sessionID under key until expiry.
+func (s *MemorySessionStore) Put(_ context.Context, key, sessionID string, expiry time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[key] = storedSession{id: sessionID, expiry: expiry}
+
+	return nil
+}
+
+// Delete removes the session saved under key.
+func (s *MemorySessionStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, key)
+
+	return nil
+}
+
+// sessionKey returns the key of the sessions of the client in the
+// SessionStore: a hash of the API key, so that the key is not leaked to the
+// store, the platform and the market.
+func (c *Client) sessionKey(ctx context.Context) (string, error) {
+	apiKey, _, _, err := c.credentials(ctx)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(apiKey))
+
+	return "mpesa:session:" + c.Conf.Platform.String() + ":" + c.Conf.Market.URLContextValue() + ":" + hex.EncodeToString(sum[:]), nil
+}
+
+// loadSession caches the session of the SessionStore when it is not about to
+// expire and returns it.
+func (c *Client) loadSession(ctx context.Context) (string, bool) {
+	if c.sessionStore == nil {
+		return "", false
+	}
+	key, err := c.sessionKey(ctx)
+	if err != nil {
+		return "", false
+	}
+
+	id, expiry, err := c.sessionStore.Get(ctx, key)
+	if err != nil {
+		c.logf("session store: could not get the session: %v", err)
+		return "", false
+	}
+	if id == "" || !c.clock.Now().Before(expiry) {
+		return "", false
+	}
+
+	c.sessionMu.Lock()
+	c.sessionID = &id
+	c.sessionExpiration = expiry
+	c.sessionMu.Unlock()
+
+	return id, true
+}
+
+// saveSession saves a fetched session to the SessionStore.
+func (c *Client) saveSession(ctx context.Context, id string, expiry time.Time) {
+	if c.sessionStore == nil {
+		return
+	}
+	key, err := c.sessionKey(ctx)
+	if err != nil {
+		return
+	}
+
+	if err := c.sessionStore.Put(ctx, key, id, expiry); err != nil {
+		c.logf("session store: could not save the session: %v", err)
+	}
+}
+
+// forgetSession deletes the rejected session from the SessionStore, unless the
+// store already holds another one.
+func (c *Client) forgetSession(ctx context.Context, rejected string) {
+	if c.sessionStore == nil {
+		return
+	}
+	key, err := c.sessionKey(ctx)
+	if err != nil {
+		return
+	}
+
+	id, _, err := c.sessionStore.Get(ctx, key)
+	if err == nil && id != rejected {
+		return
+	}
+	if err := c.sessionStore.Delete(ctx, key); err != nil {
+		c.logf("session store: could not delete the session: %v", err)
+	}
+}
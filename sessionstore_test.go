@@ -0,0 +1,127 @@
+package mpesa
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// failingSessionStore is a SessionStore failing every call.
+type failingSessionStore struct{}
+
+func (failingSessionStore) Get(context.Context, string) (string, time.Time, error) {
+	return "", time.Time{}, errors.New("store unreachable")
+}
+
+func (failingSessionStore) Put(context.Context, string, string, time.Time) error {
+	return errors.New("store unreachable")
+}
+
+func (failingSessionStore) Delete(context.Context, string) error {
+	return errors.New("store unreachable")
+}
+
+func TestSessionStore(t *testing.T) {
+	g := newTestGateway(t)
+	var rejected int32
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		if session := g.session(t, r); session == "session-1" && atomic.LoadInt32(&rejected) == 1 {
+			writeJSON(w, http.StatusUnauthorized, PushAsyncResponse{ResponseCode: "INS-2"})
+			return
+		}
+		writeJSON(w, http.StatusCreated, PushAsyncResponse{ResponseCode: "INS-0"})
+	}
+	push := func(c *Client) {
+		t.Helper()
+		if _, err := c.PushAsync(context.Background(), testRequest("tp-1").PushRequest()); err != nil {
+			t.Fatalf("PushAsync() error = %v", err)
+		}
+	}
+
+	store := NewMemorySessionStore()
+	first := g.client(WithSessionStore(store))
+	push(first)
+
+	// a cold started worker reuses the session of the store
+	second := g.client(WithSessionStore(store))
+	push(second)
+	if n := atomic.LoadInt32(&g.sessions); n != 1 {
+		t.Errorf("sessions = %d, want the stored session reused", n)
+	}
+
+	// the rejected session is replaced in the store
+	atomic.StoreInt32(&rejected, 1)
+	push(second)
+	key, err := second.sessionKey(context.Background())
+	if err != nil {
+		t.Fatalf("sessionKey() error = %v", err)
+	}
+	if id, _, _ := store.Get(context.Background(), key); id != "session-2" {
+		t.Errorf("stored session = %q, want session-2", id)
+	}
+
+	push(g.client(WithSessionStore(store)))
+	if n := atomic.LoadInt32(&g.sessions); n != 2 {
+		t.Errorf("sessions = %d, want 2", n)
+	}
+}
+
+func TestSessionStoreErrors(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusCreated, PushAsyncResponse{ResponseCode: "INS-0"})
+	}
+
+	var logs bytes.Buffer
+	c := g.client(WithSessionStore(failingSessionStore{}), WithLogger(&logs))
+	if _, err := c.PushAsync(context.Background(), testRequest("tp-1").PushRequest()); err != nil {
+		t.Fatalf("PushAsync() error = %v", err)
+	}
+	if n := atomic.LoadInt32(&g.sessions); n != 1 {
+		t.Errorf("sessions = %d, want a session fetched despite the store", n)
+	}
+	if !strings.Contains(logs.String(), "session store: could not get the session: store unreachable") {
+		t.Errorf("logs = %q, want the store error", logs.String())
+	}
+}
+
+func TestSessionKey(t *testing.T) {
+	g := newTestGateway(t)
+	tanzania, _ := g.client().sessionKey(context.Background())
+	ghana, _ := g.client(WithMarket(GhanaMarket)).sessionKey(context.Background())
+
+	if tanzania == ghana || strings.Contains(tanzania, "api-key") {
+		t.Errorf("session keys = %q, %q, want distinct keys without the API key", tanzania, ghana)
+	}
+}
+
+func TestMemorySessionStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemorySessionStore()
+	now := time.Date(2021, 10, 1, 8, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	if id, _, err := store.Get(ctx, "k"); id != "" || err != nil {
+		t.Errorf("Get() = %q, %v, want a miss", id, err)
+	}
+	_ = store.Put(ctx, "k", "session-1", now.Add(time.Hour))
+	if id, expiry, _ := store.Get(ctx, "k"); id != "session-1" || !expiry.Equal(now.Add(time.Hour)) {
+		t.Errorf("Get() = %q, %s, want session-1", id, expiry)
+	}
+
+	now = now.Add(time.Hour)
+	if id, _, _ := store.Get(ctx, "k"); id != "" {
+		t.Errorf("Get() = %q, want the expired session dropped", id)
+	}
+
+	_ = store.Put(ctx, "k", "session-2", now.Add(time.Hour))
+	_ = store.Delete(ctx, "k")
+	if id, _, _ := store.Get(ctx, "k"); id != "" {
+		t.Errorf("Get() = %q, want the deleted session dropped", id)
+	}
+}
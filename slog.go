@@ -0,0 +1,72 @@
+package mpesa
+
+import (
+	"context"
+	"log/slog"
+)
+
+// WithSlog writes the logs of the client to logger as structured records in
+// place of the lines of the logger set by WithLogger. The records of an
+// operation carry the attributes op, market, conversation_id, response_code
+// and duration_ms, plus correlation_id when the context has one: the start of
+// the gateway operations and their retries are logged at the debug level and
+// their outcome at the info level, or warn when they fail, like the session
+// refreshes and the callbacks received.
+//
+// In debug mode the requests, responses and callbacks are written as debug
+// records carrying the dump in the payload attribute. The values are redacted
+// unless WithRedaction(false) is set.
+func WithSlog(logger *slog.Logger) ClientOption {
+	return func(client *Client) {
+		if logger == nil {
+			client.optionErrs = append(client.optionErrs, "slog logger is nil")
+			return
+		}
+
+		client.records = slogRecorder{logger: logger}
+	}
+}
+
+// slogRecorder is the recordLogger writing to a *slog.Logger.
+type slogRecorder struct {
+	logger *slog.Logger
+}
+
+func (r slogRecorder) logRecord(ctx context.Context, level logLevel, msg string, attrs ...interface{}) {
+	r.logger.Log(ctx, slogLevel(level), msg, attrs...)
+}
+
+func slogLevel(level logLevel) slog.Level {
+	switch level {
+	case levelDebug:
+		return slog.LevelDebug
+	case levelWarn:
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// LogValue logs conf as a group of attributes with the secrets redacted as in
+// Config.Redacted.
+func (conf Config) LogValue() slog.Value {
+	r := conf.Redacted()
+	var endpoints Endpoints
+	if r.Endpoints != nil {
+		endpoints = *r.Endpoints
+	}
+
+	return slog.GroupValue(
+		slog.String("name", r.Name),
+		slog.String("version", r.Version),
+		slog.String("market", r.Market.String()),
+		slog.String("platform", r.Platform.String()),
+		slog.String("base_path", r.BasePath),
+		slog.Any("endpoints", endpoints),
+		slog.Duration("session_lifetime", r.SessionLifetime),
+		slog.String("service_provider_code", r.ServiceProviderCode),
+		slog.Any("trusted_sources", r.TrustedSources),
+		slog.String("api_key", r.APIKey),
+		slog.String("public_key", r.PublicKey),
+	)
+}
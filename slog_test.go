@@ -0,0 +1,155 @@
+package mpesa
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slogRecords decodes the JSON records written to out.
+func slogRecords(t *testing.T, out *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("record %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+
+	return records
+}
+
+// findRecord returns the first record with msg, of the operation op when op is
+// not empty.
+func findRecord(records []map[string]interface{}, msg, op string) map[string]interface{} {
+	for _, record := range records {
+		if record["msg"] == msg && (op == "" || record["op"] == op) {
+			return record
+		}
+	}
+
+	return nil
+}
+
+func TestWithSlog(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["b2cPayment/"] = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusCreated, DisburseResponse{ResponseCode: "INS-0", ConversationID: "conversation-1"})
+	}
+	var queries int32
+	g.handlers["queryTransactionStatus/"] = func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&queries, 1) == 1 {
+			writeJSON(w, http.StatusTooManyRequests, QueryTxResponse{ResponseCode: "INS-16"})
+			return
+		}
+		writeJSON(w, http.StatusOK, QueryTxResponse{ResponseCode: "INS-0"})
+	}
+
+	out, lines := new(bytes.Buffer), new(bytes.Buffer)
+	logger := slog.New(slog.NewJSONHandler(out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	c := g.client(WithSlog(logger), WithLogger(lines), WithDebugMode(true),
+		WithRetry(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}))
+
+	if _, err := c.Disburse(context.Background(), testRequest("").DisburseRequest()); err != nil {
+		t.Fatalf("Disburse() error = %v", err)
+	}
+	if _, err := c.QueryTx(context.Background(), QueryTxParams{Reference: "ref"}); err != nil {
+		t.Fatalf("QueryTx() error = %v", err)
+	}
+	if lines.Len() > 0 {
+		t.Errorf("WithLogger output = %q, want everything written to slog", lines.String())
+	}
+
+	records := slogRecords(t, out)
+	finished := findRecord(records, "request finished", "disbursement")
+	if finished == nil || finished["level"] != "INFO" || finished["op"] != "disbursement" || finished["market"] != "TZN" ||
+		finished["conversation_id"] != "conversation-1" || finished["response_code"] != "INS-0" ||
+		finished["duration_ms"] == nil || finished["retries"] != 0.0 {
+		t.Errorf("request finished record = %v", finished)
+	}
+	if started := findRecord(records, "request started", "disbursement"); started == nil || started["level"] != "DEBUG" {
+		t.Errorf("request started record = %v", started)
+	}
+	if retried := findRecord(records, "request retried", ""); retried == nil || retried["op"] != "query transaction status" || retried["attempt"] != 1.0 {
+		t.Errorf("request retried record = %v", retried)
+	}
+	if session := findRecord(records, "session refreshed", ""); session == nil || session["op"] != "get session id" || session["expires_at"] == nil {
+		t.Errorf("session refreshed record = %v", session)
+	}
+
+	payload := findRecord(records, "payload", "")
+	if payload == nil || payload["level"] != "DEBUG" {
+		t.Fatalf("payload record = %v, want the debug dumps", payload)
+	}
+	for _, record := range records {
+		if record["msg"] != "payload" {
+			continue
+		}
+		if dump, _ := record["payload"].(string); strings.Contains(dump, "255754000123") || strings.Contains(dump, "session-1") {
+			t.Errorf("payload = %q, want it redacted", dump)
+		}
+	}
+}
+
+func TestWithSlogCallback(t *testing.T) {
+	g := newTestGateway(t)
+	handler := DisburseCallbackFunc(func(ctx context.Context, request DisburseCallbackRequest) (DisburseCallbackResponse, error) {
+		return DisburseCallbackResponse{}, errors.New("ledger is down")
+	})
+	out := new(bytes.Buffer)
+	c := g.client(WithSlog(slog.New(slog.NewJSONHandler(out, nil))), WithDisburseCallbackHandler(handler))
+
+	c.DisburseCallbackServeHTTP(httptest.NewRecorder(), newCallbackRequest(context.Background(), `{
+		"input_OriginalConversationID": "fd1e9143d22544459f7c66e1860ef276",
+		"input_ThirdPartyConversationID": "1e9b774d1da34af78412a498cbc28f5e",
+		"input_ResultCode": "INS-0"
+	}`))
+
+	records := slogRecords(t, out)
+	received := findRecord(records, "callback received", "")
+	if received == nil || received["level"] != "WARN" || received["op"] != "disburse callback" ||
+		received["conversation_id"] != "fd1e9143d22544459f7c66e1860ef276" || received["error"] == nil {
+		t.Errorf("callback received record = %v", received)
+	}
+	if findRecord(records, "payload", "") != nil {
+		t.Errorf("records = %v, want no payload out of debug mode", records)
+	}
+}
+
+func TestWithSlogNil(t *testing.T) {
+	g := newTestGateway(t)
+
+	var configErr *ConfigError
+	if _, err := NewClient(g.config(), nil, WithSlog(nil)); !errors.As(err, &configErr) {
+		t.Errorf("NewClient(WithSlog(nil)) error = %v, want a *ConfigError", err)
+	}
+}
+
+func TestConfigLogValue(t *testing.T) {
+	g := newTestGateway(t)
+	conf := g.config()
+
+	var out bytes.Buffer
+	slog.New(slog.NewJSONHandler(&out, nil)).Info("starting", "config", conf)
+
+	if strings.Contains(out.String(), conf.APIKey) || strings.Contains(out.String(), conf.PublicKey) {
+		t.Errorf("record = %s, want the keys redacted", out.String())
+	}
+	records := slogRecords(t, &out)
+	group, _ := records[0]["config"].(map[string]interface{})
+	if group["api_key"] != conf.Redacted().APIKey || group["public_key"] != conf.Redacted().PublicKey ||
+		group["market"] != conf.Market.String() {
+		t.Errorf("config = %v, want the redacted configuration", group)
+	}
+}
@@ -0,0 +1,179 @@
+package mpesa
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/techcraftlabs/base"
+)
+
+// throttleTransport turns the 429 responses of the gateway into an *APIError
+// carrying the Retry-After delay. The gateway does not always answer 429 with
+// a JSON body, and base.Client.Do drops the status and the headers of the
+// responses it cannot decode.
+type throttleTransport struct {
+	next http.RoundTripper
+	now  func() time.Time
+}
+
+func (t *throttleTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.next.RoundTrip(req)
+	if err != nil || res.StatusCode != http.StatusTooManyRequests {
+		return res, err
+	}
+	defer res.Body.Close()
+
+	var body struct {
+		Code      ResponseCode `json:"output_ResponseCode"`
+		Desc      string       `json:"output_ResponseDesc"`
+		OutputErr string       `json:"output_error"`
+	}
+	buf, _ := io.ReadAll(io.LimitReader(res.Body, 64<<10))
+	_ = json.NewDecoder(bytes.NewReader(buf)).Decode(&body)
+
+	apiErr := &APIError{
+		Code:        string(body.Code),
+		Description: body.OutputErr,
+		StatusCode:  res.StatusCode,
+		RetryAfter:  retryAfter(res.Header.Get("Retry-After"), t.now()),
+	}
+	if apiErr.Description == "" {
+		apiErr.Description = body.Desc
+	}
+	if apiErr.Description == "" {
+		apiErr.Description = http.StatusText(res.StatusCode)
+	}
+
+	return nil, apiErr
+}
+
+// throttle wraps the transport of the http.Client used by c, or the one set
+// with WithRoundTripper. The http.Client is copied first, it may be shared with
+// other code through WithHTTPClient.
+func (c *Client) throttle() {
+	hc := *c.base.Http
+	next := hc.Transport
+	if c.roundTripper != nil {
+		next = c.roundTripper
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if c.dryRun {
+		next = dryRunTransport{}
+	}
+	if c.tracer != nil {
+		next = &tracingTransport{next: next, tracer: c.tracer}
+	}
+	if c.wireDump != nil {
+		next = &dumpTransport{next: next, c: c, w: c.wireDump, now: func() time.Time { return c.clock.Now() }}
+	}
+	hc.Transport = &captureTransport{
+		next: &throttleTransport{next: next, now: func() time.Time { return c.clock.Now() }},
+	}
+	c.base.Http = &hc
+}
+
+// do is base.Client.Do going through the rate limiter and the circuit breaker,
+// setting the HTTPInfo of v and reporting the throttled requests as an
+// *APIError named after the operation rather than the *url.Error built by the
+// http.Client. A response that is empty or can not be decoded is reported as a
+// *GatewayError, a request that got no response as a *TransportError.
+func (c *Client) do(ctx context.Context, requestType requestType, re *base.Request, v interface{}) (*base.Response, error) {
+	if c.dryRun {
+		return nil, c.doDryRun(ctx, requestType, re)
+	}
+
+	operation := requestType.Name()
+	if err := c.limiter(requestType).Wait(ctx); err != nil {
+		return nil, unsent(fmt.Errorf("could not perform %s request: %w", operation, err))
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, unsent(fmt.Errorf("could not perform %s request: %w", operation, err))
+	}
+
+	probe, err := c.breaker.allow()
+	if err != nil {
+		return nil, unsent(fmt.Errorf("could not perform %s request: %w", operation, err))
+	}
+
+	captured := new(capture)
+	res, err := c.base.Do(withCapture(ctx, captured), re, v)
+	outcome := classify(res, err, v)
+	c.breaker.done(probe, outcome)
+	c.recordHealth(requestType, outcome, res, err, v)
+
+	requestID, gatewayHeaders := c.gatewayIDs(captured.header)
+	if captured.done {
+		gatewayResponded(ctx, requestID, gatewayHeaders)
+	}
+
+	var apiErr *APIError
+	if err != nil && errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests {
+		throttled := *apiErr
+		throttled.Operation = operation
+		throttled.GatewayRequestID, throttled.GatewayHeaders = requestID, gatewayHeaders
+
+		return nil, &throttled
+	}
+	if !captured.done {
+		var urlErr *url.Error
+		if err != nil && errors.As(err, &urlErr) {
+			return nil, newTransportError(operation, err)
+		}
+		return res, err
+	}
+
+	if r, ok := v.(httpResponse); ok {
+		info := captured.info(c.responseBodyLimit)
+		info.GatewayRequestID, info.GatewayHeaders = requestID, gatewayHeaders
+		r.setHTTP(info)
+	}
+
+	if captured.err != nil {
+		return nil, c.gatewayError(operation, captured, captured.err)
+	}
+	// the response was received, base.Client.Do failed to decode it
+	if err != nil {
+		return nil, c.gatewayError(operation, captured, err)
+	}
+	if v != nil && len(bytes.TrimSpace(captured.body)) == 0 {
+		return nil, c.gatewayError(operation, captured, errEmptyBody)
+	}
+
+	return res, nil
+}
+
+// retryAfter parses the value of a Retry-After header, either a number of
+// seconds or an HTTP date. It returns 0 when value is empty, invalid or in the
+// past.
+func retryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+
+		return time.Duration(seconds) * time.Second
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil || !date.After(now) {
+		return 0
+	}
+
+	return date.Sub(now)
+}
@@ -0,0 +1,103 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2021, 12, 31, 14, 30, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{" 3 ", 3 * time.Second},
+		{"-1", 0},
+		{"soon", 0},
+		{"Fri, 31 Dec 2021 14:30:45 GMT", 45 * time.Second},
+		{"Fri, 31 Dec 2021 14:29:00 GMT", 0},
+	}
+
+	for _, tt := range tests {
+		if got := retryAfter(tt.value, now); got != tt.want {
+			t.Errorf("retryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestThrottledPushAsync(t *testing.T) {
+	g := newTestGateway(t)
+	var pushes int32
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&pushes, 1)
+		w.Header().Set("Retry-After", "7")
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte("slow down"))
+	}
+
+	hc := g.Client()
+	transport := hc.Transport
+	c := g.client(WithHTTPClient(hc), WithRetry(RetryPolicy{MaxAttempts: 3}))
+
+	_, err := c.PushAsync(context.Background(), testRequest("tp-1").PushRequest())
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("PushAsync() error = %v, want ErrRateLimited", err)
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.RetryAfter != 7*time.Second || apiErr.Operation != "ussd push" {
+		t.Errorf("PushAsync() error = %#v", err)
+	}
+	if got := atomic.LoadInt32(&pushes); got != 1 {
+		t.Errorf("pushes = %d, want 1", got)
+	}
+	if hc.Transport != transport {
+		t.Error("WithHTTPClient() client was modified")
+	}
+}
+
+func TestThrottledQueryTxRetries(t *testing.T) {
+	g, queries := queryGateway(t, QueryTxResponse{ResponseCode: "INS-0", ResponseTransactionStatus: "Completed"})
+	var throttled int32
+	handler := g.handlers["queryTransactionStatus/"]
+	g.handlers["queryTransactionStatus/"] = func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&throttled, 1) == 1 {
+			w.Header().Set("Retry-After", "30")
+			writeJSON(w, http.StatusTooManyRequests, QueryTxResponse{ResponseCode: "INS-16"})
+			return
+		}
+		handler(w, r)
+	}
+	c := g.client(WithRetry(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}))
+	clk := newFakeClock()
+	c.clock = clk
+
+	type result struct {
+		response QueryTxResponse
+		err      error
+	}
+	results := make(chan result, 1)
+	go func() {
+		response, err := c.QueryTx(context.Background(), QueryTxParams{Reference: "ref"})
+		results <- result{response, err}
+	}()
+
+	clk.waitForTimers(t, 1)
+	clk.Advance(29 * time.Second)
+	if got := atomic.LoadInt32(queries); got != 0 {
+		t.Fatalf("queries = %d before Retry-After elapsed, want 0", got)
+	}
+	clk.Advance(time.Second)
+
+	res := <-results
+	if res.err != nil || res.response.ResponseTransactionStatus != "Completed" {
+		t.Fatalf("QueryTx() = %+v, %v", res.response, res.err)
+	}
+}
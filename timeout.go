@@ -0,0 +1,58 @@
+package mpesa
+
+import (
+	"context"
+	"time"
+)
+
+// TimeoutConfig holds the default timeouts of the gateway operations. A
+// timeout only applies when the context passed to the operation has no
+// deadline, and a zero value applies none.
+//
+// Auth covers SessionID. Transaction covers PushAsync, Disburse, B2BPayment and
+// the direct debit creation, payment and cancellation. Query covers QueryTx,
+// QueryDirectDebit and QueryBeneficiaryName.
+type TimeoutConfig struct {
+	Auth        time.Duration
+	Transaction time.Duration
+	Query       time.Duration
+}
+
+// DefaultTimeouts returns the recommended TimeoutConfig: 15 seconds for the
+// session and the queries, 30 seconds for the transactions.
+func DefaultTimeouts() TimeoutConfig {
+	return TimeoutConfig{
+		Auth:        15 * time.Second,
+		Transaction: 30 * time.Second,
+		Query:       15 * time.Second,
+	}
+}
+
+// WithTimeouts applies the default timeouts of timeouts to the operations
+// called without a deadline, so that a hung connection to the gateway cannot
+// block the caller forever.
+func WithTimeouts(timeouts TimeoutConfig) ClientOption {
+	return func(client *Client) {
+		client.timeouts = timeouts
+	}
+}
+
+// withTimeout returns ctx with the default timeout of requestType when ctx has
+// no deadline.
+func (c *Client) withTimeout(ctx context.Context, requestType requestType) (context.Context, context.CancelFunc) {
+	var timeout time.Duration
+	switch requestType {
+	case sessionID:
+		timeout = c.timeouts.Auth
+	case queryTxn, directDebitQuery, beneficiaryName:
+		timeout = c.timeouts.Query
+	default:
+		timeout = c.timeouts.Transaction
+	}
+
+	if _, ok := ctx.Deadline(); ok || timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
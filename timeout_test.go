@@ -0,0 +1,69 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestWithTimeouts(t *testing.T) {
+	g := newTestGateway(t)
+	hung := make(chan struct{})
+	t.Cleanup(func() { close(hung) })
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		<-hung
+	}
+	c := g.client(WithTimeouts(TimeoutConfig{Transaction: 50 * time.Millisecond}))
+
+	start := time.Now()
+	_, err := c.PushAsync(context.Background(), testRequest("tp-1").PushRequest())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("PushAsync() error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("PushAsync() returned after %s", elapsed)
+	}
+}
+
+func TestWithTimeoutDeadlines(t *testing.T) {
+	c := &Client{timeouts: TimeoutConfig{Auth: time.Second, Transaction: time.Minute}}
+
+	tests := []struct {
+		name        string
+		requestType requestType
+		deadline    time.Duration
+		want        time.Duration
+	}{
+		{"auth", sessionID, 0, time.Second},
+		{"transaction", disburse, 0, time.Minute},
+		{"no query default", queryTxn, 0, 0},
+		{"shorter caller deadline", pushPay, time.Second, time.Second},
+		{"longer caller deadline", pushPay, time.Hour, time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.deadline)
+				defer cancel()
+			}
+
+			ctx, cancel := c.withTimeout(ctx, tt.requestType)
+			defer cancel()
+
+			deadline, ok := ctx.Deadline()
+			if ok != (tt.want > 0) {
+				t.Fatalf("deadline set = %v, want %v", ok, tt.want > 0)
+			}
+			if ok {
+				if got := time.Until(deadline); got > tt.want || got < tt.want-time.Second {
+					t.Errorf("deadline in %s, want %s", got, tt.want)
+				}
+			}
+		})
+	}
+}
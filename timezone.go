@@ -0,0 +1,143 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// GatewayTimeLayout is the layout of the timestamps sent by the gateway, e.g.
+// the TransactionTime of the callbacks. They carry no offset and are in the
+// local time of the market, see Market.Location.
+const GatewayTimeLayout = "20060102150405"
+
+var _ located = (*QueryDirectDebitResponse)(nil)
+
+// marketLocations caches the locations loaded by Market.Location.
+var marketLocations sync.Map
+
+type (
+	locationKey struct{}
+
+	// located is implemented by the responses whose dates are parsed in
+	// the location of the Client that received them.
+	located interface {
+		setLocation(loc *time.Location)
+	}
+)
+
+// ContextWithLocation returns a copy of ctx carrying loc, the location the
+// timestamps of the callbacks are parsed in by their Time methods.
+func ContextWithLocation(ctx context.Context, loc *time.Location) context.Context {
+	return context.WithValue(ctx, locationKey{}, loc)
+}
+
+// LocationFromContext returns the location carried by ctx, UTC and false when
+// there is none.
+func LocationFromContext(ctx context.Context) (*time.Location, bool) {
+	loc, ok := ctx.Value(locationKey{}).(*time.Location)
+	if !ok || loc == nil {
+		return time.UTC, false
+	}
+
+	return loc, true
+}
+
+// WithLocation sets the location the timestamps of the gateway are read in,
+// and the dates of the requests written in, instead of the location of the
+// market. It is useful when the gateway of a market reports another time
+// zone.
+func WithLocation(loc *time.Location) ClientOption {
+	return func(client *Client) {
+		if loc == nil {
+			client.optionErrs = append(client.optionErrs, "location is nil")
+			return
+		}
+		client.location = loc
+	}
+}
+
+// Location returns the location of the timestamps of the gateway: the one
+// set with WithLocation, the location of the market otherwise.
+func (c *Client) Location() *time.Location {
+	if c.location != nil {
+		return c.location
+	}
+
+	return c.Conf.Market.Location()
+}
+
+// locate sets the location of v when it has timestamps.
+func (c *Client) locate(v interface{}) {
+	if l, ok := v.(located); ok {
+		l.setLocation(c.Location())
+	}
+}
+
+// ParseGatewayTime parses s, in the GatewayTimeLayout, in loc. A nil loc is
+// UTC. It fails when s is empty or invalid.
+func ParseGatewayTime(s string, loc *time.Location) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, errors.New("mpesa: no transaction time")
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	t, err := time.ParseInLocation(GatewayTimeLayout, s, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("mpesa: invalid transaction time %q", s)
+	}
+
+	return t, nil
+}
+
+// FormatGatewayTime formats t in the GatewayTimeLayout in the local time of m.
+func FormatGatewayTime(t time.Time, m Market) string {
+	return t.In(m.Location()).Format(GatewayTimeLayout)
+}
+
+// Location returns the time zone of the market, e.g. Africa/Dar_es_Salaam for
+// Tanzania. When the time zone database is not available, see the time/tzdata
+// package, it is a fixed zone with the standard offset of the market. It is
+// UTC for an unknown market.
+func (m Market) Location() *time.Location {
+	if loc, ok := marketLocations.Load(m); ok {
+		return loc.(*time.Location)
+	}
+
+	name, offset := m.timeZone()
+	if name == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		loc = time.FixedZone(m.Country(), offset)
+	}
+	marketLocations.Store(m, loc)
+
+	return loc
+}
+
+// timeZone returns the IANA name of the time zone of the market and its
+// standard offset in seconds east of UTC.
+func (m Market) timeZone() (string, int) {
+	switch m {
+	case GhanaMarket:
+		return "Africa/Accra", 0
+	case TanzaniaMarket:
+		return "Africa/Dar_es_Salaam", 3 * 60 * 60
+	case DRCMarket:
+		return "Africa/Kinshasa", 1 * 60 * 60
+	case LesothoMarket:
+		return "Africa/Maseru", 2 * 60 * 60
+	case MozambiqueMarket:
+		return "Africa/Maputo", 2 * 60 * 60
+	case EgyptMarket:
+		return "Africa/Cairo", 2 * 60 * 60
+	default:
+		return "", 0
+	}
+}
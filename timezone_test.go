@@ -0,0 +1,103 @@
+package mpesa
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarketLocation(t *testing.T) {
+	instant := time.Date(2021, 12, 31, 22, 30, 0, 0, time.UTC)
+	tests := []struct {
+		market    Market
+		offset    int
+		formatted string
+	}{
+		{TanzaniaMarket, 3 * 60 * 60, "20220101013000"},
+		{GhanaMarket, 0, "20211231223000"},
+		{Market(-1), 0, "20211231223000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.market.String(), func(t *testing.T) {
+			loc := tt.market.Location()
+			if _, offset := instant.In(loc).Zone(); offset != tt.offset {
+				t.Errorf("offset of Location() = %d, want %d", offset, tt.offset)
+			}
+
+			got := FormatGatewayTime(instant, tt.market)
+			if got != tt.formatted {
+				t.Errorf("FormatGatewayTime() = %q, want %q", got, tt.formatted)
+			}
+			parsed, err := ParseGatewayTime(got, loc)
+			if err != nil || !parsed.Equal(instant) {
+				t.Errorf("ParseGatewayTime(%q) = %v, %v, want %v", got, parsed, err, instant)
+			}
+		})
+	}
+
+	// the same wall clock is three hours earlier in Dar es Salaam than in Accra
+	accra, _ := ParseGatewayTime("20211231230000", GhanaMarket.Location())
+	dar, _ := ParseGatewayTime("20211231230000", TanzaniaMarket.Location())
+	if d := accra.Sub(dar); d != 3*time.Hour {
+		t.Errorf("Accra - Dar es Salaam = %v, want 3h", d)
+	}
+}
+
+func TestCallbackTimeLocation(t *testing.T) {
+	body := strings.Replace(testCallbackBody, `"tp-1"`, `"tp-1", "input_TransactionTime": "20211231230000"`, 1)
+	tests := []struct {
+		name string
+		opts []ClientOption
+		want time.Time
+	}{
+		{name: "market", want: time.Date(2021, 12, 31, 20, 0, 0, 0, time.UTC)},
+		{name: "override", opts: []ClientOption{WithLocation(time.UTC)}, want: time.Date(2021, 12, 31, 23, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGateway(t)
+			times := make(chan time.Time, 1)
+			handler := PushCallbackContextFunc(func(ctx context.Context, request PushCallbackRequest) (PushCallbackResponse, error) {
+				at, err := request.Time(ctx)
+				if err != nil {
+					t.Errorf("Time() error = %v", err)
+				}
+				times <- at
+				return PushCallbackResponse{ResponseCode: SUCCESS_CODE}, nil
+			})
+			c := g.client(append([]ClientOption{WithCallbackHandler(handler)}, tt.opts...)...)
+
+			c.CallbackServeHTTP(httptest.NewRecorder(), newCallbackRequest(context.Background(), body))
+			if got := <-times; !got.Equal(tt.want) {
+				t.Errorf("Time() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	g := newTestGateway(t)
+	if _, err := NewClient(g.config(), nil, WithLocation(nil)); err == nil {
+		t.Errorf("NewClient() with a nil location error = nil")
+	}
+}
+
+func TestGatewayDateLocation(t *testing.T) {
+	// late in the evening in UTC is already the next day in Dar es Salaam
+	a := &requestAdapter{market: TanzaniaMarket, location: TanzaniaMarket.Location()}
+	request := DirectDebitCreateRequest{
+		MSISDN:           "255754000000",
+		Reference:        "Test123",
+		ThirdPartyID:     "tp-1",
+		FirstPaymentDate: time.Date(2019, 2, 5, 22, 0, 0, 0, time.UTC),
+	}
+	payload, err := a.adaptDirectDebitCreate(request)
+	if err != nil {
+		t.Fatalf("adaptDirectDebitCreate() error = %v", err)
+	}
+	if payload.FirstPaymentDate != "20190206" {
+		t.Errorf("FirstPaymentDate = %q, want the date in Tanzania", payload.FirstPaymentDate)
+	}
+}
@@ -0,0 +1,111 @@
+package mpesa
+
+import (
+	"context"
+	"net/http"
+)
+
+// Span attribute keys set by the client.
+const (
+	AttrMarket                   = "mpesa.market"
+	AttrPlatform                 = "mpesa.platform"
+	AttrServiceProviderCode      = "mpesa.service_provider_code"
+	AttrRequestType              = "mpesa.request_type"
+	AttrConversationID           = "mpesa.conversation_id"
+	AttrThirdPartyConversationID = "mpesa.third_party_conversation_id"
+	AttrResponseCode             = "mpesa.response_code"
+)
+
+type (
+	// Tracer instruments the gateway operations and the callbacks. The
+	// otelmpesa module implements it with OpenTelemetry, it lives in its own
+	// module so that the OpenTelemetry dependencies are only pulled in by the
+	// users of tracing.
+	Tracer interface {
+		// Start starts a span named name, a child of the span carried by ctx.
+		Start(ctx context.Context, name string) (context.Context, Span)
+
+		// Inject writes the trace context of ctx to the headers of a request
+		// sent to the gateway.
+		Inject(ctx context.Context, header http.Header)
+
+		// Extract returns ctx with the trace context carried by the headers of
+		// a callback request.
+		Extract(ctx context.Context, header http.Header) context.Context
+	}
+
+	// Span is a span started by a Tracer.
+	Span interface {
+		SetAttribute(key, value string)
+
+		// End ends the span, recording err when it is not nil.
+		End(err error)
+	}
+)
+
+// WithTracer creates a span with tracer around every gateway operation of
+// Client and the callbacks, and propagates the trace context to the gateway.
+func WithTracer(tracer Tracer) ClientOption {
+	return func(client *Client) {
+		if tracer == nil {
+			client.optionErrs = append(client.optionErrs, "tracer is nil")
+			return
+		}
+
+		client.tracer = tracer
+	}
+}
+
+// tracedSpan is the Span of an operation, nil when tracing is off.
+type tracedSpan struct {
+	Span
+}
+
+// startSpan starts the span of an operation or of a callback named name.
+func (c *Client) startSpan(ctx context.Context, name, requestType string) (context.Context, *tracedSpan) {
+	if c.tracer == nil {
+		return ctx, nil
+	}
+
+	ctx, span := c.tracer.Start(ctx, name)
+	span.SetAttribute(AttrMarket, c.Conf.Market.Country())
+	span.SetAttribute(AttrPlatform, c.Conf.Platform.String())
+	span.SetAttribute(AttrServiceProviderCode, c.Conf.ServiceProvideCode)
+	span.SetAttribute(AttrRequestType, requestType)
+
+	return ctx, &tracedSpan{span}
+}
+
+// finish records the conversation ids and the response code, the empty ones
+// are left out, and ends the span.
+func (s *tracedSpan) finish(conversationID, thirdPartyID string, code ResponseCode, err error) {
+	if s == nil {
+		return
+	}
+
+	for key, value := range map[string]string{
+		AttrConversationID:           conversationID,
+		AttrThirdPartyConversationID: thirdPartyID,
+		AttrResponseCode:             string(code),
+	} {
+		if value != "" {
+			s.SetAttribute(key, value)
+		}
+	}
+
+	s.End(err)
+}
+
+// tracingTransport injects the trace context of the request context into the
+// headers of the requests sent to the gateway.
+type tracingTransport struct {
+	next   http.RoundTripper
+	tracer Tracer
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	t.tracer.Inject(req.Context(), req.Header)
+
+	return t.next.RoundTrip(req)
+}
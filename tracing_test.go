@@ -0,0 +1,170 @@
+package mpesa
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+const traceHeader = "X-Test-Trace"
+
+type traceKey struct{}
+
+type testSpan struct {
+	name  string
+	attrs map[string]string
+	err   error
+	ended bool
+}
+
+func (s *testSpan) SetAttribute(key, value string) { s.attrs[key] = value }
+
+func (s *testSpan) End(err error) { s.err, s.ended = err, true }
+
+// testTracer records the spans and propagates the span name as the trace.
+type testTracer struct {
+	mu    sync.Mutex
+	spans []*testSpan
+}
+
+func (tr *testTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	span := &testSpan{name: name, attrs: map[string]string{}}
+	if parent, ok := ctx.Value(traceKey{}).(string); ok {
+		span.attrs["parent"] = parent
+	}
+	tr.spans = append(tr.spans, span)
+
+	return context.WithValue(ctx, traceKey{}, name), span
+}
+
+func (tr *testTracer) Inject(ctx context.Context, header http.Header) {
+	if trace, ok := ctx.Value(traceKey{}).(string); ok {
+		header.Set(traceHeader, trace)
+	}
+}
+
+func (tr *testTracer) Extract(ctx context.Context, header http.Header) context.Context {
+	if trace := header.Get(traceHeader); trace != "" {
+		return context.WithValue(ctx, traceKey{}, trace)
+	}
+
+	return ctx
+}
+
+func TestWithTracer(t *testing.T) {
+	g := newTestGateway(t)
+	var traces []string
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		traces = append(traces, r.Header.Get(traceHeader))
+		writeJSON(w, http.StatusCreated, PushAsyncResponse{
+			ResponseCode:             "INS-0",
+			ConversationID:           "conv-1",
+			ThirdPartyConversationID: "tp-1",
+		})
+	}
+
+	tracer := &testTracer{}
+	c := g.client(WithTracer(tracer), WithCallbackHandler(PushCallbackContextFunc(func(ctx context.Context, request PushCallbackRequest) (PushCallbackResponse, error) {
+		return PushCallbackResponse{ResponseCode: "INS-0"}, nil
+	})))
+
+	ctx := context.WithValue(context.Background(), traceKey{}, "checkout")
+	if _, err := c.PushAsync(ctx, testRequest("tp-1").PushRequest()); err != nil {
+		t.Fatalf("PushAsync() error = %v", err)
+	}
+
+	r := newCallbackRequest(context.Background(), testCallbackBody)
+	r.Header.Set(traceHeader, "gateway")
+	c.CallbackServeHTTP(httptest.NewRecorder(), r)
+
+	if want := []string{"mpesa ussd push"}; !reflect.DeepEqual(traces, want) {
+		t.Errorf("propagated traces = %v, want %v", traces, want)
+	}
+
+	want := []struct {
+		name  string
+		attrs map[string]string
+	}{
+		{"mpesa ussd push", map[string]string{
+			"parent":                     "checkout",
+			AttrMarket:                   "TZN",
+			AttrPlatform:                 "sandbox",
+			AttrServiceProviderCode:      g.config().ServiceProvideCode,
+			AttrRequestType:              "ussd push",
+			AttrConversationID:           "conv-1",
+			AttrThirdPartyConversationID: "tp-1",
+			AttrResponseCode:             "INS-0",
+		}},
+		{"mpesa get session id", nil},
+		{"mpesa push callback", map[string]string{
+			"parent":                     "gateway",
+			AttrMarket:                   "TZN",
+			AttrPlatform:                 "sandbox",
+			AttrServiceProviderCode:      g.config().ServiceProvideCode,
+			AttrRequestType:              "mpesa push callback",
+			AttrConversationID:           "conv-1",
+			AttrThirdPartyConversationID: "tp-1",
+			AttrResponseCode:             "INS-0",
+		}},
+	}
+	if len(tracer.spans) != len(want) {
+		t.Fatalf("spans = %d, want %d", len(tracer.spans), len(want))
+	}
+	for i, span := range tracer.spans {
+		if span.name != want[i].name || !span.ended || span.err != nil {
+			t.Errorf("span %d = %q ended %v error %v", i, span.name, span.ended, span.err)
+		}
+		if want[i].attrs != nil && !reflect.DeepEqual(span.attrs, want[i].attrs) {
+			t.Errorf("span %q attributes = %v, want %v", span.name, span.attrs, want[i].attrs)
+		}
+	}
+}
+
+func TestWithTracerMoreOperations(t *testing.T) {
+	g := newTestGateway(t)
+	var traces []string
+	for _, path := range []string{"b2bPayment/", "directDebitCancel/", "queryBeneficiaryName/"} {
+		g.handlers[path] = func(w http.ResponseWriter, r *http.Request) {
+			traces = append(traces, r.Header.Get(traceHeader))
+			writeJSON(w, http.StatusOK, map[string]string{"output_ResponseCode": "INS-0"})
+		}
+	}
+
+	tracer := &testTracer{}
+	c := g.client(WithTracer(tracer))
+	ctx := context.Background()
+
+	if _, err := c.B2BPayment(ctx, Request{ThirdPartyID: "tp-1", Reference: "ref", Amount: MustParseAmount("1000"), ReceiverPartyCode: "000001"}); err != nil {
+		t.Fatalf("B2BPayment() error = %v", err)
+	}
+	if _, err := c.CancelDirectDebit(ctx, DirectDebitCancelRequest{AgreementID: "agreement", ThirdPartyID: "tp-1"}); err != nil {
+		t.Fatalf("CancelDirectDebit() error = %v", err)
+	}
+	if _, err := c.QueryBeneficiaryName(ctx, "255754000000"); err != nil {
+		t.Fatalf("QueryBeneficiaryName() error = %v", err)
+	}
+
+	operations := []string{"mpesa b2b payment", "mpesa direct debit cancellation", "mpesa query beneficiary name"}
+	if !reflect.DeepEqual(traces, operations) {
+		t.Errorf("propagated traces = %v, want %v", traces, operations)
+	}
+
+	want := []string{operations[0], "mpesa get session id", operations[1], operations[2]}
+	if len(tracer.spans) != len(want) {
+		t.Fatalf("spans = %d, want %d", len(tracer.spans), len(want))
+	}
+	for i, span := range tracer.spans {
+		if span.name != want[i] || !span.ended || span.err != nil {
+			t.Errorf("span %d = %q ended %v error %v, want %q", i, span.name, span.ended, span.err, want[i])
+		}
+		if i != 1 && span.attrs[AttrResponseCode] != "INS-0" {
+			t.Errorf("span %q response code = %q, want INS-0", span.name, span.attrs[AttrResponseCode])
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package mpesa
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWithTLSConfig(t *testing.T) {
+	g := newTestGateway(t)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(g.Certificate())
+	c, err := NewClient(g.config(), nil, WithDebugMode(false), WithTLSConfig(&tls.Config{RootCAs: pool}))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	if _, err := c.SessionID(context.Background()); err != nil {
+		t.Fatalf("SessionID() error = %v", err)
+	}
+	if c.tlsConfig.MinVersion != tls.VersionTLS12 {
+		t.Errorf("MinVersion = %x, want TLS 1.2", c.tlsConfig.MinVersion)
+	}
+
+	untrusted, _ := NewClient(g.config(), nil, WithDebugMode(false), WithTLSConfig(&tls.Config{}))
+	if _, err := untrusted.SessionID(context.Background()); err == nil {
+		t.Error("SessionID() error = nil without the gateway CA")
+	}
+}
+
+func TestWithProxy(t *testing.T) {
+	g := newTestGateway(t)
+	var proxied int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host == "gateway.invalid" {
+			atomic.AddInt32(&proxied, 1)
+		}
+		g.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(proxy.Close)
+
+	conf := g.config()
+	conf.BasePath = ""
+	c, err := NewClient(conf, nil, WithDebugMode(false),
+		WithBaseURL("http://gateway.invalid/sandbox/ipg/v2/vodacomTZN/"), WithProxy(proxy.URL))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	if _, err := c.SessionID(context.Background()); err != nil {
+		t.Fatalf("SessionID() error = %v", err)
+	}
+	if got := atomic.LoadInt32(&proxied); got != 1 {
+		t.Errorf("proxied requests = %d, want 1", got)
+	}
+}
+
+func TestWithProxyRejectsInvalidURLs(t *testing.T) {
+	g := newTestGateway(t)
+
+	for _, rawURL := range []string{"proxy.example.com:3128", "ftp://proxy.example.com/", "http://", "http://%zz"} {
+		t.Run(rawURL, func(t *testing.T) {
+			_, err := NewClient(g.config(), nil, WithDebugMode(false), WithProxy(rawURL))
+
+			var confErr *ConfigError
+			if !errors.As(err, &confErr) || !strings.Contains(confErr.Error(), "proxy URL") {
+				t.Errorf("NewClient() error = %v, want a proxy URL *ConfigError", err)
+			}
+		})
+	}
+}
+
+func TestWithProxyNeedsHTTPTransport(t *testing.T) {
+	g := newTestGateway(t)
+	rt := &recordingTransport{next: g.Client().Transport}
+
+	_, err := NewClient(g.config(), nil, WithRoundTripper(rt), WithProxy("http://proxy.example.com:3128"))
+	var confErr *ConfigError
+	if !errors.As(err, &confErr) {
+		t.Errorf("NewClient() error = %v, want a *ConfigError", err)
+	}
+}
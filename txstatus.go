@@ -0,0 +1,163 @@
+package mpesa
+
+import (
+	"encoding"
+	"strings"
+)
+
+var (
+	_ encoding.TextMarshaler   = TransactionStatus(0)
+	_ encoding.TextUnmarshaler = (*TransactionStatus)(nil)
+)
+
+const (
+	StatusUnknown TransactionStatus = iota
+	StatusPending
+	StatusCompleted
+	StatusFailed
+	StatusCancelled
+	StatusExpired
+	StatusReversed
+)
+
+// TransactionStatus is the state of a transaction as derived from the response
+// codes and the status fields of the gateway by DeriveTransactionStatus. A
+// state that can not be told for sure is StatusUnknown.
+type TransactionStatus int
+
+// ParseTransactionStatus returns the status named by s, a status field of the
+// gateway such as output_ResponseTransactionStatus. The match is
+// case-insensitive, names the package does not know about are StatusUnknown.
+func ParseTransactionStatus(s string) TransactionStatus {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "pending", "processing", "in progress", "initiated":
+		return StatusPending
+	case "completed", "complete", "successful", "success":
+		return StatusCompleted
+	case "failed", "failure":
+		return StatusFailed
+	case "cancelled", "canceled":
+		return StatusCancelled
+	case "expired":
+		return StatusExpired
+	case "reversed":
+		return StatusReversed
+	default:
+		return StatusUnknown
+	}
+}
+
+// DeriveTransactionStatus returns the status of a transaction from the code
+// of its result and its status field, either of which may be empty.
+//
+// Without a status field, INS-0 is StatusCompleted, a cancellation by the
+// customer (INS-5) StatusCancelled and the other codes StatusFailed, except
+// the codes leaving the outcome open: an internal error, a timeout, an unknown
+// status or a duplicate (INS-1, INS-9, INS-23 and INS-10) are StatusUnknown.
+// A status field contradicting the code, e.g. Completed with a failure code,
+// is StatusUnknown too.
+func DeriveTransactionStatus(code ResponseCode, status string) TransactionStatus {
+	parsed := ParseTransactionStatus(status)
+	switch {
+	case code == "":
+		return parsed
+	case strings.TrimSpace(status) == "":
+		return codeStatus(code)
+	case code.IsSuccess() && !parsed.isFailure():
+		return parsed
+	case !code.IsSuccess() && parsed.isFailure():
+		return parsed
+	default:
+		return StatusUnknown
+	}
+}
+
+// codeStatus returns the status of a transaction whose result is code.
+func codeStatus(code ResponseCode) TransactionStatus {
+	switch code {
+	case SUCCESS_CODE:
+		return StatusCompleted
+	case "INS-5":
+		return StatusCancelled
+	case "INS-1", "INS-9", "INS-10", "INS-23":
+		return StatusUnknown
+	default:
+		return StatusFailed
+	}
+}
+
+// IsTerminal reports whether the status is final: completed, failed,
+// cancelled, expired or reversed.
+func (s TransactionStatus) IsTerminal() bool {
+	switch s {
+	case StatusCompleted, StatusFailed, StatusCancelled, StatusExpired, StatusReversed:
+		return true
+	default:
+		return false
+	}
+}
+
+// isFailure reports whether the status means the transaction was not carried
+// out.
+func (s TransactionStatus) isFailure() bool {
+	switch s {
+	case StatusFailed, StatusCancelled, StatusExpired:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s TransactionStatus) String() string {
+	switch s {
+	case StatusPending:
+		return "Pending"
+	case StatusCompleted:
+		return "Completed"
+	case StatusFailed:
+		return "Failed"
+	case StatusCancelled:
+		return "Cancelled"
+	case StatusExpired:
+		return "Expired"
+	case StatusReversed:
+		return "Reversed"
+	default:
+		return "Unknown"
+	}
+}
+
+func (s TransactionStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *TransactionStatus) UnmarshalText(text []byte) error {
+	*s = ParseTransactionStatus(string(text))
+
+	return nil
+}
+
+// Status returns the status of the queried transaction. A failed query, e.g.
+// an unknown transaction, or a response without a status is StatusUnknown.
+func (r QueryTxResponse) Status() TransactionStatus {
+	if !r.Code().IsSuccess() {
+		return StatusUnknown
+	}
+
+	return ParseTransactionStatus(r.ResponseTransactionStatus)
+}
+
+// Status returns the status of the push, see DeriveTransactionStatus.
+func (r PushCallbackRequest) Status() TransactionStatus {
+	return DeriveTransactionStatus(r.Code(), "")
+}
+
+// Status returns the status of the disbursement, see DeriveTransactionStatus.
+func (r DisburseResponse) Status() TransactionStatus {
+	return DeriveTransactionStatus(r.Code(), "")
+}
+
+// Status returns the status of the disbursement, see DeriveTransactionStatus.
+func (r DisburseCallbackRequest) Status() TransactionStatus {
+	return DeriveTransactionStatus(r.Code(), r.TransactionStatus)
+}
@@ -0,0 +1,76 @@
+package mpesa
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDeriveTransactionStatus(t *testing.T) {
+	tests := []struct {
+		code   ResponseCode
+		status string
+		want   TransactionStatus
+	}{
+		{"INS-0", "", StatusCompleted},
+		{"INS-0", "Completed", StatusCompleted},
+		{"INS-0", "pending", StatusPending},
+		{"INS-0", "Reversed", StatusReversed},
+		{"INS-0", "Failed", StatusUnknown},
+		{"INS-0", "Settling", StatusUnknown},
+		{"INS-5", "", StatusCancelled},
+		{"INS-6", "", StatusFailed},
+		{"INS-2006", "Failed", StatusFailed},
+		{"INS-2006", "Completed", StatusUnknown},
+		{"INS-1", "", StatusUnknown},
+		{"INS-9", "", StatusUnknown},
+		{"INS-10", "", StatusUnknown},
+		{"", "Expired", StatusExpired},
+		{"", "", StatusUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := DeriveTransactionStatus(tt.code, tt.status); got != tt.want {
+			t.Errorf("DeriveTransactionStatus(%q, %q) = %v, want %v", tt.code, tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestTransactionStatusMethods(t *testing.T) {
+	queries := []struct {
+		response QueryTxResponse
+		want     TransactionStatus
+	}{
+		{QueryTxResponse{ResponseCode: "INS-0", ResponseTransactionStatus: "Completed"}, StatusCompleted},
+		{QueryTxResponse{ResponseCode: "INS-0", ResponseTransactionStatus: "Pending"}, StatusPending},
+		{QueryTxResponse{ResponseCode: "INS-0"}, StatusUnknown},
+		// the code of a query is the outcome of the query, not of the transaction
+		{QueryTxResponse{ResponseCode: "INS-18", ResponseTransactionStatus: "Failed"}, StatusUnknown},
+	}
+	for _, tt := range queries {
+		if got := tt.response.Status(); got != tt.want {
+			t.Errorf("%+v.Status() = %v, want %v", tt.response, got, tt.want)
+		}
+	}
+
+	if got := (PushCallbackRequest{ResultCode: "INS-5"}).Status(); got != StatusCancelled {
+		t.Errorf("PushCallbackRequest.Status() = %v, want Cancelled", got)
+	}
+	if got := (DisburseResponse{ResponseCode: "INS-9"}).Status(); got != StatusUnknown {
+		t.Errorf("DisburseResponse.Status() = %v, want Unknown", got)
+	}
+	if got := (DisburseCallbackRequest{ResultCode: "INS-0", TransactionStatus: "Completed"}).Status(); got != StatusCompleted {
+		t.Errorf("DisburseCallbackRequest.Status() = %v, want Completed", got)
+	}
+
+	for s := StatusUnknown; s <= StatusReversed; s++ {
+		if got := s.IsTerminal(); got != (s != StatusUnknown && s != StatusPending) {
+			t.Errorf("%v.IsTerminal() = %v", s, got)
+		}
+
+		b, _ := json.Marshal(s)
+		var decoded TransactionStatus
+		if err := json.Unmarshal(b, &decoded); err != nil || decoded != s {
+			t.Errorf("round trip of %v = %v, %v", s, decoded, err)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package mpesa
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// MaxThirdPartyIDLength is the longest input_ThirdPartyConversationID
+	// accepted by the gateway.
+	MaxThirdPartyIDLength = 40
+
+	// MaxDescriptionLength is the longest description of the purchased or the
+	// paid items accepted by the gateway.
+	MaxDescriptionLength = 256
+)
+
+// ValidationError is returned when a request is rejected before anything is
+// sent. Fields lists every invalid field, errors.As matches the first one
+// with a *FieldError target and Unwrap returns them all.
+type ValidationError struct {
+	Operation string
+	Fields    []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	problems := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		problems[i] = fmt.Sprintf("%s %s", field.Field, field.Reason)
+	}
+
+	return fmt.Sprintf("invalid %s request: %s", e.Operation, strings.Join(problems, "; "))
+}
+
+// Unwrap returns the *FieldError of every invalid field.
+func (e *ValidationError) Unwrap() []error {
+	return fieldErrors(e.Fields)
+}
+
+// As sets a *FieldError target to the first invalid field.
+func (e *ValidationError) As(target interface{}) bool {
+	fieldErr, ok := target.(**FieldError)
+	if !ok || len(e.Fields) == 0 {
+		return false
+	}
+	*fieldErr = e.Fields[0]
+
+	return true
+}
+
+// Validate checks request against the limits of the gateway in market: the
+// ThirdPartyID and the MSISDN are required, the MSISDN must be a mobile number
+// of market in the format returned by NormalizeMSISDN, the amount must be
+// positive, the currency, when set, must be accepted in market, the
+// ServiceProviderCode, when set, must be 4 to 12 digits and the fields must fit
+// the length and the characters allowed by the gateway.
+// It returns a *ValidationError listing every invalid field.
+//
+// PushAsync and BuildPayload validate the request after generating the
+// missing ThirdPartyID.
+func (request PushRequest) Validate(market Market) error {
+	return request.validate(market, true)
+}
+
+// validate checks request, the MSISDN against the numbering plan of market
+// when plan is true.
+func (request PushRequest) validate(market Market, plan bool) error {
+	var v validation
+	v.checkTransfer(request.ThirdPartyID, request.Reference, request.Description, request.Amount)
+	v.checkMSISDN(request.MSISDN, market)
+	if plan {
+		v.checkMobile(request.MSISDN, market)
+	}
+	v.checkCurrency(request.Currency, market)
+	v.checkProviderCode(request.ServiceProviderCode)
+
+	return v.err(PushOperation)
+}
+
+// Validate checks request like PushRequest.Validate does.
+//
+// Disburse and BuildPayload validate the request after generating the missing
+// ThirdPartyID.
+func (request DisburseRequest) Validate(market Market) error {
+	return request.validate(market, true)
+}
+
+// validate checks request like PushRequest.validate does.
+func (request DisburseRequest) validate(market Market, plan bool) error {
+	var v validation
+	v.checkTransfer(request.ThirdPartyID, request.Reference, request.Description, request.Amount)
+	v.checkMSISDN(request.MSISDN, market)
+	if plan {
+		v.checkMobile(request.MSISDN, market)
+	}
+	v.checkCurrency(request.Currency, market)
+	v.checkProviderCode(request.ServiceProviderCode)
+
+	return v.err(DisburseOperation)
+}
+
+// Validate checks request against the limits of the gateway for op in market.
+// A push or a disbursement is checked by PushRequest.Validate or
+// DisburseRequest.Validate. A B2B payment is checked the same way, except
+// that it needs a numeric ReceiverPartyCode instead of an MSISDN.
+//
+// B2BPayment and BuildPayload validate the request after generating the
+// missing ThirdPartyID.
+func (request Request) Validate(op Operation, market Market) error {
+	switch op {
+	case PushOperation:
+		return request.PushRequest().Validate(market)
+	case DisburseOperation:
+		return request.DisburseRequest().Validate(market)
+	}
+
+	var v validation
+	v.checkTransfer(request.ThirdPartyID, request.Reference, request.Description, request.Amount)
+	if op == B2BOperation && !isNumeric(request.ReceiverPartyCode) {
+		v.add("ReceiverPartyCode", RuleFormat, "must be numeric, got %q", request.ReceiverPartyCode)
+	}
+	v.checkCurrency(request.Currency, market)
+	v.checkProviderCode(request.ServiceProviderCode)
+
+	return v.err(op)
+}
+
+// validation collects the invalid fields of a request.
+type validation struct {
+	fields []*FieldError
+}
+
+func (v *validation) add(field, rule, format string, args ...interface{}) {
+	v.fields = append(v.fields, &FieldError{Field: field, Rule: rule, Reason: fmt.Sprintf(format, args...)})
+}
+
+// checkTransfer checks the fields shared by the pushes, the disbursements and
+// the B2B payments.
+func (v *validation) checkTransfer(thirdPartyID, reference, description string, amount Amount) {
+	if thirdPartyID == "" {
+		v.add("ThirdPartyID", RuleRequired, "is required")
+	}
+	v.checkThirdPartyID(thirdPartyID)
+	v.checkReference(reference)
+	v.checkDescription(description)
+	v.checkAmount(amount)
+}
+
+// checkThirdPartyID checks the length of thirdPartyID.
+func (v *validation) checkThirdPartyID(thirdPartyID string) {
+	if n := len([]rune(thirdPartyID)); n > MaxThirdPartyIDLength {
+		v.add("ThirdPartyID", RuleLength, "must be at most %d characters, got %d", MaxThirdPartyIDLength, n)
+	}
+}
+
+// checkReference checks reference with ValidateReference when it is set.
+func (v *validation) checkReference(reference string) {
+	if reference != "" {
+		if err := ValidateReference(reference); err != nil {
+			v.fields = append(v.fields, err.(*FieldError))
+		}
+	}
+}
+
+// checkDescription checks the length of description.
+func (v *validation) checkDescription(description string) {
+	if n := len([]rune(description)); n > MaxDescriptionLength {
+		v.add("Description", RuleLength, "must be at most %d characters, got %d", MaxDescriptionLength, n)
+	}
+}
+
+// checkAmount checks that amount is positive.
+func (v *validation) checkAmount(amount Amount) {
+	if amount.MinorUnits() <= 0 {
+		v.add("Amount", RuleRange, "must be positive, got %s", amount)
+	}
+}
+
+// checkMSISDN checks the MSISDN of a customer of market is an international
+// number, checkMobile checks it against the numbering plan of market.
+func (v *validation) checkMSISDN(msisdn string, market Market) {
+	switch {
+	case msisdn == "":
+		v.add("MSISDN", RuleRequired, "is required")
+	case !isNumeric(msisdn) || len(msisdn) < 8 || len(msisdn) > 15:
+		v.add("MSISDN", RuleFormat, "must be 8 to 15 digits, got %q", msisdn)
+	case !strings.HasPrefix(msisdn, market.DialingPrefix()):
+		v.add("MSISDN", RuleFormat, "must start with the dialing prefix %s of %s", market.DialingPrefix(), market.Description())
+	}
+}
+
+// checkProviderCode checks that code, when set, is a short code like
+// Config.ServiceProvideCode.
+func (v *validation) checkProviderCode(code string) {
+	if n := len(code); code != "" && (!isNumeric(code) || n < 4 || n > 12) {
+		v.add("ServiceProviderCode", RuleFormat, "must be 4 to 12 digits, got %q", code)
+	}
+}
+
+// checkCurrency checks that currency, when set, is accepted in market.
+func (v *validation) checkCurrency(currency string, market Market) {
+	if currency == "" {
+		return
+	}
+
+	for _, accepted := range market.Currencies() {
+		if currency == accepted {
+			return
+		}
+	}
+	v.add("Currency", RuleUnsupported, "must be one of %s in %s, got %q", strings.Join(market.Currencies(), ", "), market.Description(), currency)
+}
+
+// has reports whether field was found invalid.
+func (v *validation) has(field string) bool {
+	for _, f := range v.fields {
+		if f.Field == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+// err returns the *ValidationError listing the invalid fields, nil when there
+// are none.
+func (v *validation) err(op Operation) error {
+	return v.errFor(op.String())
+}
+
+// errFor is err for the operation named operation.
+func (v *validation) errFor(operation string) error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+
+	return &ValidationError{Operation: operation, Fields: v.fields}
+}
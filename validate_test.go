@@ -0,0 +1,89 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRequestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		op     Operation
+		modify func(r *Request)
+		want   []string
+	}{
+		{"valid push", PushOperation, func(r *Request) {}, nil},
+		{"valid b2b without msisdn", B2BOperation, func(r *Request) { r.MSISDN = "" }, nil},
+		{"missing msisdn", PushOperation, func(r *Request) { r.MSISDN = "" }, []string{"MSISDN"}},
+		{"msisdn of another market", DisburseOperation, func(r *Request) { r.MSISDN = "254707161122" }, []string{"MSISDN"}},
+		{"short msisdn", DisburseOperation, func(r *Request) { r.MSISDN = "25575400012" }, []string{"MSISDN"}},
+		{"non numeric msisdn", PushOperation, func(r *Request) { r.MSISDN = "+255754000123" }, []string{"MSISDN"}},
+		{"zero amount", PushOperation, func(r *Request) { r.Amount = Amount{} }, []string{"Amount"}},
+		{"negative amount", PushOperation, func(r *Request) { r.Amount = FromMinorUnits(-1) }, []string{"Amount"}},
+		{"long description", DisburseOperation, func(r *Request) { r.Description = strings.Repeat("a", 257) }, []string{"Description"}},
+		{"long third party id", PushOperation, func(r *Request) { r.ThirdPartyID = strings.Repeat("a", 41) }, []string{"ThirdPartyID"}},
+		{"non numeric receiver", B2BOperation, func(r *Request) { r.ReceiverPartyCode = "ORG001" }, []string{"ReceiverPartyCode"}},
+		{
+			"every problem", PushOperation,
+			func(r *Request) { *r = Request{Reference: "INV-1", Description: strings.Repeat("a", 300)} },
+			[]string{"ThirdPartyID", "Reference", "Description", "Amount", "MSISDN"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := testRequest("tp-1")
+			tt.modify(&request)
+
+			err := request.Validate(tt.op, TanzaniaMarket)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Validate() error = %v, want a *ValidationError", err)
+			}
+			var fields []string
+			for _, field := range validationErr.Fields {
+				fields = append(fields, field.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.want) {
+				t.Errorf("invalid fields = %v, want %v", fields, tt.want)
+			}
+
+			var fieldErr *FieldError
+			if !errors.As(err, &fieldErr) || fieldErr.Field != tt.want[0] {
+				t.Errorf("errors.As(*FieldError) = %v, want the %s field", fieldErr, tt.want[0])
+			}
+		})
+	}
+}
+
+func TestDisburseRejectsInvalidRequest(t *testing.T) {
+	g := newTestGateway(t)
+
+	_, err := g.client().Disburse(context.Background(), DisburseRequest{ThirdPartyID: "tp-1", Amount: MustParseAmount("-5")})
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Operation != "disbursement" || len(validationErr.Fields) != 2 {
+		t.Fatalf("Disburse() error = %v, want the amount and the msisdn rejected", err)
+	}
+	if errs := validationErr.Unwrap(); len(errs) != 2 {
+		t.Errorf("Unwrap() = %v, want both fields", errs)
+	}
+	for _, field := range validationErr.Fields {
+		if field.Rule == "" {
+			t.Errorf("field %s has no rule", field.Field)
+		}
+	}
+	if g.sessions != 0 {
+		t.Errorf("sessions = %d, want nothing sent", g.sessions)
+	}
+}
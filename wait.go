@@ -0,0 +1,121 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultCallbackRegistryTTL is how long the callback registry keeps an entry
+// when WithCallbackRegistry is given no TTL.
+const DefaultCallbackRegistryTTL = 10 * time.Minute
+
+// ErrCallbackRegistryDisabled is returned by WaitForCallback when the Client was
+// created without WithCallbackRegistry.
+var ErrCallbackRegistryDisabled = errors.New("mpesa: callback registry is not enabled")
+
+// callbackRegistry matches push callbacks with the PushAsync calls they are for,
+// keyed on the third party conversation id.
+type callbackRegistry struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	clock    Clock
+	entries  map[string]*pendingCallback
+	expiries expiryQueue
+}
+
+type pendingCallback struct {
+	done    chan struct{}
+	request PushCallbackRequest
+	expires time.Time
+}
+
+func newCallbackRegistry(ttl time.Duration, clk Clock) *callbackRegistry {
+	return &callbackRegistry{
+		ttl:     ttl,
+		clock:   clk,
+		entries: make(map[string]*pendingCallback),
+	}
+}
+
+// entry returns the entry of id, adding it when missing. It must be called
+// with mu held.
+func (r *callbackRegistry) entry(id string) *pendingCallback {
+	now := r.clock.Now()
+	r.expiries.popExpired(now, func(key string) {
+		if e, ok := r.entries[key]; ok && !now.Before(e.expires) {
+			delete(r.entries, key)
+		}
+	})
+
+	e, ok := r.entries[id]
+	if !ok {
+		e = &pendingCallback{done: make(chan struct{}), expires: now.Add(r.ttl)}
+		r.entries[id] = e
+		r.expiries.add(id, e.expires)
+	}
+
+	return e
+}
+
+// register records a PushAsync call whose callback may be waited for.
+func (r *callbackRegistry) register(id string) {
+	if r == nil || id == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entry(id)
+}
+
+// deliver hands request to whoever waits for it. A callback that arrives
+// before anyone waits is kept until the entry expires.
+func (r *callbackRegistry) deliver(request PushCallbackRequest) {
+	if r == nil || request.ThirdPartyConversationID == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e := r.entry(request.ThirdPartyConversationID)
+	select {
+	case <-e.done:
+		// a re-delivery, keep the first callback
+	default:
+		e.request = request
+		close(e.done)
+	}
+}
+
+// WaitForCallback blocks until the push callback for the PushAsync request
+// with ThirdPartyID thirdPartyConversationID arrives through CallbackServeHTTP,
+// or ctx is done. A callback that arrived before WaitForCallback was called is
+// returned straight away as long as it has not expired. It requires
+// WithCallbackRegistry.
+func (c *Client) WaitForCallback(ctx context.Context, thirdPartyConversationID string) (PushCallbackRequest, error) {
+	r := c.callbackRegistry
+	if r == nil {
+		return PushCallbackRequest{}, ErrCallbackRegistryDisabled
+	}
+
+	r.mu.Lock()
+	e := r.entry(thirdPartyConversationID)
+	r.mu.Unlock()
+
+	select {
+	case <-e.done:
+		r.mu.Lock()
+		if r.entries[thirdPartyConversationID] == e {
+			delete(r.entries, thirdPartyConversationID)
+		}
+		r.mu.Unlock()
+
+		return e.request, nil
+
+	case <-ctx.Done():
+		return PushCallbackRequest{}, ctx.Err()
+	}
+}
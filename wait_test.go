@@ -0,0 +1,88 @@
+package mpesa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func deliverCallback(t *testing.T, c *Client, thirdPartyID string) {
+	t.Helper()
+
+	body := `{"input_OriginalConversationID": "conv-1", "input_TransactionID": "tx-1", "input_ResultCode": "INS-0", "input_ThirdPartyConversationID": "` + thirdPartyID + `"}`
+	rec := httptest.NewRecorder()
+	c.CallbackServeHTTP(rec, newCallbackRequest(context.Background(), body))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("callback status = %d, want 200", rec.Code)
+	}
+}
+
+func TestWaitForCallback(t *testing.T) {
+	g := newTestGateway(t)
+	g.handlers["c2bPayment/singleStage/"] = func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusCreated, PushAsyncResponse{ResponseCode: "INS-0"})
+	}
+	c := g.client(WithCallbackRegistry(time.Minute))
+
+	ctx := context.Background()
+	if _, err := c.PushAsync(ctx, PushRequest{Amount: MustParseAmount("1000"), MSISDN: "255765123456", ThirdPartyID: "checkout-1"}); err != nil {
+		t.Fatalf("PushAsync() error = %v", err)
+	}
+
+	got := make(chan PushCallbackRequest)
+	go func() {
+		request, err := c.WaitForCallback(ctx, "checkout-1")
+		if err != nil {
+			t.Errorf("WaitForCallback() error = %v", err)
+		}
+		got <- request
+	}()
+
+	deliverCallback(t, c, "checkout-1")
+	if request := <-got; request.TransactionID != "tx-1" || request.ResultCode != "INS-0" {
+		t.Errorf("WaitForCallback() = %+v", request)
+	}
+}
+
+func TestWaitForCallbackArrivedEarly(t *testing.T) {
+	c := newTestGateway(t).client(WithCallbackRegistry(time.Minute))
+
+	deliverCallback(t, c, "checkout-2")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	request, err := c.WaitForCallback(ctx, "checkout-2")
+	if err != nil || request.TransactionID != "tx-1" {
+		t.Errorf("WaitForCallback() = %+v, %v, want the retained callback", request, err)
+	}
+}
+
+func TestWaitForCallbackExpiry(t *testing.T) {
+	clk := newFakeClock()
+	c := newTestGateway(t).client(WithCallbackRegistry(time.Minute))
+	c.callbackRegistry.clock = clk
+
+	deliverCallback(t, c, "abandoned")
+	clk.Advance(time.Minute)
+	c.callbackRegistry.register("other")
+
+	if n, queued := len(c.callbackRegistry.entries), len(c.callbackRegistry.expiries); n != 1 || queued != 1 {
+		t.Errorf("entries = %d, queued expiries = %d, want the expired one dropped", n, queued)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.WaitForCallback(ctx, "abandoned"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitForCallback() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestWaitForCallbackDisabled(t *testing.T) {
+	c := newTestGateway(t).client()
+	if _, err := c.WaitForCallback(context.Background(), "x"); !errors.Is(err, ErrCallbackRegistryDisabled) {
+		t.Errorf("WaitForCallback() error = %v, want ErrCallbackRegistryDisabled", err)
+	}
+}